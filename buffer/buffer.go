@@ -1,27 +1,32 @@
 package buffer
 
 import (
+	"sync"
+
 	"github.com/adieumonks/simple-db/file"
 	"github.com/adieumonks/simple-db/log"
 )
 
 type Buffer struct {
+	mu       sync.Mutex
 	fm       *file.FileManager
 	lm       *log.LogManager
 	contents *file.Page
 	block    file.BlockID
 	pins     int32
-	txnum    int32
-	lsn      int32
+	// the transactions that modified the contents since they were written;
+	// transactions locking different records of the block modify it together
+	modifiedBy map[int64]bool
+	lsn        int32
 }
 
 func NewBuffer(fm *file.FileManager, lm *log.LogManager) *Buffer {
 	return &Buffer{
-		fm:       fm,
-		lm:       lm,
-		contents: file.NewPage(fm.BlockSize()),
-		txnum:    -1,
-		lsn:      -1,
+		fm:         fm,
+		lm:         lm,
+		contents:   file.NewPage(fm.BlockSize()),
+		modifiedBy: make(map[int64]bool),
+		lsn:        -1,
 	}
 }
 
@@ -29,12 +34,24 @@ func (b *Buffer) Contents() *file.Page {
 	return b.contents
 }
 
+// Lock latches the buffer contents, so that a change to them is applied,
+// copied or written out as a whole.
+func (b *Buffer) Lock() {
+	b.mu.Lock()
+}
+
+func (b *Buffer) Unlock() {
+	b.mu.Unlock()
+}
+
 func (b *Buffer) Block() file.BlockID {
 	return b.block
 }
 
-func (b *Buffer) SetModified(txnum, lsn int32) {
-	b.txnum = txnum
+// SetModified records that txnum changed the contents.
+// The caller latches the buffer.
+func (b *Buffer) SetModified(txnum int64, lsn int32) {
+	b.modifiedBy[txnum] = true
 	if lsn >= 0 {
 		b.lsn = lsn
 	}
@@ -44,12 +61,22 @@ func (b *Buffer) IsPinned() bool {
 	return b.pins > 0
 }
 
-func (b *Buffer) ModifyingTx() int32 {
-	return b.txnum
+// IsModifiedBy reports whether txnum changed the contents since they were
+// last written.
+func (b *Buffer) IsModifiedBy(txnum int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.modifiedBy[txnum]
 }
 
 func (b *Buffer) AssignToBlock(block file.BlockID) error {
-	b.Flush()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.flush(); err != nil {
+		return err
+	}
 	b.block = block
 	if err := b.fm.Read(block, b.contents); err != nil {
 		return err
@@ -59,16 +86,34 @@ func (b *Buffer) AssignToBlock(block file.BlockID) error {
 }
 
 func (b *Buffer) Flush() error {
-	if b.txnum >= 0 {
-		b.lm.Flush(b.lsn)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.flush()
+}
+
+func (b *Buffer) flush() error {
+	if len(b.modifiedBy) > 0 {
+		if err := b.lm.Flush(b.lsn); err != nil {
+			return err
+		}
 		if err := b.fm.Write(b.block, b.contents); err != nil {
 			return err
 		}
-		b.txnum = -1
+		clear(b.modifiedBy)
 	}
 	return nil
 }
 
+// discard leaves the buffer assigned to no block, dropping its changes.
+func (b *Buffer) discard() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.block = file.BlockID{}
+	clear(b.modifiedBy)
+}
+
 func (b *Buffer) Pin() {
 	b.pins++
 }
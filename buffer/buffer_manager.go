@@ -41,10 +41,26 @@ func (bm *BufferManager) Available() int32 {
 
 }
 
-func (bm *BufferManager) FlushAll(txnum int32) {
+func (bm *BufferManager) FlushAll(txnum int64) error {
 	for _, buffer := range bm.bufferPool {
-		if buffer.ModifyingTx() == txnum {
-			buffer.Flush()
+		if buffer.IsModifiedBy(txnum) {
+			if err := buffer.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Discard forgets the blocks of the file held by unpinned buffers, without
+// writing them, so that a file deleted and created again is read afresh.
+func (bm *BufferManager) Discard(filename string) {
+	bm.cond.L.Lock()
+	defer bm.cond.L.Unlock()
+
+	for _, buffer := range bm.bufferPool {
+		if !buffer.IsPinned() && buffer.Block().Filename() == filename {
+			buffer.discard()
 		}
 	}
 }
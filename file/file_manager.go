@@ -7,11 +7,18 @@ import (
 	"sync"
 )
 
+// FaultInjector is consulted before every block write so that tests can
+// simulate a crash by making the write, and all writes after it, fail.
+type FaultInjector interface {
+	BeforeWrite(block BlockID) error
+}
+
 type FileManager struct {
 	dbDirectory string
 	blockSize   int32
 	isNew       bool
 	openFiles   map[string]*os.File
+	faults      FaultInjector
 	mu          sync.Mutex
 }
 
@@ -65,6 +72,10 @@ func (fm *FileManager) Write(block BlockID, page *Page) error {
 	fm.mu.Lock()
 	defer fm.mu.Unlock()
 
+	if err := fm.beforeWrite(block); err != nil {
+		return err
+	}
+
 	f, err := fm.getFile(block.Filename())
 	if err != nil {
 		return fmt.Errorf("failed to get file: %w", err)
@@ -93,6 +104,9 @@ func (fm *FileManager) Append(filename string) (BlockID, error) {
 	}
 
 	block := NewBlockID(filename, newBlockNum)
+	if err := fm.beforeWrite(block); err != nil {
+		return BlockID{}, err
+	}
 	b := make([]byte, fm.blockSize)
 
 	f, err := fm.getFile(filename)
@@ -123,6 +137,24 @@ func (fm *FileManager) Length(filename string) (int32, error) {
 	return int32(length) / fm.blockSize, err
 }
 
+// Delete closes and removes the file, if it exists.
+func (fm *FileManager) Delete(filename string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	if f, ok := fm.openFiles[filename]; ok {
+		delete(fm.openFiles, filename)
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to close file: %w", err)
+		}
+	}
+	err := os.Remove(path.Join(fm.dbDirectory, filename))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+	return nil
+}
+
 func (fm *FileManager) IsNew() bool {
 	return fm.isNew
 }
@@ -131,6 +163,20 @@ func (fm *FileManager) BlockSize() int32 {
 	return fm.blockSize
 }
 
+func (fm *FileManager) SetFaultInjector(faults FaultInjector) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	fm.faults = faults
+}
+
+func (fm *FileManager) beforeWrite(block BlockID) error {
+	if fm.faults == nil {
+		return nil
+	}
+	return fm.faults.BeforeWrite(block)
+}
+
 func (fm *FileManager) getFile(filename string) (*os.File, error) {
 	if f, ok := fm.openFiles[filename]; ok {
 		return f, nil
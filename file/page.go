@@ -2,6 +2,8 @@ package file
 
 import (
 	"encoding/binary"
+	"math"
+	"slices"
 	"unicode/utf16"
 )
 
@@ -11,6 +13,7 @@ type Page struct {
 
 const (
 	Int32Bytes int32 = 4
+	Int64Bytes int32 = 8
 	utf16Size  int32 = 2
 )
 
@@ -26,6 +29,10 @@ func NewPageFromBytes(b []byte) *Page {
 	}
 }
 
+func (p *Page) Clone() *Page {
+	return NewPageFromBytes(slices.Clone(p.buffer))
+}
+
 func (p *Page) GetInt(offset int32) int32 {
 	data := p.buffer[offset : offset+Int32Bytes]
 	val := binary.LittleEndian.Uint32(data)
@@ -38,6 +45,26 @@ func (p *Page) SetInt(offset int32, n int32) {
 	copy(p.buffer[offset:offset+Int32Bytes], data)
 }
 
+func (p *Page) GetLong(offset int32) int64 {
+	data := p.buffer[offset : offset+Int64Bytes]
+	val := binary.LittleEndian.Uint64(data)
+	return int64(val)
+}
+
+func (p *Page) SetLong(offset int32, n int64) {
+	data := make([]byte, Int64Bytes)
+	binary.LittleEndian.PutUint64(data, uint64(n))
+	copy(p.buffer[offset:offset+Int64Bytes], data)
+}
+
+func (p *Page) GetDouble(offset int32) float64 {
+	return math.Float64frombits(uint64(p.GetLong(offset)))
+}
+
+func (p *Page) SetDouble(offset int32, f float64) {
+	p.SetLong(offset, int64(math.Float64bits(f)))
+}
+
 func (p *Page) GetBytes(offset int32) []byte {
 	length := p.GetInt(offset)
 	return p.buffer[offset+Int32Bytes : offset+Int32Bytes+length]
@@ -48,6 +75,16 @@ func (p *Page) SetBytes(offset int32, b []byte) {
 	copy(p.buffer[offset+Int32Bytes:offset+Int32Bytes+int32(len(b))], b)
 }
 
+// GetRawBytes returns a copy of the length bytes at offset, which, unlike
+// those of GetBytes, are not preceded by their length.
+func (p *Page) GetRawBytes(offset int32, length int32) []byte {
+	return slices.Clone(p.buffer[offset : offset+length])
+}
+
+func (p *Page) SetRawBytes(offset int32, b []byte) {
+	copy(p.buffer[offset:offset+int32(len(b))], b)
+}
+
 func (p *Page) GetString(offset int32) string {
 	length := p.GetInt(offset) / utf16Size
 
@@ -7,8 +7,12 @@ import (
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
 	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
 )
 
+// the name under which the range past the largest key is locked
+const supremum = "+inf"
+
 var _ Index = (*BTreeIndex)(nil)
 
 type BTreeIndex struct {
@@ -23,6 +27,9 @@ type BTreeIndex struct {
 func NewBTreeIndex(tx *tx.Transaction, indexName string, leafLayout *record.Layout) (*BTreeIndex, error) {
 	// deal with the leaves
 	leafTable := indexName + "leaf"
+	// the key locks keep others from inserting what a lookup reads
+	tx.UseKeyLocks(leafTable)
+	tx.UseKeyLocks(indexName + "dir")
 	leafTableSize, err := tx.Size(leafTable)
 	if err != nil {
 		return nil, err
@@ -66,16 +73,7 @@ func NewBTreeIndex(tx *tx.Transaction, indexName string, leafLayout *record.Layo
 		}
 
 		// insert initial directory entry
-		fieldType := dirSchema.Type("dataval")
-
-		var minVal *query.Constant
-
-		if fieldType == record.INTEGER {
-			minVal = query.NewConstantWithInt(math.MinInt32)
-		} else {
-			minVal = query.NewConstantWithString("")
-		}
-
+		minVal := minValue(dirSchema, "dataval")
 		if err := node.InsertDir(0, minVal, 0); err != nil {
 			return nil, err
 		}
@@ -92,7 +90,34 @@ func NewBTreeIndex(tx *tx.Transaction, indexName string, leafLayout *record.Layo
 	}, nil
 }
 
+// BeforeFirst positions the index before the first record having the
+// search key. The search key is locked, so that records having it can be
+// neither inserted nor deleted. If there are none, a serializable
+// transaction locks the range it would go in as well, by locking the next key.
 func (bi *BTreeIndex) BeforeFirst(searchkey *query.Constant) error {
+	if err := bi.lockKey(searchkey, concurrency.S); err != nil {
+		return err
+	}
+	if err := bi.moveTo(searchkey); err != nil {
+		return err
+	}
+	found, err := bi.leaf.Next()
+	if err != nil {
+		return err
+	}
+	if !found && bi.tx.IsolationLevel() == tx.SERIALIZABLE {
+		next, err := bi.nextKey(searchkey)
+		if err != nil {
+			return err
+		}
+		if err := bi.lockKey(next, concurrency.S); err != nil {
+			return err
+		}
+	}
+	return bi.moveTo(searchkey)
+}
+
+func (bi *BTreeIndex) moveTo(searchkey *query.Constant) error {
 	bi.Close()
 
 	root, err := NewBTreeDir(bi.tx, bi.rootBlock, bi.dirLayout)
@@ -122,8 +147,21 @@ func (bi *BTreeIndex) GetDataRID() (*record.RID, error) {
 	return bi.leaf.GetDataRID()
 }
 
+// Insert adds a record to the index. It waits for the transactions that
+// have locked the range the new key goes in, so their lookups see no phantoms.
 func (bi *BTreeIndex) Insert(dataval *query.Constant, dataRID *record.RID) error {
-	if err := bi.BeforeFirst(dataval); err != nil {
+	if err := bi.lockKey(dataval, concurrency.X); err != nil {
+		return err
+	}
+	next, err := bi.nextKey(dataval)
+	if err != nil {
+		return err
+	}
+	if err := bi.tx.AwaitKey(bi.leafTable, rangeName(next), concurrency.X); err != nil {
+		return err
+	}
+
+	if err := bi.moveTo(dataval); err != nil {
 		return err
 	}
 	e, err := bi.leaf.Insert(dataRID)
@@ -153,8 +191,22 @@ func (bi *BTreeIndex) Insert(dataval *query.Constant, dataRID *record.RID) error
 	return nil
 }
 
+// Delete removes a record from the index. Both the key and the next one
+// stay locked, since removing the last record having the key merges its
+// range into the next one.
 func (bi *BTreeIndex) Delete(dataval *query.Constant, dataRID *record.RID) error {
-	if err := bi.BeforeFirst(dataval); err != nil {
+	if err := bi.lockKey(dataval, concurrency.X); err != nil {
+		return err
+	}
+	next, err := bi.nextKey(dataval)
+	if err != nil {
+		return err
+	}
+	if err := bi.lockKey(next, concurrency.X); err != nil {
+		return err
+	}
+
+	if err := bi.moveTo(dataval); err != nil {
 		return err
 	}
 	if err := bi.leaf.Delete(dataRID); err != nil {
@@ -173,3 +225,115 @@ func (bi *BTreeIndex) Close() {
 func (bi *BTreeIndex) SearchCost(numBlocks int32, rpb int32) int32 {
 	return 1 + int32(math.Log(float64(numBlocks))/math.Log(float64(rpb)))
 }
+
+// lockKey locks the range of keys that ends at key,
+// or the range past the largest key if key is nil.
+func (bi *BTreeIndex) lockKey(key *query.Constant, mode concurrency.LockMode) error {
+	return bi.tx.LockKey(bi.leafTable, rangeName(key), mode)
+}
+
+// nextKey returns the smallest key in the index greater than key,
+// or nil if there is none.
+func (bi *BTreeIndex) nextKey(key *query.Constant) (*query.Constant, error) {
+	return bi.nextKeyInDir(*bi.rootBlock, key)
+}
+
+func (bi *BTreeIndex) nextKeyInDir(block file.BlockID, key *query.Constant) (*query.Constant, error) {
+	contents, err := NewBTPage(bi.tx, &block, bi.dirLayout)
+	if err != nil {
+		return nil, err
+	}
+	defer contents.Close()
+
+	level, err := contents.GetFlag()
+	if err != nil {
+		return nil, err
+	}
+	numRecords, err := contents.GetNumRecs()
+	if err != nil {
+		return nil, err
+	}
+	slot, err := contents.FindSlotBefore(key)
+	if err != nil {
+		return nil, err
+	}
+	// children are in key order, starting from the one that may hold key
+	for slot = max(slot, 0); slot < numRecords; slot++ {
+		childNum, err := contents.GetChildNum(slot)
+		if err != nil {
+			return nil, err
+		}
+		var next *query.Constant
+		if level == 0 {
+			next, err = bi.nextKeyInLeaf(file.NewBlockID(bi.leafTable, childNum), key)
+		} else {
+			next, err = bi.nextKeyInDir(file.NewBlockID(block.Filename(), childNum), key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if next != nil {
+			return next, nil
+		}
+	}
+	return nil, nil
+}
+
+// nextKeyInLeaf ignores overflow blocks, which only repeat the first key of the leaf.
+func (bi *BTreeIndex) nextKeyInLeaf(block file.BlockID, key *query.Constant) (*query.Constant, error) {
+	contents, err := NewBTPage(bi.tx, &block, bi.leafLayout)
+	if err != nil {
+		return nil, err
+	}
+	defer contents.Close()
+
+	numRecords, err := contents.GetNumRecs()
+	if err != nil {
+		return nil, err
+	}
+	for slot := int32(0); slot < numRecords; slot++ {
+		dataVal, err := contents.GetDataVal(slot)
+		if err != nil {
+			return nil, err
+		}
+		if dataVal.CompareTo(key) > 0 {
+			return dataVal, nil
+		}
+	}
+	return nil, nil
+}
+
+func rangeName(key *query.Constant) string {
+	if key == nil {
+		return supremum
+	}
+	return "=" + key.String()
+}
+
+// minValue returns the smallest value of the field, which the first
+// directory entry holds.
+func minValue(sch *record.Schema, fieldName string) *query.Constant {
+	switch sch.Type(fieldName) {
+	case record.INTEGER:
+		return query.NewConstantWithInt(math.MinInt32)
+	case record.BIGINT:
+		return query.NewConstantWithLong(math.MinInt64)
+	case record.BOOLEAN:
+		return query.NewConstantWithBool(false)
+	case record.DOUBLE:
+		return query.NewConstantWithDouble(math.Inf(-1))
+	case record.DATE:
+		return query.NewConstant(record.DATE, int32(math.MinInt32))
+	case record.TIMESTAMP:
+		return query.NewConstant(record.TIMESTAMP, int64(math.MinInt64))
+	case record.DECIMAL:
+		// all nines of the precision
+		unscaled := int64(-9)
+		for i := int32(1); i < sch.Length(fieldName); i++ {
+			unscaled = unscaled*10 - 9
+		}
+		return query.NewConstantWithDecimal(query.NewDecimal(unscaled, sch.Scale(fieldName)))
+	default:
+		return query.NewConstantWithString("")
+	}
+}
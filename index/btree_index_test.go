@@ -0,0 +1,107 @@
+package index_test
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/adieumonks/simple-db/index"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+func TestBTreeKeyRangeLocks(t *testing.T) {
+	db, err := server.NewSimpleDB(path.Join(t.TempDir(), "keyrangetest"), 400, 20)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+
+	schema := record.NewSchema()
+	schema.AddIntField("block")
+	schema.AddIntField("id")
+	schema.AddIntField("dataval")
+	layout := record.NewLayoutFromSchema(schema)
+
+	insert := func(tx *tx.Transaction, key int32) error {
+		idx, err := index.NewBTreeIndex(tx, "idx", layout)
+		if err != nil {
+			return err
+		}
+		defer idx.Close()
+		return idx.Insert(query.NewConstantWithInt(key), record.NewRID(0, key))
+	}
+
+	// enough keys to spread over several leaves
+	tx0, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	for key := int32(0); key < 1000; key += 10 {
+		if err := insert(tx0, key); err != nil {
+			t.Fatalf("failed to insert key %d: %v", key, err)
+		}
+	}
+	if err := tx0.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	// looking up an absent key locks the range up to the next key
+	reader, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	idx, err := index.NewBTreeIndex(reader, "idx", layout)
+	if err != nil {
+		t.Fatalf("failed to open index: %v", err)
+	}
+	if err := idx.BeforeFirst(query.NewConstantWithInt(505)); err != nil {
+		t.Fatalf("failed to search index: %v", err)
+	}
+	found, err := idx.Next()
+	if err != nil {
+		t.Fatalf("failed to search index: %v", err)
+	}
+	if found {
+		t.Fatalf("expected key 505 to be absent")
+	}
+	idx.Close()
+
+	// an insert outside the range goes ahead
+	other, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if err := insert(other, 5); err != nil {
+		t.Fatalf("failed to insert key outside the locked range: %v", err)
+	}
+	if err := other.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	// but one inside it waits for the reader
+	writer, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- insert(writer, 507)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("expected the insert to wait, got %v", err)
+	default:
+	}
+	if err := reader.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("failed to insert key inside the locked range: %v", err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+}
@@ -129,14 +129,8 @@ func (p *BTPage) MakeDefaultRecord(block *file.BlockID, pos int32) error {
 	for _, fieldName := range p.layout.Schema().Fields() {
 		offset := p.layout.Offset(fieldName)
 		fieldType := p.layout.Schema().Type(fieldName)
-		if fieldType == record.INTEGER {
-			if err := p.tx.SetInt(*block, pos+offset, 0, false); err != nil {
-				return err
-			}
-		} else {
-			if err := p.tx.SetString(*block, pos+offset, "", false); err != nil {
-				return err
-			}
+		if err := record.WriteValue(p.tx, *block, pos+offset, record.ZeroValue(fieldType), false); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -212,26 +206,13 @@ func (p *BTPage) getInt(slot int32, fieldName string) (int32, error) {
 	return p.tx.GetInt(*p.currentBlock, pos)
 }
 
-func (p *BTPage) getString(slot int32, fieldName string) (string, error) {
-	pos := p.fieldPos(slot, fieldName)
-	return p.tx.GetString(*p.currentBlock, pos)
-}
-
 func (p *BTPage) getVal(slot int32, fieldName string) (*query.Constant, error) {
-	fieldType := p.layout.Schema().Type(fieldName)
-	if fieldType == record.INTEGER {
-		ival, err := p.getInt(slot, fieldName)
-		if err != nil {
-			return nil, err
-		}
-		return query.NewConstantWithInt(ival), nil
-	} else {
-		sval, err := p.getString(slot, fieldName)
-		if err != nil {
-			return nil, err
-		}
-		return query.NewConstantWithString(sval), nil
+	sch := p.layout.Schema()
+	val, err := record.ReadValue(p.tx, *p.currentBlock, p.fieldPos(slot, fieldName), sch.Type(fieldName))
+	if err != nil {
+		return nil, err
 	}
+	return query.NewFieldConstant(sch, fieldName, val), nil
 }
 
 func (p *BTPage) setInt(slot int32, fieldName string, val int32) error {
@@ -239,18 +220,12 @@ func (p *BTPage) setInt(slot int32, fieldName string, val int32) error {
 	return p.tx.SetInt(*p.currentBlock, pos, val, true)
 }
 
-func (p *BTPage) setString(slot int32, fieldName string, val string) error {
-	pos := p.fieldPos(slot, fieldName)
-	return p.tx.SetString(*p.currentBlock, pos, val, true)
-}
-
 func (p *BTPage) setVal(slot int32, fieldName string, val *query.Constant) error {
-	filedType := p.layout.Schema().Type(fieldName)
-	if filedType == record.INTEGER {
-		return p.setInt(slot, fieldName, val.AsInt())
-	} else {
-		return p.setString(slot, fieldName, val.AsString())
+	v, err := val.Value(p.layout.Schema(), fieldName)
+	if err != nil {
+		return err
 	}
+	return record.WriteValue(p.tx, *p.currentBlock, p.fieldPos(slot, fieldName), v, true)
 }
 
 func (p *BTPage) setNumRecs(n int32) error {
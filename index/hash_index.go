@@ -6,6 +6,7 @@ import (
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
 	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
 )
 
 var NUM_BUCKETS int32 = 100
@@ -24,11 +25,24 @@ func NewHashIndex(tx *tx.Transaction, indexName string, layout *record.Layout) *
 	return &HashIndex{tx: tx, indexName: indexName, layout: layout}
 }
 
+// BeforeFirst positions the index before the first record having the
+// search key. As lookups are by equality, locking the key is enough to keep
+// records having it from being inserted or deleted meanwhile.
 func (hi *HashIndex) BeforeFirst(searchKey *query.Constant) error {
+	if err := hi.tx.LockKey(hi.indexName, rangeName(searchKey), concurrency.S); err != nil {
+		return err
+	}
+	return hi.openBucket(searchKey)
+}
+
+// openBucket opens the bucket the key goes in, whose end the key locks
+// make it unnecessary to lock.
+func (hi *HashIndex) openBucket(searchKey *query.Constant) error {
 	hi.Close()
 	hi.searchKey = searchKey
 	bucket := searchKey.HashCode() % NUM_BUCKETS
 	tableName := fmt.Sprintf("%s%d", hi.indexName, bucket)
+	hi.tx.UseKeyLocks(hi.layout.FileName(tableName))
 	ts, err := query.NewTableScan(hi.tx, tableName, hi.layout)
 	if err != nil {
 		return err
@@ -68,8 +82,13 @@ func (hi *HashIndex) GetDataRID() (*record.RID, error) {
 	return record.NewRID(blockNum, id), nil
 }
 
+// Insert adds a record to the index, keeping its key locked until the
+// transaction finishes, so that lookups of the key see no phantoms.
 func (hi *HashIndex) Insert(dataVal *query.Constant, dataRID *record.RID) error {
-	if err := hi.BeforeFirst(dataVal); err != nil {
+	if err := hi.tx.LockKey(hi.indexName, rangeName(dataVal), concurrency.X); err != nil {
+		return err
+	}
+	if err := hi.openBucket(dataVal); err != nil {
 		return err
 	}
 	if err := hi.ts.Insert(); err != nil {
@@ -87,8 +106,12 @@ func (hi *HashIndex) Insert(dataVal *query.Constant, dataRID *record.RID) error
 	return nil
 }
 
+// Delete removes a record from the index, keeping its key locked as Insert does.
 func (hi *HashIndex) Delete(dataVal *query.Constant, dataRID *record.RID) error {
-	if err := hi.BeforeFirst(dataVal); err != nil {
+	if err := hi.tx.LockKey(hi.indexName, rangeName(dataVal), concurrency.X); err != nil {
+		return err
+	}
+	if err := hi.openBucket(dataVal); err != nil {
 		return err
 	}
 	for {
@@ -122,3 +145,14 @@ func (hi *HashIndex) Close() {
 func (hi *HashIndex) SearchCost(numBlocks int32, rpb int32) int32 {
 	return numBlocks / NUM_BUCKETS
 }
+
+// FileNames returns the files that may hold the buckets of the index; a
+// negative hash code puts a key in a bucket of negative number.
+func (hi *HashIndex) FileNames() []string {
+	fileNames := make([]string, 0, 2*NUM_BUCKETS-1)
+	for bucket := -NUM_BUCKETS + 1; bucket < NUM_BUCKETS; bucket++ {
+		tableName := fmt.Sprintf("%s%d", hi.indexName, bucket)
+		fileNames = append(fileNames, hi.layout.FileName(tableName))
+	}
+	return fileNames
+}
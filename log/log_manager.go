@@ -2,11 +2,13 @@ package log
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/adieumonks/simple-db/file"
 )
 
 type LogManager struct {
+	mu           sync.Mutex
 	fm           *file.FileManager
 	logfile      string
 	logPage      *file.Page
@@ -42,12 +44,23 @@ func NewLogManager(fm *file.FileManager, logfile string) (*LogManager, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to read log page: %w", err)
 		}
+		// a crash between appending a block and writing its boundary
+		// leaves a zero-filled block at the end of the log
+		if logPage.GetInt(0) == 0 {
+			logPage.SetInt(0, fm.BlockSize())
+			if err := fm.Write(lm.currentBlock, logPage); err != nil {
+				return nil, fmt.Errorf("failed to write log page: %w", err)
+			}
+		}
 	}
 
 	return lm, nil
 }
 
 func (lm *LogManager) Flush(lsn int32) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
 	if lsn >= lm.lastSavedLSN {
 		err := lm.flush()
 		if err != nil {
@@ -58,22 +71,40 @@ func (lm *LogManager) Flush(lsn int32) error {
 }
 
 func (lm *LogManager) Iterator() (*LogIterator, error) {
-	lm.flush()
+	it, _, err := lm.IteratorWithLSN()
+	return it, err
+}
+
+// IteratorWithLSN returns an iterator along with the LSN of the first record
+// it returns, the latest one. The records it returns after that have the
+// LSNs before it in turn.
+func (lm *LogManager) IteratorWithLSN() (*LogIterator, int32, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if err := lm.flush(); err != nil {
+		return nil, 0, fmt.Errorf("failed to flush log: %w", err)
+	}
 
 	it, err := NewLogIterator(lm.fm, lm.currentBlock)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create log iterator: %w", err)
+		return nil, 0, fmt.Errorf("failed to create log iterator: %w", err)
 
 	}
-	return it, nil
+	return it, lm.latestLSN, nil
 }
 
 func (lm *LogManager) Append(rec []byte) (int32, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
 	boundary := lm.logPage.GetInt(0)
 	recSize := int32(len(rec))
 	bytesneeded := recSize + file.Int32Bytes
 	if boundary-bytesneeded < file.Int32Bytes {
-		lm.flush()
+		if err := lm.flush(); err != nil {
+			return 0, fmt.Errorf("failed to flush log: %w", err)
+		}
 		currentBlock, err := lm.appendNewBlock()
 		if err != nil {
 			return 0, fmt.Errorf("failed to append new block: %w", err)
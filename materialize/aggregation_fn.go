@@ -1,10 +1,18 @@
 package materialize
 
-import "github.com/adieumonks/simple-db/query"
+import (
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+)
 
 type AggregationFn interface {
 	ProcessFirst(s query.Scan) error
 	ProcessNext(s query.Scan) error
 	FieldName() string
-	Value() *query.Constant
+	// AddField adds the field of the value to the schema, typed from the
+	// schema of the records aggregated.
+	AddField(sch *record.Schema, input *record.Schema)
+	// Value returns the aggregate of the records processed, or an error if
+	// it does not fit in its field.
+	Value() (*query.Constant, error)
 }
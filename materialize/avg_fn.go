@@ -0,0 +1,87 @@
+package materialize
+
+import (
+	"fmt"
+
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+)
+
+// avgScale is the least number of digits after the point of an average of
+// exact numbers.
+const avgScale = 6
+
+var _ AggregationFn = (*AvgFn)(nil)
+
+// AvgFn averages the values of a field, exactly unless they are doubles.
+type AvgFn struct {
+	fieldName string
+	sum       *query.Constant
+	count     int64
+}
+
+func NewAvgFn(fieldName string) *AvgFn {
+	return &AvgFn{fieldName: fieldName}
+}
+
+func (af *AvgFn) ProcessFirst(s query.Scan) error {
+	af.sum = query.NewNullConstant()
+	af.count = 0
+	return af.ProcessNext(s)
+}
+
+// ProcessNext adds the value of the record to the average, unless it is
+// NULL.
+func (af *AvgFn) ProcessNext(s query.Scan) error {
+	val, err := s.GetVal(af.fieldName)
+	if err != nil {
+		return err
+	}
+	sum, err := addNumbers(af.sum, val)
+	if err != nil {
+		return fmt.Errorf("failed to average %s: %w", af.fieldName, err)
+	}
+	if !val.IsNull() {
+		af.count++
+	}
+	af.sum = sum
+	return nil
+}
+
+func (af *AvgFn) FieldName() string {
+	return fmt.Sprintf("avgof%s", af.fieldName)
+}
+
+// AddField adds a double for the average of doubles, and otherwise a
+// decimal of the most digits with at least avgScale digits after the
+// point.
+func (af *AvgFn) AddField(sch *record.Schema, input *record.Schema) {
+	switch input.Type(af.fieldName) {
+	case record.INTEGER, record.BIGINT, record.DECIMAL:
+		sch.AddDecimalField(af.FieldName(), query.MaxDecimalPrecision, max(input.Scale(af.fieldName), avgScale))
+	default:
+		sch.AddField(af.FieldName(), record.DOUBLE, 0)
+	}
+}
+
+// Value returns the average, which is NULL if all the values are NULL.
+// A bigint sum may have more digits than a decimal, so the average is
+// rounded to the scale of the sum if it has no room for avgScale digits
+// after the point, and fails if it has no room even then.
+func (af *AvgFn) Value() (*query.Constant, error) {
+	if af.count == 0 {
+		return query.NewNullConstant(), nil
+	}
+	if af.sum.Type() == record.DOUBLE {
+		return query.NewConstantWithDouble(af.sum.AsDouble() / float64(af.count)), nil
+	}
+	sum := af.sum.AsDecimal()
+	avg, err := sum.Div(query.NewDecimal(af.count, 0), max(sum.Scale(), avgScale))
+	if err != nil {
+		avg, err = sum.Div(query.NewDecimal(af.count, 0), sum.Scale())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to average %s: %w", af.fieldName, err)
+	}
+	return query.NewConstantWithDecimal(avg), nil
+}
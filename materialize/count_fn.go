@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
 )
 
 var _ AggregationFn = (*CountFn)(nil)
@@ -18,12 +19,19 @@ func NewCountFn(fieldName string) *CountFn {
 }
 
 func (cf *CountFn) ProcessFirst(s query.Scan) error {
-	cf.count = 1
-	return nil
+	cf.count = 0
+	return cf.ProcessNext(s)
 }
 
+// ProcessNext counts the record, unless its field is NULL.
 func (cf *CountFn) ProcessNext(s query.Scan) error {
-	cf.count++
+	val, err := s.GetVal(cf.fieldName)
+	if err != nil {
+		return err
+	}
+	if !val.IsNull() {
+		cf.count++
+	}
 	return nil
 }
 
@@ -31,6 +39,10 @@ func (cf *CountFn) FieldName() string {
 	return fmt.Sprintf("countof%s", cf.fieldName)
 }
 
-func (cf *CountFn) Value() *query.Constant {
-	return query.NewConstantWithInt(cf.count)
+func (cf *CountFn) AddField(sch *record.Schema, input *record.Schema) {
+	sch.AddIntField(cf.FieldName())
+}
+
+func (cf *CountFn) Value() (*query.Constant, error) {
+	return query.NewConstantWithInt(cf.count), nil
 }
@@ -22,7 +22,7 @@ func NewGroupByPlan(tx *tx.Transaction, p plan.Plan, groupFields []string, aggFn
 		sch.Add(fieldName, p.Schema())
 	}
 	for _, fn := range aggFns {
-		sch.AddIntField(fn.FieldName())
+		fn.AddField(sch, p.Schema())
 	}
 	return &GroupByPlan{
 		p:           NewSortPlan(tx, p, groupFields),
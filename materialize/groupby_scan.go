@@ -129,7 +129,7 @@ func (gs *GroupByScan) GetVal(fieldName string) (*query.Constant, error) {
 	}
 	for _, fn := range gs.aggFns {
 		if fn.FieldName() == fieldName {
-			return fn.Value(), nil
+			return fn.Value()
 		}
 	}
 	return nil, fmt.Errorf("field %s not found", fieldName)
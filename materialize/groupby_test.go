@@ -1,12 +1,14 @@
 package materialize_test
 
 import (
+	"errors"
 	"fmt"
 	"path"
 	"testing"
 
 	"github.com/adieumonks/simple-db/materialize"
 	"github.com/adieumonks/simple-db/plan"
+	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/server"
 )
 
@@ -103,3 +105,221 @@ func TestGroupBy(t *testing.T) {
 		t.Fatalf("failed to commit transaction: %v", err)
 	}
 }
+
+func TestGroupByNull(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "groupbynulltest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+
+	planner := db.Planner()
+	for _, command := range []string{
+		"create table t1(a int, b int)",
+		"insert into t1(a, b) values(1, 5)",
+		"insert into t1(a, b) values(1, null)",
+		"insert into t1(a, b) values(1, 3)",
+		"insert into t1(a, b) values(2, null)",
+		"insert into t1(b) values(7)",
+		"insert into t1(a, b) values(null, 8)",
+	} {
+		if _, err := planner.ExecuteUpdate(command, tx); err != nil {
+			t.Fatalf("failed to execute update: %v", err)
+		}
+	}
+
+	tp, err := plan.NewTablePlan(tx, "t1", db.MetadataManager())
+	if err != nil {
+		t.Fatalf("failed to create table plan: %v", err)
+	}
+	p, err := materialize.NewGroupByPlan(
+		tx,
+		tp,
+		[]string{"a"},
+		[]materialize.AggregationFn{materialize.NewCountFn("b"), materialize.NewMaxFn("b")},
+	)
+	if err != nil {
+		t.Fatalf("failed to create group by plan: %v", err)
+	}
+	s, err := p.Open()
+	if err != nil {
+		t.Fatalf("failed to open group by scan: %v", err)
+	}
+
+	// NULL values of a form a group of their own, sorted first, and NULL
+	// values of b are not counted
+	var got []string
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next record: %v", err)
+		}
+		if !next {
+			break
+		}
+		var vals []string
+		for _, fieldName := range []string{"a", "countofb", "maxofb"} {
+			val, err := s.GetVal(fieldName)
+			if err != nil {
+				t.Fatalf("failed to get value: %v", err)
+			}
+			vals = append(vals, val.String())
+		}
+		got = append(got, fmt.Sprint(vals))
+	}
+	s.Close()
+
+	want := []string{"[null 2 8]", "[1 2 5]", "[2 0 null]"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected groups %v, got %v", want, got)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestGroupByDecimal(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "groupbydecimaltest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+
+	planner := db.Planner()
+	for _, command := range []string{
+		"create table t1(a int, p decimal(10, 2), q int)",
+		"insert into t1(a, p, q) values(1, 0.10, 1)",
+		"insert into t1(a, p, q) values(1, 0.20, 2)",
+		"insert into t1(a, p, q) values(1, 0.05, null)",
+		"insert into t1(a, p, q) values(2, 19.99, 2)",
+		"insert into t1(a, p, q) values(2, -20.00, 2)",
+		"insert into t1(a, p, q) values(3, null, null)",
+	} {
+		if _, err := planner.ExecuteUpdate(command, tx); err != nil {
+			t.Fatalf("failed to execute update: %v", err)
+		}
+	}
+
+	tp, err := plan.NewTablePlan(tx, "t1", db.MetadataManager())
+	if err != nil {
+		t.Fatalf("failed to create table plan: %v", err)
+	}
+	// sort the groups by the sum of p, which goes through a temporary table
+	// of the types of the aggregates
+	gp, err := materialize.NewGroupByPlan(
+		tx,
+		tp,
+		[]string{"a"},
+		[]materialize.AggregationFn{materialize.NewSumFn("p"), materialize.NewAvgFn("p"), materialize.NewSumFn("q"), materialize.NewAvgFn("q")},
+	)
+	if err != nil {
+		t.Fatalf("failed to create group by plan: %v", err)
+	}
+	p := materialize.NewSortPlan(tx, gp, []string{"sumofp"})
+	s, err := p.Open()
+	if err != nil {
+		t.Fatalf("failed to open sort scan: %v", err)
+	}
+
+	// sums and averages are exact, and ignore NULL
+	var got []string
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next record: %v", err)
+		}
+		if !next {
+			break
+		}
+		var vals []string
+		for _, fieldName := range []string{"a", "sumofp", "avgofp", "sumofq", "avgofq"} {
+			val, err := s.GetVal(fieldName)
+			if err != nil {
+				t.Fatalf("failed to get value: %v", err)
+			}
+			vals = append(vals, val.String())
+		}
+		got = append(got, fmt.Sprint(vals))
+	}
+	s.Close()
+
+	want := []string{
+		"[3 null null null null]",
+		"[2 -0.01 -0.005000 4 2.000000]",
+		"[1 0.35 0.116667 3 1.500000]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected groups %v, got %v", want, got)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestGroupByAvgOverflow(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "groupbyavgoverflowtest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+
+	planner := db.Planner()
+	commands := []string{"create table t1(a int, b bigint)"}
+	// the sum of group 1 has more digits than a decimal, but not its average
+	for i := 0; i < 10; i++ {
+		commands = append(commands, "insert into t1(a, b) values(1, 900000000000000000)")
+	}
+	// the average of group 2 has more digits than a decimal
+	commands = append(commands, "insert into t1(a, b) values(2, 1000000000000000000)")
+	for _, command := range commands {
+		if _, err := planner.ExecuteUpdate(command, tx); err != nil {
+			t.Fatalf("failed to execute update: %v", err)
+		}
+	}
+
+	tp, err := plan.NewTablePlan(tx, "t1", db.MetadataManager())
+	if err != nil {
+		t.Fatalf("failed to create table plan: %v", err)
+	}
+	gp, err := materialize.NewGroupByPlan(tx, tp, []string{"a"}, []materialize.AggregationFn{materialize.NewAvgFn("b")})
+	if err != nil {
+		t.Fatalf("failed to create group by plan: %v", err)
+	}
+	s, err := gp.Open()
+	if err != nil {
+		t.Fatalf("failed to open group by scan: %v", err)
+	}
+	defer s.Close()
+
+	if next, err := s.Next(); err != nil || !next {
+		t.Fatalf("expected group 1, got %v, %v", next, err)
+	}
+	avg, err := s.GetVal("avgofb")
+	if err != nil {
+		t.Fatalf("failed to get value: %v", err)
+	}
+	if avg.String() != "900000000000000000" {
+		t.Fatalf("expected 900000000000000000, got %s", avg)
+	}
+
+	if next, err := s.Next(); err != nil || !next {
+		t.Fatalf("expected group 2, got %v, %v", next, err)
+	}
+	if avg, err := s.GetVal("avgofb"); !errors.Is(err, query.ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got %v, %v", avg, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
 )
 
 var _ AggregationFn = (*MaxFn)(nil)
@@ -31,7 +32,8 @@ func (mf *MaxFn) ProcessNext(s query.Scan) error {
 	if err != nil {
 		return err
 	}
-	if newVal.CompareTo(mf.val) > 0 {
+	// NULL is ignored, and is the maximum only if all the values are NULL
+	if !newVal.IsNull() && newVal.CompareTo(mf.val) > 0 {
 		mf.val = newVal
 	}
 	return nil
@@ -41,6 +43,10 @@ func (mf *MaxFn) FieldName() string {
 	return fmt.Sprintf("maxof%s", mf.fieldName)
 }
 
-func (mf *MaxFn) Value() *query.Constant {
-	return mf.val
+func (mf *MaxFn) AddField(sch *record.Schema, input *record.Schema) {
+	sch.AddAs(mf.FieldName(), mf.fieldName, input)
+}
+
+func (mf *MaxFn) Value() (*query.Constant, error) {
+	return mf.val, nil
 }
@@ -83,7 +83,8 @@ func (s *MergeJoinScan) Next() (bool, error) {
 		if err != nil {
 			return false, err
 		}
-		if v1.CompareTo(v2) < 0 {
+		// NULL joins with nothing, and sorts before any value
+		if v1.IsNull() || v1.CompareTo(v2) < 0 {
 			hasMore1, err = s.s1.Next()
 			if err != nil {
 				return false, err
@@ -135,14 +135,12 @@ func (sp *OneBufferSortPlan) splitIntoRuns(src query.Scan) ([]*TempTable, error)
 			if err != nil {
 				return nil, err
 			}
-			if sp.sch.Type(fieldName) == record.INTEGER {
-				if err := rp.SetInt(nextSlot, fieldName, val.AsInt()); err != nil {
-					return nil, err
-				}
-			} else {
-				if err := rp.SetString(nextSlot, fieldName, val.AsString()); err != nil {
-					return nil, err
-				}
+			v, err := val.Value(sp.sch, fieldName)
+			if err != nil {
+				return nil, err
+			}
+			if err := rp.SetValue(nextSlot, fieldName, v); err != nil {
+				return nil, err
 			}
 		}
 
@@ -242,22 +240,12 @@ func (sp *OneBufferSortPlan) copyFromRecordPageToRun(rp *record.RecordPage, dest
 			return err
 		}
 		for _, fieldName := range sp.sch.Fields() {
-			if sp.sch.Type(fieldName) == record.INTEGER {
-				val, err := rp.GetInt(slot, fieldName)
-				if err != nil {
-					return err
-				}
-				if err := dest.SetInt(fieldName, val); err != nil {
-					return err
-				}
-			} else {
-				val, err := rp.GetString(slot, fieldName)
-				if err != nil {
-					return err
-				}
-				if err := dest.SetString(fieldName, val); err != nil {
-					return err
-				}
+			val, err := rp.GetValue(slot, fieldName)
+			if err != nil {
+				return err
+			}
+			if err := dest.SetVal(fieldName, query.NewFieldConstant(sp.sch, fieldName, val)); err != nil {
+				return err
 			}
 		}
 	}
@@ -0,0 +1,92 @@
+package materialize
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+)
+
+var _ AggregationFn = (*SumFn)(nil)
+
+// SumFn sums the values of a field, exactly unless they are doubles.
+type SumFn struct {
+	fieldName string
+	sum       *query.Constant
+}
+
+func NewSumFn(fieldName string) *SumFn {
+	return &SumFn{fieldName: fieldName}
+}
+
+func (sf *SumFn) ProcessFirst(s query.Scan) error {
+	sf.sum = query.NewNullConstant()
+	return sf.ProcessNext(s)
+}
+
+// ProcessNext adds the value of the record, unless it is NULL.
+func (sf *SumFn) ProcessNext(s query.Scan) error {
+	val, err := s.GetVal(sf.fieldName)
+	if err != nil {
+		return err
+	}
+	sum, err := addNumbers(sf.sum, val)
+	if err != nil {
+		return fmt.Errorf("failed to sum %s: %w", sf.fieldName, err)
+	}
+	sf.sum = sum
+	return nil
+}
+
+func (sf *SumFn) FieldName() string {
+	return fmt.Sprintf("sumof%s", sf.fieldName)
+}
+
+// AddField adds a bigint for the sum of integers, a decimal of the most
+// digits with the same scale for the sum of decimals, and a double for the
+// sum of doubles.
+func (sf *SumFn) AddField(sch *record.Schema, input *record.Schema) {
+	switch input.Type(sf.fieldName) {
+	case record.INTEGER, record.BIGINT:
+		sch.AddField(sf.FieldName(), record.BIGINT, 0)
+	case record.DECIMAL:
+		sch.AddDecimalField(sf.FieldName(), query.MaxDecimalPrecision, input.Scale(sf.fieldName))
+	default:
+		sch.AddField(sf.FieldName(), record.DOUBLE, 0)
+	}
+}
+
+// Value returns the sum, which is NULL if all the values are NULL.
+func (sf *SumFn) Value() (*query.Constant, error) {
+	return sf.sum, nil
+}
+
+// addNumbers adds the numbers, ignoring NULL. Integers add up to a bigint,
+// and decimals to a decimal, unless a double is added.
+func addNumbers(sum, val *query.Constant) (*query.Constant, error) {
+	if val.IsNull() {
+		return sum, nil
+	}
+	if !val.IsNumber() {
+		return nil, fmt.Errorf("cannot add %s %s: %w", val.Type(), val, query.ErrTypeMismatch)
+	}
+	if sum.IsNull() {
+		sum = query.NewConstantWithLong(0)
+	}
+	switch {
+	case sum.Type() == record.DOUBLE || val.Type() == record.DOUBLE:
+		return query.NewConstantWithDouble(sum.AsDouble() + val.AsDouble()), nil
+	case sum.Type() == record.DECIMAL || val.Type() == record.DECIMAL:
+		d, err := sum.AsDecimal().Add(val.AsDecimal())
+		if err != nil {
+			return nil, err
+		}
+		return query.NewConstantWithDecimal(d), nil
+	}
+	x, y := sum.AsLong(), val.AsLong()
+	if y > 0 && x > math.MaxInt64-y || y < 0 && x < math.MinInt64-y {
+		return nil, fmt.Errorf("bigint out of range: %w", query.ErrTypeMismatch)
+	}
+	return query.NewConstantWithLong(x + y), nil
+}
@@ -0,0 +1,272 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+// versionFile holds the catalog version in the first int of its first
+// block, apart from the catalog tables so that it reads the same whatever
+// their layouts. Catalogs from before versions were kept have none, which
+// reads as version 0.
+const versionFile = "catversion"
+
+// NeedsUpgrade reports whether opening the catalog writes to it, because
+// it is empty or from before versions were kept. It fails with
+// ErrCatalogVersion if the catalog has a version this build does not read.
+func NeedsUpgrade(tx *tx.Transaction) (bool, error) {
+	empty, err := catalogEmpty(tx)
+	if err != nil {
+		return false, err
+	}
+	if empty {
+		return true, nil
+	}
+	version, err := readCatalogVersion(tx)
+	if err != nil {
+		return false, err
+	}
+	switch version {
+	case 0:
+		return true, nil
+	case CATALOG_VERSION:
+		return false, nil
+	default:
+		return false, fmt.Errorf("catalog is at version %d, want %d: %w", version, CATALOG_VERSION, ErrCatalogVersion)
+	}
+}
+
+// catalogEmpty reports whether tblcat has no records, as in a new database,
+// or one whose first transaction rolled back. The first record of tblcat,
+// which describes tblcat itself, is in the first slot in every version.
+func catalogEmpty(tx *tx.Transaction) (bool, error) {
+	size, err := tx.Size("tblcat.tbl")
+	if err != nil {
+		return false, fmt.Errorf("failed to get file size: %w", err)
+	}
+	if size == 0 {
+		return true, nil
+	}
+	block := file.NewBlockID("tblcat.tbl", 0)
+	if err := tx.Pin(block); err != nil {
+		return false, fmt.Errorf("failed to pin: %w", err)
+	}
+	defer tx.Unpin(block)
+	flag, err := tx.GetInt(block, 0)
+	if err != nil {
+		return false, fmt.Errorf("failed to get int: %w", err)
+	}
+	return flag == record.EMPTY, nil
+}
+
+func readCatalogVersion(tx *tx.Transaction) (int32, error) {
+	size, err := tx.Size(versionFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file size: %w", err)
+	}
+	if size == 0 {
+		return 0, nil
+	}
+	block := file.NewBlockID(versionFile, 0)
+	if err := tx.Pin(block); err != nil {
+		return 0, fmt.Errorf("failed to pin: %w", err)
+	}
+	defer tx.Unpin(block)
+	version, err := tx.GetInt(block, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get int: %w", err)
+	}
+	return version, nil
+}
+
+// writeCatalogVersion writes the version without logging it, so that it
+// stays if the transaction creating the catalog does not finish; the empty
+// catalog left then is created again.
+func writeCatalogVersion(tx *tx.Transaction) error {
+	size, err := tx.Size(versionFile)
+	if err != nil {
+		return fmt.Errorf("failed to get file size: %w", err)
+	}
+	block := file.NewBlockID(versionFile, 0)
+	if size == 0 {
+		if block, err = tx.Append(versionFile); err != nil {
+			return fmt.Errorf("failed to append: %w", err)
+		}
+	}
+	if err := tx.Pin(block); err != nil {
+		return fmt.Errorf("failed to pin: %w", err)
+	}
+	defer tx.Unpin(block)
+	if err := tx.SetInt(block, 0, CATALOG_VERSION, false); err != nil {
+		return fmt.Errorf("failed to set int: %w", err)
+	}
+	return nil
+}
+
+// checkCatalogVersion migrates a catalog from before versions were kept,
+// and fails with ErrCatalogVersion if it has another version than this
+// build reads.
+func (tm *TableManager) checkCatalogVersion(tx *tx.Transaction) error {
+	version, err := readCatalogVersion(tx)
+	if err != nil {
+		return err
+	}
+	switch version {
+	case CATALOG_VERSION:
+		return nil
+	case 0:
+		if err := tm.migrateCatalog(tx); err != nil {
+			return fmt.Errorf("failed to migrate catalog: %w", err)
+		}
+		return writeCatalogVersion(tx)
+	default:
+		return fmt.Errorf("catalog is at version %d, want %d: %w", version, CATALOG_VERSION, ErrCatalogVersion)
+	}
+}
+
+// oldField is a field as recorded in a catalog from before versions were
+// kept.
+type oldField struct {
+	name   string
+	typ    int32
+	length int32
+	offset int32
+}
+
+// migrateCatalog rewrites a catalog from before versions were kept in the
+// current layouts. Its records have no null flags, and only the name and
+// slot size of the tables and the name, type, length and offset of their
+// fields; the tables are fixed, unversioned, at generation 0 and without
+// nullable fields, which is how their records are laid out.
+func (tm *TableManager) migrateCatalog(tx *tx.Transaction) error {
+	tcatSchema := record.NewSchema()
+	tcatSchema.AddStringField("tblname", MAX_NAME)
+	tcatSchema.AddIntField("slotsize")
+	fcatSchema := record.NewSchema()
+	fcatSchema.AddStringField("tblname", MAX_NAME)
+	fcatSchema.AddStringField("fldname", MAX_NAME)
+	fcatSchema.AddIntField("type")
+	fcatSchema.AddIntField("length")
+	fcatSchema.AddIntField("offset")
+
+	var tableNames []string
+	slotSizes := make(map[string]int32)
+	tcat, err := query.NewTableScan(tx, "tblcat", record.NewLayoutFromSchema(tcatSchema))
+	if err != nil {
+		return fmt.Errorf("failed to create table scan: %w", err)
+	}
+	for {
+		next, err := tcat.Next()
+		if err != nil {
+			tcat.Close()
+			return fmt.Errorf("failed to get next: %w", err)
+		}
+		if !next {
+			break
+		}
+		tableName, err := tcat.GetString("tblname")
+		if err != nil {
+			tcat.Close()
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		slotSize, err := tcat.GetInt("slotsize")
+		if err != nil {
+			tcat.Close()
+			return fmt.Errorf("failed to get int: %w", err)
+		}
+		tableNames = append(tableNames, tableName)
+		slotSizes[tableName] = slotSize
+	}
+	tcat.Close()
+
+	fields := make(map[string][]oldField)
+	fcat, err := query.NewTableScan(tx, "fldcat", record.NewLayoutFromSchema(fcatSchema))
+	if err != nil {
+		return fmt.Errorf("failed to create table scan: %w", err)
+	}
+	for {
+		next, err := fcat.Next()
+		if err != nil {
+			fcat.Close()
+			return fmt.Errorf("failed to get next: %w", err)
+		}
+		if !next {
+			break
+		}
+		var tableName string
+		var field oldField
+		for _, get := range []func() error{
+			func() (err error) { tableName, err = fcat.GetString("tblname"); return },
+			func() (err error) { field.name, err = fcat.GetString("fldname"); return },
+			func() (err error) { field.typ, err = fcat.GetInt("type"); return },
+			func() (err error) { field.length, err = fcat.GetInt("length"); return },
+			func() (err error) { field.offset, err = fcat.GetInt("offset"); return },
+		} {
+			if err := get(); err != nil {
+				fcat.Close()
+				return fmt.Errorf("failed to get field: %w", err)
+			}
+		}
+		fields[tableName] = append(fields[tableName], field)
+	}
+	fcat.Close()
+
+	for _, fileName := range []string{"tblcat.tbl", "fldcat.tbl"} {
+		if err := clearFile(fileName, tx); err != nil {
+			return err
+		}
+	}
+	if err := tm.insertCatalog("tblcat", tm.tcatLayout, tx); err != nil {
+		return err
+	}
+	if err := tm.insertCatalog("fldcat", tm.fcatLayout, tx); err != nil {
+		return err
+	}
+	for _, tableName := range tableNames {
+		if isCatalog(tableName) {
+			continue
+		}
+		schema := record.NewSchema()
+		offsets := make(map[string]int32)
+		for _, field := range fields[tableName] {
+			schema.AddField(field.name, record.FieldType(field.typ), field.length)
+			offsets[field.name] = field.offset
+		}
+		if err := tm.insertCatalog(tableName, record.NewLayout(schema, offsets, slotSizes[tableName]), tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearChunk is how many bytes clearFile zeroes at a time, each logged in
+// a record that must fit in a block of the log.
+const clearChunk = 64
+
+// clearFile zeroes every block of the file, leaving its records empty in
+// any layout.
+func clearFile(fileName string, tx *tx.Transaction) error {
+	size, err := tx.Size(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to get file size: %w", err)
+	}
+	for n := int32(0); n < size; n++ {
+		block := file.NewBlockID(fileName, n)
+		if err := tx.Pin(block); err != nil {
+			return fmt.Errorf("failed to pin: %w", err)
+		}
+		for offset := int32(0); offset < tx.BlockSize(); offset += clearChunk {
+			zeros := make([]byte, min(clearChunk, tx.BlockSize()-offset))
+			if err := tx.SetBytes(block, offset, zeros, true); err != nil {
+				tx.Unpin(block)
+				return fmt.Errorf("failed to set bytes: %w", err)
+			}
+		}
+		tx.Unpin(block)
+	}
+	return nil
+}
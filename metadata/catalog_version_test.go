@@ -0,0 +1,251 @@
+package metadata_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/metadata"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+func TestCatalogVersion(t *testing.T) {
+	t.Run("baseline catalog", func(t *testing.T) {
+		dir := path.Join(t.TempDir(), "catalogbaselinetest")
+		writeBaselineDatabase(t, dir)
+
+		db, err := server.NewSimpleDBWithMetadata(dir)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		executeCommitted(t, db, "insert into T1(A, B) values(4, 'four')")
+		executeCommitted(t, db, "create view V1 as select B from T1 where A > 2")
+		assertQuery(t, db, "select A, B from T1", []string{"1 one", "2 two", "3 three", "4 four"})
+
+		// the migrated catalog is at the current version
+		db, err = server.NewSimpleDBWithMetadata(dir)
+		if err != nil {
+			t.Fatalf("failed to reopen database: %v", err)
+		}
+		assertQuery(t, db, "select B from V1", []string{"three", "four"})
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		dir := path.Join(t.TempDir(), "catalogemptytest")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		db, err := server.NewSimpleDBWithMetadata(dir)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		executeCommitted(t, db, "create table T1(A int)")
+		executeCommitted(t, db, "insert into T1(A) values(1)")
+		assertQuery(t, db, "select A from T1", []string{"1"})
+	})
+
+	t.Run("crash while creating", func(t *testing.T) {
+		dir := path.Join(t.TempDir(), "catalogcrashtest")
+		db, err := server.NewSimpleDB(dir, server.BLOCK_SIZE, server.BUFFER_SIZE)
+		if err != nil {
+			t.Fatalf("failed to create new database: %v", err)
+		}
+		tx, err := db.NewTransaction()
+		if err != nil {
+			t.Fatalf("failed to create new transaction: %v", err)
+		}
+		if _, err := metadata.NewMetadataManager(true, tx); err != nil {
+			t.Fatalf("failed to create metadata manager: %v", err)
+		}
+		// the catalog reaches the disk, but its transaction never commits
+		if err := db.BufferManager().FlushAll(tx.TxNumber()); err != nil {
+			t.Fatalf("failed to flush buffers: %v", err)
+		}
+
+		db, err = server.NewSimpleDBWithMetadata(dir)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		executeCommitted(t, db, "create table T1(A int)")
+		assertQuery(t, db, "select A from T1", []string{})
+	})
+
+	t.Run("other version", func(t *testing.T) {
+		dir := path.Join(t.TempDir(), "catalogothertest")
+		db, err := server.NewSimpleDBWithMetadata(dir)
+		if err != nil {
+			t.Fatalf("failed to create new database: %v", err)
+		}
+		tx, err := db.NewTransaction()
+		if err != nil {
+			t.Fatalf("failed to create new transaction: %v", err)
+		}
+		block := file.NewBlockID("catversion", 0)
+		if err := tx.Pin(block); err != nil {
+			t.Fatalf("failed to pin: %v", err)
+		}
+		if err := tx.SetInt(block, 0, metadata.CATALOG_VERSION+1, true); err != nil {
+			t.Fatalf("failed to set int: %v", err)
+		}
+		tx.Unpin(block)
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+
+		if _, err := server.NewSimpleDBWithMetadata(dir); !errors.Is(err, metadata.ErrCatalogVersion) {
+			t.Fatalf("expected ErrCatalogVersion, got %v", err)
+		}
+	})
+}
+
+// writeBaselineDatabase writes a database as it was before catalog
+// versions were kept: the catalog records have no null flags and fewer
+// fields, and T1 has three records.
+func writeBaselineDatabase(t *testing.T, dir string) {
+	t.Helper()
+	db, err := server.NewSimpleDB(dir, server.BLOCK_SIZE, server.BUFFER_SIZE)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+
+	tcatSchema := record.NewSchema()
+	tcatSchema.AddStringField("tblname", metadata.MAX_NAME)
+	tcatSchema.AddIntField("slotsize")
+	fcatSchema := record.NewSchema()
+	fcatSchema.AddStringField("tblname", metadata.MAX_NAME)
+	fcatSchema.AddStringField("fldname", metadata.MAX_NAME)
+	fcatSchema.AddIntField("type")
+	fcatSchema.AddIntField("length")
+	fcatSchema.AddIntField("offset")
+	viewcatSchema := record.NewSchema()
+	viewcatSchema.AddStringField("viewname", metadata.MAX_NAME)
+	viewcatSchema.AddStringField("viewdef", metadata.MAX_VIEWDEF)
+	idxcatSchema := record.NewSchema()
+	idxcatSchema.AddStringField("indexname", metadata.MAX_NAME)
+	idxcatSchema.AddStringField("tablename", metadata.MAX_NAME)
+	idxcatSchema.AddStringField("fieldname", metadata.MAX_NAME)
+	t1Schema := record.NewSchema()
+	t1Schema.AddIntField("a")
+	t1Schema.AddStringField("b", 9)
+
+	tcatLayout := record.NewLayoutFromSchema(tcatSchema)
+	fcatLayout := record.NewLayoutFromSchema(fcatSchema)
+	for _, table := range []struct {
+		name   string
+		schema *record.Schema
+	}{
+		{"tblcat", tcatSchema},
+		{"fldcat", fcatSchema},
+		{"viewcat", viewcatSchema},
+		{"idxcat", idxcatSchema},
+		{"t1", t1Schema},
+	} {
+		layout := record.NewLayoutFromSchema(table.schema)
+		insertRecord(t, tx, "tblcat", tcatLayout, table.name, layout.SlotSize())
+		for _, fieldName := range table.schema.Fields() {
+			insertRecord(t, tx, "fldcat", fcatLayout, table.name, fieldName,
+				int32(table.schema.Type(fieldName)), table.schema.Length(fieldName), layout.Offset(fieldName))
+		}
+	}
+	t1Layout := record.NewLayoutFromSchema(t1Schema)
+	for i, b := range []string{"one", "two", "three"} {
+		insertRecord(t, tx, "t1", t1Layout, int32(i+1), b)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+}
+
+// insertRecord inserts a record with the values of the fields in order.
+func insertRecord(t *testing.T, tx *tx.Transaction, tableName string, layout *record.Layout, vals ...any) {
+	t.Helper()
+	ts, err := query.NewTableScan(tx, tableName, layout)
+	if err != nil {
+		t.Fatalf("failed to create table scan: %v", err)
+	}
+	defer ts.Close()
+	if err := ts.Insert(); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	for i, fieldName := range layout.Schema().Fields() {
+		switch val := vals[i].(type) {
+		case int32:
+			err = ts.SetInt(fieldName, val)
+		case string:
+			err = ts.SetString(fieldName, val)
+		default:
+			err = fmt.Errorf("unexpected value %v", val)
+		}
+		if err != nil {
+			t.Fatalf("failed to set %s: %v", fieldName, err)
+		}
+	}
+}
+
+func executeCommitted(t *testing.T, db *server.SimpleDB, command string) {
+	t.Helper()
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := db.Planner().ExecuteUpdate(command, tx); err != nil {
+		t.Fatalf("failed to execute %s: %v", command, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+}
+
+// assertQuery checks the records of the query, each as its values separated
+// by spaces, in order.
+func assertQuery(t *testing.T, db *server.SimpleDB, query string, expected []string) {
+	t.Helper()
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	defer tx.Commit()
+	plan, err := db.Planner().CreateQueryPlan(query, tx)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	scan, err := plan.Open()
+	if err != nil {
+		t.Fatalf("failed to open scan: %v", err)
+	}
+	defer scan.Close()
+	var rows []string
+	for {
+		next, err := scan.Next()
+		if err != nil {
+			t.Fatalf("failed to get next: %v", err)
+		}
+		if !next {
+			break
+		}
+		var vals []string
+		for _, fieldName := range plan.Schema().Fields() {
+			val, err := scan.GetVal(fieldName)
+			if err != nil {
+				t.Fatalf("failed to get %s: %v", fieldName, err)
+			}
+			vals = append(vals, val.String())
+		}
+		rows = append(rows, strings.Join(vals, " "))
+	}
+	if !slices.Equal(rows, expected) {
+		t.Errorf("%s: expected %v, got %v", query, expected, rows)
+	}
+}
@@ -0,0 +1,14 @@
+package metadata
+
+import "errors"
+
+var (
+	ErrTableNotFound = errors.New("table not found")
+	ErrViewNotFound  = errors.New("view not found")
+	ErrIndexNotFound = errors.New("index not found")
+	ErrFieldExists   = errors.New("field already exists")
+	ErrDependent     = errors.New("other objects depend on it")
+	// ErrCatalogVersion is returned when opening a database whose catalog
+	// has other layouts than this build reads
+	ErrCatalogVersion = errors.New("unsupported catalog version")
+)
@@ -10,23 +10,27 @@ type IndexInfo struct {
 	indexName   string
 	fieldName   string
 	tx          *tx.Transaction
-	tableSchema *record.Schema
+	tableLayout *record.Layout
 	indexLayout *record.Layout
 	si          *StatInfo
 }
 
-func NewIndexInfo(indexName string, fieldName string, tableSchema *record.Schema, tx *tx.Transaction, si *StatInfo) *IndexInfo {
+func NewIndexInfo(indexName string, fieldName string, tableLayout *record.Layout, tx *tx.Transaction, si *StatInfo) *IndexInfo {
 	ii := &IndexInfo{
 		indexName:   indexName,
 		fieldName:   fieldName,
 		tx:          tx,
-		tableSchema: tableSchema,
+		tableLayout: tableLayout,
 		si:          si,
 	}
 	ii.indexLayout = ii.createIndexLayout()
 	return ii
 }
 
+func (ii *IndexInfo) IndexName() string {
+	return ii.indexName
+}
+
 func (ii *IndexInfo) Open() index.Index {
 	return index.NewHashIndex(ii.tx, ii.indexName, ii.indexLayout)
 }
@@ -37,6 +41,11 @@ func (ii *IndexInfo) BlocksAccessed() int32 {
 	return index.NewHashIndex(ii.tx, ii.indexName, ii.indexLayout).SearchCost(numBlocks, rpb)
 }
 
+// FileNames returns the files the index may be stored in.
+func (ii *IndexInfo) FileNames() []string {
+	return index.NewHashIndex(ii.tx, ii.indexName, ii.indexLayout).FileNames()
+}
+
 func (ii *IndexInfo) RecordsOutput() int32 {
 	return ii.si.RecordsOutput() / ii.si.DistinctValues(ii.fieldName)
 }
@@ -52,11 +61,14 @@ func (ii *IndexInfo) createIndexLayout() *record.Layout {
 	schema := record.NewSchema()
 	schema.AddIntField("block")
 	schema.AddIntField("id")
-	if ii.tableSchema.Type(ii.fieldName) == record.INTEGER {
-		schema.AddIntField("dataval")
-	} else {
-		fieldLength := ii.tableSchema.Length(ii.fieldName)
-		schema.AddStringField("dataval", fieldLength)
+	schema.AddAs("dataval", ii.fieldName, ii.tableLayout.Schema())
+	// NULL is not indexed
+	for _, fieldName := range schema.Fields() {
+		schema.SetNullable(fieldName, false)
 	}
-	return record.NewLayoutFromSchema(schema)
+	// the index is rebuilt along with the records of the table, so its
+	// files follow the generation of the table
+	layout := record.NewLayoutFromSchema(schema)
+	layout.SetGeneration(ii.tableLayout.Generation())
+	return layout
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
 	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
 )
 
 type IndexManager struct {
@@ -37,6 +38,21 @@ func NewIndexManager(isNew bool, tableManager *TableManager, statManager *StatMa
 }
 
 func (im *IndexManager) CreateIndex(indexName string, tableName string, fieldName string, tx *tx.Transaction) error {
+	// keep the table from changing while the index is defined on it
+	if err := tx.LockFile(tableName+".tbl", concurrency.S); err != nil {
+		return fmt.Errorf("failed to lock table: %w", err)
+	}
+	layout, err := im.tableManager.GetLayout(tableName, tx)
+	if err != nil {
+		return fmt.Errorf("failed to get layout: %w", err)
+	}
+	// the files of an index of the same name dropped by the transaction
+	// are still there until it commits
+	for _, filename := range NewIndexInfo(indexName, fieldName, layout, tx, nil).FileNames() {
+		if tx.IsDeletedOnCommit(filename) {
+			return fmt.Errorf("create index %s: an index of the same name is dropped by the transaction", indexName)
+		}
+	}
 	ts, err := query.NewTableScan(tx, "idxcat", im.layout)
 	if err != nil {
 		return fmt.Errorf("failed to create table scan: %w", err)
@@ -93,7 +109,7 @@ func (im *IndexManager) GetIndexInfo(tableName string, tx *tx.Transaction) (map[
 			if err != nil {
 				return nil, fmt.Errorf("failed to get stat info: %w", err)
 			}
-			ii := NewIndexInfo(indexName, fieldName, layout.Schema(), tx, si)
+			ii := NewIndexInfo(indexName, fieldName, layout, tx, si)
 			result[fieldName] = ii
 		}
 		next, err = ts.Next()
@@ -104,3 +120,91 @@ func (im *IndexManager) GetIndexInfo(tableName string, tx *tx.Transaction) (map[
 	ts.Close()
 	return result, nil
 }
+
+// RenameField renames the field of the table the indexes are on.
+func (im *IndexManager) RenameField(tableName, oldName, newName string, tx *tx.Transaction) error {
+	return im.update(tx, func(ts *query.TableScan) error {
+		tableNameAtRecord, err := ts.GetString("tablename")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		fieldName, err := ts.GetString("fieldname")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		if tableNameAtRecord == tableName && fieldName == oldName {
+			if err := ts.SetString("fieldname", newName); err != nil {
+				return fmt.Errorf("failed to set string: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DropIndex removes the index from the catalog, and deletes its files when
+// the transaction commits.
+func (im *IndexManager) DropIndex(indexName string, tx *tx.Transaction) error {
+	var tableName, fieldName string
+	err := im.update(tx, func(ts *query.TableScan) error {
+		indexNameAtRecord, err := ts.GetString("indexname")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		if indexNameAtRecord != indexName {
+			return nil
+		}
+		tableName, err = ts.GetString("tablename")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		fieldName, err = ts.GetString("fieldname")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		if err := ts.Delete(); err != nil {
+			return fmt.Errorf("failed to delete: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if tableName == "" {
+		return fmt.Errorf("drop index %s: %w", indexName, ErrIndexNotFound)
+	}
+	layout, err := im.tableManager.GetLayout(tableName, tx)
+	if err != nil {
+		return fmt.Errorf("failed to get layout: %w", err)
+	}
+	// keep others from using the index while it goes
+	for _, filename := range []string{tableName + ".tbl", layout.FileName(tableName)} {
+		if err := tx.LockFile(filename, concurrency.X); err != nil {
+			return fmt.Errorf("failed to lock table: %w", err)
+		}
+	}
+	for _, filename := range NewIndexInfo(indexName, fieldName, layout, tx, nil).FileNames() {
+		tx.DeleteOnCommit(filename)
+	}
+	return nil
+}
+
+// update calls f on each record of idxcat.
+func (im *IndexManager) update(tx *tx.Transaction, f func(ts *query.TableScan) error) error {
+	ts, err := query.NewTableScan(tx, "idxcat", im.layout)
+	if err != nil {
+		return fmt.Errorf("failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	for {
+		next, err := ts.Next()
+		if err != nil {
+			return fmt.Errorf("failed to get next: %w", err)
+		}
+		if !next {
+			return nil
+		}
+		if err := f(ts); err != nil {
+			return err
+		}
+	}
+}
@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/adieumonks/simple-db/record"
 	"github.com/adieumonks/simple-db/tx"
@@ -14,7 +15,17 @@ type MetadataManager struct {
 	indexManager *IndexManager
 }
 
+// NewMetadataManager opens the catalog, creating it if the database is new
+// or its catalog is empty, and migrating it if it is from before catalog
+// versions were kept. Either needs a transaction that can write.
 func NewMetadataManager(isNew bool, tx *tx.Transaction) (*MetadataManager, error) {
+	if !isNew {
+		empty, err := catalogEmpty(tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read catalog: %w", err)
+		}
+		isNew = empty
+	}
 	tableManager, err := NewTableManager(isNew, tx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create table manager: %w", err)
@@ -39,14 +50,71 @@ func NewMetadataManager(isNew bool, tx *tx.Transaction) (*MetadataManager, error
 	}, nil
 }
 
+// IsCatalog reports whether the table is one of those of the catalog.
+func IsCatalog(tableName string) bool {
+	return slices.Contains([]string{"tblcat", "fldcat", "viewcat", "idxcat"}, tableName)
+}
+
+// SetMVCC chooses whether the tables created from now on keep versions of
+// their records, for multi-version concurrency control.
+func (mm *MetadataManager) SetMVCC(enabled bool) {
+	mm.tableManager.SetMVCC(enabled)
+}
+
 func (mm *MetadataManager) CreateTable(tableName string, schema *record.Schema, tx *tx.Transaction) error {
 	return mm.tableManager.CreateTable(tableName, schema, tx)
 }
 
+func (mm *MetadataManager) CreateTableWithFormat(tableName string, schema *record.Schema, format record.StorageFormat, tx *tx.Transaction) error {
+	return mm.tableManager.CreateTableWithFormat(tableName, schema, format, tx)
+}
+
 func (mm *MetadataManager) GetLayout(tableName string, tx *tx.Transaction) (*record.Layout, error) {
 	return mm.tableManager.GetLayout(tableName, tx)
 }
 
+// ChangeSchema replaces the schema of the table, returning the layout of
+// the new generation of its file, into which the caller copies the records.
+func (mm *MetadataManager) ChangeSchema(tableName string, schema *record.Schema, tx *tx.Transaction) (*record.Layout, error) {
+	layout, err := mm.tableManager.ChangeSchema(tableName, schema, tx)
+	if err != nil {
+		return nil, err
+	}
+	mm.statManager.forget(tableName)
+	return layout, nil
+}
+
+// RenameField renames a field of the table, and of the indexes on it.
+func (mm *MetadataManager) RenameField(tableName, oldName, newName string, tx *tx.Transaction) error {
+	if err := mm.tableManager.RenameField(tableName, oldName, newName, tx); err != nil {
+		return err
+	}
+	if err := mm.indexManager.RenameField(tableName, oldName, newName, tx); err != nil {
+		return err
+	}
+	mm.statManager.forget(tableName)
+	return nil
+}
+
+// DropTable removes the table and the indexes on it, deleting their files
+// when the transaction commits.
+func (mm *MetadataManager) DropTable(tableName string, tx *tx.Transaction) error {
+	indexes, err := mm.indexManager.GetIndexInfo(tableName, tx)
+	if err != nil {
+		return err
+	}
+	for _, ii := range indexes {
+		if err := mm.indexManager.DropIndex(ii.IndexName(), tx); err != nil {
+			return err
+		}
+	}
+	if err := mm.tableManager.DropTable(tableName, tx); err != nil {
+		return err
+	}
+	mm.statManager.forget(tableName)
+	return nil
+}
+
 func (mm *MetadataManager) CreateView(viewName string, viewDef string, tx *tx.Transaction) error {
 	return mm.viewManager.CreateView(viewName, viewDef, tx)
 }
@@ -55,10 +123,26 @@ func (mm *MetadataManager) GetViewDef(viewName string, tx *tx.Transaction) (stri
 	return mm.viewManager.GetViewDef(viewName, tx)
 }
 
+func (mm *MetadataManager) ViewDefs(tx *tx.Transaction) (map[string]string, error) {
+	return mm.viewManager.ViewDefs(tx)
+}
+
+func (mm *MetadataManager) SetViewDef(viewName string, viewDef string, tx *tx.Transaction) error {
+	return mm.viewManager.SetViewDef(viewName, viewDef, tx)
+}
+
+func (mm *MetadataManager) DropView(viewName string, tx *tx.Transaction) error {
+	return mm.viewManager.DropView(viewName, tx)
+}
+
 func (mm *MetadataManager) CreateIndex(indexName string, tableName string, fieldName string, tx *tx.Transaction) error {
 	return mm.indexManager.CreateIndex(indexName, tableName, fieldName, tx)
 }
 
+func (mm *MetadataManager) DropIndex(indexName string, tx *tx.Transaction) error {
+	return mm.indexManager.DropIndex(indexName, tx)
+}
+
 func (mm *MetadataManager) GetIndexInfo(tableName string, tx *tx.Transaction) (map[string]*IndexInfo, error) {
 	return mm.indexManager.GetIndexInfo(tableName, tx)
 }
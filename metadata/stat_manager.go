@@ -52,6 +52,15 @@ func (sm *StatManager) GetStatInfo(tableName string, layout *record.Layout, tx *
 	return si, nil
 }
 
+// forget drops the statistics of the table, to be calculated again when
+// they are next asked for.
+func (sm *StatManager) forget(tableName string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	delete(sm.tableStats, tableName)
+}
+
 func (sm *StatManager) refreshStatics(tx *tx.Transaction) error {
 	sm.tableStats = make(map[string]*StatInfo)
 	sm.numCalls = 0
@@ -6,15 +6,21 @@ import (
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
 	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
 )
 
 const (
 	MAX_NAME = 16
+	// CATALOG_VERSION is the version of the layouts of tblcat and fldcat,
+	// raised whenever they change
+	CATALOG_VERSION = 1
 )
 
 type TableManager struct {
 	tcatLayout *record.Layout
 	fcatLayout *record.Layout
+	// whether new tables keep versions of their records
+	mvcc bool
 }
 
 func NewTableManager(isNew bool, tx *tx.Transaction) (*TableManager, error) {
@@ -22,6 +28,9 @@ func NewTableManager(isNew bool, tx *tx.Transaction) (*TableManager, error) {
 	tcatSchema := record.NewSchema()
 	tcatSchema.AddStringField("tblname", MAX_NAME)
 	tcatSchema.AddIntField("slotsize")
+	tcatSchema.AddIntField("versioned")
+	tcatSchema.AddIntField("format")
+	tcatSchema.AddIntField("generation")
 	tm.tcatLayout = record.NewLayoutFromSchema(tcatSchema)
 
 	fcatSchema := record.NewSchema()
@@ -30,6 +39,8 @@ func NewTableManager(isNew bool, tx *tx.Transaction) (*TableManager, error) {
 	fcatSchema.AddIntField("type")
 	fcatSchema.AddIntField("length")
 	fcatSchema.AddIntField("offset")
+	fcatSchema.AddIntField("nullable")
+	fcatSchema.AddIntField("scale")
 	tm.fcatLayout = record.NewLayoutFromSchema(fcatSchema)
 
 	if isNew {
@@ -41,12 +52,222 @@ func NewTableManager(isNew bool, tx *tx.Transaction) (*TableManager, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create table: %w", err)
 		}
+		if err := writeCatalogVersion(tx); err != nil {
+			return nil, err
+		}
+	} else if err := tm.checkCatalogVersion(tx); err != nil {
+		return nil, err
 	}
 	return tm, nil
 }
 
+// SetMVCC chooses whether the tables created from now on keep versions of
+// their records, for multi-version concurrency control.
+func (tm *TableManager) SetMVCC(enabled bool) {
+	tm.mvcc = enabled
+}
+
 func (tm *TableManager) CreateTable(tableName string, schema *record.Schema, tx *tx.Transaction) error {
-	layout := record.NewLayoutFromSchema(schema)
+	return tm.CreateTableWithFormat(tableName, schema, record.FIXED, tx)
+}
+
+// CreateTableWithFormat creates a table whose records are stored in the
+// given format. Slotted and columnar records do not keep versions.
+func (tm *TableManager) CreateTableWithFormat(tableName string, schema *record.Schema, format record.StorageFormat, tx *tx.Transaction) error {
+	if err := tx.LockFile(tableName+".tbl", concurrency.X); err != nil {
+		return fmt.Errorf("failed to lock table: %w", err)
+	}
+	var layout *record.Layout
+	switch {
+	case format == record.SLOTTED:
+		layout = record.NewSlottedLayoutFromSchema(schema)
+	case format == record.COLUMNAR:
+		layout = record.NewColumnarLayoutFromSchema(schema)
+	case tm.mvcc:
+		layout = record.NewVersionedLayoutFromSchema(schema)
+	default:
+		layout = record.NewLayoutFromSchema(schema)
+	}
+	if err := layout.CheckFields(tx.BlockSize()); err != nil {
+		return err
+	}
+	// the catalog tables have records by the time they are recorded in
+	// themselves, and stay at generation 0
+	if !isCatalog(tableName) {
+		if err := freeGeneration(tableName, layout, 0, tx); err != nil {
+			return err
+		}
+	}
+	return tm.insertCatalog(tableName, layout, tx)
+}
+
+// ChangeSchema replaces the schema of the table in the catalog, keeping
+// how its records are stored, and returns the layout of the new records.
+// They go in a new generation of the file of the table, which is empty, so
+// the old records stay as they are if the transaction rolls back; the
+// caller copies them over.
+func (tm *TableManager) ChangeSchema(tableName string, schema *record.Schema, tx *tx.Transaction) (*record.Layout, error) {
+	old, err := tm.GetLayout(tableName, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layout: %w", err)
+	}
+	var layout *record.Layout
+	switch {
+	case old.Format() == record.SLOTTED:
+		layout = record.NewSlottedLayoutFromSchema(schema)
+	case old.Format() == record.COLUMNAR:
+		layout = record.NewColumnarLayoutFromSchema(schema)
+	case old.Versioned():
+		layout = record.NewVersionedLayoutFromSchema(schema)
+	default:
+		layout = record.NewLayoutFromSchema(schema)
+	}
+	if err := layout.CheckFields(tx.BlockSize()); err != nil {
+		return nil, err
+	}
+	if err := freeGeneration(tableName, layout, old.Generation()+1, tx); err != nil {
+		return nil, err
+	}
+	if err := tm.deleteCatalog(tableName, tx); err != nil {
+		return nil, err
+	}
+	if err := tm.insertCatalog(tableName, layout, tx); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// DropTable removes the table from the catalog, and deletes its files once
+// the transaction commits and no snapshot from before can read them.
+func (tm *TableManager) DropTable(tableName string, tx *tx.Transaction) error {
+	layout, err := tm.GetLayout(tableName, tx)
+	if err != nil {
+		return fmt.Errorf("failed to get layout: %w", err)
+	}
+	if len(layout.Schema().Fields()) == 0 {
+		return fmt.Errorf("drop table %s: %w", tableName, ErrTableNotFound)
+	}
+	for _, filename := range []string{tableName + ".tbl", layout.FileName(tableName)} {
+		if err := tx.LockFile(filename, concurrency.X); err != nil {
+			return fmt.Errorf("failed to lock table: %w", err)
+		}
+	}
+	if err := tm.deleteCatalog(tableName, tx); err != nil {
+		return err
+	}
+	for _, filename := range layout.FileNames(tableName) {
+		tx.DeleteOnCommit(filename)
+	}
+	return nil
+}
+
+func isCatalog(tableName string) bool {
+	return tableName == "tblcat" || tableName == "fldcat"
+}
+
+// freeGeneration sets the layout to the first generation from the given one
+// whose files are empty and stay, skipping those left over by transactions
+// that rolled back or crashed, or that are dropped by this one.
+func freeGeneration(tableName string, layout *record.Layout, from int32, tx *tx.Transaction) error {
+	for generation := from; ; generation++ {
+		layout.SetGeneration(generation)
+		free, err := filesFree(layout.FileNames(tableName), tx)
+		if err != nil {
+			return err
+		}
+		if free {
+			return nil
+		}
+	}
+}
+
+func filesFree(fileNames []string, tx *tx.Transaction) (bool, error) {
+	for _, fileName := range fileNames {
+		size, err := tx.Size(fileName)
+		if err != nil {
+			return false, fmt.Errorf("failed to get file size: %w", err)
+		}
+		if size != 0 || tx.IsDeletedOnCommit(fileName) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RenameField renames a field of the table in the catalog. The records
+// stay as they are, as they are laid out by the offsets of the fields.
+func (tm *TableManager) RenameField(tableName, oldName, newName string, tx *tx.Transaction) error {
+	fcat, err := query.NewTableScan(tx, "fldcat", tm.fcatLayout)
+	if err != nil {
+		return fmt.Errorf("failed to create table scan: %w", err)
+	}
+	defer fcat.Close()
+	for {
+		next, err := fcat.Next()
+		if err != nil {
+			return fmt.Errorf("failed to get next: %w", err)
+		}
+		if !next {
+			return nil
+		}
+		tableNameAtRecord, err := fcat.GetString("tblname")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		fieldName, err := fcat.GetString("fldname")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		if tableNameAtRecord == tableName && fieldName == oldName {
+			if err := fcat.SetString("fldname", newName); err != nil {
+				return fmt.Errorf("failed to set string: %w", err)
+			}
+		}
+	}
+}
+
+// deleteCatalog deletes the records of the table from tblcat and fldcat.
+func (tm *TableManager) deleteCatalog(tableName string, tx *tx.Transaction) error {
+	for _, cat := range []struct {
+		name   string
+		layout *record.Layout
+	}{
+		{"tblcat", tm.tcatLayout},
+		{"fldcat", tm.fcatLayout},
+	} {
+		ts, err := query.NewTableScan(tx, cat.name, cat.layout)
+		if err != nil {
+			return fmt.Errorf("failed to create table scan: %w", err)
+		}
+		for {
+			next, err := ts.Next()
+			if err != nil {
+				ts.Close()
+				return fmt.Errorf("failed to get next: %w", err)
+			}
+			if !next {
+				break
+			}
+			tableNameAtRecord, err := ts.GetString("tblname")
+			if err != nil {
+				ts.Close()
+				return fmt.Errorf("failed to get string: %w", err)
+			}
+			if tableNameAtRecord == tableName {
+				if err := ts.Delete(); err != nil {
+					ts.Close()
+					return fmt.Errorf("failed to delete: %w", err)
+				}
+			}
+		}
+		ts.Close()
+	}
+	return nil
+}
+
+// insertCatalog records the table with the layout in tblcat and fldcat.
+func (tm *TableManager) insertCatalog(tableName string, layout *record.Layout, tx *tx.Transaction) error {
+	schema := layout.Schema()
 	tcat, err := query.NewTableScan(tx, "tblcat", tm.tcatLayout)
 	if err != nil {
 		return fmt.Errorf("failed to create table scan: %w", err)
@@ -63,6 +284,22 @@ func (tm *TableManager) CreateTable(tableName string, schema *record.Schema, tx
 	if err != nil {
 		return fmt.Errorf("failed to set int: %w", err)
 	}
+	versioned := int32(0)
+	if layout.Versioned() {
+		versioned = 1
+	}
+	err = tcat.SetInt("versioned", versioned)
+	if err != nil {
+		return fmt.Errorf("failed to set int: %w", err)
+	}
+	err = tcat.SetInt("format", int32(layout.Format()))
+	if err != nil {
+		return fmt.Errorf("failed to set int: %w", err)
+	}
+	err = tcat.SetInt("generation", layout.Generation())
+	if err != nil {
+		return fmt.Errorf("failed to set int: %w", err)
+	}
 	tcat.Close()
 
 	fcat, err := query.NewTableScan(tx, "fldcat", tm.fcatLayout)
@@ -94,6 +331,18 @@ func (tm *TableManager) CreateTable(tableName string, schema *record.Schema, tx
 		if err != nil {
 			return fmt.Errorf("failed to set int: %w", err)
 		}
+		nullable := int32(0)
+		if schema.Nullable(filedName) {
+			nullable = 1
+		}
+		err = fcat.SetInt("nullable", nullable)
+		if err != nil {
+			return fmt.Errorf("failed to set int: %w", err)
+		}
+		err = fcat.SetInt("scale", schema.Scale(filedName))
+		if err != nil {
+			return fmt.Errorf("failed to set int: %w", err)
+		}
 	}
 	fcat.Close()
 	return nil
@@ -101,6 +350,9 @@ func (tm *TableManager) CreateTable(tableName string, schema *record.Schema, tx
 
 func (tm *TableManager) GetLayout(tableName string, tx *tx.Transaction) (*record.Layout, error) {
 	slotSize := int32(-1)
+	versioned := int32(0)
+	format := int32(0)
+	generation := int32(0)
 	tcat, err := query.NewTableScan(tx, "tblcat", tm.tcatLayout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create table scan: %w", err)
@@ -121,6 +373,18 @@ func (tm *TableManager) GetLayout(tableName string, tx *tx.Transaction) (*record
 			if err != nil {
 				return nil, fmt.Errorf("failed to get int: %w", err)
 			}
+			versioned, err = tcat.GetInt("versioned")
+			if err != nil {
+				return nil, fmt.Errorf("failed to get int: %w", err)
+			}
+			format, err = tcat.GetInt("format")
+			if err != nil {
+				return nil, fmt.Errorf("failed to get int: %w", err)
+			}
+			generation, err = tcat.GetInt("generation")
+			if err != nil {
+				return nil, fmt.Errorf("failed to get int: %w", err)
+			}
 			break
 		}
 		next, err = tcat.Next()
@@ -162,7 +426,17 @@ func (tm *TableManager) GetLayout(tableName string, tx *tx.Transaction) (*record
 			if err != nil {
 				return nil, fmt.Errorf("failed to get int: %w", err)
 			}
+			nullable, err := fcat.GetInt("nullable")
+			if err != nil {
+				return nil, fmt.Errorf("failed to get int: %w", err)
+			}
+			scale, err := fcat.GetInt("scale")
+			if err != nil {
+				return nil, fmt.Errorf("failed to get int: %w", err)
+			}
 			schema.AddField(fieldName, record.FieldType(fieldType), length)
+			schema.SetNullable(fieldName, nullable != 0)
+			schema.SetScale(fieldName, scale)
 			offsets[fieldName] = offset
 		}
 		next, err = fcat.Next()
@@ -171,5 +445,17 @@ func (tm *TableManager) GetLayout(tableName string, tx *tx.Transaction) (*record
 		}
 	}
 	fcat.Close()
-	return record.NewLayout(schema, offsets, slotSize), nil
+	var layout *record.Layout
+	switch {
+	case versioned != 0:
+		layout = record.NewVersionedLayout(schema, offsets, slotSize)
+	case record.StorageFormat(format) == record.SLOTTED:
+		layout = record.NewSlottedLayout(schema, offsets, slotSize)
+	case record.StorageFormat(format) == record.COLUMNAR:
+		layout = record.NewColumnarLayout(schema, offsets, slotSize)
+	default:
+		layout = record.NewLayout(schema, offsets, slotSize)
+	}
+	layout.SetGeneration(generation)
+	return layout, nil
 }
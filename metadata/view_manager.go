@@ -90,3 +90,103 @@ func (vm *ViewManager) GetViewDef(viewName string, tx *tx.Transaction) (string,
 	ts.Close()
 	return result, nil
 }
+
+// ViewDefs returns the definitions of all the views by their names.
+func (vm *ViewManager) ViewDefs(tx *tx.Transaction) (map[string]string, error) {
+	result := make(map[string]string)
+	layout, err := vm.tableManager.GetLayout("viewcat", tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layout: %w", err)
+	}
+	ts, err := query.NewTableScan(tx, "viewcat", layout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	for {
+		next, err := ts.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next: %w", err)
+		}
+		if !next {
+			return result, nil
+		}
+		viewName, err := ts.GetString("viewname")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get string: %w", err)
+		}
+		viewDef, err := ts.GetString("viewdef")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get string: %w", err)
+		}
+		result[viewName] = viewDef
+	}
+}
+
+// SetViewDef replaces the definition of the view.
+func (vm *ViewManager) SetViewDef(viewName string, viewDef string, tx *tx.Transaction) error {
+	layout, err := vm.tableManager.GetLayout("viewcat", tx)
+	if err != nil {
+		return fmt.Errorf("failed to get layout: %w", err)
+	}
+	ts, err := query.NewTableScan(tx, "viewcat", layout)
+	if err != nil {
+		return fmt.Errorf("failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	for {
+		next, err := ts.Next()
+		if err != nil {
+			return fmt.Errorf("failed to get next: %w", err)
+		}
+		if !next {
+			return nil
+		}
+		viewNameAtRecord, err := ts.GetString("viewname")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		if viewNameAtRecord == viewName {
+			if err := ts.SetString("viewdef", viewDef); err != nil {
+				return fmt.Errorf("failed to set string: %w", err)
+			}
+		}
+	}
+}
+
+// DropView removes the view from the catalog.
+func (vm *ViewManager) DropView(viewName string, tx *tx.Transaction) error {
+	layout, err := vm.tableManager.GetLayout("viewcat", tx)
+	if err != nil {
+		return fmt.Errorf("failed to get layout: %w", err)
+	}
+	ts, err := query.NewTableScan(tx, "viewcat", layout)
+	if err != nil {
+		return fmt.Errorf("failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	found := false
+	for {
+		next, err := ts.Next()
+		if err != nil {
+			return fmt.Errorf("failed to get next: %w", err)
+		}
+		if !next {
+			break
+		}
+		viewNameAtRecord, err := ts.GetString("viewname")
+		if err != nil {
+			return fmt.Errorf("failed to get string: %w", err)
+		}
+		if viewNameAtRecord == viewName {
+			if err := ts.Delete(); err != nil {
+				return fmt.Errorf("failed to delete: %w", err)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("drop view %s: %w", viewName, ErrViewNotFound)
+	}
+	return nil
+}
@@ -88,19 +88,11 @@ func (s *ChunkScan) GetString(fieldName string) (string, error) {
 }
 
 func (s *ChunkScan) GetVal(fieldName string) (*query.Constant, error) {
-	if s.layout.Schema().Type(fieldName) == record.INTEGER {
-		ival, err := s.GetInt(fieldName)
-		if err != nil {
-			return nil, err
-		}
-		return query.NewConstantWithInt(ival), nil
-	} else {
-		sval, err := s.GetString(fieldName)
-		if err != nil {
-			return nil, err
-		}
-		return query.NewConstantWithString(sval), nil
+	val, err := s.rp.GetValue(s.currentSlot, fieldName)
+	if err != nil {
+		return nil, err
 	}
+	return query.NewFieldConstant(s.layout.Schema(), fieldName, val), nil
 }
 
 func (s *ChunkScan) HasField(fieldName string) bool {
@@ -173,14 +173,12 @@ func (sp *MultiBufferSortPlan) splitIntoRuns(src query.Scan, k int32) ([]*materi
 			if err != nil {
 				return nil, err
 			}
-			if sp.sch.Type(fieldName) == record.INTEGER {
-				if err := rp.SetInt(nextSlot, fieldName, val.AsInt()); err != nil {
-					return nil, err
-				}
-			} else {
-				if err := rp.SetString(nextSlot, fieldName, val.AsString()); err != nil {
-					return nil, err
-				}
+			v, err := val.Value(sp.sch, fieldName)
+			if err != nil {
+				return nil, err
+			}
+			if err := rp.SetValue(nextSlot, fieldName, v); err != nil {
+				return nil, err
 			}
 		}
 
@@ -256,34 +254,16 @@ func (sp *MultiBufferSortPlan) compareAcrossBuffers(buffers []*record.RecordPage
 		return 0, err
 	}
 	for _, fieldName := range fields {
-		if sp.sch.Type(fieldName) == record.INTEGER {
-			val1, err := rp1.GetInt(offset1, fieldName)
-			if err != nil {
-				return 0, err
-			}
-			val2, err := rp2.GetInt(offset2, fieldName)
-			if err != nil {
-				return 0, err
-			}
-			if val1 < val2 {
-				return -1, nil
-			} else if val1 > val2 {
-				return 1, nil
-			}
-		} else {
-			val1, err := rp1.GetString(offset1, fieldName)
-			if err != nil {
-				return 0, err
-			}
-			val2, err := rp2.GetString(offset2, fieldName)
-			if err != nil {
-				return 0, err
-			}
-			if val1 < val2 {
-				return -1, nil
-			} else if val1 > val2 {
-				return 1, nil
-			}
+		val1, err := rp1.GetValue(offset1, fieldName)
+		if err != nil {
+			return 0, err
+		}
+		val2, err := rp2.GetValue(offset2, fieldName)
+		if err != nil {
+			return 0, err
+		}
+		if c := record.CompareValues(val1, val2); c != 0 {
+			return int32(c), nil
 		}
 	}
 	return 0, nil
@@ -299,36 +279,19 @@ func (sp *MultiBufferSortPlan) swapAcrossBuffers(buffers []*record.RecordPage, s
 		return err
 	}
 	for _, fieldName := range sp.sch.Fields() {
-		if sp.sch.Type(fieldName) == record.INTEGER {
-			val1, err := rp1.GetInt(offset1, fieldName)
-			if err != nil {
-				return err
-			}
-			val2, err := rp2.GetInt(offset2, fieldName)
-			if err != nil {
-				return err
-			}
-			if err := rp1.SetInt(offset1, fieldName, val2); err != nil {
-				return err
-			}
-			if err := rp2.SetInt(offset2, fieldName, val1); err != nil {
-				return err
-			}
-		} else {
-			val1, err := rp1.GetString(offset1, fieldName)
-			if err != nil {
-				return err
-			}
-			val2, err := rp2.GetString(offset2, fieldName)
-			if err != nil {
-				return err
-			}
-			if err := rp1.SetString(offset1, fieldName, val2); err != nil {
-				return err
-			}
-			if err := rp2.SetString(offset2, fieldName, val1); err != nil {
-				return err
-			}
+		val1, err := rp1.GetValue(offset1, fieldName)
+		if err != nil {
+			return err
+		}
+		val2, err := rp2.GetValue(offset2, fieldName)
+		if err != nil {
+			return err
+		}
+		if err := rp1.SetValue(offset1, fieldName, val2); err != nil {
+			return err
+		}
+		if err := rp2.SetValue(offset2, fieldName, val1); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -365,22 +328,12 @@ func (sp *MultiBufferSortPlan) copyFromRecordPageToRun(buffers []*record.RecordP
 				return err
 			}
 			for _, fieldName := range sp.sch.Fields() {
-				if sp.sch.Type(fieldName) == record.INTEGER {
-					val, err := rp.GetInt(slot, fieldName)
-					if err != nil {
-						return err
-					}
-					if err := dest.SetInt(fieldName, val); err != nil {
-						return err
-					}
-				} else {
-					val, err := rp.GetString(slot, fieldName)
-					if err != nil {
-						return err
-					}
-					if err := dest.SetString(fieldName, val); err != nil {
-						return err
-					}
+				val, err := rp.GetValue(slot, fieldName)
+				if err != nil {
+					return err
+				}
+				if err := dest.SetVal(fieldName, query.NewFieldConstant(sp.sch, fieldName, val)); err != nil {
+					return err
 				}
 			}
 		}
@@ -1,8 +1,6 @@
 package multibuffer
 
 import (
-	"fmt"
-
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
 	"github.com/adieumonks/simple-db/tx"
@@ -26,7 +24,7 @@ func NewMultibufferProductScan(tx *tx.Transaction, lhsScan query.Scan, tableName
 	s := &MultibufferProductScan{
 		tx:       tx,
 		lhsScan:  lhsScan,
-		fileName: fmt.Sprintf("%s.tbl", tableName),
+		fileName: layout.FileName(tableName),
 		layout:   layout,
 	}
 	fileSize, err := s.tx.Size(s.fileName)
@@ -2,16 +2,23 @@ package parse
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/tx"
 )
 
 type QueryData struct {
+	// the names of the fields selected
 	Fields []string
+	// the expressions of the computed fields, by their names
+	Exprs  map[string]*query.Expression
 	Tables []string
 	Pred   *query.Predicate
+	// how the records read are locked, as given by a FOR UPDATE clause
+	LockPolicy query.LockPolicy
 }
 
 func NewQueryData(fields, tables []string, pred *query.Predicate) *QueryData {
@@ -21,15 +28,58 @@ func NewQueryData(fields, tables []string, pred *query.Predicate) *QueryData {
 func (q *QueryData) String() string {
 	var sb strings.Builder
 
-	fmt.Fprintf(&sb, "select %s from %s", strings.Join(q.Fields, ", "), strings.Join(q.Tables, ", "))
+	var fields []string
+	for _, field := range q.Fields {
+		if expr, ok := q.Exprs[field]; ok && expr.String() != field {
+			field = fmt.Sprintf("%s as %s", expr, field)
+		}
+		fields = append(fields, field)
+	}
+	fmt.Fprintf(&sb, "select %s from %s", strings.Join(fields, ", "), strings.Join(q.Tables, ", "))
 
 	if pred := q.Pred.String(); pred != "" {
 		fmt.Fprintf(&sb, " where %s", pred)
 	}
 
+	if q.LockPolicy != query.READ {
+		fmt.Fprintf(&sb, " %s", q.LockPolicy)
+	}
+
 	return sb.String()
 }
 
+// UsesField reports whether the query selects the field, or uses it to
+// compute a field or in its predicate.
+func (q *QueryData) UsesField(fieldName string) bool {
+	if _, ok := q.Exprs[fieldName]; !ok && slices.Contains(q.Fields, fieldName) {
+		return true
+	}
+	for _, expr := range q.Exprs {
+		if expr.UsesField(fieldName) {
+			return true
+		}
+	}
+	return q.Pred.UsesField(fieldName)
+}
+
+// RenameField renames the field wherever the query uses it, and reports
+// whether it is selected, so that the field of the query is renamed too.
+func (q *QueryData) RenameField(oldName, newName string) bool {
+	for _, expr := range q.Exprs {
+		expr.RenameField(oldName, newName)
+	}
+	q.Pred.RenameField(oldName, newName)
+	if _, ok := q.Exprs[oldName]; ok {
+		return false
+	}
+	i := slices.Index(q.Fields, oldName)
+	if i < 0 {
+		return false
+	}
+	q.Fields[i] = newName
+	return true
+}
+
 type UpdateCommandType int
 
 const (
@@ -39,6 +89,11 @@ const (
 	CreateTable
 	CreateView
 	CreateIndex
+	SetIsolationLevel
+	AlterTable
+	DropTable
+	DropView
+	DropIndex
 )
 
 type UpdateCommand interface {
@@ -76,6 +131,31 @@ func (*CreateIndexData) CommandType() UpdateCommandType {
 	return CreateIndex
 }
 
+func (*SetIsolationLevelData) updateCommand() {}
+func (*SetIsolationLevelData) CommandType() UpdateCommandType {
+	return SetIsolationLevel
+}
+
+func (*AlterTableData) updateCommand() {}
+func (*AlterTableData) CommandType() UpdateCommandType {
+	return AlterTable
+}
+
+func (*DropTableData) updateCommand() {}
+func (*DropTableData) CommandType() UpdateCommandType {
+	return DropTable
+}
+
+func (*DropViewData) updateCommand() {}
+func (*DropViewData) CommandType() UpdateCommandType {
+	return DropView
+}
+
+func (*DropIndexData) updateCommand() {}
+func (*DropIndexData) CommandType() UpdateCommandType {
+	return DropIndex
+}
+
 type InsertData struct {
 	TableName string
 	Fields    []string
@@ -109,6 +189,8 @@ func NewDeleteData(tableName string, pred *query.Predicate) *DeleteData {
 type CreateTableData struct {
 	TableName string
 	Schema    *record.Schema
+	// how the records are stored, as given by a USING clause
+	Format record.StorageFormat
 }
 
 func NewCreateTableData(tableName string, schema *record.Schema) *CreateTableData {
@@ -137,3 +219,71 @@ type CreateIndexData struct {
 func NewCreateIndexData(indexName, tableName, fieldName string) *CreateIndexData {
 	return &CreateIndexData{IndexName: indexName, TableName: tableName, FieldName: fieldName}
 }
+
+type SetIsolationLevelData struct {
+	Level tx.IsolationLevel
+}
+
+func NewSetIsolationLevelData(level tx.IsolationLevel) *SetIsolationLevelData {
+	return &SetIsolationLevelData{Level: level}
+}
+
+// AlterAction is what ALTER TABLE does to a column.
+type AlterAction int
+
+const (
+	ADD_COLUMN AlterAction = iota
+	DROP_COLUMN
+	RENAME_COLUMN
+)
+
+type AlterTableData struct {
+	TableName string
+	Action    AlterAction
+	// the column added by ADD COLUMN
+	Schema *record.Schema
+	// the column dropped or renamed, and its new name
+	FieldName string
+	NewName   string
+}
+
+func NewAddColumnData(tableName string, schema *record.Schema) *AlterTableData {
+	return &AlterTableData{TableName: tableName, Action: ADD_COLUMN, Schema: schema}
+}
+
+func NewDropColumnData(tableName, fieldName string) *AlterTableData {
+	return &AlterTableData{TableName: tableName, Action: DROP_COLUMN, FieldName: fieldName}
+}
+
+func NewRenameColumnData(tableName, fieldName, newName string) *AlterTableData {
+	return &AlterTableData{TableName: tableName, Action: RENAME_COLUMN, FieldName: fieldName, NewName: newName}
+}
+
+type DropTableData struct {
+	TableName string
+	// whether the views on the table are dropped too, rather than keeping
+	// it from being dropped
+	Cascade bool
+}
+
+func NewDropTableData(tableName string, cascade bool) *DropTableData {
+	return &DropTableData{TableName: tableName, Cascade: cascade}
+}
+
+type DropViewData struct {
+	ViewName string
+	// whether the views on the view are dropped too
+	Cascade bool
+}
+
+func NewDropViewData(viewName string, cascade bool) *DropViewData {
+	return &DropViewData{ViewName: viewName, Cascade: cascade}
+}
+
+type DropIndexData struct {
+	IndexName string
+}
+
+func NewDropIndexData(indexName string) *DropIndexData {
+	return &DropIndexData{IndexName: indexName}
+}
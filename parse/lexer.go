@@ -13,6 +13,7 @@ const (
 	tokenKindEOF tokenKind = iota
 	tokenKindDelimiter
 	tokenKindInteger
+	tokenKindDouble
 	tokenKindString
 	tokenKindKeyword
 	tokenKindIdentifier
@@ -20,11 +21,15 @@ const (
 
 const whiteSpaces = " \t\n\r"
 
+// operators are the delimiters written with two characters.
+var operators = []string{"<=", ">=", "<>", "!=", "||"}
+
 var keywords = map[string]struct{}{
 	"select":  {},
 	"from":    {},
 	"where":   {},
 	"and":     {},
+	"or":      {},
 	"insert":  {},
 	"into":    {},
 	"values":  {},
@@ -39,6 +44,31 @@ var keywords = map[string]struct{}{
 	"as":      {},
 	"index":   {},
 	"on":      {},
+	// CREATE TABLE ... USING SLOTTED, USING COLUMNAR
+	"using": {},
+	// NULL, IS [NOT] NULL, NOT NULL, and NOT of a predicate
+	"null": {},
+	"is":   {},
+	"not":  {},
+	// field types and their literals
+	"bigint":    {},
+	"boolean":   {},
+	"double":    {},
+	"date":      {},
+	"timestamp": {},
+	"decimal":   {},
+	"true":      {},
+	"false":     {},
+	// ALTER TABLE ... ADD, DROP or RENAME COLUMN
+	"alter":  {},
+	"add":    {},
+	"column": {},
+	"drop":   {},
+	"rename": {},
+	"to":     {},
+	// DROP ... CASCADE or RESTRICT
+	"cascade":  {},
+	"restrict": {},
 }
 
 type token struct {
@@ -72,10 +102,20 @@ func (l *Lexer) MatchDelim(d rune) bool {
 	return l.token.kind == tokenKindDelimiter && l.token.value == string(d)
 }
 
+// MatchOperator reports whether the token is the operator, which is a
+// delimiter such as < or <=.
+func (l *Lexer) MatchOperator(op string) bool {
+	return l.token.kind == tokenKindDelimiter && l.token.value == op
+}
+
 func (l *Lexer) MatchIntConstant() bool {
 	return l.token.kind == tokenKindInteger
 }
 
+func (l *Lexer) MatchDoubleConstant() bool {
+	return l.token.kind == tokenKindDouble
+}
+
 func (l *Lexer) MatchStringConstant() bool {
 	return l.token.kind == tokenKindString
 }
@@ -88,6 +128,12 @@ func (l *Lexer) MatchIdentifier() bool {
 	return l.token.kind == tokenKindIdentifier
 }
 
+// MatchWord reports whether the token is the word, which is not reserved
+// as a keyword but has a meaning where the parser expects it.
+func (l *Lexer) MatchWord(w string) bool {
+	return l.token.kind == tokenKindIdentifier && l.token.value == w
+}
+
 func (l *Lexer) EatDelim(d rune) error {
 	if !l.MatchDelim(d) {
 		return NewBadSyntaxError(fmt.Sprintf("expected %q, but got %q", d, l.token.value))
@@ -100,6 +146,18 @@ func (l *Lexer) EatDelim(d rune) error {
 	return nil
 }
 
+func (l *Lexer) EatOperator(op string) error {
+	if !l.MatchOperator(op) {
+		return NewBadSyntaxError(fmt.Sprintf("expected %q, but got %q", op, l.token.value))
+	}
+
+	if err := l.nextToken(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (l *Lexer) EatIntConstant() (int32, error) {
 	if !l.MatchIntConstant() {
 		return 0, NewBadSyntaxError(fmt.Sprintf("expected integer, but got %q", l.token.value))
@@ -117,6 +175,57 @@ func (l *Lexer) EatIntConstant() (int32, error) {
 	return int32(value), nil
 }
 
+// EatLongConstant eats an integer too large for EatIntConstant.
+func (l *Lexer) EatLongConstant() (int64, error) {
+	if !l.MatchIntConstant() {
+		return 0, NewBadSyntaxError(fmt.Sprintf("expected integer, but got %q", l.token.value))
+	}
+
+	value, err := strconv.ParseInt(l.token.value, 10, 64)
+	if err != nil {
+		return 0, NewBadSyntaxError(fmt.Sprintf("integer %s out of range", l.token.value))
+	}
+
+	if err := l.nextToken(); err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+func (l *Lexer) EatDoubleConstant() (float64, error) {
+	if !l.MatchDoubleConstant() {
+		return 0, NewBadSyntaxError(fmt.Sprintf("expected double, but got %q", l.token.value))
+	}
+
+	value, err := strconv.ParseFloat(l.token.value, 64)
+	if err != nil {
+		return 0, NewBadSyntaxError(fmt.Sprintf("double %s out of range", l.token.value))
+	}
+
+	if err := l.nextToken(); err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+// EatDecimalConstant returns a number with a fractional part as it is
+// written.
+func (l *Lexer) EatDecimalConstant() (string, error) {
+	if !l.MatchDoubleConstant() {
+		return "", NewBadSyntaxError(fmt.Sprintf("expected decimal, but got %q", l.token.value))
+	}
+
+	value := l.token.value
+
+	if err := l.nextToken(); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
 func (l *Lexer) EatStringConstant() (string, error) {
 	if !l.MatchStringConstant() {
 		return "", NewBadSyntaxError(fmt.Sprintf("expected string, but got %q", l.token.value))
@@ -143,6 +252,18 @@ func (l *Lexer) EatKeyword(w string) error {
 	return nil
 }
 
+func (l *Lexer) EatWord(w string) error {
+	if !l.MatchWord(w) {
+		return NewBadSyntaxError(fmt.Sprintf("expected %q, but got %q", w, l.token.value))
+	}
+
+	if err := l.nextToken(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (l *Lexer) EatIdentifier() (string, error) {
 	if !l.MatchIdentifier() {
 		return "", NewBadSyntaxError(fmt.Sprintf("expected identifier, but got %q", l.token.value))
@@ -180,13 +301,21 @@ func (l *Lexer) nextToken() error {
 	}
 }
 
+// readInteger reads an integer, or a double if it has a fractional part.
 func (l *Lexer) readInteger() error {
 	pos := 1
 	for ; pos < len(l.input) && isDigit(l.input[pos]); pos++ {
 	}
 
+	kind := tokenKindInteger
+	if pos+1 < len(l.input) && l.input[pos] == '.' && isDigit(l.input[pos+1]) {
+		kind = tokenKindDouble
+		for pos++; pos < len(l.input) && isDigit(l.input[pos]); pos++ {
+		}
+	}
+
 	l.token = &token{
-		kind:  tokenKindInteger,
+		kind:  kind,
 		value: l.input[:pos],
 	}
 
@@ -235,6 +364,11 @@ func (l *Lexer) readIdentifier() error {
 
 func (l *Lexer) readDelimiter() error {
 	_, size := utf8.DecodeRuneInString(l.input)
+	for _, op := range operators {
+		if strings.HasPrefix(l.input, op) {
+			size = len(op)
+		}
+	}
 
 	l.token = &token{
 		kind:  tokenKindDelimiter,
@@ -1,8 +1,13 @@
 package parse
 
 import (
+	"fmt"
+	"math"
+	"strconv"
+
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/tx"
 )
 
 type Parser struct {
@@ -23,35 +28,246 @@ func (p *Parser) Field() (string, error) {
 }
 
 func (p *Parser) Constant() (*query.Constant, error) {
-	if p.lex.MatchStringConstant() {
+	switch {
+	case p.lex.MatchKeyword("null"):
+		if err := p.lex.EatKeyword("null"); err != nil {
+			return nil, err
+		}
+		return query.NewNullConstant(), nil
+	case p.lex.MatchKeyword("true"), p.lex.MatchKeyword("false"):
+		value := p.lex.MatchKeyword("true")
+		if err := p.lex.EatKeyword(strconv.FormatBool(value)); err != nil {
+			return nil, err
+		}
+		return query.NewConstantWithBool(value), nil
+	case p.lex.MatchKeyword("date"), p.lex.MatchKeyword("timestamp"):
+		return p.timeConstant()
+	case p.lex.MatchStringConstant():
 		value, err := p.lex.EatStringConstant()
 		if err != nil {
 			return nil, err
 		}
 		return query.NewConstantWithString(value), nil
-	} else {
-		value, err := p.lex.EatIntConstant()
+	case p.lex.MatchDelim('-'):
+		if err := p.lex.EatDelim('-'); err != nil {
+			return nil, err
+		}
+		return p.number(true)
+	default:
+		return p.number(false)
+	}
+}
+
+// number parses an integer, which is a bigint if it does not fit in an int,
+// or a number with a point, which is exact unless it has too many digits.
+func (p *Parser) number(negative bool) (*query.Constant, error) {
+	if p.lex.MatchDoubleConstant() {
+		value, err := p.lex.EatDecimalConstant()
 		if err != nil {
 			return nil, err
 		}
-		return query.NewConstantWithInt(value), nil
+		if negative {
+			value = "-" + value
+		}
+		if d, err := query.ParseDecimal(value); err == nil {
+			return query.NewConstantWithDecimal(d), nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, NewBadSyntaxError(fmt.Sprintf("number %s out of range", value))
+		}
+		return query.NewConstantWithDouble(f), nil
 	}
+	value, err := p.lex.EatLongConstant()
+	if err != nil {
+		return nil, err
+	}
+	if negative {
+		value = -value
+	}
+	if value < math.MinInt32 || value > math.MaxInt32 {
+		return query.NewConstantWithLong(value), nil
+	}
+	return query.NewConstantWithInt(int32(value)), nil
 }
 
+// timeConstant parses DATE 'yyyy-mm-dd' or TIMESTAMP 'yyyy-mm-dd hh:mm:ss'.
+func (p *Parser) timeConstant() (*query.Constant, error) {
+	parseTime := query.ParseTimestamp
+	keyword := "timestamp"
+	if p.lex.MatchKeyword("date") {
+		parseTime = query.ParseDate
+		keyword = "date"
+	}
+	if err := p.lex.EatKeyword(keyword); err != nil {
+		return nil, err
+	}
+	value, err := p.lex.EatStringConstant()
+	if err != nil {
+		return nil, err
+	}
+	c, err := parseTime(value)
+	if err != nil {
+		return nil, NewBadSyntaxError(err.Error())
+	}
+	return c, nil
+}
+
+var additiveOperators = map[rune]query.ArithmeticOperator{
+	'+': query.ADD,
+	'-': query.SUB,
+}
+
+var multiplicativeOperators = map[rune]query.ArithmeticOperator{
+	'*': query.MUL,
+	'/': query.DIV,
+	'%': query.MOD,
+}
+
+// Expression parses terms joined by + and -, which bind less tightly than
+// *, / and %, which bind less tightly than unary minus. Operators of the
+// same precedence associate to the left.
+// Expression parses the concatenation of arithmetic expressions with ||,
+// which binds less tightly than + and -.
 func (p *Parser) Expression() (*query.Expression, error) {
-	if p.lex.MatchIdentifier() {
-		field, err := p.lex.EatIdentifier()
+	expr, err := p.arithmeticExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.lex.MatchOperator("||") {
+		if err := p.lex.EatOperator("||"); err != nil {
+			return nil, err
+		}
+		rhs, err := p.arithmeticExpression()
 		if err != nil {
 			return nil, err
 		}
-		return query.NewExpressionFromField(field), nil
-	} else {
-		constant, err := p.Constant()
+		expr = query.NewFunctionExpression(query.ConcatFunction(), expr, rhs)
+	}
+	return expr, nil
+}
+
+func (p *Parser) arithmeticExpression() (*query.Expression, error) {
+	return p.binaryExpression(additiveOperators, func() (*query.Expression, error) {
+		return p.binaryExpression(multiplicativeOperators, p.unaryExpression)
+	})
+}
+
+func (p *Parser) binaryExpression(operators map[rune]query.ArithmeticOperator, operand func() (*query.Expression, error)) (*query.Expression, error) {
+	expr, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		d, op, ok := p.matchOperator(operators)
+		if !ok {
+			return expr, nil
+		}
+		if err := p.lex.EatDelim(d); err != nil {
+			return nil, err
+		}
+		rhs, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		expr = query.NewArithmeticExpression(expr, op, rhs)
+	}
+}
+
+func (p *Parser) matchOperator(operators map[rune]query.ArithmeticOperator) (rune, query.ArithmeticOperator, bool) {
+	for d, op := range operators {
+		if p.lex.MatchDelim(d) {
+			return d, op, true
+		}
+	}
+	return 0, 0, false
+}
+
+// unaryExpression parses a field, a constant, a function call, an
+// expression in parentheses, or the negation of one. A minus before a
+// number is part of it.
+func (p *Parser) unaryExpression() (*query.Expression, error) {
+	switch {
+	case p.lex.MatchDelim('-'):
+		if err := p.lex.EatDelim('-'); err != nil {
+			return nil, err
+		}
+		if p.lex.MatchIntConstant() || p.lex.MatchDoubleConstant() {
+			constant, err := p.number(true)
+			if err != nil {
+				return nil, err
+			}
+			return query.NewExpressionFromConstant(constant), nil
+		}
+		expr, err := p.unaryExpression()
 		if err != nil {
 			return nil, err
 		}
-		return query.NewExpressionFromConstant(constant), nil
+		return query.NewNegatedExpression(expr), nil
+	case p.lex.MatchDelim('('):
+		if err := p.lex.EatDelim('('); err != nil {
+			return nil, err
+		}
+		expr, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatDelim(')'); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case p.lex.MatchIdentifier():
+		field, err := p.lex.EatIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		if p.lex.MatchDelim('(') {
+			return p.functionCall(field)
+		}
+		return query.NewExpressionFromField(field), nil
+	}
+
+	constant, err := p.Constant()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewExpressionFromConstant(constant), nil
+}
+
+// functionCall parses the arguments of the function of the name, which
+// the registry of functions must know and which must take their number.
+func (p *Parser) functionCall(name string) (*query.Expression, error) {
+	fn, ok := query.LookupFunction(name)
+	if !ok {
+		return nil, NewBadSyntaxError(fmt.Sprintf("unknown function %q", name))
+	}
+	if err := p.lex.EatDelim('('); err != nil {
+		return nil, err
+	}
+	var args []*query.Expression
+	if !p.lex.MatchDelim(')') {
+		for {
+			arg, err := p.Expression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.lex.MatchDelim(',') {
+				break
+			}
+			if err := p.lex.EatDelim(','); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.lex.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	if !fn.Accepts(len(args)) {
+		return nil, NewBadSyntaxError(fmt.Sprintf("wrong number of arguments to %s: %d", name, len(args)))
 	}
+	return query.NewFunctionExpression(fn, args...), nil
 }
 
 func (p *Parser) Term() (*query.Term, error) {
@@ -59,45 +275,155 @@ func (p *Parser) Term() (*query.Term, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := p.lex.EatDelim('='); err != nil {
+	if p.lex.MatchKeyword("is") {
+		return p.nullTest(lhs)
+	}
+	op, err := p.comparison()
+	if err != nil {
 		return nil, err
 	}
 	rhs, err := p.Expression()
 	if err != nil {
 		return nil, err
 	}
-	return query.NewTerm(lhs, rhs), nil
+	return query.NewComparisonTerm(lhs, op, rhs), nil
+}
+
+var comparisons = []struct {
+	symbol string
+	op     query.Operator
+}{
+	{"=", query.EQ},
+	{"<>", query.NE},
+	{"!=", query.NE},
+	{"<", query.LT},
+	{"<=", query.LE},
+	{">", query.GT},
+	{">=", query.GE},
+}
+
+// comparison parses the operator of a comparison.
+func (p *Parser) comparison() (query.Operator, error) {
+	for _, c := range comparisons {
+		if p.lex.MatchOperator(c.symbol) {
+			return c.op, p.lex.EatOperator(c.symbol)
+		}
+	}
+	return 0, NewBadSyntaxError("expected a comparison operator")
 }
 
+// nullTest parses IS NULL or IS NOT NULL after the expression.
+func (p *Parser) nullTest(expr *query.Expression) (*query.Term, error) {
+	if err := p.lex.EatKeyword("is"); err != nil {
+		return nil, err
+	}
+	not := p.lex.MatchKeyword("not")
+	if not {
+		if err := p.lex.EatKeyword("not"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.lex.EatKeyword("null"); err != nil {
+		return nil, err
+	}
+	if not {
+		return query.NewIsNotNullTerm(expr), nil
+	}
+	return query.NewIsNullTerm(expr), nil
+}
+
+// Predicate parses conjunctions joined by OR, which binds less tightly than
+// AND, which binds less tightly than NOT.
 func (p *Parser) Predicate() (*query.Predicate, error) {
-	term, err := p.Term()
+	pred, err := p.conjunction()
 	if err != nil {
 		return nil, err
 	}
+	if !p.lex.MatchKeyword("or") {
+		return pred, nil
+	}
 
-	pred := query.NewPredicateFromTerm(term)
+	operands := []*query.Predicate{pred}
+	for p.lex.MatchKeyword("or") {
+		if err := p.lex.EatKeyword("or"); err != nil {
+			return nil, err
+		}
+		operand, err := p.conjunction()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	return query.NewDisjunction(operands...), nil
+}
 
-	if p.lex.MatchKeyword("and") {
+func (p *Parser) conjunction() (*query.Predicate, error) {
+	pred, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.lex.MatchKeyword("and") {
 		if err := p.lex.EatKeyword("and"); err != nil {
 			return nil, err
 		}
-		rhs, err := p.Predicate()
+		rhs, err := p.factor()
 		if err != nil {
 			return nil, err
 		}
-
 		pred.ConjoinWith(rhs)
 	}
 
 	return pred, nil
 }
 
+// factor parses a term, a negated factor, or a predicate in parentheses.
+func (p *Parser) factor() (*query.Predicate, error) {
+	switch {
+	case p.lex.MatchKeyword("not"):
+		if err := p.lex.EatKeyword("not"); err != nil {
+			return nil, err
+		}
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewNegation(operand), nil
+	case p.lex.MatchDelim('('):
+		// the parentheses enclose either a predicate or the expression
+		// a term starts with, which is tried first
+		start := *p.lex
+		if term, err := p.Term(); err == nil {
+			return query.NewPredicateFromTerm(term), nil
+		}
+		*p.lex = start
+
+		if err := p.lex.EatDelim('('); err != nil {
+			return nil, err
+		}
+		pred, err := p.Predicate()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatDelim(')'); err != nil {
+			return nil, err
+		}
+		return pred, nil
+	}
+
+	term, err := p.Term()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewPredicateFromTerm(term), nil
+}
+
 func (p *Parser) Query() (*QueryData, error) {
 	if err := p.lex.EatKeyword("select"); err != nil {
 		return nil, err
 	}
 
-	fields, err := p.selectList()
+	fields, exprs, err := p.selectList()
 	if err != nil {
 		return nil, err
 	}
@@ -123,33 +449,84 @@ func (p *Parser) Query() (*QueryData, error) {
 		}
 	}
 
-	return NewQueryData(fields, tables, pred), nil
+	data := NewQueryData(fields, tables, pred)
+	data.Exprs = exprs
+	if p.lex.MatchWord("for") {
+		data.LockPolicy, err = p.lockClause()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
 }
 
-func (p *Parser) selectList() ([]string, error) {
-	fields := []string{}
+// lockClause parses FOR UPDATE, whose words other than UPDATE are not
+// reserved, so that they can name fields and tables.
+func (p *Parser) lockClause() (query.LockPolicy, error) {
+	if err := p.lex.EatWord("for"); err != nil {
+		return query.READ, err
+	}
+	if err := p.lex.EatKeyword("update"); err != nil {
+		return query.READ, err
+	}
 
-	field, err := p.Field()
-	if err != nil {
-		return nil, err
+	if p.lex.MatchWord("nowait") {
+		if err := p.lex.EatWord("nowait"); err != nil {
+			return query.READ, err
+		}
+		return query.NOWAIT, nil
 	}
+	if p.lex.MatchWord("skip") {
+		if err := p.lex.EatWord("skip"); err != nil {
+			return query.READ, err
+		}
+		if err := p.lex.EatWord("locked"); err != nil {
+			return query.READ, err
+		}
+		return query.SKIP_LOCKED, nil
+	}
+	return query.UPDATE, nil
+}
 
-	fields = append(fields, field)
+// selectList parses the fields selected, each an expression, named by AS
+// or else by how it is written. It returns the expressions of those that
+// are not fields under their own names, or nil if there are none.
+func (p *Parser) selectList() ([]string, map[string]*query.Expression, error) {
+	fields := []string{}
+	var exprs map[string]*query.Expression
 
-	if p.lex.MatchDelim(',') {
-		if err := p.lex.EatDelim(','); err != nil {
-			return nil, err
+	for {
+		expr, err := p.Expression()
+		if err != nil {
+			return nil, nil, err
 		}
 
-		rest, err := p.selectList()
-		if err != nil {
-			return nil, err
+		field := expr.String()
+		if p.lex.MatchKeyword("as") {
+			if err := p.lex.EatKeyword("as"); err != nil {
+				return nil, nil, err
+			}
+			field, err = p.Field()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		fields = append(fields, field)
+		if !expr.IsFieldName() || expr.AsFieldName() != field {
+			if exprs == nil {
+				exprs = make(map[string]*query.Expression)
+			}
+			exprs[field] = expr
 		}
 
-		fields = append(fields, rest...)
+		if !p.lex.MatchDelim(',') {
+			return fields, exprs, nil
+		}
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, nil, err
+		}
 	}
-
-	return fields, nil
 }
 
 func (p *Parser) tableList() ([]string, error) {
@@ -184,6 +561,12 @@ func (p *Parser) UpdateCommand() (UpdateCommand, error) {
 		return p.Delete()
 	} else if p.lex.MatchKeyword("update") {
 		return p.Modify()
+	} else if p.lex.MatchKeyword("set") {
+		return p.SetIsolationLevel()
+	} else if p.lex.MatchKeyword("alter") {
+		return p.AlterTable()
+	} else if p.lex.MatchKeyword("drop") {
+		return p.Drop()
 	} else {
 		return p.Create()
 	}
@@ -374,6 +757,42 @@ func (p *Parser) Modify() (*ModifyData, error) {
 	return NewModifyData(table, field, newValue, pred), nil
 }
 
+// SetIsolationLevel parses SET TRANSACTION ISOLATION LEVEL, whose words
+// other than SET are not reserved, so that they can name fields and tables.
+func (p *Parser) SetIsolationLevel() (*SetIsolationLevelData, error) {
+	if err := p.lex.EatKeyword("set"); err != nil {
+		return nil, err
+	}
+	for _, word := range []string{"transaction", "isolation", "level"} {
+		if err := p.lex.EatWord(word); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.lex.MatchWord("serializable") {
+		if err := p.lex.EatWord("serializable"); err != nil {
+			return nil, err
+		}
+		return NewSetIsolationLevelData(tx.SERIALIZABLE), nil
+	}
+	if p.lex.MatchWord("repeatable") {
+		if err := p.lex.EatWord("repeatable"); err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatWord("read"); err != nil {
+			return nil, err
+		}
+		return NewSetIsolationLevelData(tx.REPEATABLE_READ), nil
+	}
+	if err := p.lex.EatWord("read"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatWord("committed"); err != nil {
+		return nil, err
+	}
+	return NewSetIsolationLevelData(tx.READ_COMMITTED), nil
+}
+
 func (p *Parser) CreateTable() (*CreateTableData, error) {
 	if err := p.lex.EatKeyword("table"); err != nil {
 		return nil, err
@@ -397,7 +816,39 @@ func (p *Parser) CreateTable() (*CreateTableData, error) {
 		return nil, err
 	}
 
-	return NewCreateTableData(table, schema), nil
+	data := NewCreateTableData(table, schema)
+	if p.lex.MatchKeyword("using") {
+		if err := p.lex.EatKeyword("using"); err != nil {
+			return nil, err
+		}
+
+		data.Format, err = p.storageFormat()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// storageFormat parses the name of a storage format, which is not
+// a keyword so as not to keep it from naming fields.
+func (p *Parser) storageFormat() (record.StorageFormat, error) {
+	name, err := p.lex.EatIdentifier()
+	if err != nil {
+		return 0, err
+	}
+
+	switch name {
+	case "fixed":
+		return record.FIXED, nil
+	case "slotted":
+		return record.SLOTTED, nil
+	case "columnar":
+		return record.COLUMNAR, nil
+	default:
+		return 0, NewBadSyntaxError(fmt.Sprintf("unknown storage format %q", name))
+	}
 }
 
 func (p *Parser) fieldDefs() (*record.Schema, error) {
@@ -433,39 +884,218 @@ func (p *Parser) fieldDef() (*record.Schema, error) {
 		return nil, err
 	}
 
+	if p.lex.MatchKeyword("not") {
+		if err := p.lex.EatKeyword("not"); err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatKeyword("null"); err != nil {
+			return nil, err
+		}
+		schema.SetNullable(field, false)
+	} else if p.lex.MatchKeyword("null") {
+		if err := p.lex.EatKeyword("null"); err != nil {
+			return nil, err
+		}
+	}
+
 	return schema, nil
 }
 
 func (p *Parser) fieldType(field string) (*record.Schema, error) {
 	schema := record.NewSchema()
 
-	if p.lex.MatchKeyword("int") {
-		if err := p.lex.EatKeyword("int"); err != nil {
+	for keyword, fieldType := range map[string]record.FieldType{
+		"int":       record.INTEGER,
+		"bigint":    record.BIGINT,
+		"boolean":   record.BOOLEAN,
+		"double":    record.DOUBLE,
+		"date":      record.DATE,
+		"timestamp": record.TIMESTAMP,
+	} {
+		if p.lex.MatchKeyword(keyword) {
+			if err := p.lex.EatKeyword(keyword); err != nil {
+				return nil, err
+			}
+			schema.AddField(field, fieldType, 0)
+			return schema, nil
+		}
+	}
+
+	if p.lex.MatchKeyword("decimal") {
+		return p.decimalType(field)
+	}
+
+	if err := p.lex.EatKeyword("varchar"); err != nil {
+		return nil, err
+	}
+
+	if err := p.lex.EatDelim('('); err != nil {
+		return nil, err
+	}
+
+	length, err := p.lex.EatIntConstant()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.lex.EatDelim(')'); err != nil {
+		return nil, err
+	}
+
+	schema.AddStringField(field, length)
+
+	return schema, nil
+}
+
+// decimalType parses DECIMAL(p) or DECIMAL(p, s), whose scale defaults to
+// 0.
+func (p *Parser) decimalType(field string) (*record.Schema, error) {
+	if err := p.lex.EatKeyword("decimal"); err != nil {
+		return nil, err
+	}
+
+	if err := p.lex.EatDelim('('); err != nil {
+		return nil, err
+	}
+
+	precision, err := p.lex.EatIntConstant()
+	if err != nil {
+		return nil, err
+	}
+
+	scale := int32(0)
+	if p.lex.MatchDelim(',') {
+		if err := p.lex.EatDelim(','); err != nil {
 			return nil, err
 		}
-		schema.AddIntField(field)
-	} else {
-		if err := p.lex.EatKeyword("varchar"); err != nil {
+		scale, err = p.lex.EatIntConstant()
+		if err != nil {
 			return nil, err
 		}
+	}
 
-		if err := p.lex.EatDelim('('); err != nil {
+	if err := p.lex.EatDelim(')'); err != nil {
+		return nil, err
+	}
+
+	if precision < 1 || precision > query.MaxDecimalPrecision || scale > precision {
+		return nil, NewBadSyntaxError(fmt.Sprintf("invalid decimal(%d,%d)", precision, scale))
+	}
+
+	schema := record.NewSchema()
+	schema.AddDecimalField(field, precision, scale)
+
+	return schema, nil
+}
+
+// AlterTable parses ALTER TABLE t ADD [COLUMN] field type, DROP [COLUMN]
+// field or RENAME [COLUMN] field TO name.
+func (p *Parser) AlterTable() (*AlterTableData, error) {
+	if err := p.lex.EatKeyword("alter"); err != nil {
+		return nil, err
+	}
+
+	if err := p.lex.EatKeyword("table"); err != nil {
+		return nil, err
+	}
+
+	table, err := p.Field()
+	if err != nil {
+		return nil, err
+	}
+
+	var action string
+	for _, keyword := range []string{"add", "drop", "rename"} {
+		if p.lex.MatchKeyword(keyword) {
+			action = keyword
+		}
+	}
+	if action == "" {
+		return nil, NewBadSyntaxError("expected add, drop or rename")
+	}
+	if err := p.lex.EatKeyword(action); err != nil {
+		return nil, err
+	}
+	if p.lex.MatchKeyword("column") {
+		if err := p.lex.EatKeyword("column"); err != nil {
 			return nil, err
 		}
+	}
 
-		length, err := p.lex.EatIntConstant()
+	if action == "add" {
+		schema, err := p.fieldDef()
 		if err != nil {
 			return nil, err
 		}
+		return NewAddColumnData(table, schema), nil
+	}
 
-		if err := p.lex.EatDelim(')'); err != nil {
+	field, err := p.Field()
+	if err != nil {
+		return nil, err
+	}
+	if action == "drop" {
+		return NewDropColumnData(table, field), nil
+	}
+
+	if err := p.lex.EatKeyword("to"); err != nil {
+		return nil, err
+	}
+
+	newName, err := p.Field()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRenameColumnData(table, field, newName), nil
+}
+
+func (p *Parser) Drop() (UpdateCommand, error) {
+	if err := p.lex.EatKeyword("drop"); err != nil {
+		return nil, err
+	}
+
+	if p.lex.MatchKeyword("index") {
+		if err := p.lex.EatKeyword("index"); err != nil {
+			return nil, err
+		}
+		index, err := p.Field()
+		if err != nil {
 			return nil, err
 		}
+		return NewDropIndexData(index), nil
+	}
 
-		schema.AddStringField(field, length)
+	isView := p.lex.MatchKeyword("view")
+	if isView {
+		if err := p.lex.EatKeyword("view"); err != nil {
+			return nil, err
+		}
+	} else if err := p.lex.EatKeyword("table"); err != nil {
+		return nil, err
 	}
 
-	return schema, nil
+	name, err := p.Field()
+	if err != nil {
+		return nil, err
+	}
+
+	cascade := false
+	if p.lex.MatchKeyword("cascade") {
+		if err := p.lex.EatKeyword("cascade"); err != nil {
+			return nil, err
+		}
+		cascade = true
+	} else if p.lex.MatchKeyword("restrict") {
+		if err := p.lex.EatKeyword("restrict"); err != nil {
+			return nil, err
+		}
+	}
+
+	if isView {
+		return NewDropViewData(name, cascade), nil
+	}
+	return NewDropTableData(name, cascade), nil
 }
 
 func (p *Parser) CreateView() (*CreateViewData, error) {
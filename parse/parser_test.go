@@ -2,10 +2,12 @@ package parse_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/adieumonks/simple-db/parse"
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/tx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -35,9 +37,123 @@ func TestParserQuery(t *testing.T) {
 		},
 		{
 			input:     "select sid, sname, did, dname FROM student, dept WHERE sname = 'John'",
-			wantQuery: "select sid, sname, did, dname from student, dept where sname = John",
+			wantQuery: "select sid, sname, did, dname from student, dept where sname = 'John'",
 			wantError: false,
 		},
+		{
+			input:     "SELECT sid FROM queue WHERE done = 0 FOR UPDATE",
+			wantQuery: "select sid from queue where done = 0 for update",
+			wantError: false,
+		},
+		{
+			input:     "SELECT sid FROM queue FOR UPDATE NOWAIT",
+			wantQuery: "select sid from queue for update nowait",
+			wantError: false,
+		},
+		{
+			input:     "SELECT sid FROM queue FOR UPDATE SKIP LOCKED",
+			wantQuery: "select sid from queue for update skip locked",
+			wantError: false,
+		},
+		{
+			input:     "SELECT sid FROM queue FOR UPDATE SKIP",
+			wantError: true,
+		},
+		{
+			input:     "SELECT skip, locked FROM q WHERE nowait = 0",
+			wantQuery: "select skip, locked from q where nowait = 0",
+			wantError: false,
+		},
+		{
+			input:     "SELECT for FROM q FOR UPDATE SKIP LOCKED",
+			wantQuery: "select for from q for update skip locked",
+			wantError: false,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE age IS NULL AND did IS NOT NULL",
+			wantQuery: "select sname from student where age is null and did is not null",
+			wantError: false,
+		},
+		{
+			input:     "SELECT id FROM account WHERE balance = -12.50 AND owner = -3",
+			wantQuery: "select id from account where balance = -12.50 and owner = -3",
+			wantError: false,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE age<20 AND age >= 10 AND did<>3 AND did != 4 AND 5<=did AND sname>'A'",
+			wantQuery: "select sname from student where age < 20 and age >= 10 and did <> 3 and did <> 4 and 5 <= did and sname > 'A'",
+			wantError: false,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE age = 20 OR NOT (did = 3 OR did = 4) AND sid > 1",
+			wantQuery: "select sname from student where age = 20 or not (did = 3 or did = 4) and sid > 1",
+			wantError: false,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE ((age = 20 OR age = 21) AND (did = 3)) AND NOT NOT sid = 1",
+			wantQuery: "select sname from student where (age = 20 or age = 21) and did = 3 and not not sid = 1",
+			wantError: false,
+		},
+		{
+			input:     "SELECT price*qty AS total, -qty, (a + b) % 3, a - -3, a - (b - c), sname AS name FROM item WHERE (a + 1) * 2 > b OR (a = 1)",
+			wantQuery: "select price * qty as total, -qty, (a + b) % 3, a - -3, a - (b - c), sname as name from item where (a + 1) * 2 > b or a = 1",
+			wantError: false,
+		},
+		{
+			input:     "SELECT a FROM item WHERE ((a) + 1 = 2 OR b = 1) AND -(a * 2) < 0",
+			wantQuery: "select a from item where (a + 1 = 2 or b = 1) and -(a * 2) < 0",
+			wantError: false,
+		},
+		{
+			input:     "SELECT UPPER(sname), Substr(sname, 2, a + 1) AS part, first || ' ' || last AS full, length(sname) FROM student WHERE coalesce(age, 0) > abs(-3)",
+			wantQuery: "select upper(sname), substr(sname, 2, a + 1) as part, concat(concat(first, ' '), last) as full, length(sname) from student where coalesce(age, 0) > abs(-3)",
+			wantError: false,
+		},
+		{
+			input:     "SELECT a || b + 1 AS c FROM item",
+			wantQuery: "select concat(a, b + 1) as c from item",
+			wantError: false,
+		},
+		{
+			input:     "SELECT nosuch(a) FROM item",
+			wantError: true,
+		},
+		{
+			input:     "SELECT nullif(a) FROM item",
+			wantError: true,
+		},
+		{
+			input:     "SELECT upper(a FROM item",
+			wantError: true,
+		},
+		{
+			input:     "SELECT a + FROM item",
+			wantError: true,
+		},
+		{
+			input:     "SELECT a AS FROM item",
+			wantError: true,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE (age = 20",
+			wantError: true,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE age = 20 OR",
+			wantError: true,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE age < > 20",
+			wantError: true,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE age ! 20",
+			wantError: true,
+		},
+		{
+			input:     "SELECT sname FROM student WHERE age IS 20",
+			wantError: true,
+		},
 		{
 			input:     "SELECT * FROM STUDENT",
 			wantError: true,
@@ -93,6 +209,25 @@ func TestParserUpdateCmd(t *testing.T) {
 			),
 			wantError: false,
 		},
+		{
+			input: "UPDATE ITEM SET qty = qty + 1 WHERE id = 1",
+			wantCmd: parse.NewModifyData(
+				"item",
+				"qty",
+				query.NewArithmeticExpression(
+					query.NewExpressionFromField("qty"),
+					query.ADD,
+					query.NewExpressionFromConstant(query.NewConstantWithInt(1)),
+				),
+				query.NewPredicateFromTerm(
+					query.NewTerm(
+						query.NewExpressionFromField("id"),
+						query.NewExpressionFromConstant(query.NewConstantWithInt(1)),
+					),
+				),
+			),
+			wantError: false,
+		},
 		{
 			input: "DELETE FROM STUDENT",
 			wantCmd: parse.NewDeleteData(
@@ -128,6 +263,124 @@ func TestParserUpdateCmd(t *testing.T) {
 			),
 			wantError: false,
 		},
+		{
+			input: "CREATE TABLE STUDENT(sid INT, sname VARCHAR(20)) USING SLOTTED",
+			wantCmd: &parse.CreateTableData{
+				TableName: "student",
+				Schema: func() *record.Schema {
+					schema := record.NewSchema()
+					schema.AddIntField("sid")
+					schema.AddStringField("sname", 20)
+					return schema
+				}(),
+				Format: record.SLOTTED,
+			},
+			wantError: false,
+		},
+		{
+			input: "CREATE TABLE STUDENT(sid INT, sname VARCHAR(20)) USING COLUMNAR",
+			wantCmd: &parse.CreateTableData{
+				TableName: "student",
+				Schema: func() *record.Schema {
+					schema := record.NewSchema()
+					schema.AddIntField("sid")
+					schema.AddStringField("sname", 20)
+					return schema
+				}(),
+				Format: record.COLUMNAR,
+			},
+			wantError: false,
+		},
+		{
+			input: "INSERT INTO STUDENT(sid, sname) VALUES (1, NULL)",
+			wantCmd: parse.NewInsertData(
+				"student",
+				[]string{"sid", "sname"},
+				[]*query.Constant{
+					query.NewConstantWithInt(1),
+					query.NewNullConstant(),
+				},
+			),
+			wantError: false,
+		},
+		{
+			input: "CREATE TABLE STUDENT(sid INT NOT NULL, sname VARCHAR(20) NULL)",
+			wantCmd: parse.NewCreateTableData(
+				"student",
+				func() *record.Schema {
+					schema := record.NewSchema()
+					schema.AddIntField("sid")
+					schema.SetNullable("sid", false)
+					schema.AddStringField("sname", 20)
+					return schema
+				}(),
+			),
+			wantError: false,
+		},
+		{
+			input: "CREATE TABLE EVENT(id BIGINT, done BOOLEAN, score DOUBLE, day DATE, at TIMESTAMP)",
+			wantCmd: parse.NewCreateTableData(
+				"event",
+				func() *record.Schema {
+					schema := record.NewSchema()
+					schema.AddField("id", record.BIGINT, 0)
+					schema.AddField("done", record.BOOLEAN, 0)
+					schema.AddField("score", record.DOUBLE, 0)
+					schema.AddField("day", record.DATE, 0)
+					schema.AddField("at", record.TIMESTAMP, 0)
+					return schema
+				}(),
+			),
+			wantError: false,
+		},
+		{
+			input: "INSERT INTO EVENT(id, done, score, day, at) VALUES (3000000000, TRUE, 1.25, DATE '2024-01-31', TIMESTAMP '2024-01-31 12:34:56')",
+			wantCmd: parse.NewInsertData(
+				"event",
+				[]string{"id", "done", "score", "day", "at"},
+				[]*query.Constant{
+					query.NewConstantWithLong(3000000000),
+					query.NewConstantWithBool(true),
+					query.NewConstantWithDecimal(query.NewDecimal(125, 2)),
+					query.NewConstantWithDate(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)),
+					query.NewConstantWithTimestamp(time.Date(2024, 1, 31, 12, 34, 56, 0, time.UTC)),
+				},
+			),
+			wantError: false,
+		},
+		{
+			input: "CREATE TABLE ACCOUNT(balance DECIMAL(12, 2), rate DECIMAL(5))",
+			wantCmd: parse.NewCreateTableData(
+				"account",
+				func() *record.Schema {
+					schema := record.NewSchema()
+					schema.AddDecimalField("balance", 12, 2)
+					schema.AddDecimalField("rate", 5, 0)
+					return schema
+				}(),
+			),
+			wantError: false,
+		},
+		{
+			input:     "CREATE TABLE ACCOUNT(balance DECIMAL(19, 2))",
+			wantError: true,
+		},
+		{
+			input:     "CREATE TABLE ACCOUNT(balance DECIMAL(2, 3))",
+			wantError: true,
+		},
+		{
+			input:     "INSERT INTO EVENT(day) VALUES (DATE '2024-02-30')",
+			wantError: true,
+		},
+		{
+			input:     "CREATE TABLE STUDENT(sid INT NOT)",
+			wantError: true,
+		},
+		{
+			input:     "CREATE TABLE STUDENT(sid INT) USING HEAP",
+			wantError: true,
+		},
 		{
 			input:     "CREATE TABLE STUDENT(sid INT, sname VARCHAR, age INT)", // VARCHARは長さ指定が必要
 			wantError: true,
@@ -153,6 +406,109 @@ func TestParserUpdateCmd(t *testing.T) {
 			),
 			wantError: false,
 		},
+		{
+			input: "ALTER TABLE STUDENT ADD COLUMN email VARCHAR(20) NOT NULL",
+			wantCmd: parse.NewAddColumnData(
+				"student",
+				func() *record.Schema {
+					schema := record.NewSchema()
+					schema.AddStringField("email", 20)
+					schema.SetNullable("email", false)
+					return schema
+				}(),
+			),
+			wantError: false,
+		},
+		{
+			input:     "ALTER TABLE STUDENT DROP age",
+			wantCmd:   parse.NewDropColumnData("student", "age"),
+			wantError: false,
+		},
+		{
+			input:     "ALTER TABLE STUDENT RENAME COLUMN sname TO name",
+			wantCmd:   parse.NewRenameColumnData("student", "sname", "name"),
+			wantError: false,
+		},
+		{
+			input:     "ALTER TABLE STUDENT RENAME sname",
+			wantError: true,
+		},
+		{
+			input:     "ALTER TABLE STUDENT ADD COLUMN",
+			wantError: true,
+		},
+		{
+			input:     "DROP TABLE STUDENT",
+			wantCmd:   parse.NewDropTableData("student", false),
+			wantError: false,
+		},
+		{
+			input:     "DROP TABLE STUDENT CASCADE",
+			wantCmd:   parse.NewDropTableData("student", true),
+			wantError: false,
+		},
+		{
+			input:     "DROP VIEW tmp RESTRICT",
+			wantCmd:   parse.NewDropViewData("tmp", false),
+			wantError: false,
+		},
+		{
+			input:     "DROP INDEX student_sname_idx",
+			wantCmd:   parse.NewDropIndexData("student_sname_idx"),
+			wantError: false,
+		},
+		{
+			input:     "DROP STUDENT",
+			wantError: true,
+		},
+		{
+			input:     "SET TRANSACTION ISOLATION LEVEL READ COMMITTED",
+			wantCmd:   parse.NewSetIsolationLevelData(tx.READ_COMMITTED),
+			wantError: false,
+		},
+		{
+			input:     "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+			wantCmd:   parse.NewSetIsolationLevelData(tx.REPEATABLE_READ),
+			wantError: false,
+		},
+		{
+			input:     "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE",
+			wantCmd:   parse.NewSetIsolationLevelData(tx.SERIALIZABLE),
+			wantError: false,
+		},
+		{
+			input:     "SET TRANSACTION ISOLATION LEVEL READ",
+			wantError: true,
+		},
+		{
+			input: "CREATE TABLE LOG(level INT, read INT, committed INT)",
+			wantCmd: parse.NewCreateTableData(
+				"log",
+				func() *record.Schema {
+					schema := record.NewSchema()
+					schema.AddIntField("level")
+					schema.AddIntField("read")
+					schema.AddIntField("committed")
+					return schema
+				}(),
+			),
+			wantError: false,
+		},
+		{
+			input: "UPDATE transaction SET isolation = 1 WHERE serializable = 0",
+			wantCmd: parse.NewModifyData(
+				"transaction",
+				"isolation",
+				query.NewExpressionFromConstant(query.NewConstantWithInt(1)),
+				query.NewPredicateFromTerm(
+					query.NewTerm(
+						query.NewExpressionFromField("serializable"),
+						query.NewExpressionFromConstant(query.NewConstantWithInt(0)),
+					),
+				),
+			),
+			wantError: false,
+		},
 	} {
 		t.Run(tt.input, func(t *testing.T) {
 			t.Parallel()
@@ -0,0 +1,224 @@
+package plan
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/adieumonks/simple-db/metadata"
+	"github.com/adieumonks/simple-db/parse"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
+)
+
+// alterTable adds, drops or renames a column of a table. Adding or
+// dropping one rewrites the records to a new layout, with the indexes on
+// them; renaming one only changes the catalog. The views on the table
+// follow a renamed column, and keep a column they use from being dropped.
+func alterTable(mdm *metadata.MetadataManager, data *parse.AlterTableData, tx *tx.Transaction) (int32, error) {
+	tableName := data.TableName
+	if metadata.IsCatalog(tableName) {
+		return 0, fmt.Errorf("alter table %s: cannot alter a catalog table", tableName)
+	}
+	layout, err := mdm.GetLayout(tableName, tx)
+	if err != nil {
+		return 0, err
+	}
+	sch := layout.Schema()
+	if len(sch.Fields()) == 0 {
+		return 0, fmt.Errorf("alter table %s: %w", tableName, metadata.ErrTableNotFound)
+	}
+	// keep others from using the table, or defining indexes on it,
+	// while it changes
+	for _, filename := range []string{tableName + ".tbl", layout.FileName(tableName)} {
+		if err := tx.LockFile(filename, concurrency.X); err != nil {
+			return 0, fmt.Errorf("failed to lock table: %w", err)
+		}
+	}
+
+	switch data.Action {
+	case parse.ADD_COLUMN:
+		newSch := record.NewSchema()
+		newSch.AddAll(sch)
+		for _, fieldName := range data.Schema.Fields() {
+			if sch.HasField(fieldName) {
+				return 0, fmt.Errorf("alter table %s: field %s: %w", tableName, fieldName, metadata.ErrFieldExists)
+			}
+			newSch.Add(fieldName, data.Schema)
+		}
+		return rewriteTable(mdm, tableName, layout, newSch, tx)
+	case parse.DROP_COLUMN:
+		if !sch.HasField(data.FieldName) {
+			return 0, fmt.Errorf("alter table %s: field %s: %w", tableName, data.FieldName, query.ErrFieldNotFound)
+		}
+		if len(sch.Fields()) == 1 {
+			return 0, fmt.Errorf("alter table %s: cannot drop its only field %s", tableName, data.FieldName)
+		}
+		views, err := viewsOn(mdm, tableName, tx)
+		if err != nil {
+			return 0, err
+		}
+		for viewName, view := range views {
+			if view.UsesField(data.FieldName) {
+				return 0, fmt.Errorf("alter table %s: field %s is used by view %s: %w", tableName, data.FieldName, viewName, metadata.ErrDependent)
+			}
+		}
+		newSch := record.NewSchema()
+		for _, fieldName := range sch.Fields() {
+			if fieldName != data.FieldName {
+				newSch.Add(fieldName, sch)
+			}
+		}
+		return rewriteTable(mdm, tableName, layout, newSch, tx)
+	case parse.RENAME_COLUMN:
+		if !sch.HasField(data.FieldName) {
+			return 0, fmt.Errorf("alter table %s: field %s: %w", tableName, data.FieldName, query.ErrFieldNotFound)
+		}
+		if sch.HasField(data.NewName) {
+			return 0, fmt.Errorf("alter table %s: field %s: %w", tableName, data.NewName, metadata.ErrFieldExists)
+		}
+		if err := mdm.RenameField(tableName, data.FieldName, data.NewName, tx); err != nil {
+			return 0, err
+		}
+		return 0, renameInViews(mdm, tableName, data.FieldName, data.NewName, tx)
+	default:
+		return 0, fmt.Errorf("alter table %s: invalid action", tableName)
+	}
+}
+
+// rewriteTable copies the records of the table to the layout of the new
+// schema, where the fields it adds are NULL, and rebuilds the indexes on
+// the fields it keeps, dropping the others.
+func rewriteTable(mdm *metadata.MetadataManager, tableName string, layout *record.Layout, sch *record.Schema, tx *tx.Transaction) (int32, error) {
+	// the old files go once the transaction commits
+	oldIndexes, err := mdm.GetIndexInfo(tableName, tx)
+	if err != nil {
+		return 0, err
+	}
+	for fieldName, ii := range oldIndexes {
+		if !sch.HasField(fieldName) {
+			if err := mdm.DropIndex(ii.IndexName(), tx); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		for _, filename := range ii.FileNames() {
+			tx.DeleteOnCommit(filename)
+		}
+	}
+	for _, filename := range layout.FileNames(tableName) {
+		tx.DeleteOnCommit(filename)
+	}
+
+	newLayout, err := mdm.ChangeSchema(tableName, sch, tx)
+	if err != nil {
+		return 0, err
+	}
+	indexes, err := mdm.GetIndexInfo(tableName, tx)
+	if err != nil {
+		return 0, err
+	}
+	idxs := make(map[string]query.Index)
+	for fieldName, ii := range indexes {
+		idx := ii.Open()
+		defer idx.Close()
+		idxs[fieldName] = idx
+	}
+
+	src, err := query.NewTableScan(tx, tableName, layout)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := query.NewTableScan(tx, tableName, newLayout)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	count := int32(0)
+	for {
+		next, err := src.Next()
+		if err != nil {
+			return 0, err
+		}
+		if !next {
+			break
+		}
+		if err := dst.Insert(); err != nil {
+			return 0, err
+		}
+		for _, fieldName := range sch.Fields() {
+			if !layout.Schema().HasField(fieldName) {
+				if !sch.Nullable(fieldName) {
+					return 0, fmt.Errorf("alter table %s: field %s: %w", tableName, fieldName, query.ErrNotNull)
+				}
+				continue
+			}
+			val, err := src.GetVal(fieldName)
+			if err != nil {
+				return 0, err
+			}
+			if err := dst.SetVal(fieldName, val); err != nil {
+				return 0, err
+			}
+			if idx, ok := idxs[fieldName]; ok && !val.IsNull() {
+				if err := idx.Insert(val, dst.GetRID()); err != nil {
+					return 0, err
+				}
+			}
+		}
+		count++
+	}
+	return count, nil
+}
+
+// viewsOn returns the parsed definitions of the views that select from the
+// table.
+func viewsOn(mdm *metadata.MetadataManager, tableName string, tx *tx.Transaction) (map[string]*parse.QueryData, error) {
+	viewDefs, err := mdm.ViewDefs(tx)
+	if err != nil {
+		return nil, err
+	}
+	views := make(map[string]*parse.QueryData)
+	for viewName, viewDef := range viewDefs {
+		parser, err := parse.NewParser(viewDef)
+		if err != nil {
+			return nil, err
+		}
+		view, err := parser.Query()
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(view.Tables, tableName) {
+			views[viewName] = view
+		}
+	}
+	return views, nil
+}
+
+// renameInViews renames the field of the table in the views on it, and in
+// turn in the views on those that select it.
+func renameInViews(mdm *metadata.MetadataManager, tableName, oldName, newName string, tx *tx.Transaction) error {
+	renamed := []string{tableName}
+	for len(renamed) > 0 {
+		views, err := viewsOn(mdm, renamed[0], tx)
+		if err != nil {
+			return err
+		}
+		renamed = renamed[1:]
+		for viewName, view := range views {
+			if !view.UsesField(oldName) {
+				continue
+			}
+			if view.RenameField(oldName, newName) {
+				renamed = append(renamed, viewName)
+			}
+			if err := mdm.SetViewDef(viewName, view.String(), tx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package plan_test
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/metadata"
+	"github.com/adieumonks/simple-db/plan"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+func TestAlterTable(t *testing.T) {
+	for _, format := range []string{"fixed", "slotted", "columnar"} {
+		t.Run(format, func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "altertabletest")
+			db, err := server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to create new database: %v", err)
+			}
+			executeCommitted(t, db, fmt.Sprintf("create table T1(A int, B varchar(10)) using %s", format))
+			executeCommitted(t, db, "create index T1_B on T1(B)")
+			executeCommitted(t, db, "create view V1 as select A, B from T1 where B = 'x'")
+			for i := 1; i <= 30; i++ {
+				executeCommitted(t, db, fmt.Sprintf("insert into T1(A, B) values(%d, '%s')", i, []string{"x", "y", "z"}[i%3]))
+			}
+
+			executeCommitted(t, db, "alter table T1 add column C decimal(6, 2)")
+			executeCommitted(t, db, "insert into T1(A, B, C) values(31, 'w', 1.5)")
+
+			// the new column and the rewritten index are kept
+			db, err = server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			tx, err := db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			planner := db.Planner()
+			assertRows(t, planner, "select A, C from T1 where A = 3", tx, []string{"3 null"})
+			assertRows(t, planner, "select A, C from T1 where A = 31", tx, []string{"31 1.50"})
+			assertIndexA(t, db, tx, "b", query.NewConstantWithString("x"), 10)
+
+			_, err = planner.ExecuteUpdate("alter table T1 add C int", tx)
+			if !errors.Is(err, metadata.ErrFieldExists) {
+				t.Errorf("expected %v, got %v", metadata.ErrFieldExists, err)
+			}
+			_, err = planner.ExecuteUpdate("alter table T1 add D int not null", tx)
+			if !errors.Is(err, query.ErrNotNull) {
+				t.Errorf("expected %v, got %v", query.ErrNotNull, err)
+			}
+			_, err = planner.ExecuteUpdate("alter table T9 add D int", tx)
+			if !errors.Is(err, metadata.ErrTableNotFound) {
+				t.Errorf("expected %v, got %v", metadata.ErrTableNotFound, err)
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Fatalf("failed to rollback transaction: %v", err)
+			}
+
+			// the view follows a renamed column, and keeps it from being dropped
+			executeCommitted(t, db, "alter table T1 rename column B to BB")
+			tx, err = db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			assertA(t, planner, "select A from T1 where BB = 'y' and A = 1", tx, []int32{1})
+			assertA(t, planner, "select A from V1 where BB = 'x' and A = 3", tx, []int32{3})
+			assertIndexA(t, db, tx, "bb", query.NewConstantWithString("z"), 10)
+			_, err = planner.ExecuteUpdate("alter table T1 drop column BB", tx)
+			if !errors.Is(err, metadata.ErrDependent) {
+				t.Errorf("expected %v, got %v", metadata.ErrDependent, err)
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Fatalf("failed to rollback transaction: %v", err)
+			}
+
+			// a rolled back change leaves the table as it was
+			tx, err = db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			if _, err := planner.ExecuteUpdate("alter table T1 drop column C", tx); err != nil {
+				t.Fatalf("failed to execute update: %v", err)
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Fatalf("failed to rollback transaction: %v", err)
+			}
+			tx = db.NewReadOnlyTransaction()
+			assertRows(t, planner, "select A, BB, C from T1 where A = 31", tx, []string{"31 w 1.50"})
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+
+			// dropping a column drops the indexes on it
+			executeCommitted(t, db, "alter table T1 add column E int")
+			executeCommitted(t, db, "create index T1_E on T1(E)")
+			executeCommitted(t, db, "alter table T1 drop column E")
+			tx, err = db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			indexes, err := db.MetadataManager().GetIndexInfo("t1", tx)
+			if err != nil {
+				t.Fatalf("failed to get index info: %v", err)
+			}
+			if _, ok := indexes["e"]; ok || len(indexes) != 1 {
+				t.Errorf("expected only the index on bb, got %v", indexes)
+			}
+			assertA(t, planner, "select A from T1 where C = 1.5", tx, []int32{31})
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+		})
+	}
+}
+
+// assertIndexA checks the number of records the index on the field of T1
+// finds for the value.
+func assertIndexA(t *testing.T, db *server.SimpleDB, tx *tx.Transaction, fieldName string, val *query.Constant, expected int) {
+	t.Helper()
+
+	mdm := db.MetadataManager()
+	indexes, err := mdm.GetIndexInfo("t1", tx)
+	if err != nil {
+		t.Fatalf("failed to get index info: %v", err)
+	}
+	ii, ok := indexes[fieldName]
+	if !ok {
+		t.Fatalf("index on %s not found", fieldName)
+	}
+	p, err := plan.NewTablePlan(tx, "t1", mdm)
+	if err != nil {
+		t.Fatalf("failed to create table plan: %v", err)
+	}
+	s, err := plan.NewIndexSelectPlan(p, ii, val).Open()
+	if err != nil {
+		t.Fatalf("failed to open scan: %v", err)
+	}
+	defer s.Close()
+
+	got := 0
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next scan: %v", err)
+		}
+		if !next {
+			break
+		}
+		v, err := s.GetVal(fieldName)
+		if err != nil {
+			t.Fatalf("failed to get value: %v", err)
+		}
+		if !v.Equals(val) {
+			t.Errorf("expected %s, got %s", val, v)
+		}
+		got++
+	}
+	if got != expected {
+		t.Errorf("expected %d records, got %d", expected, got)
+	}
+}
@@ -0,0 +1,78 @@
+package plan_test
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestArithmetic(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "arithmetictest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table ITEM(id int, name varchar(10), qty int, price decimal(8, 2), weight double, stock bigint)")
+	executeCommitted(t, db, "insert into ITEM(id, name, qty, price, weight, stock) values(1, 'pen', 3, 1.25, 0.5, 3000000000)")
+	executeCommitted(t, db, "insert into ITEM(id, name, qty, price, weight, stock) values(2, 'ink', 7, 10.00, 1.5, 1)")
+	executeCommitted(t, db, "insert into ITEM(id, name, qty) values(3, 'cap', 2)")
+	executeCommitted(t, db, "update ITEM set qty = qty + 1 where id = 1 or id = 3")
+	executeCommitted(t, db, "update ITEM set price = price * 2 - 0.5 where id = 2")
+	executeCommitted(t, db, "create view TOTALS as select id, price * qty as total from ITEM where qty % 2 = 0")
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	planner := db.Planner()
+	assertRows(t, planner, "select id, qty from ITEM", tx, []string{"1 4", "2 7", "3 3"})
+	assertRows(t, planner, "select id, price * qty as total, -qty, qty / 2, qty % 3 from ITEM", tx,
+		[]string{"1 5.00 -4 2 1", "2 136.50 -7 3 1", "3 null -3 1 0"})
+	assertRows(t, planner, "select id, stock + qty, weight * 2, price / 3 from ITEM where id < 3", tx,
+		[]string{"1 3000000004 1 0.416667", "2 8 3 6.500000"})
+	assertRows(t, planner, "select id from ITEM where (qty + 1) * 2 >= 10 and -(qty - 10) > 2", tx, []string{"1", "2"})
+	assertRows(t, planner, "select id, total from TOTALS where total > 1", tx, []string{"1 5.00"})
+
+	// computed fields have the types of their values
+	p, err := planner.CreateQueryPlan("select price * qty as total, qty + 1 as next, stock - qty as rest, weight / qty as w, name from ITEM", tx)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	sch := p.Schema()
+	for fieldName, want := range map[string]record.FieldType{
+		"total": record.DECIMAL,
+		"next":  record.INTEGER,
+		"rest":  record.BIGINT,
+		"w":     record.DOUBLE,
+		"name":  record.STRING,
+	} {
+		if got := sch.Type(fieldName); got != want {
+			t.Errorf("expected %s to be %s, got %s", fieldName, want, got)
+		}
+	}
+	if sch.Scale("total") != 2 || sch.Length("name") != 10 {
+		t.Errorf("expected total to have scale 2 and name length 10, got %d and %d", sch.Scale("total"), sch.Length("name"))
+	}
+
+	// arithmetic on what is not a number fails before the scan
+	for _, cmd := range []string{"select name + 1 as n from ITEM", "select -name as n from ITEM"} {
+		if _, err := planner.CreateQueryPlan(cmd, tx); !errors.Is(err, query.ErrTypeMismatch) {
+			t.Errorf("%s: expected %v, got %v", cmd, query.ErrTypeMismatch, err)
+		}
+	}
+	if _, err := planner.ExecuteUpdate("update ITEM set qty = name * 2", tx); !errors.Is(err, query.ErrTypeMismatch) {
+		t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+	}
+	if _, err := planner.ExecuteUpdate("update ITEM set qty = qty / 0", tx); !errors.Is(err, query.ErrDivisionByZero) {
+		t.Errorf("expected %v, got %v", query.ErrDivisionByZero, err)
+	}
+	if _, err := planner.ExecuteUpdate("update ITEM set qty = qty * 2147483647", tx); !errors.Is(err, query.ErrTypeMismatch) {
+		t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to rollback transaction: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package plan_test
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/parse"
+	"github.com/adieumonks/simple-db/plan"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestComparison(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "comparisontest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table T1(A int, B varchar(10), C decimal(5, 2), D date)")
+	for i := 1; i <= 6; i++ {
+		executeCommitted(t, db, fmt.Sprintf("insert into T1(A, B, C, D) values(%d, 'b%d', %d.50, date '2024-01-0%d')", i, i, i, i))
+	}
+	executeCommitted(t, db, "insert into T1(A) values(7)")
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	planner := db.Planner()
+	assertA(t, planner, "select A from T1 where A < 3", tx, []int32{1, 2})
+	assertA(t, planner, "select A from T1 where A <= 3", tx, []int32{1, 2, 3})
+	assertA(t, planner, "select A from T1 where A > 5", tx, []int32{6, 7})
+	assertA(t, planner, "select A from T1 where A >= 5 and A <> 6", tx, []int32{5, 7})
+	assertA(t, planner, "select A from T1 where 2 != A and 4 > A", tx, []int32{1, 3})
+	assertA(t, planner, "select A from T1 where B >= 'b5'", tx, []int32{5, 6})
+	assertA(t, planner, "select A from T1 where C >= 4.5", tx, []int32{4, 5, 6})
+	assertA(t, planner, "select A from T1 where D < date '2024-01-03'", tx, []int32{1, 2})
+	assertA(t, planner, "select A from T1 where D >= timestamp '2024-01-05 12:00:00'", tx, []int32{6})
+	// a comparison with NULL is never true
+	assertA(t, planner, "select A from T1 where B <> 'b1'", tx, []int32{2, 3, 4, 5, 6})
+	assertA(t, planner, "select A from T1 where A < null", tx, []int32{})
+
+	// values of types that do not compare fail before the scan, whatever
+	// the operator
+	for _, cmd := range []string{
+		"select A from T1 where B < 3",
+		"select A from T1 where A = 'x'",
+		"select A from T1 where B <> A",
+		"select A from T1 where D >= 1",
+		"select A from T1 where not (C = 'c' or A = 1)",
+	} {
+		if _, err := planner.CreateQueryPlan(cmd, tx); !errors.Is(err, query.ErrTypeMismatch) {
+			t.Errorf("%s: expected %v, got %v", cmd, query.ErrTypeMismatch, err)
+		}
+	}
+	if _, err := planner.ExecuteUpdate("delete from T1 where B = 1", tx); !errors.Is(err, query.ErrTypeMismatch) {
+		t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestComparisonReductionFactor(t *testing.T) {
+	dir := path.Join(t.TempDir(), "reductionfactortest")
+	db, err := server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table T1(A int, B int)")
+	for i := 0; i < 90; i++ {
+		executeCommitted(t, db, fmt.Sprintf("insert into T1(A, B) values(%d, %d)", i, i%3))
+	}
+
+	// the statistics are gathered when the database opens
+	db, err = server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	p, err := plan.NewTablePlan(tx, "t1", db.MetadataManager())
+	if err != nil {
+		t.Fatalf("failed to create table plan: %v", err)
+	}
+	for _, tt := range []struct {
+		where string
+		want  int32
+	}{
+		{"A = 10", 3},
+		{"A <> 10", 87},
+		{"A < 10", 30},
+		{"A > 10 and A <= 20", 10},
+		{"A > B", 30},
+		{"1 < 2", 90},
+		{"2 < 1", 0},
+	} {
+		parser, err := parse.NewParser(tt.where)
+		if err != nil {
+			t.Fatalf("failed to create parser: %v", err)
+		}
+		pred, err := parser.Predicate()
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.where, err)
+		}
+		if got := plan.NewSelectPlan(p, pred).RecordsOutput(); got != tt.want {
+			t.Errorf("%s: expected %d records, got %d", tt.where, tt.want, got)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package plan
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/adieumonks/simple-db/metadata"
+	"github.com/adieumonks/simple-db/parse"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+// dropTable drops the table along with the indexes on it. The views on it
+// keep it from being dropped, unless CASCADE drops them too.
+func dropTable(mdm *metadata.MetadataManager, data *parse.DropTableData, tx *tx.Transaction) (int32, error) {
+	if metadata.IsCatalog(data.TableName) {
+		return 0, fmt.Errorf("drop table %s: cannot drop a catalog table", data.TableName)
+	}
+	if err := dropViewsOn(mdm, data.TableName, data.Cascade, tx); err != nil {
+		return 0, err
+	}
+	return 0, mdm.DropTable(data.TableName, tx)
+}
+
+// dropView drops the view. The views on it keep it from being dropped,
+// unless CASCADE drops them too.
+func dropView(mdm *metadata.MetadataManager, data *parse.DropViewData, tx *tx.Transaction) (int32, error) {
+	if err := dropViewsOn(mdm, data.ViewName, data.Cascade, tx); err != nil {
+		return 0, err
+	}
+	return 0, mdm.DropView(data.ViewName, tx)
+}
+
+func dropIndex(mdm *metadata.MetadataManager, data *parse.DropIndexData, tx *tx.Transaction) (int32, error) {
+	return 0, mdm.DropIndex(data.IndexName, tx)
+}
+
+// dropViewsOn drops the views that select from the table or view, and in
+// turn the views on those, or fails if there are any and cascade is not set.
+func dropViewsOn(mdm *metadata.MetadataManager, name string, cascade bool, tx *tx.Transaction) error {
+	var dependents []string
+	for names := []string{name}; len(names) > 0; names = names[1:] {
+		views, err := viewsOn(mdm, names[0], tx)
+		if err != nil {
+			return err
+		}
+		for viewName := range views {
+			if !slices.Contains(dependents, viewName) {
+				dependents = append(dependents, viewName)
+				names = append(names, viewName)
+			}
+		}
+	}
+	if len(dependents) == 0 {
+		return nil
+	}
+	slices.Sort(dependents)
+	if !cascade {
+		return fmt.Errorf("drop %s: used by view %s: %w", name, dependents[0], metadata.ErrDependent)
+	}
+	for _, viewName := range dependents {
+		if err := mdm.DropView(viewName, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package plan_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/adieumonks/simple-db/metadata"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestDrop(t *testing.T) {
+	for _, format := range []string{"fixed", "slotted", "columnar"} {
+		t.Run(format, func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "droptest")
+			db, err := server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to create new database: %v", err)
+			}
+			executeCommitted(t, db, fmt.Sprintf("create table T1(A int, B int) using %s", format))
+			executeCommitted(t, db, "create index T1_B on T1(B)")
+			executeCommitted(t, db, "create view V1 as select A, B from T1")
+			executeCommitted(t, db, "create view V2 as select A from V1 where B = 1")
+			for i := 1; i <= 20; i++ {
+				executeCommitted(t, db, fmt.Sprintf("insert into T1(A, B) values(%d, %d)", i, i%5))
+			}
+			// index entries are written by a rewrite of the table
+			executeCommitted(t, db, "alter table T1 add column C int")
+			files := func(pattern string) []string {
+				t.Helper()
+				matches, err := filepath.Glob(path.Join(dir, pattern))
+				if err != nil {
+					t.Fatalf("failed to glob: %v", err)
+				}
+				return matches
+			}
+			if len(files("t1.*tbl")) != 1 || len(files("t1_b*.tbl")) == 0 {
+				t.Fatalf("expected the files of the table and index, got %v", files("*"))
+			}
+
+			// the views keep the table and the view they select from
+			tx, err := db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			planner := db.Planner()
+			for _, cmd := range []string{"drop table T1", "drop table T1 restrict", "drop view V1"} {
+				if _, err := planner.ExecuteUpdate(cmd, tx); !errors.Is(err, metadata.ErrDependent) {
+					t.Errorf("%s: expected %v, got %v", cmd, metadata.ErrDependent, err)
+				}
+			}
+			if _, err := planner.ExecuteUpdate("drop view V9", tx); !errors.Is(err, metadata.ErrViewNotFound) {
+				t.Errorf("expected %v, got %v", metadata.ErrViewNotFound, err)
+			}
+			if _, err := planner.ExecuteUpdate("drop index T1_X", tx); !errors.Is(err, metadata.ErrIndexNotFound) {
+				t.Errorf("expected %v, got %v", metadata.ErrIndexNotFound, err)
+			}
+			if _, err := planner.ExecuteUpdate("drop table T9", tx); !errors.Is(err, metadata.ErrTableNotFound) {
+				t.Errorf("expected %v, got %v", metadata.ErrTableNotFound, err)
+			}
+			if _, err := planner.ExecuteUpdate("drop table tblcat", tx); err == nil {
+				t.Errorf("expected dropping a catalog table to fail")
+			}
+
+			// a rolled back drop leaves everything as it was
+			if _, err := planner.ExecuteUpdate("drop table T1 cascade", tx); err != nil {
+				t.Fatalf("failed to execute update: %v", err)
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Fatalf("failed to rollback transaction: %v", err)
+			}
+			tx, err = db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			assertA(t, planner, "select A from V2", tx, []int32{1, 6, 11, 16})
+			assertIndexA(t, db, tx, "b", query.NewConstantWithInt(2), 4)
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+
+			executeCommitted(t, db, "drop view V2")
+			executeCommitted(t, db, "drop table T1 cascade")
+			if got := files("t1*"); len(got) != 0 {
+				t.Errorf("expected the files to be deleted, got %v", got)
+			}
+
+			// a table of the same name starts empty, and is kept on restart
+			executeCommitted(t, db, "create table T1(A int)")
+			executeCommitted(t, db, "insert into T1(A) values(100)")
+			db, err = server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			tx, err = db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			planner = db.Planner()
+			assertA(t, planner, "select A from T1", tx, []int32{100})
+			for _, viewName := range []string{"v1", "v2"} {
+				viewDef, err := db.MetadataManager().GetViewDef(viewName, tx)
+				if err != nil {
+					t.Fatalf("failed to get view def: %v", err)
+				}
+				if viewDef != "" {
+					t.Errorf("expected view %s to be dropped, got %s", viewName, viewDef)
+				}
+			}
+			indexes, err := db.MetadataManager().GetIndexInfo("t1", tx)
+			if err != nil {
+				t.Fatalf("failed to get index info: %v", err)
+			}
+			if len(indexes) != 0 {
+				t.Errorf("expected the indexes to be dropped, got %v", indexes)
+			}
+
+			// a table dropped and created again by one transaction
+			if _, err := planner.ExecuteUpdate("drop table T1", tx); err != nil {
+				t.Fatalf("failed to execute update: %v", err)
+			}
+			if _, err := planner.ExecuteUpdate("create table T1(A int, B varchar(5))", tx); err != nil {
+				t.Fatalf("failed to execute update: %v", err)
+			}
+			if _, err := planner.ExecuteUpdate("insert into T1(A, B) values(200, 'new')", tx); err != nil {
+				t.Fatalf("failed to execute update: %v", err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+			tx = db.NewReadOnlyTransaction()
+			assertRows(t, planner, "select A, B from T1", tx, []string{"200 new"})
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+
+			executeCommitted(t, db, "create index T1_A on T1(A)")
+			executeCommitted(t, db, "alter table T1 add column C int")
+			executeCommitted(t, db, "drop index T1_A")
+			if got := files("t1_a*.tbl"); len(got) != 0 {
+				t.Errorf("expected the files of the index to be deleted, got %v", got)
+			}
+			if _, err := os.Stat(path.Join(dir, "tblcat.tbl")); err != nil {
+				t.Errorf("expected the catalog to be kept: %v", err)
+			}
+		})
+	}
+}
+
+func TestDropWithSnapshotReader(t *testing.T) {
+	for _, cmd := range []string{"drop table T1", "alter table T1 add column C int"} {
+		t.Run(cmd, func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "dropsnapshottest")
+			db, err := server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to create new database: %v", err)
+			}
+			executeCommitted(t, db, "create table T1(A int, B varchar(40))")
+			for i := 1; i <= 30; i++ {
+				executeCommitted(t, db, fmt.Sprintf("insert into T1(A, B) values(%d, 'b%d')", i, i))
+			}
+			oldFile := path.Join(dir, "t1.tbl")
+
+			// the reader is partway through the table, which spans several
+			// blocks, when the command commits
+			ro := db.NewReadOnlyTransaction()
+			plan, err := db.Planner().CreateQueryPlan("select A from T1", ro)
+			if err != nil {
+				t.Fatalf("failed to create query plan: %v", err)
+			}
+			scan, err := plan.Open()
+			if err != nil {
+				t.Fatalf("failed to open scan: %v", err)
+			}
+			if next, err := scan.Next(); err != nil || !next {
+				t.Fatalf("failed to get next: %v", err)
+			}
+			executeCommitted(t, db, cmd)
+
+			// the old files are kept for the reader's snapshot
+			count := 1
+			for {
+				next, err := scan.Next()
+				if err != nil {
+					t.Fatalf("failed to get next: %v", err)
+				}
+				if !next {
+					break
+				}
+				count++
+			}
+			scan.Close()
+			if count != 30 {
+				t.Errorf("expected 30 records, got %d", count)
+			}
+			if _, err := os.Stat(oldFile); err != nil {
+				t.Errorf("expected %s to be kept while the reader is active: %v", oldFile, err)
+			}
+
+			if err := ro.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+			if _, err := os.Stat(oldFile); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be deleted once the reader finished, got %v", oldFile, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package plan_test
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestFunctions(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "functiontest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table PERSON(id int, first varchar(8), last varchar(12), nick varchar(8), balance decimal(8, 2), born date, seen timestamp)")
+	executeCommitted(t, db, "insert into PERSON(id, first, last, nick, balance, born, seen) values(1, 'Ada', ' Lovelace ', 'ada', -12.50, date '1815-12-10', timestamp '2024-02-28 10:00:00')")
+	executeCommitted(t, db, "insert into PERSON(id, first, last, balance, born) values(2, 'Alan', 'Turing', 3.00, date '1912-06-23')")
+	executeCommitted(t, db, "insert into PERSON(id, first, last) values(3, 'Grace', 'Hopper')")
+	executeCommitted(t, db, "update PERSON set nick = lower(substr(first, 1, 3)) where nick is null and id = 3")
+	executeCommitted(t, db, "create view NAMES as select id, first || ' ' || trim(last) as full from PERSON")
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	planner := db.Planner()
+	assertRows(t, planner, "select id, upper(first), length(last), substr(first, 2), substr(first, 0, 3) from PERSON", tx,
+		[]string{"1 ADA 10 da Ad", "2 ALAN 6 lan Al", "3 GRACE 6 race Gr"})
+	assertRows(t, planner, "select id, full from NAMES where length(full) > 11", tx, []string{"1 Ada Lovelace", "3 Grace Hopper"})
+	assertRows(t, planner, "select id, coalesce(nick, first), nullif(nick, 'ada'), abs(balance) from PERSON", tx,
+		[]string{"1 ada null 12.50", "2 Alan null 3.00", "3 gra gra null"})
+	assertRows(t, planner, "select id, year(born), month(born), day(born), add_days(born, 1) from PERSON where born is not null", tx,
+		[]string{"1 1815 12 10 1815-12-11", "2 1912 6 23 1912-06-24"})
+	assertRows(t, planner, "select id, add_days(seen, 2) from PERSON where id = 1", tx, []string{"1 2024-03-01 10:00:00"})
+	assertRows(t, planner, "select id from PERSON where upper(nick) = 'ADA' or abs(balance) = 3", tx, []string{"1", "2"})
+
+	// functions have the types of their results
+	p, err := planner.CreateQueryPlan("select first || last as full, length(first) as n, abs(balance) as b, coalesce(id, balance) as c, year(seen) as y from PERSON", tx)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	sch := p.Schema()
+	for fieldName, want := range map[string]record.FieldType{
+		"full": record.STRING,
+		"n":    record.INTEGER,
+		"b":    record.DECIMAL,
+		"c":    record.DECIMAL,
+		"y":    record.INTEGER,
+	} {
+		if got := sch.Type(fieldName); got != want {
+			t.Errorf("expected %s to be %s, got %s", fieldName, want, got)
+		}
+	}
+	if sch.Length("full") != 20 {
+		t.Errorf("expected full to have length 20, got %d", sch.Length("full"))
+	}
+
+	// functions of arguments of types they do not take fail before the scan
+	for _, cmd := range []string{
+		"select upper(id) as u from PERSON",
+		"select substr(first, last) as s from PERSON",
+		"select id || first as s from PERSON",
+		"select coalesce(first, id) as c from PERSON",
+		"select id from PERSON where year(first) = 1",
+	} {
+		if _, err := planner.CreateQueryPlan(cmd, tx); !errors.Is(err, query.ErrTypeMismatch) {
+			t.Errorf("%s: expected %v, got %v", cmd, query.ErrTypeMismatch, err)
+		}
+	}
+	if _, err := planner.ExecuteUpdate("update PERSON set id = abs(first)", tx); !errors.Is(err, query.ErrTypeMismatch) {
+		t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+	}
+	if _, err := planner.ExecuteUpdate("delete from PERSON where length(id) = 1", tx); !errors.Is(err, query.ErrTypeMismatch) {
+		t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to rollback transaction: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math/rand/v2"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/server"
@@ -187,6 +188,100 @@ func TestIndexDelete(t *testing.T) {
 	}
 }
 
+func TestIndexKeyLocks(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "indexkeylocktest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	// a record takes a block, so every insert appends one
+	executeCommitted(t, db, "create table t1(a int, b varchar(150))")
+	executeCommitted(t, db, "create index idxa on t1(a)")
+	insertIndexedA(t, db, 1)
+
+	reader, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	assertIndexA(t, db, reader, "a", query.NewConstantWithInt(1), 1)
+	assertIndexA(t, db, reader, "a", query.NewConstantWithInt(2), 0)
+
+	// the keys the reader looked up are locked, but not the end of the table
+	done := make(chan struct{})
+	go func() {
+		insertIndexedA(t, db, 3)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected the insert of another key not to wait for the reader")
+	}
+
+	done = make(chan struct{})
+	go func() {
+		insertIndexedA(t, db, 2)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatalf("expected the insert of a key the reader looked up to wait for it")
+	default:
+	}
+	assertIndexA(t, db, reader, "a", query.NewConstantWithInt(2), 0)
+	if err := reader.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	<-done
+}
+
+// insertIndexedA inserts a record into T1 and the index on its field A,
+// in a transaction of its own.
+func insertIndexedA(t *testing.T, db *server.SimpleDB, a int32) {
+	t.Helper()
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Errorf("failed to create new transaction: %v", err)
+		return
+	}
+	defer func() {
+		if err := tx.Commit(); err != nil {
+			t.Errorf("failed to commit transaction: %v", err)
+		}
+	}()
+	mdm := db.MetadataManager()
+	indexes, err := mdm.GetIndexInfo("t1", tx)
+	if err != nil {
+		t.Errorf("failed to get index info: %v", err)
+		return
+	}
+	layout, err := mdm.GetLayout("t1", tx)
+	if err != nil {
+		t.Errorf("failed to get layout: %v", err)
+		return
+	}
+	ts, err := query.NewTableScan(tx, "t1", layout)
+	if err != nil {
+		t.Errorf("failed to create table scan: %v", err)
+		return
+	}
+	defer ts.Close()
+	if err := ts.Insert(); err != nil {
+		t.Errorf("failed to insert record: %v", err)
+		return
+	}
+	if err := ts.SetInt("a", a); err != nil {
+		t.Errorf("failed to set int: %v", err)
+		return
+	}
+	idx := indexes["a"].Open()
+	defer idx.Close()
+	if err := idx.Insert(query.NewConstantWithInt(a), ts.GetRID()); err != nil {
+		t.Errorf("failed to insert index: %v", err)
+	}
+}
+
 func initialize(db *server.SimpleDB, tx *tx.Transaction, t *testing.T) {
 	planner := db.Planner()
 
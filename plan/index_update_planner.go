@@ -30,6 +30,10 @@ func (up *IndexUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Trans
 	if !ok {
 		return 0, fmt.Errorf("insert: invalid scan type")
 	}
+	if err := checkNotNull(p.Schema(), data); err != nil {
+		us.Close()
+		return 0, err
+	}
 	if err := us.Insert(); err != nil {
 		return 0, err
 	}
@@ -42,12 +46,16 @@ func (up *IndexUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Trans
 	for i := 0; i < len(data.Fields); i++ {
 		fieldName := data.Fields[i]
 		val := data.Values[i]
+		if !p.Schema().HasField(fieldName) {
+			return 0, fmt.Errorf("insert: field %s not found in %s", fieldName, tableName)
+		}
 		if err := us.SetVal(fieldName, val); err != nil {
 			return 0, err
 		}
 
+		// NULL is not indexed, as no search finds it
 		ii, ok := indexes[fieldName]
-		if ok {
+		if ok && !val.IsNull() {
 			idx := ii.Open()
 			if err := idx.Insert(val, rid); err != nil {
 				return 0, err
@@ -66,6 +74,9 @@ func (up *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Trans
 		return 0, err
 	}
 	sp := NewSelectPlan(tp, data.Pred)
+	if err := data.Pred.CheckTypes(sp.Schema()); err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
 	indexes, err := up.mdm.GetIndexInfo(tableName, tx)
 	if err != nil {
 		return 0, err
@@ -96,6 +107,9 @@ func (up *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Trans
 			if err != nil {
 				return 0, err
 			}
+			if val.IsNull() {
+				continue
+			}
 			ii := indexes[fieldName]
 			idx := ii.Open()
 			if err := idx.Delete(val, rid); err != nil {
@@ -121,6 +135,12 @@ func (up *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Trans
 		return 0, err
 	}
 	sp := NewSelectPlan(tp, data.Pred)
+	if err := data.Pred.CheckTypes(sp.Schema()); err != nil {
+		return 0, fmt.Errorf("modify: %w", err)
+	}
+	if _, err := data.NewValue.Type(sp.Schema()); err != nil {
+		return 0, fmt.Errorf("modify: %w", err)
+	}
 
 	indexes, err := up.mdm.GetIndexInfo(tableName, tx)
 	if err != nil {
@@ -165,11 +185,15 @@ func (up *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Trans
 
 		if idx != nil {
 			rid := us.GetRID()
-			if err := idx.Delete(oldVal, rid); err != nil {
-				return 0, err
+			if !oldVal.IsNull() {
+				if err := idx.Delete(oldVal, rid); err != nil {
+					return 0, err
+				}
 			}
-			if err := idx.Insert(newVal, rid); err != nil {
-				return 0, err
+			if !newVal.IsNull() {
+				if err := idx.Insert(newVal, rid); err != nil {
+					return 0, err
+				}
 			}
 		}
 
@@ -184,7 +208,7 @@ func (up *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Trans
 }
 
 func (up *IndexUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) (int32, error) {
-	if err := up.mdm.CreateTable(data.TableName, data.Schema, tx); err != nil {
+	if err := up.mdm.CreateTableWithFormat(data.TableName, data.Schema, data.Format, tx); err != nil {
 		return 0, err
 	}
 	return 0, nil
@@ -203,3 +227,19 @@ func (up *IndexUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, tx
 	}
 	return 0, nil
 }
+
+func (up *IndexUpdatePlanner) ExecuteAlterTable(data *parse.AlterTableData, tx *tx.Transaction) (int32, error) {
+	return alterTable(up.mdm, data, tx)
+}
+
+func (up *IndexUpdatePlanner) ExecuteDropTable(data *parse.DropTableData, tx *tx.Transaction) (int32, error) {
+	return dropTable(up.mdm, data, tx)
+}
+
+func (up *IndexUpdatePlanner) ExecuteDropView(data *parse.DropViewData, tx *tx.Transaction) (int32, error) {
+	return dropView(up.mdm, data, tx)
+}
+
+func (up *IndexUpdatePlanner) ExecuteDropIndex(data *parse.DropIndexData, tx *tx.Transaction) (int32, error) {
+	return dropIndex(up.mdm, data, tx)
+}
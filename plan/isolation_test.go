@@ -0,0 +1,133 @@
+package plan_test
+
+import (
+	"errors"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+func TestReadCommitted(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "readcommittedtest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	planner := db.Planner()
+	executeCommitted(t, db, "create table T1(A int, B varchar(9))")
+	executeCommitted(t, db, "insert into T1(A, B) values(1, 'one')")
+
+	reader, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := planner.ExecuteUpdate("set transaction isolation level read committed", reader); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+	if reader.IsolationLevel() != tx.READ_COMMITTED {
+		t.Fatalf("expected %v, got %v", tx.READ_COMMITTED, reader.IsolationLevel())
+	}
+	assertA(t, planner, "select A from T1", reader, []int32{1})
+
+	// the reader holds no lock once it has read, so the writer goes ahead,
+	// and the reader sees the committed change when it reads again
+	executeCommitted(t, db, "update T1 set A=2 where A=1")
+	assertA(t, planner, "select A from T1", reader, []int32{2})
+
+	// the isolation level is fixed once the transaction has run a statement
+	if _, err := planner.ExecuteUpdate("set transaction isolation level repeatable read", reader); !errors.Is(err, tx.ErrStatementStarted) {
+		t.Fatalf("expected ErrStatementStarted, got %v", err)
+	}
+	if reader.IsolationLevel() != tx.READ_COMMITTED {
+		t.Fatalf("expected %v, got %v", tx.READ_COMMITTED, reader.IsolationLevel())
+	}
+	if err := reader.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	// a repeatable read keeps its locks until it finishes
+	reader, err = db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := planner.ExecuteUpdate("set transaction isolation level repeatable read", reader); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+	assertA(t, planner, "select A from T1", reader, []int32{2})
+	done := make(chan struct{})
+	go func() {
+		executeCommitted(t, db, "update T1 set A=3 where A=2")
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatalf("expected the update to wait for the reader")
+	default:
+	}
+	assertA(t, planner, "select A from T1", reader, []int32{2})
+	if err := reader.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	<-done
+}
+
+func TestReadCommittedSnapshots(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "readcommittedmvcctest"), server.WithMVCC())
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	planner := db.Planner()
+	executeCommitted(t, db, "create table T1(A int, B varchar(9))")
+	executeCommitted(t, db, "insert into T1(A, B) values(1, 'one')")
+
+	committed, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if err := committed.SetIsolationLevel(tx.READ_COMMITTED); err != nil {
+		t.Fatalf("failed to set isolation level: %v", err)
+	}
+	repeatable, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if repeatable.IsolationLevel() != tx.REPEATABLE_READ {
+		t.Fatalf("expected %v, got %v", tx.REPEATABLE_READ, repeatable.IsolationLevel())
+	}
+	assertA(t, planner, "select A from T1", committed, []int32{1})
+	assertA(t, planner, "select A from T1", repeatable, []int32{1})
+
+	executeCommitted(t, db, "insert into T1(A, B) values(2, 'two')")
+
+	// each statement of a read committed transaction takes a new snapshot
+	assertA(t, planner, "select A from T1", committed, []int32{1, 2})
+	assertA(t, planner, "select A from T1", repeatable, []int32{1})
+
+	for _, tx := range []*tx.Transaction{committed, repeatable} {
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("failed to commit transaction: %v", err)
+		}
+	}
+}
+
+// executeCommitted runs the command in a transaction of its own.
+func executeCommitted(t *testing.T, db *server.SimpleDB, command string) {
+	t.Helper()
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Errorf("failed to create new transaction: %v", err)
+		return
+	}
+	if _, err := db.Planner().ExecuteUpdate(command, tx); err != nil {
+		t.Errorf("failed to execute update: %v", err)
+		tx.Rollback()
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("failed to commit transaction: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package plan_test
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/adieumonks/simple-db/plan"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
+)
+
+func TestSelectForUpdate(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "forupdatetest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	planner := db.Planner()
+	executeCommitted(t, db, "create table queue(A int, B varchar(9))")
+	for i := range 10 {
+		executeCommitted(t, db, fmt.Sprintf("insert into queue(A, B) values(%d, 'job')", i))
+	}
+
+	worker1, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	job1, err := firstA(planner, "select A from queue for update", worker1)
+	if err != nil {
+		t.Fatalf("failed to take job: %v", err)
+	}
+
+	worker2, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := firstA(planner, "select A from queue for update nowait", worker2); !errors.Is(err, concurrency.ErrLockNotAvailable) {
+		t.Errorf("expected %v, got %v", concurrency.ErrLockNotAvailable, err)
+	}
+	job2, err := firstA(planner, "select A from queue for update skip locked", worker2)
+	if err != nil {
+		t.Fatalf("failed to take job: %v", err)
+	}
+	if job2 == job1 {
+		t.Errorf("expected the workers to take different jobs, both took %d", job1)
+	}
+
+	// once the first worker is done, its jobs are available again
+	if err := worker1.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	job, err := firstA(planner, "select A from queue for update nowait", worker2)
+	if err != nil {
+		t.Fatalf("failed to take job: %v", err)
+	}
+	if job != job1 {
+		t.Errorf("expected job %d, got %d", job1, job)
+	}
+	if err := worker2.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestSelectForUpdateWhere(t *testing.T) {
+	for _, format := range []string{"fixed", "slotted"} {
+		t.Run(format, func(t *testing.T) {
+			db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "forupdatewheretest"))
+			if err != nil {
+				t.Fatalf("failed to create new database: %v", err)
+			}
+			planner := db.Planner()
+			// slotted tables are locked block by block, so the records go in several
+			executeCommitted(t, db, fmt.Sprintf("create table queue(A int, B varchar(9)) using %s", format))
+			for i := range 50 {
+				executeCommitted(t, db, fmt.Sprintf("insert into queue(A) values(%d)", i))
+			}
+
+			worker1, err := db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			if _, err := firstA(planner, "select A from queue where A = 49 for update", worker1); err != nil {
+				t.Fatalf("failed to take job: %v", err)
+			}
+
+			// only the records the first worker selected stay locked
+			worker2, err := db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			job, err := firstA(planner, "select A from queue where A = 1 for update nowait", worker2)
+			if err != nil {
+				t.Fatalf("failed to take job: %v", err)
+			}
+			if job != 1 {
+				t.Errorf("expected job 1, got %d", job)
+			}
+			jobs, err := allA(planner, "select A from queue where A < 3 for update skip locked", worker2)
+			if err != nil {
+				t.Fatalf("failed to take jobs: %v", err)
+			}
+			if fmt.Sprint(jobs) != "[0 1 2]" {
+				t.Errorf("expected jobs [0 1 2], got %v", jobs)
+			}
+			jobs, err = allA(planner, "select A from queue where A > 46 for update skip locked", worker2)
+			if err != nil {
+				t.Fatalf("failed to take jobs: %v", err)
+			}
+			if slices.Contains(jobs, 49) {
+				t.Errorf("expected job 49 to be skipped, got %v", jobs)
+			}
+			if _, err := allA(planner, "select A from queue where A > 46 for update nowait", worker2); !errors.Is(err, concurrency.ErrLockNotAvailable) {
+				t.Errorf("expected %v, got %v", concurrency.ErrLockNotAvailable, err)
+			}
+			if err := worker2.Rollback(); err != nil {
+				t.Fatalf("failed to rollback transaction: %v", err)
+			}
+			if err := worker1.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+		})
+	}
+}
+
+// allA returns field A of all the records the query finds.
+func allA(planner *plan.Planner, query string, tx *tx.Transaction) ([]int32, error) {
+	p, err := planner.CreateQueryPlan(query, tx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := p.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	var as []int32
+	for {
+		next, err := s.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !next {
+			return as, nil
+		}
+		a, err := s.GetInt("a")
+		if err != nil {
+			return nil, err
+		}
+		as = append(as, a)
+	}
+}
+
+// firstA returns field A of the first record the query finds.
+func firstA(planner *plan.Planner, query string, tx *tx.Transaction) (int32, error) {
+	p, err := planner.CreateQueryPlan(query, tx)
+	if err != nil {
+		return 0, err
+	}
+	s, err := p.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	next, err := s.Next()
+	if err != nil {
+		return 0, err
+	}
+	if !next {
+		return 0, fmt.Errorf("no record found")
+	}
+	return s.GetInt("a")
+}
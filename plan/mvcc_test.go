@@ -0,0 +1,150 @@
+package plan_test
+
+import (
+	"errors"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/adieumonks/simple-db/plan"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+func TestMVCCSnapshotIsolation(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "mvcctest"), server.WithMVCC())
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	planner := db.Planner()
+
+	setup, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	for _, command := range []string{
+		"create table T1(A int, B varchar(9))",
+		"insert into T1(A, B) values(1, 'one')",
+		"insert into T1(A, B) values(2, 'two')",
+		"insert into T1(A, B) values(3, 'three')",
+	} {
+		if _, err := planner.ExecuteUpdate(command, setup); err != nil {
+			t.Fatalf("failed to execute update: %v", err)
+		}
+	}
+	if err := setup.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	reader, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	assertA(t, planner, "select A from T1", reader, []int32{1, 2, 3})
+
+	// the writer's changes neither wait for the reader nor show up for it
+	writer, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	for _, command := range []string{
+		"update T1 set A=20 where A=2",
+		"delete from T1 where A=3",
+		"insert into T1(A, B) values(4, 'four')",
+	} {
+		if _, err := planner.ExecuteUpdate(command, writer); err != nil {
+			t.Fatalf("failed to execute update: %v", err)
+		}
+	}
+	assertA(t, planner, "select A from T1", writer, []int32{1, 4, 20})
+	assertA(t, planner, "select A from T1", reader, []int32{1, 2, 3})
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	assertA(t, planner, "select A from T1", reader, []int32{1, 2, 3})
+
+	// the first updater wins
+	if _, err := planner.ExecuteUpdate("update T1 set B='deux' where A=2", reader); !errors.Is(err, query.ErrWriteConflict) {
+		t.Errorf("expected %v, got %v", query.ErrWriteConflict, err)
+	}
+	if err := reader.Rollback(); err != nil {
+		t.Fatalf("failed to rollback transaction: %v", err)
+	}
+
+	// old versions are kept while a transaction may still see them
+	old, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	updater, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := planner.ExecuteUpdate("update T1 set A=10 where A=1", updater); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+	if err := updater.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	freed, err := db.Vacuum("t1")
+	if err != nil {
+		t.Fatalf("failed to vacuum: %v", err)
+	}
+	// the versions replaced or deleted by the writer
+	if freed != 2 {
+		t.Errorf("expected 2 versions to be freed, got %d", freed)
+	}
+	assertA(t, planner, "select A from T1", old, []int32{1, 4, 20})
+	if err := old.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	freed, err = db.Vacuum("t1")
+	if err != nil {
+		t.Fatalf("failed to vacuum: %v", err)
+	}
+	if freed != 1 {
+		t.Errorf("expected 1 version to be freed, got %d", freed)
+	}
+	latest := db.NewReadOnlyTransaction()
+	assertA(t, planner, "select A from T1", latest, []int32{4, 10, 20})
+	if err := latest.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+// assertA checks the sorted values of field A in the result of the query.
+func assertA(t *testing.T, planner *plan.Planner, query string, tx *tx.Transaction, expected []int32) {
+	t.Helper()
+
+	p, err := planner.CreateQueryPlan(query, tx)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	s, err := p.Open()
+	if err != nil {
+		t.Fatalf("failed to open scan: %v", err)
+	}
+	defer s.Close()
+
+	got := []int32{}
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next scan: %v", err)
+		}
+		if !next {
+			break
+		}
+		a, err := s.GetInt("a")
+		if err != nil {
+			t.Fatalf("failed to get int: %v", err)
+		}
+		got = append(got, a)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,62 @@
+package plan_test
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestNull(t *testing.T) {
+	for _, format := range []string{"fixed", "slotted", "columnar"} {
+		t.Run(format, func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "nulltest")
+			db, err := server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to create new database: %v", err)
+			}
+			executeCommitted(t, db, fmt.Sprintf("create table T1(A int not null, B varchar(10), C int null) using %s", format))
+			executeCommitted(t, db, "create index T1_B on T1(B)")
+			executeCommitted(t, db, "insert into T1(A, B, C) values(1, 'one', 10)")
+			// fields not given start NULL
+			executeCommitted(t, db, "insert into T1(A) values(2)")
+			executeCommitted(t, db, "insert into T1(A, B, C) values(3, null, 30)")
+			executeCommitted(t, db, "insert into T1(A, B, C) values(4, 'four', null)")
+			executeCommitted(t, db, "update T1 set B = null where A = 1")
+			executeCommitted(t, db, "update T1 set C = 40 where A = 4")
+
+			// the nullability of the fields is kept in the catalog
+			db, err = server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			tx, err := db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			planner := db.Planner()
+			_, err = planner.ExecuteUpdate("insert into T1(B) values('none')", tx)
+			if !errors.Is(err, query.ErrNotNull) {
+				t.Errorf("expected %v, got %v", query.ErrNotNull, err)
+			}
+			_, err = planner.ExecuteUpdate("update T1 set A = null where A = 2", tx)
+			if !errors.Is(err, query.ErrNotNull) {
+				t.Errorf("expected %v, got %v", query.ErrNotNull, err)
+			}
+
+			assertA(t, planner, "select A from T1 where B is null", tx, []int32{1, 2, 3})
+			assertA(t, planner, "select A from T1 where B is not null", tx, []int32{4})
+			assertA(t, planner, "select A from T1 where C is null", tx, []int32{2})
+			assertA(t, planner, "select A from T1 where B = 'four'", tx, []int32{4})
+			// a comparison with NULL is never true
+			assertA(t, planner, "select A from T1 where B = null", tx, []int32{})
+			assertA(t, planner, "select A from T1 where C = C", tx, []int32{1, 3, 4})
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package plan
 
 import (
+	"fmt"
+	"math"
+
 	"github.com/adieumonks/simple-db/metadata"
 	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/record"
@@ -22,6 +25,8 @@ type TablePlan struct {
 	tx        *tx.Transaction
 	layout    *record.Layout
 	si        *metadata.StatInfo
+	// how the scan locks the records it reads
+	lockPolicy query.LockPolicy
 }
 
 func NewTablePlan(tx *tx.Transaction, tableName string, mdm *metadata.MetadataManager) (*TablePlan, error) {
@@ -42,7 +47,17 @@ func NewTablePlan(tx *tx.Transaction, tableName string, mdm *metadata.MetadataMa
 }
 
 func (tp *TablePlan) Open() (query.Scan, error) {
-	return query.NewTableScan(tp.tx, tp.tableName, tp.layout)
+	ts, err := query.NewTableScan(tp.tx, tp.tableName, tp.layout)
+	if err != nil {
+		return nil, err
+	}
+	ts.SetLockPolicy(tp.lockPolicy)
+	return ts, nil
+}
+
+// SetLockPolicy sets how the scans of the plan lock the records they read.
+func (tp *TablePlan) SetLockPolicy(policy query.LockPolicy) {
+	tp.lockPolicy = policy
 }
 
 func (tp *TablePlan) BlocksAccessed() int32 {
@@ -88,7 +103,7 @@ func (sp *SelectPlan) BlocksAccessed() int32 {
 }
 
 func (sp *SelectPlan) RecordsOutput() int32 {
-	return sp.p.RecordsOutput() / sp.pred.ReductionFactor(sp.p)
+	return int32(math.Round(float64(sp.p.RecordsOutput()) * sp.pred.Selectivity(sp.p)))
 }
 
 func (sp *SelectPlan) DistinctValues(fieldName string) int32 {
@@ -113,6 +128,8 @@ var _ Plan = (*ProjectPlan)(nil)
 type ProjectPlan struct {
 	p      Plan
 	schema *record.Schema
+	// the expressions of the computed fields, by their names
+	exprs map[string]*query.Expression
 }
 
 func NewProjectPlan(p Plan, fields []string) *ProjectPlan {
@@ -126,12 +143,35 @@ func NewProjectPlan(p Plan, fields []string) *ProjectPlan {
 	}
 }
 
+// NewProjectPlanWithExpressions returns a plan of the fields, computing
+// those that have an expression. Their fields in the output schema have
+// the types of the values of their expressions, which fails with
+// query.ErrTypeMismatch if they do not type check.
+func NewProjectPlanWithExpressions(p Plan, fields []string, exprs map[string]*query.Expression) (*ProjectPlan, error) {
+	schema := record.NewSchema()
+	for _, field := range fields {
+		expr, ok := exprs[field]
+		if !ok {
+			schema.Add(field, p.Schema())
+			continue
+		}
+		if err := expr.AddField(schema, field, p.Schema()); err != nil {
+			return nil, fmt.Errorf("failed to compute %s: %w", field, err)
+		}
+	}
+	return &ProjectPlan{
+		p:      p,
+		schema: schema,
+		exprs:  exprs,
+	}, nil
+}
+
 func (pp *ProjectPlan) Open() (query.Scan, error) {
 	s, err := pp.p.Open()
 	if err != nil {
 		return nil, err
 	}
-	return query.NewProjectScan(s, pp.schema.Fields()), nil
+	return query.NewProjectScanWithExpressions(s, pp.schema.Fields(), pp.exprs), nil
 }
 
 func (pp *ProjectPlan) BlocksAccessed() int32 {
@@ -142,8 +182,18 @@ func (pp *ProjectPlan) RecordsOutput() int32 {
 	return pp.p.RecordsOutput()
 }
 
+// DistinctValues estimates a computed field to have as many values as the
+// field it uses that has the most.
 func (pp *ProjectPlan) DistinctValues(fieldName string) int32 {
-	return pp.p.DistinctValues(fieldName)
+	expr, ok := pp.exprs[fieldName]
+	if !ok {
+		return pp.p.DistinctValues(fieldName)
+	}
+	distinct := int32(1)
+	for _, name := range expr.FieldNames() {
+		distinct = max(distinct, pp.p.DistinctValues(name))
+	}
+	return distinct
 }
 
 func (pp *ProjectPlan) Schema() *record.Schema {
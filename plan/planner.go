@@ -1,11 +1,14 @@
 package plan
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/adieumonks/simple-db/metadata"
 	"github.com/adieumonks/simple-db/parse"
 	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
 	"github.com/adieumonks/simple-db/tx"
 )
 
@@ -20,6 +23,10 @@ type UpdatePlanner interface {
 	ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) (int32, error)
 	ExecuteCreateView(data *parse.CreateViewData, tx *tx.Transaction) (int32, error)
 	ExecuteCreateIndex(data *parse.CreateIndexData, tx *tx.Transaction) (int32, error)
+	ExecuteAlterTable(data *parse.AlterTableData, tx *tx.Transaction) (int32, error)
+	ExecuteDropTable(data *parse.DropTableData, tx *tx.Transaction) (int32, error)
+	ExecuteDropView(data *parse.DropViewData, tx *tx.Transaction) (int32, error)
+	ExecuteDropIndex(data *parse.DropIndexData, tx *tx.Transaction) (int32, error)
 }
 
 type BasicQueryPlanner struct {
@@ -46,6 +53,9 @@ func (qp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transactio
 			if err != nil {
 				return nil, err
 			}
+			if data.LockPolicy != query.READ {
+				viewData.LockPolicy = data.LockPolicy
+			}
 			viewPlan, err := qp.CreatePlan(viewData, tx)
 			if err != nil {
 				return nil, err
@@ -56,6 +66,7 @@ func (qp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transactio
 			if err != nil {
 				return nil, err
 			}
+			tablePlan.SetLockPolicy(data.LockPolicy)
 			plans = append(plans, tablePlan)
 		}
 	}
@@ -72,10 +83,11 @@ func (qp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transactio
 	}
 
 	plan = NewSelectPlan(plan, data.Pred)
+	if err := data.Pred.CheckTypes(plan.Schema()); err != nil {
+		return nil, err
+	}
 
-	plan = NewProjectPlan(plan, data.Fields)
-
-	return plan, nil
+	return NewProjectPlanWithExpressions(plan, data.Fields, data.Exprs)
 }
 
 type BasicUpdatePlanner struct {
@@ -93,6 +105,9 @@ func (up *BasicUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Trans
 		return 0, err
 	}
 	plan = NewSelectPlan(plan, data.Pred)
+	if err := data.Pred.CheckTypes(plan.Schema()); err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
 
 	scan, err := plan.Open()
 	if err != nil {
@@ -129,6 +144,12 @@ func (up *BasicUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Trans
 		return 0, err
 	}
 	plan = NewSelectPlan(plan, data.Pred)
+	if err := data.Pred.CheckTypes(plan.Schema()); err != nil {
+		return 0, fmt.Errorf("modify: %w", err)
+	}
+	if _, err := data.NewValue.Type(plan.Schema()); err != nil {
+		return 0, fmt.Errorf("modify: %w", err)
+	}
 
 	scan, err := plan.Open()
 	if err != nil {
@@ -178,6 +199,9 @@ func (up *BasicUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Trans
 	if !ok {
 		return 0, fmt.Errorf("insert: invalid scan type")
 	}
+	if err := checkNotNull(plan.Schema(), data); err != nil {
+		return 0, err
+	}
 
 	if err := us.Insert(); err != nil {
 		return 0, err
@@ -186,6 +210,9 @@ func (up *BasicUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Trans
 	for i := 0; i < len(data.Fields); i++ {
 		field := data.Fields[i]
 		val := data.Values[i]
+		if !plan.Schema().HasField(field) {
+			return 0, fmt.Errorf("insert: field %s not found in %s", field, data.TableName)
+		}
 		if err := us.SetVal(field, val); err != nil {
 			return 0, err
 		}
@@ -195,7 +222,7 @@ func (up *BasicUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Trans
 }
 
 func (up *BasicUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) (int32, error) {
-	if err := up.mdm.CreateTable(data.TableName, data.Schema, tx); err != nil {
+	if err := up.mdm.CreateTableWithFormat(data.TableName, data.Schema, data.Format, tx); err != nil {
 		return 0, err
 	}
 	return 0, nil
@@ -215,6 +242,22 @@ func (up *BasicUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, tx
 	return 0, nil
 }
 
+func (up *BasicUpdatePlanner) ExecuteAlterTable(data *parse.AlterTableData, tx *tx.Transaction) (int32, error) {
+	return alterTable(up.mdm, data, tx)
+}
+
+func (up *BasicUpdatePlanner) ExecuteDropTable(data *parse.DropTableData, tx *tx.Transaction) (int32, error) {
+	return dropTable(up.mdm, data, tx)
+}
+
+func (up *BasicUpdatePlanner) ExecuteDropView(data *parse.DropViewData, tx *tx.Transaction) (int32, error) {
+	return dropView(up.mdm, data, tx)
+}
+
+func (up *BasicUpdatePlanner) ExecuteDropIndex(data *parse.DropIndexData, tx *tx.Transaction) (int32, error) {
+	return dropIndex(up.mdm, data, tx)
+}
+
 type Planner struct {
 	qp QueryPlanner
 	up UpdatePlanner
@@ -233,6 +276,7 @@ func (p *Planner) CreateQueryPlan(query string, tx *tx.Transaction) (Plan, error
 	if err != nil {
 		return nil, err
 	}
+	tx.StartStatement()
 	return p.qp.CreatePlan(data, tx)
 }
 
@@ -245,6 +289,31 @@ func (p *Planner) ExecuteUpdate(command string, tx *tx.Transaction) (int32, erro
 	if err != nil {
 		return 0, err
 	}
+	if data, ok := data.(*parse.SetIsolationLevelData); ok {
+		if err := tx.SetIsolationLevel(data.Level); err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+	tx.StartStatement()
+
+	// undo the partial effects of a failed statement,
+	// leaving earlier statements of the transaction intact
+	savepoint, err := tx.Savepoint()
+	if err != nil {
+		return 0, err
+	}
+	count, err := p.executeUpdate(data, tx)
+	if err != nil {
+		if rbErr := tx.RollbackToSavepoint(savepoint); rbErr != nil {
+			return 0, errors.Join(err, rbErr)
+		}
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *Planner) executeUpdate(data parse.UpdateCommand, tx *tx.Transaction) (int32, error) {
 	switch data.CommandType() {
 	case parse.Insert:
 		return p.up.ExecuteInsert(data.(*parse.InsertData), tx)
@@ -258,7 +327,30 @@ func (p *Planner) ExecuteUpdate(command string, tx *tx.Transaction) (int32, erro
 		return p.up.ExecuteCreateView(data.(*parse.CreateViewData), tx)
 	case parse.CreateIndex:
 		return p.up.ExecuteCreateIndex(data.(*parse.CreateIndexData), tx)
+	case parse.AlterTable:
+		return p.up.ExecuteAlterTable(data.(*parse.AlterTableData), tx)
+	case parse.DropTable:
+		return p.up.ExecuteDropTable(data.(*parse.DropTableData), tx)
+	case parse.DropView:
+		return p.up.ExecuteDropView(data.(*parse.DropViewData), tx)
+	case parse.DropIndex:
+		return p.up.ExecuteDropIndex(data.(*parse.DropIndexData), tx)
 	default:
 		return 0, fmt.Errorf("invalid command type")
 	}
 }
+
+// checkNotNull checks that the insert gives a value to every field of the
+// schema that does not allow NULL, as the others start NULL.
+func checkNotNull(schema *record.Schema, data *parse.InsertData) error {
+	for _, fieldName := range schema.Fields() {
+		if schema.Nullable(fieldName) {
+			continue
+		}
+		i := slices.Index(data.Fields, fieldName)
+		if i < 0 || data.Values[i].IsNull() {
+			return fmt.Errorf("insert: field %s of %s: %w", fieldName, data.TableName, query.ErrNotNull)
+		}
+	}
+	return nil
+}
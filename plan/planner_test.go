@@ -1,6 +1,7 @@
 package plan_test
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand/v2"
@@ -8,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
 )
 
 func TestQueryPlanner(t *testing.T) {
@@ -160,3 +162,159 @@ func TestUpdatePlanner(t *testing.T) {
 		t.Fatalf("failed to commit transaction: %v", err)
 	}
 }
+
+func TestUpdatePlannerStatementAtomicity(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "statementatomicitytest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+
+	planner := db.Planner()
+
+	if _, err := planner.ExecuteUpdate("create table T1(A int, B varchar(9))", tx); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+	if _, err := planner.ExecuteUpdate("insert into T1(A, B) values(1, 'one')", tx); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+
+	// the record is inserted and A is set before the unknown field C fails the statement
+	if _, err := planner.ExecuteUpdate("insert into T1(A, C) values(2, 'two')", tx); err == nil {
+		t.Fatalf("expected insert with unknown field to fail")
+	}
+
+	if _, err := planner.ExecuteUpdate("insert into T1(A, B) values(3, 'three')", tx); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+
+	p, err := planner.CreateQueryPlan("select A, B from T1", tx)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	s, err := p.Open()
+	if err != nil {
+		t.Fatalf("failed to open scan: %v", err)
+	}
+
+	got := []int32{}
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next scan: %v", err)
+		}
+		if !next {
+			break
+		}
+		a, err := s.GetInt("a")
+		if err != nil {
+			t.Fatalf("failed to get int: %v", err)
+		}
+		got = append(got, a)
+	}
+	s.Close()
+
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("unexpected records after failed statement: %v", got)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestReadOnlyTransactionQuery(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "readonlyquerytest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	planner := db.Planner()
+
+	tx1, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := planner.ExecuteUpdate("create table T1(A int, B varchar(9))", tx1); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+	if _, err := planner.ExecuteUpdate("insert into T1(A, B) values(1, 'one')", tx1); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	// tx2 holds an exclusive lock on the inserted block while the reader scans it
+	tx2, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := planner.ExecuteUpdate("insert into T1(A, B) values(2, 'two')", tx2); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+
+	ro := db.NewReadOnlyTransaction()
+	if _, err := planner.ExecuteUpdate("insert into T1(A, B) values(3, 'three')", ro); !errors.Is(err, tx.ErrReadOnly) {
+		t.Errorf("expected %v, got %v", tx.ErrReadOnly, err)
+	}
+
+	p, err := planner.CreateQueryPlan("select A from T1", ro)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	s, err := p.Open()
+	if err != nil {
+		t.Fatalf("failed to open scan: %v", err)
+	}
+	got := []int32{}
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next scan: %v", err)
+		}
+		if !next {
+			break
+		}
+		a, err := s.GetInt("a")
+		if err != nil {
+			t.Fatalf("failed to get int: %v", err)
+		}
+		got = append(got, a)
+	}
+	s.Close()
+
+	if len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected only the committed record, got %v", got)
+	}
+
+	if err := ro.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestCatalogQuery(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "catalogquerytest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table T1(A int, B varchar(9))")
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	planner := db.Planner()
+	assertRows(t, planner, "select tblname, fldname from fldcat where tblname = 't1'", tx, []string{"t1 a", "t1 b"})
+	assertRows(t, planner, "select tblname, generation from tblcat where tblname = 'tblcat' or tblname = 'fldcat'", tx,
+		[]string{"fldcat 0", "tblcat 0"})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package plan_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/parse"
+	"github.com/adieumonks/simple-db/plan"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestBooleanPredicate(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "booleantest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table T1(A int, B varchar(10), C int)")
+	executeCommitted(t, db, "insert into T1(A, B, C) values(1, 'x', 10)")
+	executeCommitted(t, db, "insert into T1(A, B, C) values(2, 'y', 20)")
+	executeCommitted(t, db, "insert into T1(A, B, C) values(3, 'x', null)")
+	executeCommitted(t, db, "insert into T1(A, B) values(4, null)")
+	executeCommitted(t, db, "create view V1 as select A, B from T1 where not (B = 'x' or C = 20)")
+
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	planner := db.Planner()
+	assertA(t, planner, "select A from T1 where A = 1 or A = 3", tx, []int32{1, 3})
+	assertA(t, planner, "select A from T1 where B = 'y' or A = 1 and C = 10", tx, []int32{1, 2})
+	assertA(t, planner, "select A from T1 where (B = 'y' or A = 1) and C = 20", tx, []int32{2})
+	assertA(t, planner, "select A from T1 where not B = 'x'", tx, []int32{2})
+	assertA(t, planner, "select A from T1 where not (A < 2 or A > 3)", tx, []int32{2, 3})
+	// UNKNOWN or TRUE is TRUE, and the negation of UNKNOWN is UNKNOWN
+	assertA(t, planner, "select A from T1 where C = 10 or B = 'x'", tx, []int32{1, 3})
+	assertA(t, planner, "select A from T1 where not (C = 10 or C = 20)", tx, []int32{})
+	assertA(t, planner, "select A from T1 where not C > 100 or B is null", tx, []int32{1, 2, 4})
+	// the view definition is written back with its parentheses
+	assertA(t, planner, "select A from V1", tx, []int32{})
+	assertA(t, planner, "select A from V1 where A = 2 or B = 'y'", tx, []int32{})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestBooleanPredicateEstimates(t *testing.T) {
+	dir := path.Join(t.TempDir(), "booleanestimatetest")
+	db, err := server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table T1(A int, B int)")
+	for i := 0; i < 90; i++ {
+		executeCommitted(t, db, "insert into T1(A, B) values(1, 2)")
+	}
+
+	// the statistics are gathered when the database opens
+	db, err = server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	p, err := plan.NewTablePlan(tx, "t1", db.MetadataManager())
+	if err != nil {
+		t.Fatalf("failed to create table plan: %v", err)
+	}
+	for _, tt := range []struct {
+		where    string
+		records  int32
+		distinct int32
+	}{
+		{"A < 10", 30, 31},
+		{"A < 10 or B < 10", 50, 31},
+		{"not A < 10", 60, 31},
+		{"A = 1 or A = 1", 6, 1},
+		{"A = 1 or A = 2", 6, 31},
+		{"A = 1 and (B = 2 or B = 2)", 0, 1},
+		{"not A = 1", 87, 31},
+		{"A = 1 or 1 = 1", 90, 31},
+	} {
+		parser, err := parse.NewParser(tt.where)
+		if err != nil {
+			t.Fatalf("failed to create parser: %v", err)
+		}
+		pred, err := parser.Predicate()
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.where, err)
+		}
+		sp := plan.NewSelectPlan(p, pred)
+		if got := sp.RecordsOutput(); got != tt.records {
+			t.Errorf("%s: expected %d records, got %d", tt.where, tt.records, got)
+		}
+		if got := sp.DistinctValues("a"); got != tt.distinct {
+			t.Errorf("%s: expected %d distinct values, got %d", tt.where, tt.distinct, got)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package plan_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestSlottedTable(t *testing.T) {
+	dir := path.Join(t.TempDir(), "slottedtabletest")
+	db, err := server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table T1(A int, B varchar(40)) using slotted")
+	for i := 0; i < 30; i++ {
+		executeCommitted(t, db, fmt.Sprintf("insert into T1(A, B) values(%d, 'b%d')", i, i))
+	}
+	long := strings.Repeat("x", 40)
+	// records grown too large for their blocks move, but keep their RIDs
+	for i := 0; i < 10; i++ {
+		executeCommitted(t, db, fmt.Sprintf("update T1 set B='%s' where A=%d", long, i))
+	}
+	executeCommitted(t, db, "delete from T1 where A=4")
+
+	// the format is kept in the catalog
+	db, err = server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	layout, err := db.MetadataManager().GetLayout("t1", tx)
+	if err != nil {
+		t.Fatalf("failed to get layout: %v", err)
+	}
+	if layout.Format() != record.SLOTTED {
+		t.Fatalf("expected format %v, got %v", record.SLOTTED, layout.Format())
+	}
+	size, err := tx.Size("t1.tbl")
+	if err != nil {
+		t.Fatalf("failed to get size: %v", err)
+	}
+	// most records are short, and take fewer blocks than their fixed
+	// size would need
+	if fixedBlocks := int32(30) / (tx.BlockSize() / layout.SlotSize()); size >= fixedBlocks {
+		t.Errorf("expected fewer than %d blocks, got %d", fixedBlocks, size)
+	}
+
+	planner := db.Planner()
+	assertA(t, planner, fmt.Sprintf("select A from T1 where B='%s'", long), tx, []int32{0, 1, 2, 3, 5, 6, 7, 8, 9})
+	assertA(t, planner, "select A from T1 where B='b29'", tx, []int32{29})
+	assertA(t, planner, "select A from T1 where A=4", tx, []int32{})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestColumnarTable(t *testing.T) {
+	dir := path.Join(t.TempDir(), "columnartabletest")
+	db, err := server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	executeCommitted(t, db, "create table T1(A int, B varchar(40), C bigint) using columnar")
+	for i := 0; i < 200; i++ {
+		executeCommitted(t, db, fmt.Sprintf("insert into T1(A, B, C) values(%d, 'b%d', %d)", i, i, i*10))
+	}
+	executeCommitted(t, db, "update T1 set B='changed' where A=7")
+	executeCommitted(t, db, "delete from T1 where A=4")
+
+	// the format is kept in the catalog
+	db, err = server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	layout, err := db.MetadataManager().GetLayout("t1", tx)
+	if err != nil {
+		t.Fatalf("failed to get layout: %v", err)
+	}
+	if layout.Format() != record.COLUMNAR {
+		t.Fatalf("expected format %v, got %v", record.COLUMNAR, layout.Format())
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	// a query reads only the files of the fields it uses
+	fileNames := layout.FileNames("t1")
+	if len(fileNames) != 4 {
+		t.Fatalf("expected the file of the table and one for each field, got %v", fileNames)
+	}
+	if err := os.Remove(path.Join(dir, layout.ColumnFileName(fileNames[0], "b"))); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	tx = db.NewReadOnlyTransaction()
+	planner := db.Planner()
+	assertA(t, planner, "select A from T1 where C=70", tx, []int32{7})
+	assertA(t, planner, "select A from T1 where A=4", tx, []int32{})
+	assertRows(t, planner, "select A, C from T1 where A=199", tx, []string{"199 1990"})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func TestColumnarWideField(t *testing.T) {
+	db, err := server.NewSimpleDBWithMetadata(path.Join(t.TempDir(), "columnarwidetest"))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	planner := db.Planner()
+
+	// a value of varchar(200) takes 404 bytes, more than a block
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := planner.ExecuteUpdate("create table W(A int, B varchar(200)) using columnar", tx); !errors.Is(err, record.ErrFieldExceedsBlock) {
+		t.Fatalf("expected ErrFieldExceedsBlock, got %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back transaction: %v", err)
+	}
+
+	// a value of varchar(99) fills a block
+	executeCommitted(t, db, "create table W(A int, B varchar(99)) using columnar")
+	executeCommitted(t, db, "insert into W(A, B) values(1, 'one')")
+	executeCommitted(t, db, "insert into W(A, B) values(2, 'two')")
+	tx, err = db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	assertRows(t, planner, "select A, B from W", tx, []string{"1 one", "2 two"})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package plan_test
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/adieumonks/simple-db/plan"
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+func TestFieldTypes(t *testing.T) {
+	for _, format := range []string{"fixed", "slotted", "columnar"} {
+		t.Run(format, func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "typestest")
+			db, err := server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to create new database: %v", err)
+			}
+			executeCommitted(t, db, fmt.Sprintf("create table T1(A int, L bigint, B boolean, D double, DT date, TS timestamp) using %s", format))
+			executeCommitted(t, db, "create index T1_L on T1(L)")
+			executeCommitted(t, db, "create index T1_DT on T1(DT)")
+			executeCommitted(t, db, "insert into T1(A, L, B, D, DT, TS) values(1, 5000000000, true, 1.5, date '2024-02-29', timestamp '2024-02-29 12:34:56.789')")
+			executeCommitted(t, db, "insert into T1(A, L, B, D, DT, TS) values(2, 7, false, 2, date '1969-12-31', timestamp '1969-12-31 23:59:59')")
+			// strings are converted to dates and timestamps when stored
+			executeCommitted(t, db, "insert into T1(A, L, B, D, DT, TS) values(3, 7, true, 0.25, '2000-01-01', '2000-01-01')")
+
+			// the types are kept in the catalog
+			db, err = server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			tx, err := db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			layout, err := db.MetadataManager().GetLayout("t1", tx)
+			if err != nil {
+				t.Fatalf("failed to get layout: %v", err)
+			}
+			if got := layout.Schema().Type("ts"); got != record.TIMESTAMP {
+				t.Errorf("expected type %v, got %v", record.TIMESTAMP, got)
+			}
+
+			planner := db.Planner()
+			assertRows(t, planner, "select A, L, B, D, DT, TS from T1 where A = 1", tx, []string{
+				"1 5000000000 true 1.5 2024-02-29 2024-02-29 12:34:56.789",
+			})
+			assertRows(t, planner, "select A, DT, TS from T1 where A = 2", tx, []string{
+				"2 1969-12-31 1969-12-31 23:59:59",
+			})
+			assertA(t, planner, "select A from T1 where L = 7", tx, []int32{2, 3})
+			assertA(t, planner, "select A from T1 where L = 5000000000", tx, []int32{1})
+			assertA(t, planner, "select A from T1 where B = true", tx, []int32{1, 3})
+			// numbers compare by value, whatever their types
+			assertA(t, planner, "select A from T1 where D = 2", tx, []int32{2})
+			assertA(t, planner, "select A from T1 where D = L", tx, []int32{})
+			assertA(t, planner, "select A from T1 where DT = date '2000-01-01'", tx, []int32{3})
+			// a date equals the timestamp of its midnight
+			assertA(t, planner, "select A from T1 where DT = TS", tx, []int32{3})
+
+			_, err = planner.ExecuteUpdate("insert into T1(A, B) values(4, 'yes')", tx)
+			if !errors.Is(err, query.ErrTypeMismatch) {
+				t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+			}
+			_, err = planner.ExecuteUpdate("update T1 set A = 5000000000 where A = 1", tx)
+			if !errors.Is(err, query.ErrTypeMismatch) {
+				t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Fatalf("failed to rollback transaction: %v", err)
+			}
+
+			// changes to values of every type are undone
+			tx, err = db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			if _, err := planner.ExecuteUpdate("update T1 set D = 9.75 where A = 1", tx); err != nil {
+				t.Fatalf("failed to execute update: %v", err)
+			}
+			if _, err := planner.ExecuteUpdate("update T1 set TS = null where A = 1", tx); err != nil {
+				t.Fatalf("failed to execute update: %v", err)
+			}
+			assertRows(t, planner, "select D, TS from T1 where A = 1", tx, []string{"9.75 null"})
+			if err := tx.Rollback(); err != nil {
+				t.Fatalf("failed to rollback transaction: %v", err)
+			}
+			tx = db.NewReadOnlyTransaction()
+			assertRows(t, planner, "select D, TS from T1 where A = 1", tx, []string{"1.5 2024-02-29 12:34:56.789"})
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+		})
+	}
+}
+
+// assertRows checks the sorted rows of the result of the query, each as its
+// values separated by spaces.
+func assertRows(t *testing.T, planner *plan.Planner, query string, tx *tx.Transaction, expected []string) {
+	t.Helper()
+
+	data, err := planner.CreateQueryPlan(query, tx)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	s, err := data.Open()
+	if err != nil {
+		t.Fatalf("failed to open scan: %v", err)
+	}
+	defer s.Close()
+
+	got := []string{}
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next scan: %v", err)
+		}
+		if !next {
+			break
+		}
+		row := ""
+		for i, fieldName := range data.Schema().Fields() {
+			val, err := s.GetVal(fieldName)
+			if err != nil {
+				t.Fatalf("failed to get value: %v", err)
+			}
+			if i > 0 {
+				row += " "
+			}
+			row += val.String()
+		}
+		got = append(got, row)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	for _, format := range []string{"fixed", "slotted", "columnar"} {
+		t.Run(format, func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "decimaltest")
+			db, err := server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to create new database: %v", err)
+			}
+			executeCommitted(t, db, fmt.Sprintf("create table T1(A int, P decimal(8, 2)) using %s", format))
+			executeCommitted(t, db, "create index T1_P on T1(P)")
+			executeCommitted(t, db, "insert into T1(A, P) values(1, 19.99)")
+			executeCommitted(t, db, "insert into T1(A, P) values(2, -0.1)")
+			// values are rounded to the scale of the field
+			executeCommitted(t, db, "insert into T1(A, P) values(3, 7)")
+			executeCommitted(t, db, "insert into T1(A, P) values(4, 0.125)")
+			executeCommitted(t, db, "insert into T1(A, P) values(5, 7.004)")
+
+			// the precision and scale are kept in the catalog
+			db, err = server.NewSimpleDBWithMetadata(dir)
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			tx, err := db.NewTransaction()
+			if err != nil {
+				t.Fatalf("failed to create new transaction: %v", err)
+			}
+			planner := db.Planner()
+			assertRows(t, planner, "select A, P from T1", tx, []string{
+				"1 19.99", "2 -0.10", "3 7.00", "4 0.13", "5 7.00",
+			})
+			assertA(t, planner, "select A from T1 where P = 7", tx, []int32{3, 5})
+			assertA(t, planner, "select A from T1 where P = 19.990", tx, []int32{1})
+			assertA(t, planner, "select A from T1 where P = -0.1", tx, []int32{2})
+
+			_, err = planner.ExecuteUpdate("insert into T1(A, P) values(6, 1000000)", tx)
+			if !errors.Is(err, query.ErrTypeMismatch) {
+				t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+			}
+			_, err = planner.ExecuteUpdate("insert into T1(A, P) values(6, 'free')", tx)
+			if !errors.Is(err, query.ErrTypeMismatch) {
+				t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("failed to commit transaction: %v", err)
+			}
+		})
+	}
+}
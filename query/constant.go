@@ -1,72 +1,429 @@
 package query
 
-import "fmt"
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
 
+	"github.com/adieumonks/simple-db/record"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05.999999"
+	secondsPerDay   = 24 * 60 * 60
+	microsPerDay    = secondsPerDay * 1000 * 1000
+)
+
+// Constant is a value of one of the field types, held as the record
+// package stores it except for a DECIMAL, which is held as a Decimal, or
+// NULL if it has no value.
 type Constant struct {
-	ival *int32
-	sval *string
+	typ record.FieldType
+	val any
+}
+
+func NewNullConstant() *Constant {
+	return &Constant{}
+}
+
+// NewConstant returns a constant of the field type from a value as the
+// record package stores it, which is NULL if the value is nil. A DECIMAL
+// needs its scale, given by NewFieldConstant.
+func NewConstant(fieldType record.FieldType, val any) *Constant {
+	return &Constant{typ: fieldType, val: val}
+}
+
+// NewFieldConstant returns a constant from a value of the field as the
+// record package stores it, which is NULL if the value is nil.
+func NewFieldConstant(sch *record.Schema, fieldName string, val any) *Constant {
+	fieldType := sch.Type(fieldName)
+	if v, ok := val.(int64); ok && fieldType == record.DECIMAL {
+		return NewConstantWithDecimal(NewDecimal(v, sch.Scale(fieldName)))
+	}
+	return NewConstant(fieldType, val)
 }
 
 func NewConstantWithInt(ival int32) *Constant {
-	return &Constant{ival: &ival}
+	return &Constant{typ: record.INTEGER, val: ival}
 }
 
 func NewConstantWithString(sval string) *Constant {
-	return &Constant{sval: &sval}
+	return &Constant{typ: record.STRING, val: sval}
+}
+
+func NewConstantWithLong(lval int64) *Constant {
+	return &Constant{typ: record.BIGINT, val: lval}
+}
+
+func NewConstantWithBool(bval bool) *Constant {
+	return &Constant{typ: record.BOOLEAN, val: bval}
 }
 
+func NewConstantWithDouble(dval float64) *Constant {
+	return &Constant{typ: record.DOUBLE, val: dval}
+}
+
+func NewConstantWithDecimal(dval Decimal) *Constant {
+	return &Constant{typ: record.DECIMAL, val: dval}
+}
+
+// NewConstantWithDate returns the date of the time, in UTC.
+func NewConstantWithDate(t time.Time) *Constant {
+	sec := t.Unix()
+	days := sec / secondsPerDay
+	if sec%secondsPerDay < 0 {
+		days--
+	}
+	return &Constant{typ: record.DATE, val: int32(days)}
+}
+
+func NewConstantWithTimestamp(t time.Time) *Constant {
+	return &Constant{typ: record.TIMESTAMP, val: t.UnixMicro()}
+}
+
+// ParseDate parses a date such as 2024-01-31.
+func ParseDate(s string) (*Constant, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", s, ErrTypeMismatch)
+	}
+	return NewConstantWithDate(t), nil
+}
+
+// ParseTimestamp parses a timestamp such as 2024-01-31 12:34:56.789, or a
+// date, which is taken as its midnight.
+func ParseTimestamp(s string) (*Constant, error) {
+	for _, layout := range []string{timestampLayout, "2006-01-02T15:04:05.999999", dateLayout} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return NewConstantWithTimestamp(t), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid timestamp %q: %w", s, ErrTypeMismatch)
+}
+
+func (c *Constant) IsNull() bool {
+	return c.val == nil
+}
+
+// Type returns the field type of the constant, which means nothing if it
+// is NULL.
+func (c *Constant) Type() record.FieldType {
+	return c.typ
+}
+
+// AsInt returns the value of a number as an int, or 0 if the constant is
+// not a number.
 func (c *Constant) AsInt() int32 {
-	return *c.ival
+	return int32(c.AsLong())
 }
 
+// AsLong returns the value of a number as a bigint, or 0 if the constant is
+// not a number.
+func (c *Constant) AsLong() int64 {
+	switch v := c.val.(type) {
+	case int32:
+		if c.typ == record.INTEGER {
+			return int64(v)
+		}
+	case int64:
+		if c.typ == record.BIGINT {
+			return v
+		}
+	case float64:
+		return int64(v)
+	case Decimal:
+		return v.Int64()
+	}
+	return 0
+}
+
+// AsDouble returns the value of a number as a double, or 0 if the constant
+// is not a number.
+func (c *Constant) AsDouble() float64 {
+	switch v := c.val.(type) {
+	case float64:
+		return v
+	case Decimal:
+		return v.Float64()
+	}
+	return float64(c.AsLong())
+}
+
+// AsDecimal returns the value of a number as a decimal, or 0 if the constant
+// is not a number or is a double too large for one.
+func (c *Constant) AsDecimal() Decimal {
+	switch v := c.val.(type) {
+	case Decimal:
+		return v
+	case float64:
+		d, _ := ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+		return d
+	}
+	return NewDecimal(c.AsLong(), 0)
+}
+
+// AsBool returns the boolean value, or false if the constant is not one.
+func (c *Constant) AsBool() bool {
+	v, _ := c.val.(bool)
+	return v
+}
+
+// AsString returns the string value, or "" if the constant is not a string.
 func (c *Constant) AsString() string {
-	return *c.sval
+	v, _ := c.val.(string)
+	return v
+}
+
+// AsTime returns the time of a date or a timestamp in UTC, or the zero time
+// if the constant is neither.
+func (c *Constant) AsTime() time.Time {
+	if !c.isTime() {
+		return time.Time{}
+	}
+	return time.UnixMicro(c.micros()).UTC()
 }
 
+// Value returns the value of the constant as the record package stores the
+// field of the schema, or nil if it is NULL. Numbers convert to the other
+// number types they fit in, rounded to the scale of a decimal, dates to
+// timestamps, and strings to dates and timestamps; any other conversion
+// fails with ErrTypeMismatch.
+func (c *Constant) Value(sch *record.Schema, fieldName string) (any, error) {
+	if c.IsNull() {
+		return nil, nil
+	}
+	fieldType := sch.Type(fieldName)
+	if fieldType == record.DECIMAL {
+		return c.decimalValue(sch.Length(fieldName), sch.Scale(fieldName))
+	}
+	if c.typ == fieldType {
+		return c.val, nil
+	}
+	switch fieldType {
+	case record.INTEGER:
+		if v, ok := c.integral(); ok && v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int32(v), nil
+		}
+	case record.BIGINT:
+		if v, ok := c.integral(); ok {
+			return v, nil
+		}
+	case record.DOUBLE:
+		if c.IsNumber() {
+			return c.AsDouble(), nil
+		}
+	case record.DATE:
+		if c.typ == record.STRING {
+			d, err := ParseDate(c.AsString())
+			if err != nil {
+				return nil, err
+			}
+			return d.val, nil
+		}
+	case record.TIMESTAMP:
+		if c.typ == record.DATE {
+			return c.micros(), nil
+		}
+		if c.typ == record.STRING {
+			ts, err := ParseTimestamp(c.AsString())
+			if err != nil {
+				return nil, err
+			}
+			return ts.val, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %s %s to %s: %w", c.typ, c, fieldType, ErrTypeMismatch)
+}
+
+// decimalValue returns the unscaled value of the number as a
+// DECIMAL(precision, scale).
+func (c *Constant) decimalValue(precision, scale int32) (any, error) {
+	if c.IsNumber() {
+		if d, err := c.AsDecimal().Rescale(scale); err == nil && d.Precision() <= precision {
+			return d.Unscaled(), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %s %s to decimal(%d,%d): %w", c.typ, c, precision, scale, ErrTypeMismatch)
+}
+
+// Equals reports whether the constants have the same value, where NULL
+// only equals NULL.
 func (c *Constant) Equals(other *Constant) bool {
-	if c.ival != nil && other.ival != nil {
-		return *c.ival == *other.ival
+	if c.IsNull() || other.IsNull() {
+		return c.IsNull() && other.IsNull()
 	}
-	if c.sval != nil && other.sval != nil {
-		return *c.sval == *other.sval
+	if !c.comparable(other) {
+		return false
 	}
-	return false
+	return c.CompareTo(other) == 0
 }
 
+// CompareTo orders the constants, with NULL before any value. Numbers of
+// different types compare by their values, as do dates and timestamps;
+// constants of types that do not compare are ordered by their types.
 func (c *Constant) CompareTo(other *Constant) int32 {
-	if c.ival != nil {
-		if *c.ival == *other.ival {
-			return 0
-		} else if *c.ival < *other.ival {
-			return -1
-		} else {
-			return 1
-		}
-	} else {
-		if *c.sval == *other.sval {
+	if c.IsNull() || other.IsNull() {
+		switch {
+		case c.IsNull() && other.IsNull():
 			return 0
-		} else if *c.sval < *other.sval {
+		case c.IsNull():
 			return -1
-		} else {
+		default:
 			return 1
 		}
 	}
+	switch {
+	case !c.comparable(other):
+		return int32(cmp.Compare(c.typ, other.typ))
+	case c.typ == record.DOUBLE && other.typ == record.DOUBLE:
+		return int32(cmp.Compare(c.AsDouble(), other.AsDouble()))
+	case c.typ == record.DOUBLE:
+		return int32(compareWithDouble(c.AsDouble(), other))
+	case other.typ == record.DOUBLE:
+		return int32(-compareWithDouble(other.AsDouble(), c))
+	case c.typ == record.DECIMAL || other.typ == record.DECIMAL:
+		return int32(c.AsDecimal().Cmp(other.AsDecimal()))
+	case c.IsNumber():
+		return int32(cmp.Compare(c.AsLong(), other.AsLong()))
+	case c.isTime():
+		return int32(cmp.Compare(c.micros(), other.micros()))
+	}
+	return int32(record.CompareValues(c.val, other.val))
 }
 
+// HashCode returns the same hash for constants that are equal, whatever
+// their types.
 func (c *Constant) HashCode() int32 {
-	if c.ival != nil {
-		return *c.ival
+	switch {
+	case c.IsNull():
+		return 0
+	case c.IsNumber():
+		if v, ok := c.integral(); ok {
+			return hashLong(v)
+		}
+		return hashLong(int64(math.Float64bits(c.AsDouble())))
+	case c.isTime():
+		return hashLong(c.micros())
+	case c.typ == record.BOOLEAN:
+		if c.AsBool() {
+			return 1
+		}
+		return 0
 	}
 	hash := int32(0)
-	for _, ch := range *c.sval {
+	for _, ch := range c.AsString() {
 		hash = 31*hash + int32(ch)
 	}
 	return hash
 }
 
 func (c *Constant) String() string {
-	if c.ival != nil {
-		return fmt.Sprintf("%d", *c.ival)
+	if c.IsNull() {
+		return "null"
+	}
+	switch c.typ {
+	case record.INTEGER, record.BIGINT:
+		return fmt.Sprintf("%d", c.AsLong())
+	case record.DOUBLE:
+		return strconv.FormatFloat(c.AsDouble(), 'g', -1, 64)
+	case record.DECIMAL:
+		return c.AsDecimal().String()
+	case record.BOOLEAN:
+		return strconv.FormatBool(c.AsBool())
+	case record.DATE:
+		return c.AsTime().Format(dateLayout)
+	case record.TIMESTAMP:
+		return c.AsTime().Format(timestampLayout)
+	}
+	return c.AsString()
+}
+
+// Literal returns the constant as it is written in SQL, so that a query
+// made of it parses back to the same constant.
+func (c *Constant) Literal() string {
+	switch {
+	case c.IsNull():
+		return "null"
+	case c.typ == record.STRING:
+		return fmt.Sprintf("'%s'", c.AsString())
+	case c.typ == record.DATE:
+		return fmt.Sprintf("date '%s'", c)
+	case c.typ == record.TIMESTAMP:
+		return fmt.Sprintf("timestamp '%s'", c)
+	}
+	return c.String()
+}
+
+func (c *Constant) comparable(other *Constant) bool {
+	return c.typ == other.typ ||
+		c.IsNumber() && other.IsNumber() ||
+		c.isTime() && other.isTime()
+}
+
+func (c *Constant) IsNumber() bool {
+	switch c.typ {
+	case record.INTEGER, record.BIGINT, record.DOUBLE, record.DECIMAL:
+		return true
+	}
+	return false
+}
+
+func (c *Constant) isTime() bool {
+	return c.typ == record.DATE || c.typ == record.TIMESTAMP
+}
+
+// integral returns the value of a number if it is a whole one.
+func (c *Constant) integral() (int64, bool) {
+	if !c.IsNumber() {
+		return 0, false
+	}
+	switch c.typ {
+	case record.INTEGER, record.BIGINT:
+		return c.AsLong(), true
+	case record.DECIMAL:
+		d := c.AsDecimal()
+		return d.Int64(), d.IsIntegral()
+	}
+	v := c.AsDouble()
+	if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(v), true
+}
+
+// compareWithDouble compares a double with a number of an exact type by
+// their exact values, as converting the number to a double may round it.
+func compareWithDouble(d float64, exact *Constant) int {
+	switch {
+	case math.IsNaN(d):
+		return -1
+	case math.IsInf(d, 0):
+		return int(math.Copysign(1, d))
+	}
+	x := new(big.Rat).SetFloat64(d)
+	if exact.typ == record.DECIMAL {
+		return x.Cmp(exact.AsDecimal().rat())
+	}
+	return x.Cmp(new(big.Rat).SetInt64(exact.AsLong()))
+}
+
+// micros returns a date or a timestamp as microseconds since the Unix
+// epoch.
+func (c *Constant) micros() int64 {
+	if v, ok := c.val.(int32); ok && c.typ == record.DATE {
+		return int64(v) * microsPerDay
+	}
+	v, _ := c.val.(int64)
+	return v
+}
+
+func hashLong(v int64) int32 {
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		return int32(v)
 	}
-	return *c.sval
+	return int32(v ^ v>>32)
 }
@@ -0,0 +1,39 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/adieumonks/simple-db/query"
+)
+
+func TestConstantBigintDouble(t *testing.T) {
+	// 2^53 is a double, but the bigint after it is not
+	double := query.NewConstantWithDouble(1 << 53)
+	same := query.NewConstantWithLong(1 << 53)
+	next := query.NewConstantWithLong(1<<53 + 1)
+
+	if !double.Equals(same) || double.HashCode() != same.HashCode() {
+		t.Errorf("expected %s to equal %s with the same hash", double, same)
+	}
+	if double.Equals(next) || next.Equals(double) {
+		t.Errorf("expected %s not to equal %s", double, next)
+	}
+	if double.CompareTo(next) >= 0 || next.CompareTo(double) <= 0 {
+		t.Errorf("expected %s to be less than %s", double, next)
+	}
+
+	// the same holds for a decimal with as many digits
+	decimal := query.NewConstantWithDecimal(query.NewDecimal(1<<53+1, 0))
+	if double.Equals(decimal) || double.CompareTo(decimal) >= 0 {
+		t.Errorf("expected %s to be less than %s", double, decimal)
+	}
+
+	// doubles beyond the range of a bigint still compare
+	huge := query.NewConstantWithDouble(1e19)
+	if huge.CompareTo(query.NewConstantWithLong(1<<62)) <= 0 {
+		t.Errorf("expected %s to be greater than %d", huge, int64(1<<62))
+	}
+	if fraction := query.NewConstantWithDouble(2.5); fraction.CompareTo(query.NewConstantWithInt(2)) <= 0 || fraction.CompareTo(query.NewConstantWithInt(3)) >= 0 {
+		t.Errorf("expected %s to be between 2 and 3", fraction)
+	}
+}
@@ -0,0 +1,194 @@
+package query
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// MaxDecimalPrecision is the most digits a decimal holds, so that its
+// unscaled value fits in an int64.
+const MaxDecimalPrecision = 18
+
+// Decimal is an exact number, its unscaled value divided by ten to the power
+// of its scale.
+type Decimal struct {
+	unscaled int64
+	scale    int32
+}
+
+func NewDecimal(unscaled int64, scale int32) Decimal {
+	return Decimal{unscaled: unscaled, scale: scale}
+}
+
+// ParseDecimal parses a number such as -12.340, keeping the digits given
+// after the point as the scale.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" || strings.Trim(intPart+fracPart, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: %w", s, ErrTypeMismatch)
+	}
+	n, ok := new(big.Int).SetString("0"+intPart+fracPart, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: %w", s, ErrTypeMismatch)
+	}
+	if strings.HasPrefix(s, "-") {
+		n.Neg(n)
+	}
+	return fromBig(n, int32(len(fracPart)))
+}
+
+func (d Decimal) Unscaled() int64 {
+	return d.unscaled
+}
+
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Precision returns the number of digits of the unscaled value.
+func (d Decimal) Precision() int32 {
+	return int32(len(new(big.Int).Abs(d.big()).String()))
+}
+
+// Rescale returns the decimal with the scale, rounding half away from zero
+// if digits are dropped.
+func (d Decimal) Rescale(scale int32) (Decimal, error) {
+	if scale >= d.scale {
+		n := new(big.Int).Mul(d.big(), pow10(scale-d.scale))
+		return fromBig(n, scale)
+	}
+	return fromBig(divRound(d.big(), pow10(d.scale-scale)), scale)
+}
+
+// Fits reports whether the decimal is a value of DECIMAL(precision, scale)
+// once rescaled.
+func (d Decimal) Fits(precision, scale int32) bool {
+	r, err := d.Rescale(scale)
+	return err == nil && r.Precision() <= precision
+}
+
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	scale := max(d.scale, other.scale)
+	n := new(big.Int).Add(d.scaledBig(scale), other.scaledBig(scale))
+	return fromBig(n, scale)
+}
+
+func (d Decimal) Sub(other Decimal) (Decimal, error) {
+	return d.Add(other.Neg())
+}
+
+func (d Decimal) Mul(other Decimal) (Decimal, error) {
+	n := new(big.Int).Mul(d.big(), other.big())
+	return fromBig(n, d.scale+other.scale)
+}
+
+// Div returns the quotient with the scale, rounding half away from zero.
+func (d Decimal) Div(other Decimal, scale int32) (Decimal, error) {
+	if other.unscaled == 0 {
+		return Decimal{}, fmt.Errorf("failed to divide %s: %w", d, ErrDivisionByZero)
+	}
+	// d / other = (d.unscaled * 10^(scale - d.scale + other.scale)) / other.unscaled / 10^scale
+	n := d.big()
+	m := other.big()
+	if exp := scale - d.scale + other.scale; exp >= 0 {
+		n.Mul(n, pow10(exp))
+	} else {
+		m.Mul(m, pow10(-exp))
+	}
+	return fromBig(divRound(n, m), scale)
+}
+
+// Mod returns the remainder of the division of the decimal by the other
+// towards zero, which has the sign of the decimal.
+func (d Decimal) Mod(other Decimal) (Decimal, error) {
+	if other.unscaled == 0 {
+		return Decimal{}, fmt.Errorf("failed to divide %s: %w", d, ErrDivisionByZero)
+	}
+	scale := max(d.scale, other.scale)
+	n := new(big.Int).Rem(d.scaledBig(scale), other.scaledBig(scale))
+	return fromBig(n, scale)
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: -d.unscaled, scale: d.scale}
+}
+
+// Cmp compares the values of the decimals, whatever their scales.
+func (d Decimal) Cmp(other Decimal) int {
+	scale := max(d.scale, other.scale)
+	return d.scaledBig(scale).Cmp(other.scaledBig(scale))
+}
+
+// IsIntegral reports whether the decimal has no fractional part.
+func (d Decimal) IsIntegral() bool {
+	return d.unscaled%pow10(d.scale).Int64() == 0
+}
+
+// Int64 returns the integral part of the decimal.
+func (d Decimal) Int64() int64 {
+	return d.unscaled / pow10(d.scale).Int64()
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := d.rat().Float64()
+	return f
+}
+
+// String formats the decimal with all the digits of its scale.
+func (d Decimal) String() string {
+	s := new(big.Int).Abs(d.big()).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(s); pad > 0 {
+			s = strings.Repeat("0", pad) + s
+		}
+		s = s[:len(s)-int(d.scale)] + "." + s[len(s)-int(d.scale):]
+	}
+	if d.unscaled < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func (d Decimal) big() *big.Int {
+	return big.NewInt(d.unscaled)
+}
+
+func (d Decimal) rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.big(), pow10(d.scale))
+}
+
+func (d Decimal) scaledBig(scale int32) *big.Int {
+	return new(big.Int).Mul(d.big(), pow10(scale-d.scale))
+}
+
+// fromBig returns the decimal of the unscaled value, rounding it to at
+// most MaxDecimalPrecision digits after the point.
+func fromBig(n *big.Int, scale int32) (Decimal, error) {
+	if scale > MaxDecimalPrecision {
+		n = divRound(n, pow10(scale-MaxDecimalPrecision))
+		scale = MaxDecimalPrecision
+	}
+	if !n.IsInt64() || len(new(big.Int).Abs(n).String()) > MaxDecimalPrecision {
+		return Decimal{}, fmt.Errorf("decimal out of range: %w", ErrTypeMismatch)
+	}
+	return Decimal{unscaled: n.Int64(), scale: scale}, nil
+}
+
+// divRound divides n by m, rounding half away from zero.
+func divRound(n, m *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(n, m, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(new(big.Int).Abs(m)) >= 0 {
+		if n.Sign()*m.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func pow10(exp int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+}
@@ -0,0 +1,73 @@
+package query_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adieumonks/simple-db/query"
+)
+
+func TestDecimal(t *testing.T) {
+	parse := func(s string) query.Decimal {
+		t.Helper()
+		d, err := query.ParseDecimal(s)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", s, err)
+		}
+		return d
+	}
+
+	for _, tt := range []struct {
+		name string
+		op   func() (query.Decimal, error)
+		want string
+	}{
+		{"add", func() (query.Decimal, error) { return parse("0.1").Add(parse("0.2")) }, "0.3"},
+		{"add scales", func() (query.Decimal, error) { return parse("19.99").Add(parse("-20")) }, "-0.01"},
+		{"sub", func() (query.Decimal, error) { return parse("1.00").Sub(parse("0.01")) }, "0.99"},
+		{"mul", func() (query.Decimal, error) { return parse("1.5").Mul(parse("-0.25")) }, "-0.375"},
+		{"div", func() (query.Decimal, error) { return parse("10").Div(parse("3"), 4) }, "3.3333"},
+		{"div rounds", func() (query.Decimal, error) { return parse("-2").Div(parse("3"), 2) }, "-0.67"},
+		{"mod", func() (query.Decimal, error) { return parse("-7.5").Mod(parse("2")) }, "-1.5"},
+		{"rescale rounds", func() (query.Decimal, error) { return parse("0.125").Rescale(2) }, "0.13"},
+		{"rescale pads", func() (query.Decimal, error) { return parse("7").Rescale(2) }, "7.00"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op()
+			if err != nil {
+				t.Fatalf("failed to compute: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if parse("1.50").Cmp(parse("1.5")) != 0 || parse("-0.01").Cmp(parse("0")) >= 0 {
+		t.Errorf("decimals compare by value")
+	}
+	if _, err := parse("999999999999999999").Add(parse("1")); !errors.Is(err, query.ErrTypeMismatch) {
+		t.Errorf("expected %v, got %v", query.ErrTypeMismatch, err)
+	}
+	if _, err := parse("1").Div(parse("0.00"), 2); !errors.Is(err, query.ErrDivisionByZero) {
+		t.Errorf("expected %v, got %v", query.ErrDivisionByZero, err)
+	}
+	if parse("123.45").Fits(4, 2) || !parse("123.45").Fits(5, 2) {
+		t.Errorf("123.45 fits decimal(5,2) only")
+	}
+
+	// decimals equal numbers of the other types of the same value
+	price := query.NewConstantWithDecimal(parse("2.50"))
+	for _, other := range []*query.Constant{
+		query.NewConstantWithDecimal(parse("2.5")),
+		query.NewConstantWithDouble(2.5),
+	} {
+		if !price.Equals(other) || price.HashCode() != other.HashCode() {
+			t.Errorf("expected %s to equal %s with the same hash", price, other)
+		}
+	}
+	whole := query.NewConstantWithDecimal(parse("3.00"))
+	if !whole.Equals(query.NewConstantWithInt(3)) || whole.HashCode() != query.NewConstantWithInt(3).HashCode() {
+		t.Errorf("expected %s to equal 3 with the same hash", whole)
+	}
+}
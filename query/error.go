@@ -2,4 +2,10 @@ package query
 
 import "errors"
 
-var ErrNotUpdatable = errors.New("scan is not updatable")
+var (
+	ErrNotUpdatable   = errors.New("scan is not updatable")
+	ErrWriteConflict  = errors.New("record was changed by a concurrent transaction")
+	ErrNotNull        = errors.New("field does not allow null")
+	ErrTypeMismatch   = errors.New("value does not match the type")
+	ErrDivisionByZero = errors.New("division by zero")
+)
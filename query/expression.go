@@ -1,10 +1,48 @@
 package query
 
-import "github.com/adieumonks/simple-db/record"
+import (
+	"fmt"
+	"math"
+	"strings"
 
+	"github.com/adieumonks/simple-db/record"
+)
+
+type ArithmeticOperator int
+
+const (
+	ADD ArithmeticOperator = iota
+	SUB
+	MUL
+	DIV
+	MOD
+	NEG
+)
+
+// quotientScale is the least number of digits after the point of a
+// quotient of decimals.
+const quotientScale = 6
+
+var arithmeticSymbols = map[ArithmeticOperator]string{
+	ADD: "+",
+	SUB: "-",
+	MUL: "*",
+	DIV: "/",
+	MOD: "%",
+	NEG: "-",
+}
+
+// Expression is a constant, a field, the result of an arithmetic operator
+// on one or two expressions, which negates its lhs if it is NEG, or the
+// result of a function of expressions.
 type Expression struct {
 	val       *Constant
 	fieldName *string
+	op        ArithmeticOperator
+	lhs       *Expression
+	rhs       *Expression
+	fn        *Function
+	args      []*Expression
 }
 
 func NewExpressionFromConstant(val *Constant) *Expression {
@@ -15,35 +53,415 @@ func NewExpressionFromField(fieldName string) *Expression {
 	return &Expression{fieldName: &fieldName}
 }
 
+// NewArithmeticExpression returns the expression applying one of the
+// operators from ADD to MOD to the expressions.
+func NewArithmeticExpression(lhs *Expression, op ArithmeticOperator, rhs *Expression) *Expression {
+	return &Expression{op: op, lhs: lhs, rhs: rhs}
+}
+
+func NewNegatedExpression(expr *Expression) *Expression {
+	return &Expression{op: NEG, lhs: expr}
+}
+
+// NewFunctionExpression returns the expression calling the function with
+// the arguments, whose number it must accept.
+func NewFunctionExpression(fn *Function, args ...*Expression) *Expression {
+	return &Expression{fn: fn, args: args}
+}
+
+// Evaluate returns the value of the expression for the current record of
+// the scan. Arithmetic on NULL is NULL, and on values that are not numbers
+// fails with ErrTypeMismatch.
 func (e *Expression) Evaluate(scan Scan) (*Constant, error) {
-	if e.val != nil {
+	switch {
+	case e.val != nil:
 		return e.val, nil
+	case e.fieldName != nil:
+		return scan.GetVal(*e.fieldName)
+	case e.fn != nil:
+		args := make([]*Constant, len(e.args))
+		for i, arg := range e.args {
+			val, err := arg.Evaluate(scan)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = val
+		}
+		return e.fn.Call(args)
+	}
+	lhsVal, err := e.lhs.Evaluate(scan)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == NEG {
+		return negate(lhsVal)
 	}
-	return scan.GetVal(*e.fieldName)
+	rhsVal, err := e.rhs.Evaluate(scan)
+	if err != nil {
+		return nil, err
+	}
+	return calculate(lhsVal, e.op, rhsVal)
 }
 
 func (e *Expression) IsFieldName() bool {
 	return e.fieldName != nil
 }
 
+// IsConstant reports whether the expression uses no fields, so that it has
+// the same value for every record.
+func (e *Expression) IsConstant() bool {
+	return len(e.FieldNames()) == 0
+}
+
+// AsConstant returns the value of a constant expression, or nil if it uses
+// fields or cannot be evaluated.
 func (e *Expression) AsConstant() *Constant {
-	return e.val
+	if !e.IsConstant() {
+		return nil
+	}
+	val, err := e.Evaluate(nil)
+	if err != nil {
+		return nil
+	}
+	return val
 }
 
 func (e *Expression) AsFieldName() string {
 	return *e.fieldName
 }
 
+// FieldNames returns the fields the expression uses.
+func (e *Expression) FieldNames() []string {
+	switch {
+	case e.val != nil:
+		return nil
+	case e.fieldName != nil:
+		return []string{*e.fieldName}
+	case e.fn != nil:
+		var fieldNames []string
+		for _, arg := range e.args {
+			fieldNames = append(fieldNames, arg.FieldNames()...)
+		}
+		return fieldNames
+	case e.rhs == nil:
+		return e.lhs.FieldNames()
+	}
+	return append(e.lhs.FieldNames(), e.rhs.FieldNames()...)
+}
+
 func (e *Expression) AppliesTo(schema *record.Schema) bool {
-	if e.val != nil {
-		return true
+	for _, fieldName := range e.FieldNames() {
+		if !schema.HasField(fieldName) {
+			return false
+		}
+	}
+	return true
+}
+
+// Type returns the type of the values of the expression for records of the
+// schema, failing with ErrTypeMismatch if an operator or a function is
+// applied to values of types it does not take.
+func (e *Expression) Type(schema *record.Schema) (record.FieldType, error) {
+	vt, err := e.typeOf(schema)
+	return vt.fieldType, err
+}
+
+// AddField adds the field of the values of the expression for records of
+// the input schema to the schema. A field keeps how it is declared, and a
+// decimal computed has the most digits.
+func (e *Expression) AddField(sch *record.Schema, fieldName string, input *record.Schema) error {
+	if e.fieldName != nil {
+		if !input.HasField(*e.fieldName) {
+			return fmt.Errorf("field %s: %w", *e.fieldName, ErrFieldNotFound)
+		}
+		sch.AddAs(fieldName, *e.fieldName, input)
+		return nil
+	}
+	vt, err := e.typeOf(input)
+	if err != nil {
+		return err
+	}
+	switch vt.fieldType {
+	case record.DECIMAL:
+		sch.AddDecimalField(fieldName, MaxDecimalPrecision, vt.scale)
+	default:
+		sch.AddField(fieldName, vt.fieldType, vt.length)
+	}
+	return nil
+}
+
+// valueType is the type of the values of an expression, with the most
+// characters of a string or the scale of a decimal. The type of NULL goes
+// with any other.
+type valueType struct {
+	fieldType record.FieldType
+	length    int32
+	scale     int32
+	null      bool
+}
+
+// comparable reports whether values of the types compare, as numbers of
+// any types do, and dates with timestamps.
+func (vt valueType) comparable(other valueType) bool {
+	return vt.null || other.null ||
+		vt.fieldType == other.fieldType ||
+		isNumberType(vt.fieldType) && isNumberType(other.fieldType) ||
+		isTimeType(vt.fieldType) && isTimeType(other.fieldType)
+}
+
+func (e *Expression) typeOf(schema *record.Schema) (valueType, error) {
+	switch {
+	case e.val != nil:
+		return constantType(e.val), nil
+	case e.fieldName != nil:
+		if !schema.HasField(*e.fieldName) {
+			return valueType{}, fmt.Errorf("field %s: %w", *e.fieldName, ErrFieldNotFound)
+		}
+		return valueType{
+			fieldType: schema.Type(*e.fieldName),
+			length:    schema.Length(*e.fieldName),
+			scale:     schema.Scale(*e.fieldName),
+		}, nil
+	case e.fn != nil:
+		args := make([]valueType, len(e.args))
+		for i, arg := range e.args {
+			vt, err := arg.typeOf(schema)
+			if err != nil {
+				return valueType{}, err
+			}
+			args[i] = vt
+		}
+		return e.fn.resultType(e.fn.name, args)
+	}
+	lhs, err := e.lhs.typeOf(schema)
+	if err != nil {
+		return valueType{}, err
+	}
+	if !isNumberType(lhs.fieldType) {
+		return valueType{}, fmt.Errorf("cannot apply %s to %s %s: %w", arithmeticSymbols[e.op], lhs.fieldType, e.lhs, ErrTypeMismatch)
+	}
+	if e.op == NEG {
+		return lhs, nil
+	}
+	rhs, err := e.rhs.typeOf(schema)
+	if err != nil {
+		return valueType{}, err
+	}
+	if !isNumberType(rhs.fieldType) {
+		return valueType{}, fmt.Errorf("cannot apply %s to %s %s: %w", arithmeticSymbols[e.op], rhs.fieldType, e.rhs, ErrTypeMismatch)
+	}
+	return valueType{
+		fieldType: numberTypes[max(numberRank(lhs.fieldType), numberRank(rhs.fieldType))],
+		scale:     resultScale(lhs.scale, e.op, rhs.scale),
+		null:      lhs.null && rhs.null,
+	}, nil
+}
+
+// UsesField reports whether the expression uses the field.
+func (e *Expression) UsesField(fieldName string) bool {
+	for _, name := range e.FieldNames() {
+		if name == fieldName {
+			return true
+		}
+	}
+	return false
+}
+
+// RenameField renames the field wherever the expression uses it.
+func (e *Expression) RenameField(oldName, newName string) {
+	switch {
+	case e.val != nil:
+	case e.fieldName != nil:
+		if *e.fieldName == oldName {
+			e.fieldName = &newName
+		}
+	case e.fn != nil:
+		for _, arg := range e.args {
+			arg.RenameField(oldName, newName)
+		}
+	default:
+		e.lhs.RenameField(oldName, newName)
+		if e.rhs != nil {
+			e.rhs.RenameField(oldName, newName)
+		}
 	}
-	return schema.HasField(*e.fieldName)
 }
 
+// String writes the expression so that it parses back to the same one,
+// enclosing in parentheses the operands that bind less tightly than their
+// operator.
 func (e *Expression) String() string {
-	if e.val != nil {
-		return e.val.String()
+	switch {
+	case e.val != nil:
+		return e.val.Literal()
+	case e.fieldName != nil:
+		return *e.fieldName
+	case e.fn != nil:
+		args := make([]string, len(e.args))
+		for i, arg := range e.args {
+			args[i] = arg.String()
+		}
+		return fmt.Sprintf("%s(%s)", e.fn.Name(), strings.Join(args, ", "))
+	case e.op == NEG:
+		return "-" + e.lhs.enclosed(precedence(NEG))
 	}
-	return *e.fieldName
+	// the operators are left associative, so an rhs of the same
+	// precedence is enclosed
+	return fmt.Sprintf("%s %s %s", e.lhs.enclosed(precedence(e.op)), arithmeticSymbols[e.op], e.rhs.enclosed(precedence(e.op)+1))
+}
+
+func (e *Expression) enclosed(prec int) string {
+	if e.val != nil || e.fieldName != nil || e.fn != nil || precedence(e.op) >= prec {
+		return e.String()
+	}
+	return "(" + e.String() + ")"
+}
+
+func precedence(op ArithmeticOperator) int {
+	switch op {
+	case ADD, SUB:
+		return 1
+	case MUL, DIV, MOD:
+		return 2
+	}
+	return 3
+}
+
+// numberTypes are the types of numbers, each holding the values of those
+// before it, exactly but for doubles.
+var numberTypes = []record.FieldType{record.INTEGER, record.BIGINT, record.DECIMAL, record.DOUBLE}
+
+func isNumberType(fieldType record.FieldType) bool {
+	return numberRank(fieldType) >= 0
+}
+
+func numberRank(fieldType record.FieldType) int {
+	for i, t := range numberTypes {
+		if t == fieldType {
+			return i
+		}
+	}
+	return -1
+}
+
+// resultScale returns the scale of the result of the operator on decimals
+// of the scales.
+func resultScale(lhsScale int32, op ArithmeticOperator, rhsScale int32) int32 {
+	switch op {
+	case MUL:
+		return min(lhsScale+rhsScale, MaxDecimalPrecision)
+	case DIV:
+		return max(lhsScale, rhsScale, quotientScale)
+	}
+	return max(lhsScale, rhsScale)
+}
+
+func negate(val *Constant) (*Constant, error) {
+	switch {
+	case val.IsNull():
+		return val, nil
+	case !val.IsNumber():
+		return nil, fmt.Errorf("cannot negate %s %s: %w", val.Type(), val, ErrTypeMismatch)
+	}
+	return calculate(NewConstantWithInt(0), SUB, val)
+}
+
+// calculate applies the operator to the numbers, whose result has the type
+// of the wider of them. Integers out of the range of their type fail with
+// ErrTypeMismatch, and a division by zero with ErrDivisionByZero.
+func calculate(lhsVal *Constant, op ArithmeticOperator, rhsVal *Constant) (*Constant, error) {
+	if lhsVal.IsNull() || rhsVal.IsNull() {
+		return NewNullConstant(), nil
+	}
+	for _, val := range []*Constant{lhsVal, rhsVal} {
+		if !val.IsNumber() {
+			return nil, fmt.Errorf("cannot apply %s to %s %s: %w", arithmeticSymbols[op], val.Type(), val, ErrTypeMismatch)
+		}
+	}
+	switch numberTypes[max(numberRank(lhsVal.Type()), numberRank(rhsVal.Type()))] {
+	case record.DOUBLE:
+		return calculateDouble(lhsVal.AsDouble(), op, rhsVal.AsDouble())
+	case record.DECIMAL:
+		return calculateDecimal(lhsVal.AsDecimal(), op, rhsVal.AsDecimal())
+	case record.BIGINT:
+		return calculateLong(lhsVal.AsLong(), op, rhsVal.AsLong())
+	}
+	result, err := calculateLong(lhsVal.AsLong(), op, rhsVal.AsLong())
+	if err != nil {
+		return nil, err
+	}
+	if v := result.AsLong(); v < math.MinInt32 || v > math.MaxInt32 {
+		return nil, fmt.Errorf("int out of range: %w", ErrTypeMismatch)
+	}
+	return NewConstantWithInt(int32(result.AsLong())), nil
+}
+
+func calculateDouble(x float64, op ArithmeticOperator, y float64) (*Constant, error) {
+	if (op == DIV || op == MOD) && y == 0 {
+		return nil, fmt.Errorf("failed to divide %g: %w", x, ErrDivisionByZero)
+	}
+	switch op {
+	case ADD:
+		return NewConstantWithDouble(x + y), nil
+	case SUB:
+		return NewConstantWithDouble(x - y), nil
+	case MUL:
+		return NewConstantWithDouble(x * y), nil
+	case DIV:
+		return NewConstantWithDouble(x / y), nil
+	}
+	return NewConstantWithDouble(math.Mod(x, y)), nil
+}
+
+func calculateDecimal(x Decimal, op ArithmeticOperator, y Decimal) (*Constant, error) {
+	var d Decimal
+	var err error
+	switch op {
+	case ADD:
+		d, err = x.Add(y)
+	case SUB:
+		d, err = x.Sub(y)
+	case MUL:
+		d, err = x.Mul(y)
+	case DIV:
+		d, err = x.Div(y, resultScale(x.Scale(), DIV, y.Scale()))
+	default:
+		d, err = x.Mod(y)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewConstantWithDecimal(d), nil
+}
+
+// calculateLong divides integers towards zero, so that the remainder has
+// the sign of the dividend.
+func calculateLong(x int64, op ArithmeticOperator, y int64) (*Constant, error) {
+	if (op == DIV || op == MOD) && y == 0 {
+		return nil, fmt.Errorf("failed to divide %d: %w", x, ErrDivisionByZero)
+	}
+	var v int64
+	overflow := false
+	switch op {
+	case ADD:
+		v = x + y
+		overflow = (y > 0 && v < x) || (y < 0 && v > x)
+	case SUB:
+		v = x - y
+		overflow = (y > 0 && v > x) || (y < 0 && v < x)
+	case MUL:
+		v = x * y
+		overflow = x != 0 && (v/x != y || x == -1 && y == math.MinInt64)
+	case DIV:
+		v = x / y
+		overflow = x == math.MinInt64 && y == -1
+	default:
+		if y != -1 {
+			v = x % y
+		}
+	}
+	if overflow {
+		return nil, fmt.Errorf("bigint out of range: %w", ErrTypeMismatch)
+	}
+	return NewConstantWithLong(v), nil
 }
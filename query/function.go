@@ -0,0 +1,310 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adieumonks/simple-db/record"
+)
+
+// Function is a scalar function that expressions call by name. A strict
+// function is NULL if any of its arguments is, without being called.
+type Function struct {
+	name       string
+	minArgs    int
+	maxArgs    int
+	strict     bool
+	resultType func(name string, args []valueType) (valueType, error)
+	call       func(args []*Constant) (*Constant, error)
+}
+
+// variadic is the maxArgs of a function that takes any number of arguments.
+const variadic = -1
+
+var functions = map[string]*Function{}
+
+func init() {
+	for _, fn := range []*Function{
+		{name: "upper", minArgs: 1, maxArgs: 1, strict: true, resultType: sameString, call: upper},
+		{name: "lower", minArgs: 1, maxArgs: 1, strict: true, resultType: sameString, call: lower},
+		{name: "trim", minArgs: 1, maxArgs: 1, strict: true, resultType: sameString, call: trim},
+		{name: "length", minArgs: 1, maxArgs: 1, strict: true, resultType: lengthType, call: length},
+		{name: "substr", minArgs: 2, maxArgs: 3, strict: true, resultType: substrType, call: substr},
+		{name: "concat", minArgs: 1, maxArgs: variadic, strict: true, resultType: concatType, call: concat},
+		{name: "abs", minArgs: 1, maxArgs: 1, strict: true, resultType: absType, call: abs},
+		{name: "coalesce", minArgs: 1, maxArgs: variadic, resultType: commonType, call: coalesce},
+		{name: "nullif", minArgs: 2, maxArgs: 2, resultType: nullifType, call: nullif},
+		{name: "year", minArgs: 1, maxArgs: 1, strict: true, resultType: datePartType, call: year},
+		{name: "month", minArgs: 1, maxArgs: 1, strict: true, resultType: datePartType, call: month},
+		{name: "day", minArgs: 1, maxArgs: 1, strict: true, resultType: datePartType, call: day},
+		{name: "add_days", minArgs: 2, maxArgs: 2, strict: true, resultType: addDaysType, call: addDays},
+	} {
+		functions[fn.name] = fn
+	}
+}
+
+// LookupFunction returns the function of the name, or false if there is
+// none.
+func LookupFunction(name string) (*Function, bool) {
+	fn, ok := functions[strings.ToLower(name)]
+	return fn, ok
+}
+
+// ConcatFunction returns the function the || operator calls.
+func ConcatFunction() *Function {
+	return functions["concat"]
+}
+
+func (f *Function) Name() string {
+	return f.name
+}
+
+// Accepts reports whether the function takes the number of arguments.
+func (f *Function) Accepts(n int) bool {
+	return n >= f.minArgs && (f.maxArgs == variadic || n <= f.maxArgs)
+}
+
+// Call returns the result of the function of the arguments, failing with
+// ErrTypeMismatch if it does not take their types.
+func (f *Function) Call(args []*Constant) (*Constant, error) {
+	types := make([]valueType, len(args))
+	for i, arg := range args {
+		types[i] = constantType(arg)
+	}
+	if _, err := f.resultType(f.name, types); err != nil {
+		return nil, err
+	}
+	if f.strict {
+		for _, arg := range args {
+			if arg.IsNull() {
+				return NewNullConstant(), nil
+			}
+		}
+	}
+	return f.call(args)
+}
+
+func constantType(val *Constant) valueType {
+	return valueType{
+		fieldType: val.Type(),
+		length:    int32(utf8.RuneCountInString(val.AsString())),
+		scale:     val.AsDecimal().Scale(),
+		null:      val.IsNull(),
+	}
+}
+
+// expectArg fails with ErrTypeMismatch unless the argument is NULL or has a
+// type the function takes.
+func expectArg(name string, i int, arg valueType, want string, ok func(record.FieldType) bool) error {
+	if arg.null || ok(arg.fieldType) {
+		return nil
+	}
+	return fmt.Errorf("argument %d of %s is %s, not %s: %w", i+1, name, arg.fieldType, want, ErrTypeMismatch)
+}
+
+func isString(fieldType record.FieldType) bool {
+	return fieldType == record.STRING
+}
+
+func isIntegerType(fieldType record.FieldType) bool {
+	return fieldType == record.INTEGER || fieldType == record.BIGINT
+}
+
+func isTimeType(fieldType record.FieldType) bool {
+	return fieldType == record.DATE || fieldType == record.TIMESTAMP
+}
+
+func sameString(name string, args []valueType) (valueType, error) {
+	if err := expectArg(name, 0, args[0], "a string", isString); err != nil {
+		return valueType{}, err
+	}
+	return valueType{fieldType: record.STRING, length: args[0].length, null: args[0].null}, nil
+}
+
+func upper(args []*Constant) (*Constant, error) {
+	return NewConstantWithString(strings.ToUpper(args[0].AsString())), nil
+}
+
+func lower(args []*Constant) (*Constant, error) {
+	return NewConstantWithString(strings.ToLower(args[0].AsString())), nil
+}
+
+func trim(args []*Constant) (*Constant, error) {
+	return NewConstantWithString(strings.Trim(args[0].AsString(), " ")), nil
+}
+
+func lengthType(name string, args []valueType) (valueType, error) {
+	if err := expectArg(name, 0, args[0], "a string", isString); err != nil {
+		return valueType{}, err
+	}
+	return valueType{fieldType: record.INTEGER, null: args[0].null}, nil
+}
+
+func length(args []*Constant) (*Constant, error) {
+	return NewConstantWithInt(int32(utf8.RuneCountInString(args[0].AsString()))), nil
+}
+
+func substrType(name string, args []valueType) (valueType, error) {
+	if err := expectArg(name, 0, args[0], "a string", isString); err != nil {
+		return valueType{}, err
+	}
+	for i, arg := range args[1:] {
+		if err := expectArg(name, i+1, arg, "an integer", isIntegerType); err != nil {
+			return valueType{}, err
+		}
+	}
+	return valueType{fieldType: record.STRING, length: args[0].length}, nil
+}
+
+// substr returns the characters of the string from the 1-based start, up to
+// the given number of them or to its end.
+func substr(args []*Constant) (*Constant, error) {
+	runes := []rune(args[0].AsString())
+	from, to := args[1].AsLong(), int64(len(runes))+1
+	if len(args) == 3 {
+		n := args[2].AsLong()
+		if n < 0 {
+			return nil, fmt.Errorf("negative substr length %d: %w", n, ErrTypeMismatch)
+		}
+		if n < to-from {
+			to = from + n
+		}
+	}
+	from = max(from, 1)
+	if from >= to {
+		return NewConstantWithString(""), nil
+	}
+	return NewConstantWithString(string(runes[from-1 : to-1])), nil
+}
+
+func concatType(name string, args []valueType) (valueType, error) {
+	result := valueType{fieldType: record.STRING}
+	for i, arg := range args {
+		if err := expectArg(name, i, arg, "a string", isString); err != nil {
+			return valueType{}, err
+		}
+		result.length += arg.length
+	}
+	return result, nil
+}
+
+func concat(args []*Constant) (*Constant, error) {
+	var sb strings.Builder
+	for _, arg := range args {
+		sb.WriteString(arg.AsString())
+	}
+	return NewConstantWithString(sb.String()), nil
+}
+
+func absType(name string, args []valueType) (valueType, error) {
+	if err := expectArg(name, 0, args[0], "a number", isNumberType); err != nil {
+		return valueType{}, err
+	}
+	return args[0], nil
+}
+
+func abs(args []*Constant) (*Constant, error) {
+	val := args[0]
+	if val.CompareTo(NewConstantWithInt(0)) >= 0 {
+		return val, nil
+	}
+	if val.Type() == record.DOUBLE {
+		return NewConstantWithDouble(math.Abs(val.AsDouble())), nil
+	}
+	return negate(val)
+}
+
+// commonType returns the type that values of all the arguments have: the
+// widest of numbers, the longest of strings, or a timestamp for dates and
+// timestamps.
+func commonType(name string, args []valueType) (valueType, error) {
+	common := valueType{fieldType: record.INTEGER, null: true}
+	for i, arg := range args {
+		switch {
+		case arg.null:
+		case common.null:
+			common = arg
+		case isNumberType(common.fieldType) && isNumberType(arg.fieldType):
+			common.fieldType = numberTypes[max(numberRank(common.fieldType), numberRank(arg.fieldType))]
+			common.scale = max(common.scale, arg.scale)
+		case isTimeType(common.fieldType) && isTimeType(arg.fieldType):
+			if arg.fieldType == record.TIMESTAMP {
+				common.fieldType = record.TIMESTAMP
+			}
+		case common.fieldType == arg.fieldType:
+			common.length = max(common.length, arg.length)
+		default:
+			return valueType{}, fmt.Errorf("argument %d of %s is %s, not %s: %w", i+1, name, arg.fieldType, common.fieldType, ErrTypeMismatch)
+		}
+	}
+	return common, nil
+}
+
+func coalesce(args []*Constant) (*Constant, error) {
+	for _, arg := range args {
+		if !arg.IsNull() {
+			return arg, nil
+		}
+	}
+	return NewNullConstant(), nil
+}
+
+func nullifType(name string, args []valueType) (valueType, error) {
+	if _, err := commonType(name, args); err != nil {
+		return valueType{}, err
+	}
+	return args[0], nil
+}
+
+// nullif returns NULL if the first argument equals the second, and the
+// first otherwise.
+func nullif(args []*Constant) (*Constant, error) {
+	if !args[1].IsNull() && args[0].Equals(args[1]) {
+		return NewNullConstant(), nil
+	}
+	return args[0], nil
+}
+
+func datePartType(name string, args []valueType) (valueType, error) {
+	if err := expectArg(name, 0, args[0], "a date", isTimeType); err != nil {
+		return valueType{}, err
+	}
+	return valueType{fieldType: record.INTEGER, null: args[0].null}, nil
+}
+
+func year(args []*Constant) (*Constant, error) {
+	return NewConstantWithInt(int32(args[0].AsTime().Year())), nil
+}
+
+func month(args []*Constant) (*Constant, error) {
+	return NewConstantWithInt(int32(args[0].AsTime().Month())), nil
+}
+
+func day(args []*Constant) (*Constant, error) {
+	return NewConstantWithInt(int32(args[0].AsTime().Day())), nil
+}
+
+func addDaysType(name string, args []valueType) (valueType, error) {
+	if err := expectArg(name, 0, args[0], "a date", isTimeType); err != nil {
+		return valueType{}, err
+	}
+	if err := expectArg(name, 1, args[1], "an integer", isIntegerType); err != nil {
+		return valueType{}, err
+	}
+	if args[0].null {
+		return valueType{fieldType: record.DATE, null: true}, nil
+	}
+	return args[0], nil
+}
+
+// addDays returns the date or timestamp the number of days after the first
+// argument, or before it if the number is negative.
+func addDays(args []*Constant) (*Constant, error) {
+	t := args[0].AsTime().AddDate(0, 0, int(args[1].AsLong()))
+	if args[0].Type() == record.DATE {
+		return NewConstantWithDate(t), nil
+	}
+	return NewConstantWithTimestamp(t), nil
+}
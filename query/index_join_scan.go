@@ -44,10 +44,14 @@ func (s *IndexJoinScan) Next() (bool, error) {
 			if err != nil {
 				return false, err
 			}
-			if err := s.rhs.MoveToRID(rid); err != nil {
+			visible, err := s.rhs.MoveToVisibleRID(rid)
+			if err != nil {
 				return false, err
 			}
-			return true, nil
+			if visible {
+				return true, nil
+			}
+			continue
 		}
 		next1, err := s.lhs.Next()
 		if err != nil {
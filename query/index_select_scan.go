@@ -25,20 +25,24 @@ func (s *IndexSelectScan) BeforeFirst() error {
 }
 
 func (s *IndexSelectScan) Next() (bool, error) {
-	next, err := s.idx.Next()
-	if err != nil {
-		return false, err
-	}
-	if next {
+	for {
+		next, err := s.idx.Next()
+		if err != nil || !next {
+			return false, err
+		}
 		rid, err := s.idx.GetDataRID()
 		if err != nil {
 			return false, err
 		}
-		if err := s.ts.MoveToRID(rid); err != nil {
+		// an index refers to records the transaction may not see
+		visible, err := s.ts.MoveToVisibleRID(rid)
+		if err != nil {
 			return false, err
 		}
+		if visible {
+			return true, nil
+		}
 	}
-	return next, nil
 }
 
 func (s *IndexSelectScan) GetInt(fieldName string) (int32, error) {
@@ -0,0 +1,38 @@
+package query
+
+// LockPolicy tells a table scan whether to lock the blocks of the records
+// it reads for update, and what to do when another transaction holds a
+// conflicting lock.
+type LockPolicy int
+
+const (
+	// READ reads records as the isolation level of the transaction says.
+	READ LockPolicy = iota
+	// UPDATE locks records for update, waiting for other transactions.
+	UPDATE
+	// NOWAIT locks records for update, and fails instead of waiting.
+	NOWAIT
+	// SKIP_LOCKED locks records for update, and skips those it cannot lock
+	// without waiting.
+	SKIP_LOCKED
+)
+
+func (p LockPolicy) String() string {
+	switch p {
+	case UPDATE:
+		return "for update"
+	case NOWAIT:
+		return "for update nowait"
+	case SKIP_LOCKED:
+		return "for update skip locked"
+	default:
+		return ""
+	}
+}
+
+// Rejecter is a scan that can give up the locks it took for update to read
+// its current record, when a select scan over it does not select the
+// record.
+type Rejecter interface {
+	Reject()
+}
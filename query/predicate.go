@@ -2,10 +2,25 @@ package query
 
 import (
 	"strings"
+
+	"github.com/adieumonks/simple-db/record"
+)
+
+type Connective int
+
+const (
+	AND Connective = iota
+	OR
+	NOT
 )
 
+// Predicate is a boolean expression: a term, or the conjunction or
+// disjunction of its operands, or the negation of its one operand. The
+// empty predicate is the conjunction of none, which is TRUE.
 type Predicate struct {
-	terms []*Term
+	term       *Term
+	connective Connective
+	operands   []*Predicate
 }
 
 func NewPredicate() *Predicate {
@@ -13,58 +28,254 @@ func NewPredicate() *Predicate {
 }
 
 func NewPredicateFromTerm(t *Term) *Predicate {
-	return &Predicate{
-		terms: []*Term{t},
-	}
+	return &Predicate{term: t}
+}
+
+// NewDisjunction returns a predicate that is TRUE if any of the operands
+// is.
+func NewDisjunction(operands ...*Predicate) *Predicate {
+	return &Predicate{connective: OR, operands: operands}
 }
 
+func NewNegation(operand *Predicate) *Predicate {
+	return &Predicate{connective: NOT, operands: []*Predicate{operand}}
+}
+
+// ConjoinWith makes the predicate the conjunction of itself and the other,
+// keeping the operands of conjunctions at one level.
 func (p *Predicate) ConjoinWith(other *Predicate) {
-	p.terms = append(p.terms, other.terms...)
+	if !p.isConjunction() {
+		operand := *p
+		*p = Predicate{operands: []*Predicate{&operand}}
+	}
+	if other.isConjunction() {
+		p.operands = append(p.operands, other.operands...)
+	} else {
+		p.operands = append(p.operands, other)
+	}
 }
 
+func (p *Predicate) isConjunction() bool {
+	return p.term == nil && p.connective == AND
+}
+
+// IsSatisfied reports whether the predicate is TRUE for the current record
+// of the scan, so that a record for which it is UNKNOWN is not selected.
 func (p *Predicate) IsSatisfied(scan Scan) (bool, error) {
-	for _, term := range p.terms {
-		isSatisfied, err := term.IsSatisfied(scan)
+	truth, err := p.Evaluate(scan)
+	if err != nil {
+		return false, err
+	}
+	return truth == TRUE, nil
+}
+
+// Evaluate returns the truth of the predicate for the current record of the
+// scan, evaluating the operands only until it is known.
+func (p *Predicate) Evaluate(scan Scan) (Truth, error) {
+	if p.term != nil {
+		return p.term.Evaluate(scan)
+	}
+	switch p.connective {
+	case OR:
+		truth := FALSE
+		for _, operand := range p.operands {
+			t, err := operand.Evaluate(scan)
+			if err != nil {
+				return FALSE, err
+			}
+			if truth = truth.Or(t); truth == TRUE {
+				return TRUE, nil
+			}
+		}
+		return truth, nil
+	case NOT:
+		t, err := p.operands[0].Evaluate(scan)
+		if err != nil {
+			return FALSE, err
+		}
+		return t.Not(), nil
+	}
+	truth := TRUE
+	for _, operand := range p.operands {
+		t, err := operand.Evaluate(scan)
 		if err != nil {
-			return false, err
+			return FALSE, err
 		}
-		if !isSatisfied {
-			return false, nil
+		if truth = truth.And(t); truth == FALSE {
+			return FALSE, nil
 		}
 	}
-	return true, nil
+	return truth, nil
 }
 
+// ReductionFactor estimates how many times fewer records satisfy the
+// predicate, up to math.MaxInt32.
 func (p *Predicate) ReductionFactor(plan Plan) int32 {
-	var factor int32
-	for _, term := range p.terms {
-		factor *= term.ReductionFactor(plan)
+	return reductionFactor(p.Selectivity(plan))
+}
+
+// Selectivity estimates the part of the records that satisfy the
+// predicate, taking its operands to be independent of each other.
+func (p *Predicate) Selectivity(plan Plan) float64 {
+	if p.term != nil {
+		return p.term.selectivity(plan)
 	}
-	return factor
+	switch p.connective {
+	case OR:
+		none := 1.0
+		for _, operand := range p.operands {
+			none *= 1 - operand.Selectivity(plan)
+		}
+		return 1 - none
+	case NOT:
+		return 1 - p.operands[0].Selectivity(plan)
+	}
+	all := 1.0
+	for _, operand := range p.operands {
+		all *= operand.Selectivity(plan)
+	}
+	return all
 }
 
+// EquatesWithConstant returns the constant the field equals wherever the
+// predicate is TRUE: the one a conjunct equates it with, or the one every
+// operand of a disjunction equates it with.
 func (p *Predicate) EquatesWithConstant(fieldName string) *Constant {
-	for _, term := range p.terms {
-		if constant := term.EquatesWithConstant(fieldName); constant != nil {
+	if p.term != nil {
+		return p.term.EquatesWithConstant(fieldName)
+	}
+	switch p.connective {
+	case OR:
+		var constant *Constant
+		for _, operand := range p.operands {
+			c := operand.EquatesWithConstant(fieldName)
+			if c == nil || constant != nil && !c.Equals(constant) {
+				return nil
+			}
+			constant = c
+		}
+		return constant
+	case NOT:
+		return nil
+	}
+	for _, operand := range p.operands {
+		if constant := operand.EquatesWithConstant(fieldName); constant != nil {
 			return constant
 		}
 	}
 	return nil
 }
 
+// EquatesWithField returns the field the field equals wherever the
+// predicate is TRUE, in the way of EquatesWithConstant.
 func (p *Predicate) EquatesWithField(fieldName string) string {
-	for _, term := range p.terms {
-		if otherFieldName := term.EquatesWithField(fieldName); otherFieldName != "" {
+	if p.term != nil {
+		return p.term.EquatesWithField(fieldName)
+	}
+	switch p.connective {
+	case OR:
+		var field string
+		for _, operand := range p.operands {
+			f := operand.EquatesWithField(fieldName)
+			if f == "" || field != "" && f != field {
+				return ""
+			}
+			field = f
+		}
+		return field
+	case NOT:
+		return ""
+	}
+	for _, operand := range p.operands {
+		if otherFieldName := operand.EquatesWithField(fieldName); otherFieldName != "" {
 			return otherFieldName
 		}
 	}
 	return ""
 }
 
+// AppliesTo reports whether every term uses only fields of the schema.
+func (p *Predicate) AppliesTo(schema *record.Schema) bool {
+	if p.term != nil {
+		return p.term.AppliesTo(schema)
+	}
+	for _, operand := range p.operands {
+		if !operand.AppliesTo(schema) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckTypes checks the types of every term, in the way of
+// Term.CheckTypes.
+func (p *Predicate) CheckTypes(schema *record.Schema) error {
+	if p.term != nil {
+		return p.term.CheckTypes(schema)
+	}
+	for _, operand := range p.operands {
+		if err := operand.CheckTypes(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UsesField reports whether any term uses the field.
+func (p *Predicate) UsesField(fieldName string) bool {
+	if p.term != nil {
+		return p.term.UsesField(fieldName)
+	}
+	for _, operand := range p.operands {
+		if operand.UsesField(fieldName) {
+			return true
+		}
+	}
+	return false
+}
+
+// RenameField renames the field wherever the terms use it.
+func (p *Predicate) RenameField(oldName, newName string) {
+	if p.term != nil {
+		p.term.RenameField(oldName, newName)
+		return
+	}
+	for _, operand := range p.operands {
+		operand.RenameField(oldName, newName)
+	}
+}
+
+// String writes the predicate so that it parses back to the same one,
+// enclosing in parentheses the operands that bind less tightly than it.
 func (p *Predicate) String() string {
-	var terms []string
-	for _, term := range p.terms {
-		terms = append(terms, term.String())
+	if p.term != nil {
+		return p.term.String()
+	}
+	switch p.connective {
+	case OR:
+		var operands []string
+		for _, operand := range p.operands {
+			operands = append(operands, operand.enclosed(OR))
+		}
+		return strings.Join(operands, " or ")
+	case NOT:
+		return "not " + p.operands[0].enclosed(NOT)
+	}
+	var operands []string
+	for _, operand := range p.operands {
+		operands = append(operands, operand.enclosed(AND))
+	}
+	return strings.Join(operands, " and ")
+}
+
+// enclosed writes the predicate as an operand of the connective, where OR
+// binds less tightly than AND, and AND than NOT.
+func (p *Predicate) enclosed(connective Connective) string {
+	if p.term != nil || p.connective == NOT || p.connective == connective {
+		return p.String()
+	}
+	if p.connective == AND && connective == OR {
+		return p.String()
 	}
-	return strings.Join(terms, " and ")
+	return "(" + p.String() + ")"
 }
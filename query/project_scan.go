@@ -11,12 +11,20 @@ var _ Scan = (*ProjectScan)(nil)
 type ProjectScan struct {
 	scan      Scan
 	fieldList []string
+	// the expressions of the computed fields, by their names
+	exprs map[string]*Expression
 }
 
 func NewProjectScan(scan Scan, fieldList []string) *ProjectScan {
 	return &ProjectScan{scan: scan, fieldList: fieldList}
 }
 
+// NewProjectScanWithExpressions returns a scan of the fields, whose values
+// are those of their expressions for the fields that have one.
+func NewProjectScanWithExpressions(scan Scan, fieldList []string, exprs map[string]*Expression) *ProjectScan {
+	return &ProjectScan{scan: scan, fieldList: fieldList, exprs: exprs}
+}
+
 func (ps *ProjectScan) BeforeFirst() error {
 	if err := ps.scan.BeforeFirst(); err != nil {
 		return err
@@ -29,6 +37,13 @@ func (ps *ProjectScan) Next() (bool, error) {
 }
 
 func (ps *ProjectScan) GetInt(fieldName string) (int32, error) {
+	if expr, ok := ps.exprs[fieldName]; ok {
+		val, err := expr.Evaluate(ps.scan)
+		if err != nil {
+			return 0, err
+		}
+		return val.AsInt(), nil
+	}
 	if ps.HasField(fieldName) {
 		return ps.scan.GetInt(fieldName)
 	}
@@ -36,6 +51,13 @@ func (ps *ProjectScan) GetInt(fieldName string) (int32, error) {
 }
 
 func (ps *ProjectScan) GetString(fieldName string) (string, error) {
+	if expr, ok := ps.exprs[fieldName]; ok {
+		val, err := expr.Evaluate(ps.scan)
+		if err != nil {
+			return "", err
+		}
+		return val.AsString(), nil
+	}
 	if ps.HasField(fieldName) {
 		return ps.scan.GetString(fieldName)
 	}
@@ -43,6 +65,9 @@ func (ps *ProjectScan) GetString(fieldName string) (string, error) {
 }
 
 func (ps *ProjectScan) GetVal(fieldName string) (*Constant, error) {
+	if expr, ok := ps.exprs[fieldName]; ok {
+		return expr.Evaluate(ps.scan)
+	}
 	if ps.HasField(fieldName) {
 		return ps.scan.GetVal(fieldName)
 	}
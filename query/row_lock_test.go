@@ -0,0 +1,149 @@
+package query_test
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/adieumonks/simple-db/query"
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestRowLocks(t *testing.T) {
+	dir := path.Join(t.TempDir(), "rowlocktest")
+	db, err := server.NewSimpleDB(dir, 400, 8)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	sch := record.NewSchema()
+	sch.AddIntField("A")
+	layout := record.NewLayoutFromSchema(sch)
+
+	setup, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	ts, err := query.NewTableScan(setup, "T", layout)
+	if err != nil {
+		t.Fatalf("failed to create table scan: %v", err)
+	}
+	var rids []*record.RID
+	for i := range 3 {
+		if err := ts.Insert(); err != nil {
+			t.Fatalf("failed to insert record: %v", err)
+		}
+		if err := ts.SetInt("A", int32(i)); err != nil {
+			t.Fatalf("failed to set int: %v", err)
+		}
+		rids = append(rids, ts.GetRID())
+	}
+	ts.Close()
+	if err := setup.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	if rids[0].BlockNumber() != rids[2].BlockNumber() {
+		t.Fatalf("expected the records to share a block, got %v and %v", rids[0], rids[2])
+	}
+
+	// transactions updating different records of a block do not wait
+	tx1, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	ts1, err := query.NewTableScan(tx1, "T", layout)
+	if err != nil {
+		t.Fatalf("failed to create table scan: %v", err)
+	}
+	defer ts1.Close()
+	if err := ts1.MoveToRID(rids[0]); err != nil {
+		t.Fatalf("failed to move to rid: %v", err)
+	}
+	if err := ts1.SetInt("A", 10); err != nil {
+		t.Fatalf("failed to set int: %v", err)
+	}
+
+	tx2, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	ts2, err := query.NewTableScan(tx2, "T", layout)
+	if err != nil {
+		t.Fatalf("failed to create table scan: %v", err)
+	}
+	defer ts2.Close()
+	if err := ts2.MoveToRID(rids[1]); err != nil {
+		t.Fatalf("failed to move to rid: %v", err)
+	}
+	if err := ts2.SetInt("A", 11); err != nil {
+		t.Fatalf("failed to set int: %v", err)
+	}
+	// nor does an insert into an empty slot of the block
+	if err := ts2.Insert(); err != nil {
+		t.Fatalf("failed to insert record: %v", err)
+	}
+	if ts2.GetRID().BlockNumber() != rids[0].BlockNumber() {
+		t.Errorf("expected the record to be inserted into block %d, got %v", rids[0].BlockNumber(), ts2.GetRID())
+	}
+	if err := ts2.SetInt("A", 13); err != nil {
+		t.Fatalf("failed to set int: %v", err)
+	}
+
+	// reading a record another transaction changed waits for it
+	done := make(chan int32)
+	go func() {
+		if err := ts2.MoveToRID(rids[0]); err != nil {
+			t.Errorf("failed to move to rid: %v", err)
+		}
+		a, err := ts2.GetInt("A")
+		if err != nil {
+			t.Errorf("failed to get int: %v", err)
+		}
+		done <- a
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case a := <-done:
+		t.Fatalf("expected the read to wait, got %d", a)
+	default:
+	}
+	ts1.Close()
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	if a := <-done; a != 10 {
+		t.Errorf("expected 10, got %d", a)
+	}
+	ts2.Close()
+
+	// after a crash, the committed change is there and the other is undone,
+	// although both were made to the same block
+	db, err = server.NewSimpleDB(dir, 400, 8)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	rtx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if err := rtx.Recover(); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	ts, err = query.NewTableScan(rtx, "T", layout)
+	if err != nil {
+		t.Fatalf("failed to create table scan: %v", err)
+	}
+	defer ts.Close()
+	for i, expected := range []int32{10, 1, 2} {
+		if err := ts.MoveToRID(rids[i]); err != nil {
+			t.Fatalf("failed to move to rid: %v", err)
+		}
+		a, err := ts.GetInt("A")
+		if err != nil {
+			t.Fatalf("failed to get int: %v", err)
+		}
+		if a != expected {
+			t.Errorf("expected %d at %v, got %d", expected, rids[i], a)
+		}
+	}
+}
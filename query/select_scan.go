@@ -37,6 +37,9 @@ func (ss *SelectScan) Next() (bool, error) {
 		if ok {
 			return true, nil
 		}
+		if r, ok := ss.scan.(Rejecter); ok {
+			r.Reject()
+		}
 		next, err = ss.scan.Next()
 		if err != nil {
 			return false, err
@@ -1,36 +1,71 @@
 package query
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/adieumonks/simple-db/file"
 	"github.com/adieumonks/simple-db/record"
 	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
 )
 
 var _ Scan = (*TableScan)(nil)
 var _ UpdateScan = (*TableScan)(nil)
+var _ Rejecter = (*TableScan)(nil)
 
+// TableScan scans the records of a table. In a versioned table, it returns
+// for each record the version the transaction sees, if there is one, and
+// updates a record in place after saving the version others may still see.
+// In a slotted table, it follows a record that grew too large for its
+// block to where it moved.
 type TableScan struct {
 	tx          *tx.Transaction
 	layout      *record.Layout
 	rp          *record.RecordPage
 	filename    string
 	currentSlot int32
+	// where the version of the current record the transaction sees is,
+	// if it is an old one
+	version     *record.RecordPage
+	versionSlot int32
+	// where the current slotted record is, if it moved to another block
+	moved      *record.RecordPage
+	movedSlot  int32
+	lockPolicy LockPolicy
+	// the locks the scan took for update itself, which it gives up for
+	// the records a select scan rejects: on the current block, kept if
+	// any of its records is used, or on the current record
+	blockLocked bool
+	blockUsed   bool
+	slotLocked  bool
+	lockedSlots []int32
+	// whether the current record is in use, not rejected
+	selected bool
 }
 
 func NewTableScan(tx *tx.Transaction, tableName string, layout *record.Layout) (*TableScan, error) {
 	ts := &TableScan{
 		tx:       tx,
 		layout:   layout,
-		filename: tableName + ".tbl",
+		filename: layout.FileName(tableName),
+	}
+	if layout.Versioned() {
+		tx.UseVersions(ts.filename)
 	}
 
-	fileSize, err := tx.Size(ts.filename)
+	// the end of the file is locked once the scan reaches it, so a scan
+	// that only moves to records found through an index leaves it alone
+	fileSize, err := tx.UnlockedSize(ts.filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file size: %v", err)
 	}
 	if fileSize == 0 {
+		// a read-only transaction cannot add the first block,
+		// and there is nothing for it to read yet
+		if tx.IsReadOnly() {
+			return ts, nil
+		}
 		if err := ts.moveToNewBlock(); err != nil {
 			return nil, err
 		}
@@ -42,58 +77,112 @@ func NewTableScan(tx *tx.Transaction, tableName string, layout *record.Layout) (
 	return ts, nil
 }
 
+// SetLockPolicy sets how the scan locks the records it reads from now on.
+func (ts *TableScan) SetLockPolicy(policy LockPolicy) {
+	ts.lockPolicy = policy
+}
+
 func (ts *TableScan) BeforeFirst() error {
+	if ts.tx.IsReadOnly() {
+		fileSize, err := ts.tx.Size(ts.filename)
+		if err != nil {
+			return fmt.Errorf("failed to get file size: %v", err)
+		}
+		if fileSize == 0 {
+			ts.Close()
+			return nil
+		}
+	}
 	return ts.moveToBlock(0)
 }
 
 func (ts *TableScan) Next() (bool, error) {
-	currentSlot, err := ts.rp.NextAfter(ts.currentSlot)
-	if err != nil {
-		return false, fmt.Errorf("failed to get next slot: %v", err)
+	if ts.selected {
+		ts.blockUsed = true
 	}
-	ts.currentSlot = currentSlot
-	for ts.currentSlot < 0 {
+	ts.selected = false
+	for {
+		next, err := ts.nextRecord()
+		if err != nil || !next {
+			return false, err
+		}
+		visible, err := ts.findVisibleVersion()
+		if err != nil {
+			return false, err
+		}
+		if visible {
+			ts.selected = true
+			return true, nil
+		}
+	}
+}
+
+// Reject gives up the lock the scan took for update to read the current
+// record, as a select scan does not select it. The lock on a block is
+// given up once the scan leaves it, if it rejected all its records.
+func (ts *TableScan) Reject() {
+	ts.selected = false
+	if ts.slotLocked {
+		ts.tx.UnlockRecord(ts.rp.Block(), ts.currentSlot)
+		ts.slotLocked = false
+	}
+}
+
+func (ts *TableScan) nextRecord() (bool, error) {
+	if ts.rp == nil {
+		return false, nil
+	}
+	for {
+		// a block is locked for update before any of it is read
+		locked := true
+		if ts.currentSlot < 0 {
+			var err error
+			locked, err = ts.lockBlock()
+			if err != nil {
+				return false, err
+			}
+		}
+		if locked {
+			currentSlot, err := ts.nextSlot()
+			if err != nil {
+				return false, fmt.Errorf("failed to get next slot: %w", err)
+			}
+			if currentSlot >= 0 {
+				ts.currentSlot = currentSlot
+				return true, nil
+			}
+		}
 		atLastBlock, err := ts.atLastBlock()
 		if err != nil {
 			return false, fmt.Errorf("failed to check if at last block: %v", err)
 		}
 		if atLastBlock {
+			ts.currentSlot = -1
 			return false, nil
 		}
 		if err := ts.moveToBlock(ts.rp.Block().Number() + 1); err != nil {
 			return false, err
 		}
-		currentSlot, err = ts.rp.NextAfter(ts.currentSlot)
-		if err != nil {
-			return false, fmt.Errorf("failed to get next slot: %v", err)
-		}
-		ts.currentSlot = currentSlot
 	}
-	return true, nil
 }
 
 func (ts *TableScan) GetInt(fieldName string) (int32, error) {
-	return ts.rp.GetInt(ts.currentSlot, fieldName)
+	rp, slot := ts.current()
+	return rp.GetInt(slot, fieldName)
 }
 
 func (ts *TableScan) GetString(fieldName string) (string, error) {
-	return ts.rp.GetString(ts.currentSlot, fieldName)
+	rp, slot := ts.current()
+	return rp.GetString(slot, fieldName)
 }
 
 func (ts *TableScan) GetVal(fieldName string) (*Constant, error) {
-	if ts.layout.Schema().Type(fieldName) == record.INTEGER {
-		val, err := ts.GetInt(fieldName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get int value: %v", err)
-		}
-		return NewConstantWithInt(val), nil
-	} else {
-		val, err := ts.GetString(fieldName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get string value: %v", err)
-		}
-		return NewConstantWithString(val), nil
+	rp, slot := ts.current()
+	val, err := rp.GetValue(slot, fieldName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get value: %w", err)
 	}
+	return NewFieldConstant(ts.layout.Schema(), fieldName, val), nil
 }
 
 func (ts *TableScan) HasField(fieldName string) bool {
@@ -101,36 +190,86 @@ func (ts *TableScan) HasField(fieldName string) bool {
 }
 
 func (ts *TableScan) Close() {
+	ts.closeVersion()
+	ts.closeMoved()
 	if ts.rp != nil {
+		if ts.blockLocked && !ts.blockUsed && !ts.selected {
+			ts.tx.UnlockBlock(ts.rp.Block())
+		}
+		ts.blockLocked = false
 		ts.tx.Unpin(ts.rp.Block())
+		ts.rp = nil
 	}
 }
 
 func (ts *TableScan) SetInt(fieldName string, val int32) error {
-	return ts.rp.SetInt(ts.currentSlot, fieldName, val)
+	if err := ts.beforeUpdate(); err != nil {
+		return err
+	}
+	rp, slot := ts.record()
+	return rp.SetInt(slot, fieldName, val)
 }
 
 func (ts *TableScan) SetString(fieldName string, val string) error {
-	return ts.rp.SetString(ts.currentSlot, fieldName, val)
+	if err := ts.beforeUpdate(); err != nil {
+		return err
+	}
+	rp, slot := ts.record()
+	err := rp.SetString(slot, fieldName, val)
+	if errors.Is(err, record.ErrRecordTooLarge) {
+		return ts.moveRecord(fieldName, val)
+	}
+	return err
+}
+
+// SetNull sets the field of the current record to NULL, failing with
+// ErrNotNull if the field does not allow it.
+func (ts *TableScan) SetNull(fieldName string) error {
+	if !ts.layout.Schema().Nullable(fieldName) {
+		return fmt.Errorf("failed to set %s: %w", fieldName, ErrNotNull)
+	}
+	if err := ts.beforeUpdate(); err != nil {
+		return err
+	}
+	rp, slot := ts.record()
+	return rp.SetNull(slot, fieldName)
 }
 
+// SetVal sets the field of the current record to the value, converted to
+// the type of the field.
 func (ts *TableScan) SetVal(fieldName string, val *Constant) error {
-	if ts.layout.Schema().Type(fieldName) == record.INTEGER {
-		err := ts.SetInt(fieldName, val.AsInt())
-		if err != nil {
-			return fmt.Errorf("failed to set int value: %w", err)
-		}
-	} else {
-		err := ts.SetString(fieldName, val.AsString())
-		if err != nil {
+	if val.IsNull() {
+		return ts.SetNull(fieldName)
+	}
+	v, err := val.Value(ts.layout.Schema(), fieldName)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", fieldName, err)
+	}
+	if s, ok := v.(string); ok {
+		if err := ts.SetString(fieldName, s); err != nil {
 			return fmt.Errorf("failed to set string value: %w", err)
 		}
+		return nil
+	}
+	if err := ts.beforeUpdate(); err != nil {
+		return err
+	}
+	rp, slot := ts.record()
+	if err := rp.SetValue(slot, fieldName, v); err != nil {
+		return fmt.Errorf("failed to set value: %w", err)
 	}
 	return nil
 }
 
 func (ts *TableScan) Insert() error {
-	currentSlot, err := ts.rp.InsertAfter(ts.currentSlot)
+	ts.closeVersion()
+	ts.closeMoved()
+	if ts.rp == nil {
+		if err := ts.moveToNewBlock(); err != nil {
+			return err
+		}
+	}
+	currentSlot, err := ts.insertAfter()
 	if err != nil {
 		return fmt.Errorf("failed to insert after: %v", err)
 	}
@@ -149,36 +288,506 @@ func (ts *TableScan) Insert() error {
 				return err
 			}
 		}
-		currentSlot, err = ts.rp.InsertAfter(ts.currentSlot)
+		currentSlot, err = ts.insertAfter()
 		if err != nil {
 			return fmt.Errorf("failed to insert after: %v", err)
 		}
 		ts.currentSlot = currentSlot
 	}
+	ts.selected = true
 	return nil
 }
 
+// Delete deletes the current record. A versioned record is only marked
+// as deleted by the transaction, and freed once no transaction sees it.
 func (ts *TableScan) Delete() error {
-	return ts.rp.Delete(ts.currentSlot)
+	if !ts.layout.Versioned() {
+		if ts.moved != nil {
+			if err := ts.moved.Delete(ts.movedSlot); err != nil {
+				return err
+			}
+			ts.closeMoved()
+		}
+		return ts.rp.Delete(ts.currentSlot)
+	}
+	if _, err := ts.lockCurrent(); err != nil {
+		return err
+	}
+	return ts.rp.SetDeleter(ts.currentSlot, ts.tx.TxNumber())
 }
 
 func (ts *TableScan) MoveToRID(rid *record.RID) error {
+	_, err := ts.MoveToVisibleRID(rid)
+	return err
+}
+
+// MoveToVisibleRID moves to the record with the given RID, and reports
+// whether the transaction sees a version of it.
+func (ts *TableScan) MoveToVisibleRID(rid *record.RID) (bool, error) {
 	ts.Close()
 	block := file.NewBlockID(ts.filename, rid.BlockNumber())
 
 	var err error
 	ts.rp, err = record.NewRecordPage(ts.tx, block, ts.layout)
 	if err != nil {
-		return err
+		return false, err
 	}
 	ts.currentSlot = rid.Slot()
-	return nil
+	locked, err := ts.lockBlock()
+	if err == nil && locked {
+		locked, err = ts.lockRecord(ts.currentSlot)
+	}
+	if err != nil || !locked {
+		return false, err
+	}
+	ts.selected = true
+	return ts.findVisibleVersion()
+}
+
+// Vacuum frees the versions of records in a versioned table that no
+// transaction can see any more, and returns how many it freed.
+func (ts *TableScan) Vacuum() (int, error) {
+	if !ts.layout.Versioned() {
+		return 0, nil
+	}
+	horizon := ts.tx.VersionHorizon()
+	size, err := ts.tx.Size(ts.filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file size: %v", err)
+	}
+	freed := 0
+	for blockNum := int32(0); blockNum < size; blockNum++ {
+		if err := ts.moveToBlock(blockNum); err != nil {
+			return 0, err
+		}
+		if err := ts.tx.LockForUpdate(ts.rp.Block()); err != nil {
+			return 0, fmt.Errorf("failed to vacuum: %w", err)
+		}
+		for {
+			currentSlot, err := ts.rp.NextAfter(ts.currentSlot)
+			if err != nil {
+				return 0, fmt.Errorf("failed to get next slot: %v", err)
+			}
+			if currentSlot < 0 {
+				break
+			}
+			ts.currentSlot = currentSlot
+			n, err := ts.vacuumRecord(horizon)
+			if err != nil {
+				return 0, fmt.Errorf("failed to vacuum: %w", err)
+			}
+			freed += n
+		}
+	}
+	return freed, nil
 }
 
 func (ts *TableScan) GetRID() *record.RID {
 	return record.NewRID(ts.rp.Block().Number(), ts.currentSlot)
 }
 
+// nextSlot returns the next record in the current block, locking the
+// records it reads for update as the lock policy says. The locks it took
+// on the empty slots on the way are given up.
+func (ts *TableScan) nextSlot() (int32, error) {
+	if ts.lockPolicy == READ || !ts.layout.LocksRecords() {
+		return ts.rp.NextAfter(ts.currentSlot)
+	}
+	ts.lockedSlots = ts.lockedSlots[:0]
+	slot, err := ts.rp.NextLockedAfter(ts.currentSlot, ts.lockRecord)
+	if err != nil {
+		return 0, err
+	}
+	ts.slotLocked = false
+	for _, s := range ts.lockedSlots {
+		if s == slot {
+			ts.slotLocked = true
+		} else {
+			ts.tx.UnlockRecord(ts.rp.Block(), s)
+		}
+	}
+	return slot, nil
+}
+
+// lockBlock locks the current block for update as the lock policy says,
+// if its records are not locked one by one, and reports whether its
+// records are to be read.
+func (ts *TableScan) lockBlock() (bool, error) {
+	if ts.lockPolicy == READ || ts.layout.LocksRecords() {
+		return true, nil
+	}
+	block := ts.rp.Block()
+	held := ts.tx.HoldsBlockLock(block)
+	if ts.lockPolicy == UPDATE {
+		if err := ts.tx.LockForUpdate(block); err != nil {
+			return false, fmt.Errorf("failed to lock %v for update: %w", block, err)
+		}
+		ts.blockLocked, ts.blockUsed = !held, false
+		return true, nil
+	}
+	locked, err := ts.tx.TryLockForUpdate(block)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock %v for update: %w", block, err)
+	}
+	if !locked && ts.lockPolicy == NOWAIT {
+		return false, fmt.Errorf("failed to lock %v for update: %w", block, concurrency.ErrLockNotAvailable)
+	}
+	ts.blockLocked, ts.blockUsed = locked && !held, false
+	return locked, nil
+}
+
+// lockRecord locks the record in the slot of the current block for update
+// as the lock policy says, and reports whether it is to be read.
+func (ts *TableScan) lockRecord(slot int32) (bool, error) {
+	if ts.lockPolicy == READ || !ts.layout.LocksRecords() {
+		return true, nil
+	}
+	rid := record.NewRID(ts.rp.Block().Number(), slot)
+	held := ts.tx.HoldsRecordLock(ts.rp.Block(), slot)
+	if ts.lockPolicy == UPDATE {
+		if err := ts.tx.LockRecord(ts.rp.Block(), slot, concurrency.X); err != nil {
+			return false, fmt.Errorf("failed to lock record %v for update: %w", rid, err)
+		}
+	} else {
+		locked, err := ts.tx.TryLockRecord(ts.rp.Block(), slot, concurrency.X)
+		if err != nil {
+			return false, fmt.Errorf("failed to lock record %v for update: %w", rid, err)
+		}
+		if !locked && ts.lockPolicy == NOWAIT {
+			return false, fmt.Errorf("failed to lock record %v for update: %w", rid, concurrency.ErrLockNotAvailable)
+		}
+		if !locked {
+			return false, nil
+		}
+	}
+	if !held {
+		ts.lockedSlots = append(ts.lockedSlots, slot)
+	}
+	return true, nil
+}
+
+// current returns where the version of the current record to read is.
+func (ts *TableScan) current() (*record.RecordPage, int32) {
+	if ts.version != nil {
+		return ts.version, ts.versionSlot
+	}
+	return ts.record()
+}
+
+// record returns where the current record is, following it if it moved.
+func (ts *TableScan) record() (*record.RecordPage, int32) {
+	if ts.moved != nil {
+		return ts.moved, ts.movedSlot
+	}
+	return ts.rp, ts.currentSlot
+}
+
+// findVisibleVersion follows the versions of the current record from the
+// newest one, and reports whether the transaction sees one of them.
+func (ts *TableScan) findVisibleVersion() (bool, error) {
+	ts.closeVersion()
+	if !ts.layout.Versioned() {
+		return true, ts.findMoved()
+	}
+	rp, slot := ts.rp, ts.currentSlot
+	for {
+		creator, err := rp.Creator(slot)
+		if err != nil {
+			return false, fmt.Errorf("failed to get creator: %v", err)
+		}
+		if ts.tx.IsVisible(creator) {
+			deleter, err := rp.Deleter(slot)
+			if err != nil {
+				return false, fmt.Errorf("failed to get deleter: %v", err)
+			}
+			return deleter == 0 || !ts.tx.IsVisible(deleter), nil
+		}
+		prev, err := rp.Previous(slot)
+		if err != nil {
+			return false, fmt.Errorf("failed to get previous version: %v", err)
+		}
+		if prev == nil {
+			return false, nil
+		}
+		ts.closeVersion()
+		block := file.NewBlockID(ts.filename, prev.BlockNumber())
+		ts.version, err = record.NewRecordPage(ts.tx, block, ts.layout)
+		if err != nil {
+			return false, err
+		}
+		ts.versionSlot = prev.Slot()
+		rp, slot = ts.version, ts.versionSlot
+	}
+}
+
+func (ts *TableScan) closeVersion() {
+	if ts.version != nil {
+		ts.tx.Unpin(ts.version.Block())
+		ts.version = nil
+	}
+}
+
+// findMoved finds where the current slotted record is, if it moved.
+func (ts *TableScan) findMoved() error {
+	ts.closeMoved()
+	rid, err := ts.rp.Forwarded(ts.currentSlot)
+	if err != nil {
+		return fmt.Errorf("failed to get forwarded record: %v", err)
+	}
+	if rid == nil {
+		return nil
+	}
+	block := file.NewBlockID(ts.filename, rid.BlockNumber())
+	ts.moved, err = record.NewRecordPage(ts.tx, block, ts.layout)
+	if err != nil {
+		return err
+	}
+	ts.movedSlot = rid.Slot()
+	return nil
+}
+
+func (ts *TableScan) closeMoved() {
+	if ts.moved != nil {
+		ts.tx.Unpin(ts.moved.Block())
+		ts.moved = nil
+	}
+}
+
+// moveRecord moves the current slotted record, with the new value of the
+// field, to another block as it grew too large for its own. Its slot keeps
+// where it moved to, so that its RID does not change.
+func (ts *TableScan) moveRecord(fieldName string, val string) error {
+	rp, slot := ts.record()
+	size, err := rp.SizeWith(slot, fieldName, val)
+	if err != nil {
+		return fmt.Errorf("failed to move record: %w", err)
+	}
+	dest, destSlot, err := ts.insertElsewhere(func(rp *record.RecordPage) (int32, error) {
+		return rp.InsertMovedAfter(-1, size)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move record: %w", err)
+	}
+	sch := ts.layout.Schema()
+	for _, name := range sch.Fields() {
+		var v any = val
+		if name != fieldName {
+			v, err = rp.GetValue(slot, name)
+		}
+		if err == nil {
+			err = dest.SetValue(destSlot, name, v)
+		}
+		if err != nil {
+			ts.tx.Unpin(dest.Block())
+			return fmt.Errorf("failed to move record: %w", err)
+		}
+	}
+	// a record that moves again frees where it was
+	if ts.moved != nil {
+		if err := ts.moved.Delete(ts.movedSlot); err != nil {
+			ts.tx.Unpin(dest.Block())
+			return fmt.Errorf("failed to move record: %w", err)
+		}
+		ts.closeMoved()
+	}
+	ts.moved, ts.movedSlot = dest, destSlot
+	rid := record.NewRID(dest.Block().Number(), destSlot)
+	if err := ts.rp.Forward(ts.currentSlot, rid); err != nil {
+		return fmt.Errorf("failed to move record: %w", err)
+	}
+	return nil
+}
+
+// lockCurrent locks the block of the current versioned record, and checks
+// that no other transaction has replaced or deleted the version this one
+// sees. It returns the creator of the version.
+func (ts *TableScan) lockCurrent() (int64, error) {
+	if err := ts.tx.LockForUpdate(ts.rp.Block()); err != nil {
+		return 0, fmt.Errorf("failed to lock record: %w", err)
+	}
+	creator, err := ts.rp.Creator(ts.currentSlot)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get creator: %v", err)
+	}
+	deleter, err := ts.rp.Deleter(ts.currentSlot)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deleter: %v", err)
+	}
+	if !ts.tx.IsVisible(creator) || (deleter != 0 && deleter != ts.tx.TxNumber()) {
+		return 0, fmt.Errorf("failed to lock record %v: %w", ts.GetRID(), ErrWriteConflict)
+	}
+	return creator, nil
+}
+
+// beforeUpdate lets the transaction update the current versioned record in
+// place. Unless the transaction created the version itself, the version is
+// first saved for the transactions that still see it.
+func (ts *TableScan) beforeUpdate() error {
+	if !ts.layout.Versioned() {
+		return nil
+	}
+	creator, err := ts.lockCurrent()
+	if err != nil {
+		return err
+	}
+	if creator == ts.tx.TxNumber() {
+		return nil
+	}
+	rid, err := ts.saveVersion()
+	if err != nil {
+		return fmt.Errorf("failed to save version: %w", err)
+	}
+	// link the saved version before the transaction takes the record,
+	// so that readers find it as soon as they stop seeing the record
+	if err := ts.rp.SetPrevious(ts.currentSlot, rid); err != nil {
+		return err
+	}
+	return ts.rp.SetCreator(ts.currentSlot, ts.tx.TxNumber())
+}
+
+// saveVersion copies the current record to an empty slot, as an old version
+// replaced by the transaction, and returns where the copy is.
+func (ts *TableScan) saveVersion() (*record.RID, error) {
+	dest := ts.rp
+	slot, err := dest.InsertVersionAfter(-1)
+	if err != nil {
+		return nil, err
+	}
+	if slot < 0 {
+		dest, slot, err = ts.insertElsewhere(func(rp *record.RecordPage) (int32, error) {
+			return rp.InsertVersionAfter(-1)
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer ts.tx.Unpin(dest.Block())
+	}
+	if err := ts.rp.CopyTo(ts.currentSlot, dest, slot); err != nil {
+		return nil, err
+	}
+	if err := dest.SetDeleter(slot, ts.tx.TxNumber()); err != nil {
+		return nil, err
+	}
+	return record.NewRID(dest.Block().Number(), slot), nil
+}
+
+// insertElsewhere takes an empty slot with insert, for an old version or
+// a moved record, in the last block of the table, or in a new one. The
+// returned page is pinned.
+func (ts *TableScan) insertElsewhere(insert func(rp *record.RecordPage) (int32, error)) (*record.RecordPage, int32, error) {
+	size, err := ts.tx.Size(ts.filename)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get file size: %v", err)
+	}
+	block := file.NewBlockID(ts.filename, size-1)
+	if err := ts.tx.LockForUpdate(block); err != nil {
+		return nil, 0, err
+	}
+	rp, err := record.NewRecordPage(ts.tx, block, ts.layout)
+	if err != nil {
+		return nil, 0, err
+	}
+	slot, err := insert(rp)
+	if err != nil {
+		return nil, 0, err
+	}
+	if slot >= 0 {
+		return rp, slot, nil
+	}
+	ts.tx.Unpin(block)
+
+	block, err = ts.tx.Append(ts.filename)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to append block: %v", err)
+	}
+	rp, err = record.NewRecordPage(ts.tx, block, ts.layout)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := rp.Format(); err != nil {
+		return nil, 0, err
+	}
+	slot, err = insert(rp)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rp, slot, nil
+}
+
+// vacuumRecord frees the old versions of the current record once every
+// transaction sees the record itself, and the record once every
+// transaction sees it deleted. It returns how many versions it freed.
+func (ts *TableScan) vacuumRecord(horizon int64) (int, error) {
+	creator, err := ts.rp.Creator(ts.currentSlot)
+	if err != nil {
+		return 0, err
+	}
+	deleter, err := ts.rp.Deleter(ts.currentSlot)
+	if err != nil {
+		return 0, err
+	}
+	prev, err := ts.rp.Previous(ts.currentSlot)
+	if err != nil {
+		return 0, err
+	}
+	if deleter != 0 && deleter < horizon {
+		freed, err := ts.freeVersions(prev)
+		if err != nil {
+			return 0, err
+		}
+		if err := ts.rp.Delete(ts.currentSlot); err != nil {
+			return 0, err
+		}
+		return freed + 1, nil
+	}
+	if creator < horizon && prev != nil {
+		freed, err := ts.freeVersions(prev)
+		if err != nil {
+			return 0, err
+		}
+		if err := ts.rp.SetPrevious(ts.currentSlot, nil); err != nil {
+			return 0, err
+		}
+		return freed, nil
+	}
+	return 0, nil
+}
+
+// freeVersions frees the old version at rid and all those before it.
+func (ts *TableScan) freeVersions(rid *record.RID) (int, error) {
+	freed := 0
+	for rid != nil {
+		block := file.NewBlockID(ts.filename, rid.BlockNumber())
+		rp, err := record.NewRecordPage(ts.tx, block, ts.layout)
+		if err != nil {
+			return 0, err
+		}
+		prev, err := rp.Previous(rid.Slot())
+		if err != nil {
+			return 0, err
+		}
+		if err := rp.Delete(rid.Slot()); err != nil {
+			return 0, err
+		}
+		ts.tx.Unpin(block)
+		rid = prev
+		freed++
+	}
+	return freed, nil
+}
+
+// insertAfter takes the next empty slot of the current block. The block is
+// locked first unless its records are locked one by one, as reading a
+// versioned block takes no lock, and a slotted one is read to find room.
+func (ts *TableScan) insertAfter() (int32, error) {
+	if !ts.layout.LocksRecords() {
+		if err := ts.tx.LockForUpdate(ts.rp.Block()); err != nil {
+			return 0, err
+		}
+	}
+	return ts.rp.InsertAfter(ts.currentSlot)
+}
+
 func (ts *TableScan) moveToBlock(blockNum int32) error {
 	ts.Close()
 	block := file.NewBlockID(ts.filename, blockNum)
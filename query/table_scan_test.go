@@ -1,9 +1,11 @@
 package query_test
 
 import (
+	"errors"
 	"math"
 	"math/rand"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/adieumonks/simple-db/query"
@@ -116,3 +118,51 @@ func TestTableScan(t *testing.T) {
 		t.Fatalf("failed to commit: %v", err)
 	}
 }
+
+func TestSlottedTableScanRecordSize(t *testing.T) {
+	db, _ := server.NewSimpleDB(path.Join(t.TempDir(), "slottedscantest"), 400, 8)
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+
+	sch := record.NewSchema()
+	sch.AddIntField("A")
+	sch.AddStringField("B", 300)
+	layout := record.NewSlottedLayoutFromSchema(sch)
+	ts, err := query.NewTableScan(tx, "T", layout)
+	if err != nil {
+		t.Fatalf("failed to create table scan: %v", err)
+	}
+
+	// short records share a block, though the longest would not fit in one
+	for i := range int32(10) {
+		if err := ts.Insert(); err != nil {
+			t.Fatalf("failed to insert record: %v", err)
+		}
+		if err := ts.SetInt("A", i); err != nil {
+			t.Fatalf("failed to set int: %v", err)
+		}
+		if err := ts.SetString("B", "short"); err != nil {
+			t.Fatalf("failed to set string: %v", err)
+		}
+	}
+	if size, _ := tx.Size("T.tbl"); size != 1 {
+		t.Errorf("expected the records to take 1 block, got %d", size)
+	}
+
+	// a record too large for any block fails instead of adding blocks
+	if err := ts.Insert(); err != nil {
+		t.Fatalf("failed to insert record: %v", err)
+	}
+	if err := ts.SetString("B", strings.Repeat("x", 300)); !errors.Is(err, record.ErrRecordExceedsBlock) {
+		t.Errorf("expected %v, got %v", record.ErrRecordExceedsBlock, err)
+	}
+	if size, _ := tx.Size("T.tbl"); size > 2 {
+		t.Errorf("expected at most 2 blocks, got %d", size)
+	}
+	ts.Close()
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to rollback transaction: %v", err)
+	}
+}
@@ -7,51 +7,132 @@ import (
 	"github.com/adieumonks/simple-db/record"
 )
 
+type Operator int
+
+const (
+	EQ Operator = iota
+	IS_NULL
+	IS_NOT_NULL
+	NE
+	LT
+	LE
+	GT
+	GE
+)
+
+// rangeReductionFactor is how many times fewer records are estimated to
+// satisfy a range comparison, as nothing is known of how values spread.
+const rangeReductionFactor = 3
+
+var operatorSymbols = map[Operator]string{
+	EQ: "=",
+	NE: "<>",
+	LT: "<",
+	LE: "<=",
+	GT: ">",
+	GE: ">=",
+}
+
+// Term compares two expressions, or tests whether an expression is NULL,
+// in which case it has no rhs.
 type Term struct {
 	lhs *Expression
 	rhs *Expression
+	op  Operator
 }
 
 func NewTerm(lhs *Expression, rhs *Expression) *Term {
-	return &Term{lhs: lhs, rhs: rhs}
+	return &Term{lhs: lhs, rhs: rhs, op: EQ}
+}
+
+// NewComparisonTerm returns a term comparing the expressions with one of
+// the operators from EQ to GE, other than the NULL tests.
+func NewComparisonTerm(lhs *Expression, op Operator, rhs *Expression) *Term {
+	return &Term{lhs: lhs, rhs: rhs, op: op}
+}
+
+func NewIsNullTerm(expr *Expression) *Term {
+	return &Term{lhs: expr, op: IS_NULL}
+}
+
+func NewIsNotNullTerm(expr *Expression) *Term {
+	return &Term{lhs: expr, op: IS_NOT_NULL}
 }
 
 type Plan interface {
 	DistinctValues(fieldName string) int32
 }
 
+// ReductionFactor estimates how many times fewer records satisfy the term.
 func (t *Term) ReductionFactor(p Plan) int32 {
-	var lhsName, rhsName string
-	if t.lhs.IsFieldName() && t.rhs.IsFieldName() {
-		lhsName = t.lhs.AsFieldName()
-		rhsName = t.rhs.AsFieldName()
-		return max(p.DistinctValues(lhsName), p.DistinctValues(rhsName))
+	return reductionFactor(t.selectivity(p))
+}
+
+// selectivity estimates the part of the records that satisfy the term. An
+// inequality keeps those an equality does not, and a range a part given by
+// rangeReductionFactor.
+func (t *Term) selectivity(p Plan) float64 {
+	if t.rhs == nil {
+		return 1
 	}
-	if t.lhs.IsFieldName() {
-		lhsName = t.lhs.AsFieldName()
-		return p.DistinctValues(lhsName)
+	if t.lhs.IsConstant() && t.rhs.IsConstant() {
+		lhsVal, rhsVal := t.lhs.AsConstant(), t.rhs.AsConstant()
+		if lhsVal == nil || rhsVal == nil {
+			return 0
+		}
+		truth, err := t.compare(lhsVal, rhsVal)
+		if err == nil && truth == TRUE {
+			return 1
+		}
+		return 0
 	}
-	if t.rhs.IsFieldName() {
-		rhsName = t.rhs.AsFieldName()
-		return p.DistinctValues(rhsName)
+	distinct := int32(1)
+	for _, fieldName := range append(t.lhs.FieldNames(), t.rhs.FieldNames()...) {
+		distinct = max(distinct, p.DistinctValues(fieldName))
 	}
-	if t.lhs.AsConstant().Equals(t.rhs.AsConstant()) {
-		return 1
+	switch t.op {
+	case EQ:
+		return 1 / float64(distinct)
+	case NE:
+		return 1 - 1/float64(distinct)
 	}
-	return math.MaxInt32
+	if !t.lhs.IsConstant() && !t.rhs.IsConstant() {
+		return 1.0 / rangeReductionFactor
+	}
+	return 1 / float64(min(rangeReductionFactor, distinct))
 }
 
+// reductionFactor returns how many times fewer records are kept by the
+// selectivity, up to math.MaxInt32 when none are.
+func reductionFactor(selectivity float64) int32 {
+	if selectivity*math.MaxInt32 <= 1 {
+		return math.MaxInt32
+	}
+	return int32(math.Round(1 / selectivity))
+}
+
+// EquatesWithConstant returns the constant the field equals, if the term
+// says so. No field equals NULL.
 func (t *Term) EquatesWithConstant(fieldName string) *Constant {
-	if t.lhs.IsFieldName() && t.lhs.AsFieldName() == fieldName && !t.rhs.IsFieldName() {
-		return t.rhs.AsConstant()
-	} else if t.rhs.IsFieldName() && t.rhs.AsFieldName() == fieldName && !t.lhs.IsFieldName() {
-		return t.lhs.AsConstant()
-	} else {
+	if t.op != EQ {
 		return nil
 	}
+	var c *Constant
+	if t.lhs.IsFieldName() && t.lhs.AsFieldName() == fieldName && t.rhs.IsConstant() {
+		c = t.rhs.AsConstant()
+	} else if t.rhs.IsFieldName() && t.rhs.AsFieldName() == fieldName && t.lhs.IsConstant() {
+		c = t.lhs.AsConstant()
+	}
+	if c == nil || c.IsNull() {
+		return nil
+	}
+	return c
 }
 
 func (t *Term) EquatesWithField(fieldName string) string {
+	if t.op != EQ {
+		return ""
+	}
 	if t.lhs.IsFieldName() && t.lhs.AsFieldName() == fieldName && t.rhs.IsFieldName() {
 		return t.rhs.AsFieldName()
 	} else if t.rhs.IsFieldName() && t.rhs.AsFieldName() == fieldName && t.lhs.IsFieldName() {
@@ -62,21 +143,112 @@ func (t *Term) EquatesWithField(fieldName string) string {
 }
 
 func (t *Term) IsSatisfied(scan Scan) (bool, error) {
-	lhsVal, err := t.lhs.Evaluate(scan)
+	truth, err := t.Evaluate(scan)
 	if err != nil {
 		return false, err
 	}
+	return truth == TRUE, nil
+}
+
+// Evaluate returns the truth of the term for the current record of the
+// scan, which is UNKNOWN if a value compared is NULL.
+func (t *Term) Evaluate(scan Scan) (Truth, error) {
+	lhsVal, err := t.lhs.Evaluate(scan)
+	if err != nil {
+		return FALSE, err
+	}
+	switch t.op {
+	case IS_NULL:
+		return truthOf(lhsVal.IsNull()), nil
+	case IS_NOT_NULL:
+		return truthOf(!lhsVal.IsNull()), nil
+	}
 	rhsVal, err := t.rhs.Evaluate(scan)
 	if err != nil {
-		return false, err
+		return FALSE, err
+	}
+	return t.compare(lhsVal, rhsVal)
+}
+
+// compare returns the truth of the comparison of the values, failing with
+// ErrTypeMismatch if their types do not compare.
+func (t *Term) compare(lhsVal, rhsVal *Constant) (Truth, error) {
+	if lhsVal.IsNull() || rhsVal.IsNull() {
+		return UNKNOWN, nil
+	}
+	if !lhsVal.comparable(rhsVal) {
+		return FALSE, fmt.Errorf("cannot compare %s %s with %s %s: %w", lhsVal.Type(), lhsVal, rhsVal.Type(), rhsVal, ErrTypeMismatch)
+	}
+	c := lhsVal.CompareTo(rhsVal)
+	switch t.op {
+	case EQ:
+		return truthOf(c == 0), nil
+	case NE:
+		return truthOf(c != 0), nil
+	case LT:
+		return truthOf(c < 0), nil
+	case LE:
+		return truthOf(c <= 0), nil
+	case GT:
+		return truthOf(c > 0), nil
+	default:
+		return truthOf(c >= 0), nil
 	}
-	return rhsVal.Equals(lhsVal), nil
 }
 
 func (t *Term) AppliesTo(schema *record.Schema) bool {
+	if t.rhs == nil {
+		return t.lhs.AppliesTo(schema)
+	}
 	return t.lhs.AppliesTo(schema) && t.rhs.AppliesTo(schema)
 }
 
+// CheckTypes fails with ErrTypeMismatch if an expression of the term using
+// only fields of the schema applies an operator or a function to values of
+// types it does not take, or if the term compares values of types that do
+// not compare.
+func (t *Term) CheckTypes(schema *record.Schema) error {
+	var types []valueType
+	for _, expr := range []*Expression{t.lhs, t.rhs} {
+		if expr == nil || !expr.AppliesTo(schema) {
+			continue
+		}
+		vt, err := expr.typeOf(schema)
+		if err != nil {
+			return err
+		}
+		types = append(types, vt)
+	}
+	if len(types) == 2 && !types[0].comparable(types[1]) {
+		return fmt.Errorf("cannot compare %s %s with %s %s: %w", types[0].fieldType, t.lhs, types[1].fieldType, t.rhs, ErrTypeMismatch)
+	}
+	return nil
+}
+
+func (t *Term) UsesField(fieldName string) bool {
+	return t.lhs.UsesField(fieldName) || t.rhs != nil && t.rhs.UsesField(fieldName)
+}
+
+func (t *Term) RenameField(oldName, newName string) {
+	t.lhs.RenameField(oldName, newName)
+	if t.rhs != nil {
+		t.rhs.RenameField(oldName, newName)
+	}
+}
+
 func (t *Term) String() string {
-	return fmt.Sprintf("%s = %s", t.lhs.String(), t.rhs.String())
+	switch t.op {
+	case IS_NULL:
+		return fmt.Sprintf("%s is null", t.lhs.String())
+	case IS_NOT_NULL:
+		return fmt.Sprintf("%s is not null", t.lhs.String())
+	}
+	return fmt.Sprintf("%s %s %s", t.lhs.String(), operatorSymbols[t.op], t.rhs.String())
+}
+
+func truthOf(b bool) Truth {
+	if b {
+		return TRUE
+	}
+	return FALSE
 }
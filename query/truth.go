@@ -0,0 +1,23 @@
+package query
+
+// Truth is a value of the three-valued logic of SQL, where a comparison
+// with NULL is UNKNOWN.
+type Truth int
+
+const (
+	FALSE Truth = iota
+	UNKNOWN
+	TRUE
+)
+
+func (t Truth) And(other Truth) Truth {
+	return min(t, other)
+}
+
+func (t Truth) Or(other Truth) Truth {
+	return max(t, other)
+}
+
+func (t Truth) Not() Truth {
+	return TRUE - t
+}
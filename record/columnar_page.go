@@ -0,0 +1,74 @@
+package record
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/adieumonks/simple-db/file"
+)
+
+// The values of a field of columnar records are stored one after another
+// in the file of the field, in the order of the records in the file of the
+// table, so that a scan reads only the fields it asks for. The slots of a
+// block of the table number the records from the first one of the block.
+
+// ErrFieldExceedsBlock is returned when a field of columnar records has
+// values larger than a block of its file.
+var ErrFieldExceedsBlock = errors.New("field does not fit in a block")
+
+// CheckFields fails with ErrFieldExceedsBlock if a block of the given size
+// cannot hold a value of some field of columnar records.
+func (l *Layout) CheckFields(blockSize int32) error {
+	if l.format != COLUMNAR {
+		return nil
+	}
+	for _, fieldName := range l.schema.Fields() {
+		if width := l.LengthInBytes(fieldName); width > blockSize {
+			return fmt.Errorf("field %s takes %d bytes, more than a block of %d: %w", fieldName, width, blockSize, ErrFieldExceedsBlock)
+		}
+	}
+	return nil
+}
+
+// atColumn calls f with the block of the file of the field holding its
+// value of the record in the slot, pinned, and where the value is in it.
+func (rp *RecordPage) atColumn(slot int32, fieldName string, f func(block file.BlockID, fpos int32) error) error {
+	block, fpos := rp.columnPos(slot, fieldName)
+	if err := rp.tx.Pin(block); err != nil {
+		return err
+	}
+	defer rp.tx.Unpin(block)
+	return f(block, fpos)
+}
+
+// columnPos returns the block of the file of the field, and the position
+// in it, of its value of the record in the slot.
+func (rp *RecordPage) columnPos(slot int32, fieldName string) (file.BlockID, int32) {
+	record := rp.block.Number()*rp.fixedSlotCount() + slot
+	width := rp.layout.LengthInBytes(fieldName)
+	perBlock := rp.tx.BlockSize() / width
+	fileName := rp.layout.ColumnFileName(rp.block.Filename(), fieldName)
+	return file.NewBlockID(fileName, record/perBlock), record % perBlock * width
+}
+
+// extendColumns appends blocks to the files of the fields until they have
+// room for the values of the record in the slot. The appended blocks are
+// zero, the empty value of every type.
+func (rp *RecordPage) extendColumns(slot int32) error {
+	for _, fieldName := range rp.layout.Schema().Fields() {
+		block, _ := rp.columnPos(slot, fieldName)
+		for {
+			size, err := rp.tx.Size(block.Filename())
+			if err != nil {
+				return fmt.Errorf("failed to get file size: %w", err)
+			}
+			if size > block.Number() {
+				break
+			}
+			if _, err := rp.tx.Append(block.Filename()); err != nil {
+				return fmt.Errorf("failed to append block: %w", err)
+			}
+		}
+	}
+	return nil
+}
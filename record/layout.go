@@ -1,31 +1,142 @@
 package record
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/adieumonks/simple-db/file"
 )
 
+// StorageFormat is how the records of a table are stored in its blocks.
+type StorageFormat int32
+
+const (
+	// records take slots of the same size, big enough for any of them
+	FIXED StorageFormat = iota
+	// records take the space their values need, and are found through
+	// a directory of slots at the start of the block
+	SLOTTED
+	// the values of each field are stored apart, in a file of their own,
+	// and the slots of the blocks of the table only flag the records and
+	// their NULL fields
+	COLUMNAR
+)
+
+// A versioned record starts with the numbers of the transactions that
+// created and deleted it, and where its previous version is.
+const (
+	creatorPos       = file.Int32Bytes
+	deleterPos       = creatorPos + file.Int64Bytes
+	previousBlockPos = deleterPos + file.Int64Bytes
+	previousSlotPos  = previousBlockPos + file.Int32Bytes
+	versionEnd       = previousSlotPos + file.Int32Bytes
+)
+
+// Unless no field allows NULL, a record has a bitmap of the fields that are
+// NULL, with a bit for each field in the order they are laid out. It is at
+// the start of a slotted record, and after the header of the slot for the
+// others.
 type Layout struct {
-	schema   *Schema
-	offsets  map[string]int32
-	slotSize int32
+	schema    *Schema
+	offsets   map[string]int32
+	slotSize  int32
+	versioned bool
+	format    StorageFormat
+	// the fields in the order they are laid out
+	order    []string
+	position map[string]int32
+	nullsPos int32
+	// the records are in the file of this generation of the table, which
+	// changes when they are rewritten for a new schema
+	generation int32
 }
 
 func NewLayoutFromSchema(schema *Schema) *Layout {
-	l := &Layout{
-		schema:  schema,
-		offsets: make(map[string]int32),
+	return newLayoutFromSchema(schema, file.Int32Bytes, false)
+}
+
+// NewVersionedLayoutFromSchema lays out records that keep their old
+// versions for multi-version concurrency control.
+func NewVersionedLayoutFromSchema(schema *Schema) *Layout {
+	return newLayoutFromSchema(schema, versionEnd, true)
+}
+
+// NewSlottedLayoutFromSchema lays out records of variable size. The offset
+// of a field is its position in the record, and the slot size the most
+// bytes a record can take.
+func NewSlottedLayoutFromSchema(schema *Schema) *Layout {
+	offsets := make(map[string]int32)
+	slotSize := nullsSize(schema)
+	for i, fieldName := range schema.Fields() {
+		offsets[fieldName] = int32(i)
+		slotSize += lengthInBytes(schema, fieldName)
 	}
-	pos := file.Int32Bytes
-	for _, fieldName := range schema.Fields() {
-		l.offsets[fieldName] = pos
-		pos += l.LengthInBytes(fieldName)
+	return NewSlottedLayout(schema, offsets, slotSize)
+}
+
+// NewColumnarLayoutFromSchema lays out records whose values are stored by
+// field. The offset of a field is its position in the record, which numbers
+// the file of its values.
+func NewColumnarLayoutFromSchema(schema *Schema) *Layout {
+	offsets := make(map[string]int32)
+	for i, fieldName := range schema.Fields() {
+		offsets[fieldName] = int32(i)
 	}
-	l.slotSize = pos
-	return l
+	return NewColumnarLayout(schema, offsets, file.Int32Bytes+nullsSize(schema))
 }
 
 func NewLayout(schema *Schema, offsets map[string]int32, slotSize int32) *Layout {
-	return &Layout{schema, offsets, slotSize}
+	return newLayout(schema, offsets, slotSize, false, FIXED)
+}
+
+func NewVersionedLayout(schema *Schema, offsets map[string]int32, slotSize int32) *Layout {
+	return newLayout(schema, offsets, slotSize, true, FIXED)
+}
+
+func NewSlottedLayout(schema *Schema, offsets map[string]int32, slotSize int32) *Layout {
+	return newLayout(schema, offsets, slotSize, false, SLOTTED)
+}
+
+func NewColumnarLayout(schema *Schema, offsets map[string]int32, slotSize int32) *Layout {
+	return newLayout(schema, offsets, slotSize, false, COLUMNAR)
+}
+
+func newLayoutFromSchema(schema *Schema, pos int32, versioned bool) *Layout {
+	offsets := make(map[string]int32)
+	pos += nullsSize(schema)
+	for _, fieldName := range schema.Fields() {
+		offsets[fieldName] = pos
+		pos += lengthInBytes(schema, fieldName)
+	}
+	return newLayout(schema, offsets, pos, versioned, FIXED)
+}
+
+func newLayout(schema *Schema, offsets map[string]int32, slotSize int32, versioned bool, format StorageFormat) *Layout {
+	l := &Layout{
+		schema:    schema,
+		offsets:   offsets,
+		slotSize:  slotSize,
+		versioned: versioned,
+		format:    format,
+		order:     append([]string(nil), schema.Fields()...),
+		position:  make(map[string]int32),
+	}
+	sort.SliceStable(l.order, func(i, j int) bool {
+		return offsets[l.order[i]] < offsets[l.order[j]]
+	})
+	for i, fieldName := range l.order {
+		l.position[fieldName] = int32(i)
+	}
+	switch {
+	case format == SLOTTED:
+		l.nullsPos = 0
+	case versioned:
+		l.nullsPos = versionEnd
+	default:
+		l.nullsPos = file.Int32Bytes
+	}
+	return l
 }
 
 func (l *Layout) Schema() *Schema {
@@ -40,11 +151,88 @@ func (l *Layout) SlotSize() int32 {
 	return l.slotSize
 }
 
+// SetGeneration sets the generation of the file the records are in.
+func (l *Layout) SetGeneration(generation int32) {
+	l.generation = generation
+}
+
+func (l *Layout) Generation() int32 {
+	return l.generation
+}
+
+// FileName returns the file of the table the records are in.
+func (l *Layout) FileName(tableName string) string {
+	if l.generation == 0 {
+		return tableName + ".tbl"
+	}
+	return fmt.Sprintf("%s.%d.tbl", tableName, l.generation)
+}
+
+// ColumnFileName returns the file holding the values of the field of the
+// columnar records in the file of the table, named by the position of the
+// field so that renaming it keeps its file.
+func (l *Layout) ColumnFileName(fileName, fieldName string) string {
+	return fmt.Sprintf("%s.%d.col", strings.TrimSuffix(fileName, ".tbl"), l.position[fieldName])
+}
+
+// FileNames returns all the files of the table the records are in.
+func (l *Layout) FileNames(tableName string) []string {
+	fileName := l.FileName(tableName)
+	fileNames := []string{fileName}
+	if l.format == COLUMNAR {
+		for _, fieldName := range l.order {
+			fileNames = append(fileNames, l.ColumnFileName(fileName, fieldName))
+		}
+	}
+	return fileNames
+}
+
+func (l *Layout) Versioned() bool {
+	return l.versioned
+}
+
+func (l *Layout) Format() StorageFormat {
+	return l.format
+}
+
+// LocksRecords reports whether the records are locked one by one. Versioned
+// records are updated under locks on their blocks, and so are slotted ones,
+// as they move when their blocks are compacted. The values of a columnar
+// record are locked along with its slot.
+func (l *Layout) LocksRecords() bool {
+	return !l.versioned && l.format != SLOTTED
+}
+
+// nullBit returns where the word holding the NULL bit of the field is,
+// from the start of the bitmap, and the bit.
+func (l *Layout) nullBit(fieldName string) (int32, int32) {
+	position := l.position[fieldName]
+	return l.nullsPos + position/32*file.Int32Bytes, 1 << (position % 32)
+}
+
+// nullWords returns how many words the NULL bitmap takes.
+func (l *Layout) nullWords() int32 {
+	return nullsSize(l.schema) / file.Int32Bytes
+}
+
 func (l *Layout) LengthInBytes(fieldName string) int32 {
-	fieldType := l.schema.Type(fieldName)
-	if fieldType == INTEGER {
+	return lengthInBytes(l.schema, fieldName)
+}
+
+func lengthInBytes(schema *Schema, fieldName string) int32 {
+	switch schema.Type(fieldName) {
+	case INTEGER, BOOLEAN, DATE:
 		return file.Int32Bytes
-	} else {
-		return file.MaxLength(l.schema.Length(fieldName))
+	case BIGINT, DOUBLE, TIMESTAMP, DECIMAL:
+		return file.Int64Bytes
+	default:
+		return file.MaxLength(schema.Length(fieldName))
+	}
+}
+
+func nullsSize(schema *Schema) int32 {
+	if !schema.HasNullable() {
+		return 0
 	}
+	return (int32(len(schema.Fields())) + 31) / 32 * file.Int32Bytes
 }
@@ -5,13 +5,24 @@ import (
 
 	"github.com/adieumonks/simple-db/file"
 	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
 )
 
 const (
 	EMPTY = iota
 	USED
+	// an old version of a record, only reached from the newer one
+	VERSION
+	// a slotted record that moved to another block, where its slot points
+	FORWARDED
+	// a slotted record moved from another block, only reached from there
+	MOVED
 )
 
+// RecordPage stores records in the slots of a block. Unless they are
+// versioned or slotted, records are locked one by one before they are
+// accessed, so that transactions can use different records of the block
+// at once.
 type RecordPage struct {
 	tx     *tx.Transaction
 	block  file.BlockID
@@ -22,12 +33,26 @@ func NewRecordPage(tx *tx.Transaction, block file.BlockID, layout *Layout) (*Rec
 	if err := tx.Pin(block); err != nil {
 		return nil, err
 	}
+	if layout.LocksRecords() {
+		tx.UseRecordLocks(block.Filename())
+	}
+	if layout.Format() == COLUMNAR {
+		for _, fieldName := range layout.Schema().Fields() {
+			tx.UseRecordLocks(layout.ColumnFileName(block.Filename(), fieldName))
+		}
+	}
 	return &RecordPage{tx, block, layout}, nil
 }
 
 func (rp *RecordPage) GetInt(slot int32, fieldName string) (int32, error) {
-	fpos := rp.offset(slot) + rp.layout.Offset(fieldName)
-	val, err := rp.tx.GetInt(rp.block, fpos)
+	if err := rp.lock(slot, concurrency.S); err != nil {
+		return 0, fmt.Errorf("failed to get int: %w", err)
+	}
+	var val int32
+	err := rp.atField(slot, fieldName, func(block file.BlockID, fpos int32) (err error) {
+		val, err = rp.tx.GetInt(block, fpos)
+		return err
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to get int: %v", err)
 	}
@@ -35,8 +60,14 @@ func (rp *RecordPage) GetInt(slot int32, fieldName string) (int32, error) {
 }
 
 func (rp *RecordPage) GetString(slot int32, fieldName string) (string, error) {
-	fpos := rp.offset(slot) + rp.layout.Offset(fieldName)
-	val, err := rp.tx.GetString(rp.block, fpos)
+	if err := rp.lock(slot, concurrency.S); err != nil {
+		return "", fmt.Errorf("failed to get string: %w", err)
+	}
+	var val string
+	err := rp.atField(slot, fieldName, func(block file.BlockID, fpos int32) (err error) {
+		val, err = rp.tx.GetString(block, fpos)
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to get string: %v", err)
 	}
@@ -44,24 +75,111 @@ func (rp *RecordPage) GetString(slot int32, fieldName string) (string, error) {
 }
 
 func (rp *RecordPage) SetInt(slot int32, fieldName string, val int32) error {
-	fpos := rp.offset(slot) + rp.layout.Offset(fieldName)
-	err := rp.tx.SetInt(rp.block, fpos, val, true)
-	if err != nil {
+	if err := rp.lock(slot, concurrency.X); err != nil {
+		return fmt.Errorf("failed to set int: %w", err)
+	}
+	if err := rp.setValue(slot, fieldName, val); err != nil {
+		return fmt.Errorf("failed to set int: %v", err)
+	}
+	if err := rp.setNullBit(slot, fieldName, false); err != nil {
 		return fmt.Errorf("failed to set int: %v", err)
 	}
 	return nil
 }
 
 func (rp *RecordPage) SetString(slot int32, fieldName string, val string) error {
-	fpos := rp.offset(slot) + rp.layout.Offset(fieldName)
-	err := rp.tx.SetString(rp.block, fpos, val, true)
-	if err != nil {
+	if err := rp.lock(slot, concurrency.X); err != nil {
+		return fmt.Errorf("failed to set string: %w", err)
+	}
+	if err := rp.setString(slot, fieldName, val); err != nil {
+		return fmt.Errorf("failed to set string: %w", err)
+	}
+	if err := rp.setNullBit(slot, fieldName, false); err != nil {
 		return fmt.Errorf("failed to set string: %v", err)
 	}
 	return nil
 }
 
+// GetValue returns the value of the field in the slot, of the Go type its
+// field type is stored as, or nil if it is NULL.
+func (rp *RecordPage) GetValue(slot int32, fieldName string) (any, error) {
+	if err := rp.lock(slot, concurrency.S); err != nil {
+		return nil, fmt.Errorf("failed to get value: %w", err)
+	}
+	null, err := rp.IsNull(slot, fieldName)
+	if err != nil || null {
+		return nil, err
+	}
+	var val any
+	err = rp.atField(slot, fieldName, func(block file.BlockID, fpos int32) (err error) {
+		val, err = ReadValue(rp.tx, block, fpos, rp.layout.Schema().Type(fieldName))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get value: %v", err)
+	}
+	return val, nil
+}
+
+// SetValue sets the field in the slot to the value, which must be of the Go
+// type its field type is stored as, or to NULL if it is nil.
+func (rp *RecordPage) SetValue(slot int32, fieldName string, val any) error {
+	if val == nil {
+		return rp.SetNull(slot, fieldName)
+	}
+	if err := rp.lock(slot, concurrency.X); err != nil {
+		return fmt.Errorf("failed to set value: %w", err)
+	}
+	if err := rp.setValue(slot, fieldName, val); err != nil {
+		return fmt.Errorf("failed to set value: %w", err)
+	}
+	if err := rp.setNullBit(slot, fieldName, false); err != nil {
+		return fmt.Errorf("failed to set value: %v", err)
+	}
+	return nil
+}
+
+// IsNull reports whether the field in the slot is NULL.
+func (rp *RecordPage) IsNull(slot int32, fieldName string) (bool, error) {
+	if !rp.layout.Schema().Nullable(fieldName) {
+		return false, nil
+	}
+	if err := rp.lock(slot, concurrency.S); err != nil {
+		return false, fmt.Errorf("failed to check null: %w", err)
+	}
+	pos, bit, err := rp.nullPos(slot, fieldName)
+	if err != nil {
+		return false, fmt.Errorf("failed to check null: %v", err)
+	}
+	word, err := rp.tx.GetInt(rp.block, pos)
+	if err != nil {
+		return false, fmt.Errorf("failed to check null: %v", err)
+	}
+	return word&bit != 0, nil
+}
+
+// SetNull sets the field in the slot to NULL, leaving the zero value of its
+// type where its value is.
+func (rp *RecordPage) SetNull(slot int32, fieldName string) error {
+	if !rp.layout.Schema().Nullable(fieldName) {
+		return fmt.Errorf("failed to set null: field %s is not nullable", fieldName)
+	}
+	if err := rp.lock(slot, concurrency.X); err != nil {
+		return fmt.Errorf("failed to set null: %w", err)
+	}
+	if err := rp.setValue(slot, fieldName, ZeroValue(rp.layout.Schema().Type(fieldName))); err != nil {
+		return fmt.Errorf("failed to set null: %w", err)
+	}
+	if err := rp.setNullBit(slot, fieldName, true); err != nil {
+		return fmt.Errorf("failed to set null: %v", err)
+	}
+	return nil
+}
+
 func (rp *RecordPage) Delete(slot int32) error {
+	if err := rp.lock(slot, concurrency.X); err != nil {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
 	err := rp.setFlag(slot, EMPTY)
 	if err != nil {
 		return fmt.Errorf("failed to delete: %v", err)
@@ -70,22 +188,32 @@ func (rp *RecordPage) Delete(slot int32) error {
 }
 
 func (rp *RecordPage) Format() error {
+	if rp.layout.Format() == SLOTTED {
+		return rp.formatSlotted()
+	}
 	slot := int32(0)
-	for rp.isValidSlot(slot) {
+	for slot < rp.fixedSlotCount() {
+		if err := rp.lock(slot, concurrency.X); err != nil {
+			return err
+		}
 		if err := rp.tx.SetInt(rp.block, rp.offset(slot), EMPTY, false); err != nil {
 			return err
 		}
-		sch := rp.layout.Schema()
-		for _, fieldName := range sch.Fields() {
-			fpos := rp.offset(slot) + rp.layout.Offset(fieldName)
-			if sch.Type(fieldName) == INTEGER {
-				if err := rp.tx.SetInt(rp.block, fpos, 0, false); err != nil {
-					return err
-				}
-			} else {
-				if err := rp.tx.SetString(rp.block, fpos, "", false); err != nil {
-					return err
-				}
+		if rp.layout.Versioned() {
+			if err := rp.formatVersion(slot); err != nil {
+				return err
+			}
+		}
+		for i := int32(0); i < rp.layout.nullWords(); i++ {
+			pos := rp.offset(slot) + rp.layout.nullsPos + i*file.Int32Bytes
+			if err := rp.tx.SetInt(rp.block, pos, 0, false); err != nil {
+				return err
+			}
+		}
+		// the values of columnar records are in the files of their fields
+		if rp.layout.Format() != COLUMNAR {
+			if err := rp.formatValues(slot); err != nil {
+				return err
 			}
 		}
 		slot++
@@ -93,42 +221,245 @@ func (rp *RecordPage) Format() error {
 	return nil
 }
 
+func (rp *RecordPage) formatValues(slot int32) error {
+	sch := rp.layout.Schema()
+	for _, fieldName := range sch.Fields() {
+		fpos := rp.offset(slot) + rp.layout.Offset(fieldName)
+		if err := WriteValue(rp.tx, rp.block, fpos, ZeroValue(sch.Type(fieldName)), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (rp *RecordPage) NextAfter(slot int32) (int32, error) {
-	return rp.searchAfter(slot, USED)
+	return rp.NextLockedAfter(slot, func(slot int32) (bool, error) {
+		return true, rp.lock(slot, concurrency.S)
+	})
+}
+
+// NextLockedAfter returns the next used slot after slot, calling lock to
+// lock each slot before reading it. The slots lock reports it did not lock
+// are skipped.
+func (rp *RecordPage) NextLockedAfter(slot int32, lock func(slot int32) (bool, error)) (int32, error) {
+	count, err := rp.slotCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to search after: %v", err)
+	}
+	slot++
+	for slot < count {
+		locked, err := lock(slot)
+		if err != nil {
+			return 0, fmt.Errorf("failed to search after: %w", err)
+		}
+		if locked {
+			flag, err := rp.getFlag(slot)
+			if err != nil {
+				return 0, fmt.Errorf("failed to search after: %v", err)
+			}
+			if flag == USED || flag == FORWARDED {
+				return slot, nil
+			}
+		}
+		slot++
+	}
+	return -1, nil
 }
+
 func (rp *RecordPage) InsertAfter(slot int32) (int32, error) {
-	newSlot, err := rp.searchAfter(slot, EMPTY)
+	return rp.insertAfter(slot, USED)
+}
+
+// InsertMovedAfter takes an empty slot for a record of size bytes moved
+// from another block, or returns -1 if the block has no room for it.
+func (rp *RecordPage) InsertMovedAfter(slot int32, size int32) (int32, error) {
+	if rp.layout.Format() == SLOTTED {
+		return rp.insertSlottedAfter(slot, MOVED, size)
+	}
+	return rp.insertAfter(slot, MOVED)
+}
+
+// InsertVersionAfter takes an empty slot for an old version of a record.
+func (rp *RecordPage) InsertVersionAfter(slot int32) (int32, error) {
+	return rp.insertAfter(slot, VERSION)
+}
+
+// Creator returns the number of the transaction that created the version
+// of a record in the slot.
+func (rp *RecordPage) Creator(slot int32) (int64, error) {
+	return rp.tx.GetLong(rp.block, rp.offset(slot)+creatorPos)
+}
+
+// Deleter returns the number of the transaction that deleted or replaced
+// the version of a record in the slot, or 0 if none has.
+func (rp *RecordPage) Deleter(slot int32) (int64, error) {
+	return rp.tx.GetLong(rp.block, rp.offset(slot)+deleterPos)
+}
+
+// Previous returns where the previous version of the record in the slot
+// is, or nil if it has none.
+func (rp *RecordPage) Previous(slot int32) (*RID, error) {
+	blockNum, err := rp.tx.GetInt(rp.block, rp.offset(slot)+previousBlockPos)
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert after: %v", err)
+		return nil, err
 	}
-	if newSlot >= 0 {
-		err = rp.setFlag(newSlot, USED)
+	if blockNum < 0 {
+		return nil, nil
+	}
+	prevSlot, err := rp.tx.GetInt(rp.block, rp.offset(slot)+previousSlotPos)
+	if err != nil {
+		return nil, err
+	}
+	return NewRID(blockNum, prevSlot), nil
+}
+
+func (rp *RecordPage) SetCreator(slot int32, txnum int64) error {
+	return rp.tx.SetLong(rp.block, rp.offset(slot)+creatorPos, txnum, true)
+}
+
+func (rp *RecordPage) SetDeleter(slot int32, txnum int64) error {
+	return rp.tx.SetLong(rp.block, rp.offset(slot)+deleterPos, txnum, true)
+}
+
+func (rp *RecordPage) SetPrevious(slot int32, rid *RID) error {
+	blockNum, prevSlot := int32(-1), int32(-1)
+	if rid != nil {
+		blockNum, prevSlot = rid.BlockNumber(), rid.Slot()
+	}
+	if err := rp.tx.SetInt(rp.block, rp.offset(slot)+previousBlockPos, blockNum, true); err != nil {
+		return err
+	}
+	return rp.tx.SetInt(rp.block, rp.offset(slot)+previousSlotPos, prevSlot, true)
+}
+
+// CopyTo copies the version of a record in the slot to a slot of dest.
+func (rp *RecordPage) CopyTo(slot int32, dest *RecordPage, destSlot int32) error {
+	creator, err := rp.Creator(slot)
+	if err != nil {
+		return err
+	}
+	if err := dest.SetCreator(destSlot, creator); err != nil {
+		return err
+	}
+	deleter, err := rp.Deleter(slot)
+	if err != nil {
+		return err
+	}
+	if err := dest.SetDeleter(destSlot, deleter); err != nil {
+		return err
+	}
+	prev, err := rp.Previous(slot)
+	if err != nil {
+		return err
+	}
+	if err := dest.SetPrevious(destSlot, prev); err != nil {
+		return err
+	}
+	for _, fieldName := range rp.layout.Schema().Fields() {
+		val, err := rp.GetValue(slot, fieldName)
 		if err != nil {
-			return 0, fmt.Errorf("failed to insert after: %v", err)
+			return err
+		}
+		if err := dest.SetValue(destSlot, fieldName, val); err != nil {
+			return err
 		}
 	}
-	return newSlot, nil
+	return nil
 }
 
 func (rp *RecordPage) Block() file.BlockID {
 	return rp.block
 }
 
+// insertAfter takes the next empty slot, with all the fields that allow it
+// NULL. A new version of a record is
+// created by the transaction, which readers can tell before its flag is set.
+func (rp *RecordPage) insertAfter(slot int32, flag int32) (int32, error) {
+	if rp.layout.Format() == SLOTTED {
+		return rp.insertSlottedAfter(slot, flag, recordSize(rp.emptyValues()))
+	}
+	newSlot, err := rp.searchEmptyAfter(slot)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert after: %v", err)
+	}
+	if newSlot < 0 {
+		return newSlot, nil
+	}
+	if rp.layout.Versioned() {
+		if err := rp.SetCreator(newSlot, rp.tx.TxNumber()); err != nil {
+			return 0, fmt.Errorf("failed to insert after: %v", err)
+		}
+		if err := rp.SetDeleter(newSlot, 0); err != nil {
+			return 0, fmt.Errorf("failed to insert after: %v", err)
+		}
+		if err := rp.SetPrevious(newSlot, nil); err != nil {
+			return 0, fmt.Errorf("failed to insert after: %v", err)
+		}
+	}
+	for i, word := range rp.nullWords() {
+		pos := rp.offset(newSlot) + rp.layout.nullsPos + int32(i)*file.Int32Bytes
+		if err := rp.tx.SetInt(rp.block, pos, word, true); err != nil {
+			return 0, fmt.Errorf("failed to insert after: %v", err)
+		}
+	}
+	if rp.layout.Format() == COLUMNAR {
+		if err := rp.extendColumns(newSlot); err != nil {
+			return 0, fmt.Errorf("failed to insert after: %v", err)
+		}
+	}
+	if err := rp.setFlag(newSlot, flag); err != nil {
+		return 0, fmt.Errorf("failed to insert after: %v", err)
+	}
+	return newSlot, nil
+}
+
+func (rp *RecordPage) formatVersion(slot int32) error {
+	if err := rp.tx.SetLong(rp.block, rp.offset(slot)+creatorPos, 0, false); err != nil {
+		return err
+	}
+	if err := rp.tx.SetLong(rp.block, rp.offset(slot)+deleterPos, 0, false); err != nil {
+		return err
+	}
+	if err := rp.tx.SetInt(rp.block, rp.offset(slot)+previousBlockPos, -1, false); err != nil {
+		return err
+	}
+	return rp.tx.SetInt(rp.block, rp.offset(slot)+previousSlotPos, -1, false)
+}
+
 func (rp *RecordPage) setFlag(slot int32, flag int32) error {
-	err := rp.tx.SetInt(rp.block, rp.offset(slot), flag, true)
+	err := rp.tx.SetInt(rp.block, rp.flagPos(slot), flag, true)
 	if err != nil {
 		return fmt.Errorf("failed to set flag: %v", err)
 	}
 	return nil
 }
-func (rp *RecordPage) searchAfter(slot int32, flag int32) (int32, error) {
+
+// searchEmptyAfter returns the next empty slot after slot that the
+// transaction can lock without waiting. A slot emptied by a transaction
+// that has not finished stays locked, as it may be rolled back.
+func (rp *RecordPage) searchEmptyAfter(slot int32) (int32, error) {
 	slot++
-	for rp.isValidSlot(slot) {
-		flagVal, err := rp.getFlag(slot)
+	for slot < rp.fixedSlotCount() {
+		flag, err := rp.getFlag(slot)
 		if err != nil {
 			return 0, fmt.Errorf("failed to search after: %v", err)
 		}
-		if flagVal == flag {
+		if flag == EMPTY && !rp.layout.Versioned() {
+			locked, err := rp.tx.TryLockRecord(rp.block, slot, concurrency.X)
+			if err != nil {
+				return 0, fmt.Errorf("failed to search after: %w", err)
+			}
+			// the slot may have been taken before it was locked
+			if locked {
+				flag, err = rp.getFlag(slot)
+				if err != nil {
+					return 0, fmt.Errorf("failed to search after: %v", err)
+				}
+			} else {
+				flag = USED
+			}
+		}
+		if flag == EMPTY {
 			return slot, nil
 		}
 		slot++
@@ -136,52 +467,81 @@ func (rp *RecordPage) searchAfter(slot int32, flag int32) (int32, error) {
 	return -1, nil
 }
 
+// lock locks the record in the slot, if records are locked one by one.
+func (rp *RecordPage) lock(slot int32, mode concurrency.LockMode) error {
+	if !rp.layout.LocksRecords() {
+		return nil
+	}
+	return rp.tx.LockRecord(rp.block, slot, mode)
+}
+
 func (rp *RecordPage) getFlag(slot int32) (int32, error) {
-	val, err := rp.tx.GetInt(rp.block, rp.offset(slot))
+	val, err := rp.tx.GetInt(rp.block, rp.flagPos(slot))
 	if err != nil {
 		return 0, fmt.Errorf("failed to get flag: %v", err)
 	}
 	return val, nil
 }
 
-func (rp *RecordPage) isValidSlot(slot int32) bool {
-	return rp.offset(slot+1) <= rp.tx.BlockSize()
+// slotCount returns how many slots the block has.
+func (rp *RecordPage) slotCount() (int32, error) {
+	if rp.layout.Format() == SLOTTED {
+		return rp.tx.GetInt(rp.block, slotCountPos)
+	}
+	return rp.fixedSlotCount(), nil
+}
+
+func (rp *RecordPage) fixedSlotCount() int32 {
+	return rp.tx.BlockSize() / rp.layout.SlotSize()
+}
+
+// fieldPos returns where the value of the field in the slot is.
+func (rp *RecordPage) fieldPos(slot int32, fieldName string) (int32, error) {
+	if rp.layout.Format() == SLOTTED {
+		return rp.slottedFieldPos(slot, fieldName)
+	}
+	return rp.offset(slot) + rp.layout.Offset(fieldName), nil
+}
+
+// atField calls f with the block and position of the value of the field in
+// the slot. The value of a columnar record is in the file of its field,
+// whose block is pinned meanwhile.
+func (rp *RecordPage) atField(slot int32, fieldName string, f func(block file.BlockID, fpos int32) error) error {
+	if rp.layout.Format() == COLUMNAR {
+		return rp.atColumn(slot, fieldName, f)
+	}
+	fpos, err := rp.fieldPos(slot, fieldName)
+	if err != nil {
+		return err
+	}
+	return f(rp.block, fpos)
+}
+
+func (rp *RecordPage) flagPos(slot int32) int32 {
+	if rp.layout.Format() == SLOTTED {
+		return rp.entryPos(slot)
+	}
+	return rp.offset(slot)
 }
 
 func (rp *RecordPage) offset(slot int32) int32 {
 	return slot * rp.layout.SlotSize()
 }
 
+// Compare compares the records in the slots by the fields, where NULL comes
+// before any value.
 func (rp *RecordPage) Compare(slot1, slot2 int32, fields []string) (int, error) {
 	for _, fieldName := range fields {
-		if rp.layout.Schema().Type(fieldName) == INTEGER {
-			val1, err := rp.GetInt(slot1, fieldName)
-			if err != nil {
-				return 0, err
-			}
-			val2, err := rp.GetInt(slot2, fieldName)
-			if err != nil {
-				return 0, err
-			}
-			if val1 < val2 {
-				return -1, nil
-			} else if val1 > val2 {
-				return 1, nil
-			}
-		} else {
-			val1, err := rp.GetString(slot1, fieldName)
-			if err != nil {
-				return 0, err
-			}
-			val2, err := rp.GetString(slot2, fieldName)
-			if err != nil {
-				return 0, err
-			}
-			if val1 < val2 {
-				return -1, nil
-			} else if val1 > val2 {
-				return 1, nil
-			}
+		val1, err := rp.GetValue(slot1, fieldName)
+		if err != nil {
+			return 0, err
+		}
+		val2, err := rp.GetValue(slot2, fieldName)
+		if err != nil {
+			return 0, err
+		}
+		if c := CompareValues(val1, val2); c != 0 {
+			return c, nil
 		}
 	}
 	return 0, nil
@@ -189,37 +549,91 @@ func (rp *RecordPage) Compare(slot1, slot2 int32, fields []string) (int, error)
 
 func (rp *RecordPage) Swap(slot1, slot2 int32) error {
 	for _, fieldName := range rp.layout.Schema().Fields() {
-		if rp.layout.Schema().Type(fieldName) == INTEGER {
-			val1, err := rp.GetInt(slot1, fieldName)
-			if err != nil {
-				return err
-			}
-			val2, err := rp.GetInt(slot2, fieldName)
-			if err != nil {
-				return err
-			}
-			if err := rp.SetInt(slot1, fieldName, val2); err != nil {
-				return err
-			}
-			if err := rp.SetInt(slot2, fieldName, val1); err != nil {
-				return err
-			}
-		} else {
-			val1, err := rp.GetString(slot1, fieldName)
-			if err != nil {
-				return err
-			}
-			val2, err := rp.GetString(slot2, fieldName)
-			if err != nil {
-				return err
-			}
-			if err := rp.SetString(slot1, fieldName, val2); err != nil {
-				return err
-			}
-			if err := rp.SetString(slot2, fieldName, val1); err != nil {
-				return err
-			}
+		val1, err := rp.GetValue(slot1, fieldName)
+		if err != nil {
+			return err
+		}
+		val2, err := rp.GetValue(slot2, fieldName)
+		if err != nil {
+			return err
+		}
+		if err := rp.SetValue(slot1, fieldName, val2); err != nil {
+			return err
+		}
+		if err := rp.SetValue(slot2, fieldName, val1); err != nil {
+			return err
 		}
 	}
 	return nil
 }
+
+func (rp *RecordPage) setString(slot int32, fieldName string, val string) error {
+	if rp.layout.Format() == SLOTTED {
+		return rp.setSlottedString(slot, fieldName, val)
+	}
+	return rp.atField(slot, fieldName, func(block file.BlockID, fpos int32) error {
+		return rp.tx.SetString(block, fpos, val, true)
+	})
+}
+
+// setNullBit sets whether the field in the slot is NULL, writing the
+// bitmap only if it changes.
+func (rp *RecordPage) setNullBit(slot int32, fieldName string, null bool) error {
+	if !rp.layout.Schema().Nullable(fieldName) {
+		return nil
+	}
+	pos, bit, err := rp.nullPos(slot, fieldName)
+	if err != nil {
+		return err
+	}
+	word, err := rp.tx.GetInt(rp.block, pos)
+	if err != nil {
+		return err
+	}
+	newWord := word &^ bit
+	if null {
+		newWord = word | bit
+	}
+	if newWord == word {
+		return nil
+	}
+	return rp.tx.SetInt(rp.block, pos, newWord, true)
+}
+
+// nullPos returns where the word holding the NULL bit of the field in the
+// slot is, and the bit.
+func (rp *RecordPage) nullPos(slot int32, fieldName string) (int32, int32, error) {
+	pos, bit := rp.layout.nullBit(fieldName)
+	if rp.layout.Format() == SLOTTED {
+		start, err := rp.tx.GetInt(rp.block, rp.entryPos(slot)+entryRecordPos)
+		if err != nil {
+			return 0, 0, err
+		}
+		return start + pos, bit, nil
+	}
+	return rp.offset(slot) + pos, bit, nil
+}
+
+// nullWords returns the words of a bitmap where all the fields that allow
+// it are NULL.
+func (rp *RecordPage) nullWords() []int32 {
+	words := make([]int32, rp.layout.nullWords())
+	for _, fieldName := range rp.layout.order {
+		if rp.layout.Schema().Nullable(fieldName) {
+			position := rp.layout.position[fieldName]
+			words[position/32] |= 1 << (position % 32)
+		}
+	}
+	return words
+}
+
+// setValue writes the value of the field in the slot, leaving its NULL bit
+// as it is.
+func (rp *RecordPage) setValue(slot int32, fieldName string, val any) error {
+	if s, ok := val.(string); ok {
+		return rp.setString(slot, fieldName, s)
+	}
+	return rp.atField(slot, fieldName, func(block file.BlockID, fpos int32) error {
+		return WriteValue(rp.tx, block, fpos, val, true)
+	})
+}
@@ -1,15 +1,56 @@
 package record
 
+import "fmt"
+
+// FieldType is the type of a field. Its values are stored as int32 for
+// INTEGER and BOOLEAN, string for STRING, int64 for BIGINT, float64 for
+// DOUBLE, int32 days since the Unix epoch for DATE, and int64 microseconds
+// since the Unix epoch for TIMESTAMP. A BOOLEAN is given as a bool, and a
+// DECIMAL as its int64 unscaled value, whose scale the schema keeps.
 type FieldType int32
 
 const (
 	INTEGER FieldType = iota
 	STRING
+	BIGINT
+	BOOLEAN
+	DOUBLE
+	DATE
+	TIMESTAMP
+	DECIMAL
 )
 
+func (t FieldType) String() string {
+	switch t {
+	case INTEGER:
+		return "int"
+	case STRING:
+		return "varchar"
+	case BIGINT:
+		return "bigint"
+	case BOOLEAN:
+		return "boolean"
+	case DOUBLE:
+		return "double"
+	case DATE:
+		return "date"
+	case TIMESTAMP:
+		return "timestamp"
+	case DECIMAL:
+		return "decimal"
+	default:
+		return fmt.Sprintf("FieldType(%d)", int32(t))
+	}
+}
+
 type FieldInfo struct {
 	fieldType FieldType
-	length    int32
+	// the length of a string, or the precision of a decimal
+	length int32
+	// the digits of a decimal after the point
+	scale int32
+	// fields allow NULL unless declared NOT NULL
+	notNull bool
 }
 
 type Schema struct {
@@ -26,7 +67,7 @@ func NewSchema() *Schema {
 
 func (s *Schema) AddField(fieldName string, fieldType FieldType, length int32) {
 	s.fields = append(s.fields, fieldName)
-	s.info[fieldName] = FieldInfo{fieldType, length}
+	s.info[fieldName] = FieldInfo{fieldType: fieldType, length: length}
 }
 
 func (s *Schema) AddIntField(fieldName string) {
@@ -37,10 +78,20 @@ func (s *Schema) AddStringField(fieldName string, length int32) {
 	s.AddField(fieldName, STRING, length)
 }
 
+// AddDecimalField adds a field of DECIMAL(precision, scale).
+func (s *Schema) AddDecimalField(fieldName string, precision, scale int32) {
+	s.AddField(fieldName, DECIMAL, precision)
+	s.SetScale(fieldName, scale)
+}
+
 func (s *Schema) Add(fieldName string, sch *Schema) {
-	fieldType := sch.Type(fieldName)
-	length := sch.Length(fieldName)
-	s.AddField(fieldName, fieldType, length)
+	s.AddAs(fieldName, fieldName, sch)
+}
+
+// AddAs adds a field of the other schema under a new name.
+func (s *Schema) AddAs(fieldName, otherName string, sch *Schema) {
+	s.fields = append(s.fields, fieldName)
+	s.info[fieldName] = sch.info[otherName]
 }
 
 func (s *Schema) AddAll(sch *Schema) {
@@ -65,3 +116,35 @@ func (s *Schema) Type(fieldName string) FieldType {
 func (s *Schema) Length(fieldName string) int32 {
 	return s.info[fieldName].length
 }
+
+// SetScale sets the digits after the point of a decimal field.
+func (s *Schema) SetScale(fieldName string, scale int32) {
+	info := s.info[fieldName]
+	info.scale = scale
+	s.info[fieldName] = info
+}
+
+func (s *Schema) Scale(fieldName string) int32 {
+	return s.info[fieldName].scale
+}
+
+// SetNullable sets whether the field allows NULL.
+func (s *Schema) SetNullable(fieldName string, nullable bool) {
+	info := s.info[fieldName]
+	info.notNull = !nullable
+	s.info[fieldName] = info
+}
+
+func (s *Schema) Nullable(fieldName string) bool {
+	return !s.info[fieldName].notNull
+}
+
+// HasNullable reports whether any field allows NULL.
+func (s *Schema) HasNullable() bool {
+	for _, fieldName := range s.fields {
+		if s.Nullable(fieldName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,373 @@
+package record
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/adieumonks/simple-db/file"
+)
+
+// A slotted block starts with the number of its slots and where the
+// records stored from its end begin, followed by the directory of its
+// slots. An entry of the directory holds the flag of the slot, and where
+// the record is and how many bytes it takes. Compacting the block moves
+// the records, but not the entries, so their RIDs do not change.
+const (
+	slotCountPos    = 0
+	recordsStartPos = slotCountPos + file.Int32Bytes
+	directoryPos    = recordsStartPos + file.Int32Bytes
+
+	entryRecordPos    = file.Int32Bytes
+	entryRecordLength = entryRecordPos + file.Int32Bytes
+	entrySize         = entryRecordLength + file.Int32Bytes
+)
+
+// ErrRecordTooLarge is returned when a record grows larger than the free
+// space of its block, for it to be moved to another one.
+var ErrRecordTooLarge = errors.New("record does not fit in its block")
+
+// ErrRecordExceedsBlock is returned when a record is larger than the free
+// space of an empty block, so that no block can hold it.
+var ErrRecordExceedsBlock = errors.New("record does not fit in any block")
+
+func (rp *RecordPage) formatSlotted() error {
+	if err := rp.tx.SetInt(rp.block, slotCountPos, 0, false); err != nil {
+		return err
+	}
+	return rp.tx.SetInt(rp.block, recordsStartPos, rp.tx.BlockSize(), false)
+}
+
+// insertSlottedAfter takes the next empty slot, or a new one at the end of
+// the directory, for a record of empty values. It returns -1 if the block
+// has no room for a record of size bytes; a record that grows larger than
+// its block has room for later is moved to another one.
+func (rp *RecordPage) insertSlottedAfter(slot int32, flag int32, size int32) (int32, error) {
+	if size > rp.tx.BlockSize()-rp.entryPos(1) {
+		return 0, fmt.Errorf("failed to insert record of %d bytes: %w", size, ErrRecordExceedsBlock)
+	}
+	count, err := rp.slotCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert after: %v", err)
+	}
+	need := size
+	newSlot := int32(-1)
+	for s := slot + 1; s < count; s++ {
+		f, err := rp.getFlag(s)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert after: %v", err)
+		}
+		if f == EMPTY {
+			newSlot = s
+			break
+		}
+	}
+	if newSlot < 0 {
+		newSlot = count
+		need += entrySize
+	}
+	free, err := rp.freeSpace(newSlot)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert after: %v", err)
+	}
+	if free < need {
+		return -1, nil
+	}
+	if newSlot == count {
+		if err := rp.tx.SetInt(rp.block, slotCountPos, count+1, true); err != nil {
+			return 0, fmt.Errorf("failed to insert after: %v", err)
+		}
+	}
+	if err := rp.place(newSlot, rp.emptyValues()); err != nil {
+		return 0, fmt.Errorf("failed to insert after: %w", err)
+	}
+	if err := rp.setFlag(newSlot, flag); err != nil {
+		return 0, fmt.Errorf("failed to insert after: %v", err)
+	}
+	return newSlot, nil
+}
+
+// Forward replaces the record in the slot with where it moved to.
+func (rp *RecordPage) Forward(slot int32, rid *RID) error {
+	if err := rp.place(slot, []any{rid.BlockNumber(), rid.Slot()}); err != nil {
+		return fmt.Errorf("failed to forward: %w", err)
+	}
+	if err := rp.setFlag(slot, FORWARDED); err != nil {
+		return fmt.Errorf("failed to forward: %v", err)
+	}
+	return nil
+}
+
+// Forwarded returns where the record in the slot moved to, or nil if it
+// is still there.
+func (rp *RecordPage) Forwarded(slot int32) (*RID, error) {
+	if rp.layout.Format() != SLOTTED {
+		return nil, nil
+	}
+	flag, err := rp.getFlag(slot)
+	if err != nil || flag != FORWARDED {
+		return nil, err
+	}
+	record, err := rp.recordBytes(slot)
+	if err != nil {
+		return nil, err
+	}
+	p := file.NewPageFromBytes(record)
+	return NewRID(p.GetInt(0), p.GetInt(file.Int32Bytes)), nil
+}
+
+// setSlottedString sets the string in place if it takes as many bytes as
+// the old one, and otherwise writes the record again with it.
+func (rp *RecordPage) setSlottedString(slot int32, fieldName string, val string) error {
+	fpos, err := rp.slottedFieldPos(slot, fieldName)
+	if err != nil {
+		return err
+	}
+	old, err := rp.tx.GetString(rp.block, fpos)
+	if err != nil {
+		return err
+	}
+	if stringSize(old) == stringSize(val) {
+		return rp.tx.SetString(rp.block, fpos, val, true)
+	}
+	values, err := rp.values(slot)
+	if err != nil {
+		return err
+	}
+	values[rp.layout.nullWords()+rp.layout.position[fieldName]] = val
+	return rp.place(slot, values)
+}
+
+// SizeWith returns how many bytes the record in the slot takes with the
+// string as the value of the field.
+func (rp *RecordPage) SizeWith(slot int32, fieldName string, val string) (int32, error) {
+	values, err := rp.values(slot)
+	if err != nil {
+		return 0, err
+	}
+	values[rp.layout.nullWords()+rp.layout.position[fieldName]] = val
+	return recordSize(values), nil
+}
+
+// place writes the values of the record in the slot to the free space of
+// the block, compacting it first if the free space is in pieces.
+func (rp *RecordPage) place(slot int32, values []any) error {
+	size := recordSize(values)
+	count, err := rp.slotCount()
+	if err != nil {
+		return err
+	}
+	start, err := rp.tx.GetInt(rp.block, recordsStartPos)
+	if err != nil {
+		return err
+	}
+	if start-rp.entryPos(count) < size {
+		if start, err = rp.compact(slot, size); err != nil {
+			return err
+		}
+	}
+	pos := start - size
+	if err := rp.tx.SetBytes(rp.block, pos, encode(values), true); err != nil {
+		return err
+	}
+	if err := rp.setEntry(slot, pos, size); err != nil {
+		return err
+	}
+	return rp.tx.SetInt(rp.block, recordsStartPos, pos, true)
+}
+
+// compact moves the records of the block, but the one in the slot, to its
+// end, leaving the free space in one piece, and returns where the records
+// start. It fails if the block would still not have size bytes free.
+func (rp *RecordPage) compact(slot int32, size int32) (int32, error) {
+	free, err := rp.freeSpace(slot)
+	if err != nil {
+		return 0, err
+	}
+	if free < size {
+		return 0, ErrRecordTooLarge
+	}
+	count, err := rp.slotCount()
+	if err != nil {
+		return 0, err
+	}
+	// all the records are read before any is written, as they may overlap
+	// where the others go
+	slots := make([]int32, 0, count)
+	records := make([][]byte, 0, count)
+	for s := int32(0); s < count; s++ {
+		if s == slot {
+			continue
+		}
+		flag, err := rp.getFlag(s)
+		if err != nil {
+			return 0, err
+		}
+		if flag == EMPTY {
+			continue
+		}
+		record, err := rp.recordBytes(s)
+		if err != nil {
+			return 0, err
+		}
+		slots = append(slots, s)
+		records = append(records, record)
+	}
+	pos := rp.tx.BlockSize()
+	for i, s := range slots {
+		pos -= int32(len(records[i]))
+		if err := rp.tx.SetBytes(rp.block, pos, records[i], true); err != nil {
+			return 0, err
+		}
+		if err := rp.setEntry(s, pos, int32(len(records[i]))); err != nil {
+			return 0, err
+		}
+	}
+	if err := rp.tx.SetInt(rp.block, recordsStartPos, pos, true); err != nil {
+		return 0, err
+	}
+	return pos, nil
+}
+
+// freeSpace returns how many bytes the block has free, counting those the
+// record in the slot takes, and those no record takes any more.
+func (rp *RecordPage) freeSpace(slot int32) (int32, error) {
+	count, err := rp.slotCount()
+	if err != nil {
+		return 0, err
+	}
+	free := rp.tx.BlockSize() - rp.entryPos(count)
+	for s := int32(0); s < count; s++ {
+		if s == slot {
+			continue
+		}
+		flag, err := rp.getFlag(s)
+		if err != nil {
+			return 0, err
+		}
+		if flag == EMPTY {
+			continue
+		}
+		length, err := rp.tx.GetInt(rp.block, rp.entryPos(s)+entryRecordLength)
+		if err != nil {
+			return 0, err
+		}
+		free -= length
+	}
+	return free, nil
+}
+
+func (rp *RecordPage) slottedFieldPos(slot int32, fieldName string) (int32, error) {
+	pos, err := rp.tx.GetInt(rp.block, rp.entryPos(slot)+entryRecordPos)
+	if err != nil {
+		return 0, err
+	}
+	pos += rp.layout.nullWords() * file.Int32Bytes
+	for _, name := range rp.layout.order {
+		if name == fieldName {
+			return pos, nil
+		}
+		size := rp.layout.LengthInBytes(name)
+		if rp.layout.Schema().Type(name) == STRING {
+			length, err := rp.tx.GetInt(rp.block, pos)
+			if err != nil {
+				return 0, err
+			}
+			size = file.Int32Bytes + length
+		}
+		pos += size
+	}
+	return 0, fmt.Errorf("field %s not found", fieldName)
+}
+
+// values reads the words of the NULL bitmap and the values of the record
+// in the slot, in the order they are stored.
+func (rp *RecordPage) values(slot int32) ([]any, error) {
+	record, err := rp.recordBytes(slot)
+	if err != nil {
+		return nil, err
+	}
+	p := file.NewPageFromBytes(record)
+	pos := int32(0)
+	values := make([]any, 0, rp.layout.nullWords()+int32(len(rp.layout.order)))
+	for i := int32(0); i < rp.layout.nullWords(); i++ {
+		values = append(values, p.GetInt(pos))
+		pos += file.Int32Bytes
+	}
+	for _, name := range rp.layout.order {
+		val := pageValue(p, pos, rp.layout.Schema().Type(name))
+		values = append(values, val)
+		pos += valueSize(val)
+	}
+	return values, nil
+}
+
+func (rp *RecordPage) recordBytes(slot int32) ([]byte, error) {
+	pos, err := rp.tx.GetInt(rp.block, rp.entryPos(slot)+entryRecordPos)
+	if err != nil {
+		return nil, err
+	}
+	length, err := rp.tx.GetInt(rp.block, rp.entryPos(slot)+entryRecordLength)
+	if err != nil {
+		return nil, err
+	}
+	return rp.tx.GetBytes(rp.block, pos, length)
+}
+
+// emptyValues returns the values of a record where all the fields that
+// allow it are NULL.
+func (rp *RecordPage) emptyValues() []any {
+	values := make([]any, 0, rp.layout.nullWords()+int32(len(rp.layout.order)))
+	for _, word := range rp.nullWords() {
+		values = append(values, word)
+	}
+	for _, name := range rp.layout.order {
+		values = append(values, ZeroValue(rp.layout.Schema().Type(name)))
+	}
+	return values
+}
+
+func (rp *RecordPage) setEntry(slot int32, pos int32, length int32) error {
+	if err := rp.tx.SetInt(rp.block, rp.entryPos(slot)+entryRecordPos, pos, true); err != nil {
+		return err
+	}
+	return rp.tx.SetInt(rp.block, rp.entryPos(slot)+entryRecordLength, length, true)
+}
+
+func (rp *RecordPage) entryPos(slot int32) int32 {
+	return directoryPos + slot*entrySize
+}
+
+func encode(values []any) []byte {
+	record := make([]byte, recordSize(values))
+	p := file.NewPageFromBytes(record)
+	pos := int32(0)
+	for _, val := range values {
+		setPageValue(p, pos, val)
+		pos += valueSize(val)
+	}
+	return record
+}
+
+func recordSize(values []any) int32 {
+	size := int32(0)
+	for _, val := range values {
+		size += valueSize(val)
+	}
+	return size
+}
+
+func valueSize(val any) int32 {
+	switch v := val.(type) {
+	case string:
+		return stringSize(v)
+	case int64, float64:
+		return file.Int64Bytes
+	default:
+		return file.Int32Bytes
+	}
+}
+
+func stringSize(s string) int32 {
+	return file.MaxLength(int32(len(utf16.Encode([]rune(s)))))
+}
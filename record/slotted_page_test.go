@@ -0,0 +1,169 @@
+package record_test
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/adieumonks/simple-db/record"
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestSlottedRecordPage(t *testing.T) {
+	db, _ := server.NewSimpleDB(path.Join(t.TempDir(), "slottedtest"), 400, 8)
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+
+	sch := record.NewSchema()
+	sch.AddIntField("A")
+	sch.AddStringField("B", 50)
+	layout := record.NewSlottedLayoutFromSchema(sch)
+	fixed := record.NewLayoutFromSchema(sch)
+
+	block, _ := tx.Append("testfile")
+	rp, err := record.NewRecordPage(tx, block, layout)
+	if err != nil {
+		t.Fatalf("failed to create record page: %v", err)
+	}
+	if err := rp.Format(); err != nil {
+		t.Fatalf("failed to format record page: %v", err)
+	}
+
+	// short records take less room than the slots of a fixed layout
+	want := make(map[int32]string)
+	slot, err := rp.InsertAfter(-1)
+	if err != nil {
+		t.Fatalf("failed to insert after -1: %v", err)
+	}
+	for slot >= 0 {
+		b := fmt.Sprintf("rec%d", slot)
+		if err := rp.SetInt(slot, "A", slot); err != nil {
+			t.Fatalf("failed to set int: %v", err)
+		}
+		if err := rp.SetString(slot, "B", b); err != nil {
+			t.Fatalf("failed to set string: %v", err)
+		}
+		want[slot] = b
+		slot, err = rp.InsertAfter(slot)
+		if err != nil {
+			t.Fatalf("failed to insert after: %v", err)
+		}
+	}
+	if fixedSlots := 400 / fixed.SlotSize(); int32(len(want)) <= fixedSlots {
+		t.Fatalf("expected more than %d records, got %d", fixedSlots, len(want))
+	}
+
+	// records grow as long as the block has room for them, and one that
+	// cannot fit in its block keeps its old value
+	long := strings.Repeat("x", 40)
+	full := int32(-1)
+	for slot := int32(0); slot < int32(len(want)); slot++ {
+		err := rp.SetString(slot, "B", long)
+		if errors.Is(err, record.ErrRecordTooLarge) {
+			full = slot
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to set string: %v", err)
+		}
+		want[slot] = long
+	}
+	if full < 0 {
+		t.Fatalf("expected a record not to fit in its block")
+	}
+	assertSlotted(t, rp, want)
+
+	// freeing records leaves room for it once the block is compacted,
+	// while the others keep their slots
+	for slot := range int32(len(want)) {
+		if slot == full || slot == full+1 {
+			continue
+		}
+		if err := rp.Delete(slot); err != nil {
+			t.Fatalf("failed to delete: %v", err)
+		}
+		delete(want, slot)
+	}
+	if err := rp.SetString(full, "B", long); err != nil {
+		t.Fatalf("failed to set string: %v", err)
+	}
+	want[full] = long
+	assertSlotted(t, rp, want)
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	// undoing a compaction puts the records back where they were
+	tx, err = db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	rp, err = record.NewRecordPage(tx, block, layout)
+	if err != nil {
+		t.Fatalf("failed to create record page: %v", err)
+	}
+	if err := rp.Delete(full); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if err := rp.SetString(full+1, "B", long); err != nil {
+		t.Fatalf("failed to set string: %v", err)
+	}
+	tx.Unpin(block)
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to rollback transaction: %v", err)
+	}
+
+	tx, err = db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	rp, err = record.NewRecordPage(tx, block, layout)
+	if err != nil {
+		t.Fatalf("failed to create record page: %v", err)
+	}
+	assertSlotted(t, rp, want)
+	tx.Unpin(block)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func assertSlotted(t *testing.T, rp *record.RecordPage, want map[int32]string) {
+	t.Helper()
+
+	got := make(map[int32]string)
+	slot, err := rp.NextAfter(-1)
+	if err != nil {
+		t.Fatalf("failed to get next after -1: %v", err)
+	}
+	for slot >= 0 {
+		a, err := rp.GetInt(slot, "A")
+		if err != nil {
+			t.Fatalf("failed to get int: %v", err)
+		}
+		if a != slot {
+			t.Fatalf("expected A of slot %d to be %d, got %d", slot, slot, a)
+		}
+		got[slot], err = rp.GetString(slot, "B")
+		if err != nil {
+			t.Fatalf("failed to get string: %v", err)
+		}
+		slot, err = rp.NextAfter(slot)
+		if err != nil {
+			t.Fatalf("failed to get next after %d: %v", slot, err)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(got))
+	}
+	for slot, b := range want {
+		if got[slot] != b {
+			t.Fatalf("expected B of slot %d to be %q, got %q", slot, b, got[slot])
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package record
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+// CompareValues compares two values of a field, as stored, where NULL,
+// given as nil, comes before any value.
+func CompareValues(val1, val2 any) int {
+	switch {
+	case val1 == nil && val2 == nil:
+		return 0
+	case val1 == nil:
+		return -1
+	case val2 == nil:
+		return 1
+	}
+	switch v1 := val1.(type) {
+	case int32:
+		return cmp.Compare(v1, val2.(int32))
+	case int64:
+		return cmp.Compare(v1, val2.(int64))
+	case float64:
+		return cmp.Compare(v1, val2.(float64))
+	case bool:
+		return cmp.Compare(boolToInt(v1), boolToInt(val2.(bool)))
+	case string:
+		return cmp.Compare(v1, val2.(string))
+	}
+	return 0
+}
+
+// ReadValue reads the value of a field of the type at the offset of the
+// block.
+func ReadValue(tx *tx.Transaction, block file.BlockID, offset int32, fieldType FieldType) (any, error) {
+	switch fieldType {
+	case INTEGER, DATE:
+		return tx.GetInt(block, offset)
+	case BOOLEAN:
+		val, err := tx.GetInt(block, offset)
+		return val != 0, err
+	case BIGINT, TIMESTAMP, DECIMAL:
+		return tx.GetLong(block, offset)
+	case DOUBLE:
+		return tx.GetDouble(block, offset)
+	default:
+		return tx.GetString(block, offset)
+	}
+}
+
+// WriteValue writes the value of a field at the offset of the block.
+func WriteValue(tx *tx.Transaction, block file.BlockID, offset int32, val any, okToLog bool) error {
+	switch v := val.(type) {
+	case int32:
+		return tx.SetInt(block, offset, v, okToLog)
+	case bool:
+		return tx.SetInt(block, offset, boolToInt(v), okToLog)
+	case int64:
+		return tx.SetLong(block, offset, v, okToLog)
+	case float64:
+		return tx.SetDouble(block, offset, v, okToLog)
+	case string:
+		return tx.SetString(block, offset, v, okToLog)
+	default:
+		return fmt.Errorf("unsupported value %v", val)
+	}
+}
+
+// ZeroValue returns the value a field of the type is left with when it is
+// NULL.
+func ZeroValue(fieldType FieldType) any {
+	switch fieldType {
+	case INTEGER, DATE:
+		return int32(0)
+	case BOOLEAN:
+		return false
+	case BIGINT, TIMESTAMP, DECIMAL:
+		return int64(0)
+	case DOUBLE:
+		return float64(0)
+	default:
+		return ""
+	}
+}
+
+// pageValue reads a value of the type from a page of encoded values.
+func pageValue(p *file.Page, pos int32, fieldType FieldType) any {
+	switch fieldType {
+	case INTEGER, DATE:
+		return p.GetInt(pos)
+	case BOOLEAN:
+		return p.GetInt(pos) != 0
+	case BIGINT, TIMESTAMP, DECIMAL:
+		return p.GetLong(pos)
+	case DOUBLE:
+		return p.GetDouble(pos)
+	default:
+		return p.GetString(pos)
+	}
+}
+
+func setPageValue(p *file.Page, pos int32, val any) {
+	switch v := val.(type) {
+	case int32:
+		p.SetInt(pos, v)
+	case bool:
+		p.SetInt(pos, boolToInt(v))
+	case int64:
+		p.SetLong(pos, v)
+	case float64:
+		p.SetDouble(pos, v)
+	case string:
+		p.SetString(pos, v)
+	}
+}
+
+func boolToInt(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
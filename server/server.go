@@ -8,7 +8,9 @@ import (
 	"github.com/adieumonks/simple-db/log"
 	"github.com/adieumonks/simple-db/metadata"
 	"github.com/adieumonks/simple-db/plan"
+	"github.com/adieumonks/simple-db/query"
 	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
 )
 
 const (
@@ -18,14 +20,64 @@ const (
 )
 
 type SimpleDB struct {
-	fm      *file.FileManager
-	lm      *log.LogManager
-	bm      *buffer.BufferManager
-	mdm     *metadata.MetadataManager
-	planner *plan.Planner
+	fm       *file.FileManager
+	lm       *log.LogManager
+	bm       *buffer.BufferManager
+	lt       *concurrency.LockTable
+	registry *tx.TxRegistry
+	mdm      *metadata.MetadataManager
+	planner  *plan.Planner
+	mvcc     bool
+	// the isolation level new transactions start with
+	isolation tx.IsolationLevel
 }
 
-func NewSimpleDB(dirname string, blockSize, buffferSize int32) (*SimpleDB, error) {
+// Option configures a database when it is opened.
+type Option func(db *SimpleDB)
+
+// WithDeadlockMode chooses how lock conflicts that could deadlock are handled.
+func WithDeadlockMode(mode concurrency.DeadlockMode) Option {
+	return func(db *SimpleDB) {
+		db.lt.SetDeadlockMode(mode)
+	}
+}
+
+// WithVictimPolicy chooses the transaction aborted when a deadlock is detected.
+func WithVictimPolicy(policy concurrency.VictimPolicy) Option {
+	return func(db *SimpleDB) {
+		db.lt.SetVictimPolicy(policy)
+	}
+}
+
+// WithEscalationThreshold sets how many block locks a transaction may hold
+// on one file before they are escalated to a lock on the whole file, or
+// with 0 or less, that they never are.
+func WithEscalationThreshold(threshold int) Option {
+	return func(db *SimpleDB) {
+		db.lt.SetEscalationThreshold(threshold)
+	}
+}
+
+// WithMVCC makes the tables created keep versions of their records, so that
+// transactions read them as of their snapshots instead of locking them.
+// Writers still lock the blocks they update, and a transaction updating or
+// deleting a record another one changed after its snapshot fails.
+// Transactions start at REPEATABLE READ, unless a later option says otherwise.
+func WithMVCC() Option {
+	return func(db *SimpleDB) {
+		db.mvcc = true
+		db.isolation = tx.REPEATABLE_READ
+	}
+}
+
+// WithIsolationLevel sets the isolation level new transactions start with.
+func WithIsolationLevel(level tx.IsolationLevel) Option {
+	return func(db *SimpleDB) {
+		db.isolation = level
+	}
+}
+
+func NewSimpleDB(dirname string, blockSize, buffferSize int32, opts ...Option) (*SimpleDB, error) {
 	fm, err := file.NewFileManager(dirname, blockSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new file manager: %w", err)
@@ -38,32 +90,67 @@ func NewSimpleDB(dirname string, blockSize, buffferSize int32) (*SimpleDB, error
 
 	bm := buffer.NewBufferManager(fm, lm, buffferSize)
 
-	return &SimpleDB{
-		fm: fm,
-		lm: lm,
-		bm: bm,
-	}, nil
-}
-
-func NewSimpleDBWithMetadata(dirname string) (*SimpleDB, error) {
-	db, err := NewSimpleDB(dirname, BLOCK_SIZE, BUFFER_SIZE)
+	registry, err := tx.NewTxRegistry(lm)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create new transaction registry: %w", err)
 	}
 
-	tx, err := db.NewTransaction()
+	db := &SimpleDB{
+		fm:        fm,
+		lm:        lm,
+		bm:        bm,
+		lt:        concurrency.NewLockTable(),
+		registry:  registry,
+		isolation: tx.SERIALIZABLE,
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db, nil
+}
+
+func NewSimpleDBWithMetadata(dirname string, opts ...Option) (*SimpleDB, error) {
+	db, err := NewSimpleDB(dirname, BLOCK_SIZE, BUFFER_SIZE, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	var tx *tx.Transaction
 	isNew := db.fm.IsNew()
 	if isNew {
 		fmt.Println("creating new database")
+		tx, err = db.NewTransaction()
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		fmt.Println("recovering existing database")
-		if err := tx.Recover(); err != nil {
+		rtx, err := db.NewTransaction()
+		if err != nil {
 			return nil, err
 		}
+		if err := rtx.Recover(); err != nil {
+			return nil, err
+		}
+		if err := rtx.Commit(); err != nil {
+			return nil, err
+		}
+		// the catalog is only read here, so this must not wait
+		// for the locks held by in-doubt transactions, unless it is
+		// empty or old, when there are none
+		tx = db.NewReadOnlyTransaction()
+		upgrade, err := metadata.NeedsUpgrade(tx)
+		if err != nil {
+			return nil, err
+		}
+		if upgrade {
+			if err := tx.Commit(); err != nil {
+				return nil, err
+			}
+			if tx, err = db.NewTransaction(); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	mdm, err := metadata.NewMetadataManager(isNew, tx)
@@ -71,6 +158,7 @@ func NewSimpleDBWithMetadata(dirname string) (*SimpleDB, error) {
 		return nil, err
 	}
 
+	mdm.SetMVCC(db.mvcc)
 	db.mdm = mdm
 
 	qp := plan.NewBasicQueryPlanner(mdm)
@@ -85,7 +173,78 @@ func NewSimpleDBWithMetadata(dirname string) (*SimpleDB, error) {
 }
 
 func (db *SimpleDB) NewTransaction() (*tx.Transaction, error) {
-	return tx.NewTransaction(db.fm, db.lm, db.bm)
+	tx, err := tx.NewTransaction(db.fm, db.lm, db.bm, db.lt, db.registry)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.SetIsolationLevel(db.isolation); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (db *SimpleDB) NewReadOnlyTransaction() *tx.Transaction {
+	return tx.NewReadOnlyTransaction(db.fm, db.lm, db.bm, db.registry)
+}
+
+// InDoubtTransactions returns the global ids of the prepared transactions
+// waiting for a decision from the coordinator.
+func (db *SimpleDB) InDoubtTransactions() []string {
+	return db.registry.InDoubt()
+}
+
+func (db *SimpleDB) CommitPrepared(globalID string) error {
+	return db.registry.CommitPrepared(globalID)
+}
+
+func (db *SimpleDB) RollbackPrepared(globalID string) error {
+	return db.registry.RollbackPrepared(globalID)
+}
+
+// ActiveTransactions describes the read-write transactions that have not
+// finished yet, oldest first.
+func (db *SimpleDB) ActiveTransactions() []tx.TxInfo {
+	return db.registry.Active()
+}
+
+// Locks lists the locks currently held, by transaction.
+func (db *SimpleDB) Locks() []concurrency.LockInfo {
+	return db.lt.Locks()
+}
+
+// LockWaits lists the transactions waiting for locks, along with those
+// they wait for, the longest waiting first.
+func (db *SimpleDB) LockWaits() []concurrency.WaitInfo {
+	return db.lt.Waits()
+}
+
+// Vacuum frees the old versions of records in a table that no transaction
+// can see any more, and returns how many it freed.
+func (db *SimpleDB) Vacuum(tableName string) (int, error) {
+	tx, err := db.NewTransaction()
+	if err != nil {
+		return 0, err
+	}
+	layout, err := db.mdm.GetLayout(tableName, tx)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to get layout: %w", err)
+	}
+	ts, err := query.NewTableScan(tx, tableName, layout)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create table scan: %w", err)
+	}
+	freed, err := ts.Vacuum()
+	ts.Close()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return freed, nil
 }
 
 func (db *SimpleDB) FileManager() *file.FileManager {
@@ -100,6 +259,14 @@ func (db *SimpleDB) BufferManager() *buffer.BufferManager {
 	return db.bm
 }
 
+func (db *SimpleDB) LockTable() *concurrency.LockTable {
+	return db.lt
+}
+
+func (db *SimpleDB) TxRegistry() *tx.TxRegistry {
+	return db.registry
+}
+
 func (db *SimpleDB) MetadataManager() *metadata.MetadataManager {
 	return db.mdm
 }
@@ -6,53 +6,360 @@ import (
 	"github.com/adieumonks/simple-db/file"
 )
 
-var lockTable = NewLockTable()
+type indexKey struct {
+	index string
+	key   string
+}
 
 type ConcurrencyManager struct {
-	locks map[file.BlockID]string
+	lockTable *LockTable
+	txnum     int64
+	locks     map[file.BlockID]LockMode
+	fileLocks map[string]LockMode
+	// the intentions declared on blocks to lock their records
+	blockIntents map[file.BlockID]LockMode
+	recordLocks  map[file.BlockID]map[int32]LockMode
+	// the number of block and record locks held on each file
+	blockLocks map[string]int
+	keyLocks   map[indexKey]LockMode
 }
 
-func NewConcurrencyManager() *ConcurrencyManager {
+func NewConcurrencyManager(lockTable *LockTable, txnum int64) *ConcurrencyManager {
 	return &ConcurrencyManager{
-		locks: make(map[file.BlockID]string),
+		lockTable:    lockTable,
+		txnum:        txnum,
+		locks:        make(map[file.BlockID]LockMode),
+		fileLocks:    make(map[string]LockMode),
+		blockIntents: make(map[file.BlockID]LockMode),
+		recordLocks:  make(map[file.BlockID]map[int32]LockMode),
+		blockLocks:   make(map[string]int),
+		keyLocks:     make(map[indexKey]LockMode),
 	}
 }
 
 func (cm *ConcurrencyManager) SLock(block file.BlockID) error {
-	if cm.locks[block] != "" {
+	if cm.locks[block] != 0 || cm.fileLocks[block.Filename()].covers(S) {
 		return nil
 	}
 
-	if err := lockTable.SLock(block); err != nil {
+	if err := cm.LockFile(block.Filename(), IS); err != nil {
+		return fmt.Errorf("failed to acquire SLock: %w", err)
+	}
+	if err := cm.lockTable.SLock(block, cm.txnum); err != nil {
 		return fmt.Errorf("failed to acquire SLock: %w", err)
 	}
-	cm.locks[block] = "S"
+	cm.locks[block] = S
+	cm.addBlockLock(block.Filename())
 	return nil
 }
 
 func (cm *ConcurrencyManager) XLock(block file.BlockID) error {
-	if cm.hasXLock(block) {
+	if cm.HasXLock(block) {
 		return nil
 	}
 
-	if err := cm.SLock(block); err != nil {
-		return fmt.Errorf("failed to acquire SLock: %w", err)
+	if err := cm.LockFile(block.Filename(), IX); err != nil {
+		return fmt.Errorf("failed to acquire XLock: %w", err)
 	}
-	if err := lockTable.XLock(block); err != nil {
+	if err := cm.lockTable.XLock(block, cm.txnum); err != nil {
 		return fmt.Errorf("failed to acquire XLock: %w", err)
 	}
-	cm.locks[block] = "X"
+	if cm.locks[block] == 0 {
+		cm.addBlockLock(block.Filename())
+	}
+	cm.locks[block] = X
+	return nil
+}
+
+// TryXLock acquires an XLock on the block if it can do so without waiting,
+// and reports whether it did.
+func (cm *ConcurrencyManager) TryXLock(block file.BlockID) bool {
+	if cm.HasXLock(block) {
+		return true
+	}
+
+	filename := block.Filename()
+	if !cm.fileLocks[filename].covers(IX) {
+		if !cm.lockTable.TryLockFile(filename, cm.txnum, IX) {
+			return false
+		}
+		cm.fileLocks[filename] = IX.join(cm.fileLocks[filename])
+	}
+	if !cm.lockTable.TryXLock(block, cm.txnum) {
+		return false
+	}
+	if cm.locks[block] == 0 {
+		cm.addBlockLock(filename)
+	}
+	cm.locks[block] = X
+	return true
+}
+
+// LockRecord locks the record in the slot of a block, after declaring the
+// intention to do so on the block and its file.
+func (cm *ConcurrencyManager) LockRecord(block file.BlockID, slot int32, mode LockMode) error {
+	if cm.HasRecordLock(block, slot, mode) {
+		return nil
+	}
+
+	intent := intention(mode)
+	if err := cm.LockFile(block.Filename(), intent); err != nil {
+		return fmt.Errorf("failed to acquire %v lock on record: %w", mode, err)
+	}
+	if !cm.blockMode(block).covers(intent) {
+		if err := cm.lockTable.LockBlock(block, cm.txnum, intent); err != nil {
+			return fmt.Errorf("failed to acquire %v lock on %v: %w", intent, block, err)
+		}
+		cm.blockIntents[block] = intent.join(cm.blockIntents[block])
+	}
+	if err := cm.lockTable.LockRecord(block, slot, cm.txnum, mode); err != nil {
+		return fmt.Errorf("failed to acquire %v lock on slot %d of %v: %w", mode, slot, block, err)
+	}
+	cm.addRecordLock(block, slot, mode)
+	return nil
+}
+
+// TryLockRecord locks the record in the slot of a block if it can do so
+// without waiting, and reports whether it did.
+func (cm *ConcurrencyManager) TryLockRecord(block file.BlockID, slot int32, mode LockMode) bool {
+	if cm.HasRecordLock(block, slot, mode) {
+		return true
+	}
+
+	filename := block.Filename()
+	intent := intention(mode)
+	if !cm.fileLocks[filename].covers(intent) {
+		if !cm.lockTable.TryLockFile(filename, cm.txnum, intent) {
+			return false
+		}
+		cm.fileLocks[filename] = intent.join(cm.fileLocks[filename])
+	}
+	if !cm.blockMode(block).covers(intent) {
+		if !cm.lockTable.TryLockBlock(block, cm.txnum, intent) {
+			return false
+		}
+		cm.blockIntents[block] = intent.join(cm.blockIntents[block])
+	}
+	if !cm.lockTable.TryLockRecord(block, slot, cm.txnum, mode) {
+		return false
+	}
+	cm.addRecordLock(block, slot, mode)
+	return true
+}
+
+// LockFile locks a whole file, such as the file of a table,
+// or declares the intention to lock some of its blocks.
+func (cm *ConcurrencyManager) LockFile(filename string, mode LockMode) error {
+	if cm.fileLocks[filename].covers(mode) {
+		return nil
+	}
+
+	if err := cm.lockTable.LockFile(filename, cm.txnum, mode); err != nil {
+		return fmt.Errorf("failed to acquire %v lock on %s: %w", mode, filename, err)
+	}
+	cm.fileLocks[filename] = mode.join(cm.fileLocks[filename])
+	return nil
+}
+
+// LockKey locks the range of index keys that ends at key until the
+// transaction finishes.
+func (cm *ConcurrencyManager) LockKey(index string, key string, mode LockMode) error {
+	k := indexKey{index, key}
+	if cm.keyLocks[k].covers(mode) {
+		return nil
+	}
+
+	if err := cm.lockTable.LockKey(index, key, cm.txnum, mode); err != nil {
+		return fmt.Errorf("failed to acquire %v lock on key %s of %s: %w", mode, key, index, err)
+	}
+	cm.keyLocks[k] = mode.join(cm.keyLocks[k])
+	return nil
+}
+
+// AwaitKey waits until no other transaction holds a lock on the range of
+// index keys that ends at key incompatible with mode.
+func (cm *ConcurrencyManager) AwaitKey(index string, key string, mode LockMode) error {
+	if cm.keyLocks[indexKey{index, key}].covers(mode) {
+		return nil
+	}
+
+	if err := cm.lockTable.AwaitKey(index, key, cm.txnum, mode); err != nil {
+		return fmt.Errorf("failed to wait for %v lock on key %s of %s: %w", mode, key, index, err)
+	}
 	return nil
 }
 
+// ReleaseSLock releases the S locks on the block and its records before
+// the transaction finishes. X locks are kept.
+func (cm *ConcurrencyManager) ReleaseSLock(block file.BlockID) {
+	for slot, mode := range cm.recordLocks[block] {
+		if mode == S {
+			cm.lockTable.UnlockRecord(block, slot, cm.txnum)
+			delete(cm.recordLocks[block], slot)
+			cm.blockLocks[block.Filename()]--
+		}
+	}
+	if len(cm.recordLocks[block]) > 0 || cm.locks[block] == X {
+		return
+	}
+	delete(cm.recordLocks, block)
+	if cm.locks[block] == 0 && cm.blockIntents[block] == 0 {
+		return
+	}
+	cm.lockTable.Unlock(block, cm.txnum)
+	if cm.locks[block] == S {
+		cm.blockLocks[block.Filename()]--
+	}
+	delete(cm.locks, block)
+	delete(cm.blockIntents, block)
+}
+
+// UnlockRecord releases the lock on the record in the slot of a block
+// before the transaction finishes, for a record it locked but did not use.
+func (cm *ConcurrencyManager) UnlockRecord(block file.BlockID, slot int32) {
+	if _, ok := cm.recordLocks[block][slot]; !ok {
+		return
+	}
+	cm.lockTable.UnlockRecord(block, slot, cm.txnum)
+	delete(cm.recordLocks[block], slot)
+	cm.blockLocks[block.Filename()]--
+}
+
+// UnlockBlock releases the lock on a block before the transaction
+// finishes, for a block it locked but did not use. The intentions on it
+// are kept with the locks on its records.
+func (cm *ConcurrencyManager) UnlockBlock(block file.BlockID) {
+	if cm.locks[block] == 0 || cm.blockIntents[block] != 0 {
+		return
+	}
+	cm.lockTable.Unlock(block, cm.txnum)
+	delete(cm.locks, block)
+	cm.blockLocks[block.Filename()]--
+}
+
 func (cm *ConcurrencyManager) Release() {
+	for block, slots := range cm.recordLocks {
+		for slot := range slots {
+			cm.lockTable.UnlockRecord(block, slot, cm.txnum)
+		}
+	}
+	for block := range cm.blockIntents {
+		cm.lockTable.Unlock(block, cm.txnum)
+	}
 	for block := range cm.locks {
-		lockTable.Unlock(block)
+		cm.lockTable.Unlock(block, cm.txnum)
+	}
+	for filename := range cm.fileLocks {
+		cm.lockTable.UnlockFile(filename, cm.txnum)
+	}
+	for k := range cm.keyLocks {
+		cm.lockTable.UnlockKey(k.index, k.key, cm.txnum)
 	}
 	clear(cm.locks)
+	clear(cm.fileLocks)
+	clear(cm.blockIntents)
+	clear(cm.recordLocks)
+	clear(cm.blockLocks)
+	clear(cm.keyLocks)
+}
+
+// HasXLock reports whether the transaction holds an X lock on the block,
+// either on its own or through a lock on the whole file.
+func (cm *ConcurrencyManager) HasXLock(block file.BlockID) bool {
+	return cm.locks[block] == X || cm.fileLocks[block.Filename()].covers(X)
 }
 
-func (cm *ConcurrencyManager) hasXLock(block file.BlockID) bool {
-	lockType := cm.locks[block]
-	return lockType == "X"
+// HasLock reports whether the transaction holds a lock on the block, or
+// may read it through a lock on the whole file.
+func (cm *ConcurrencyManager) HasLock(block file.BlockID) bool {
+	return cm.locks[block] != 0 || cm.fileLocks[block.Filename()].covers(S)
+}
+
+// HasRecordLock reports whether the transaction may access the record in
+// the slot of a block in the given mode, through a lock on the record,
+// its block or its file.
+func (cm *ConcurrencyManager) HasRecordLock(block file.BlockID, slot int32, mode LockMode) bool {
+	return cm.recordLocks[block][slot].covers(mode) ||
+		cm.blockMode(block).join(cm.blockIntents[block]).covers(mode) ||
+		cm.fileLocks[block.Filename()].covers(mode)
+}
+
+// blockMode returns the mode in which the transaction holds the block,
+// intentions included.
+func (cm *ConcurrencyManager) blockMode(block file.BlockID) LockMode {
+	return cm.blockIntents[block].join(cm.locks[block])
+}
+
+func (cm *ConcurrencyManager) addRecordLock(block file.BlockID, slot int32, mode LockMode) {
+	slots, ok := cm.recordLocks[block]
+	if !ok {
+		slots = make(map[int32]LockMode)
+		cm.recordLocks[block] = slots
+	}
+	held, ok := slots[slot]
+	slots[slot] = mode.join(held)
+	if !ok {
+		cm.addBlockLock(block.Filename())
+	}
+}
+
+// intention returns the mode in which a block or file is locked
+// before locking what it contains in the given mode.
+func intention(mode LockMode) LockMode {
+	if mode == S || mode == IS {
+		return IS
+	}
+	return IX
+}
+
+// addBlockLock counts a new block or record lock on filename, and trades
+// the block and record locks on the file for a lock on the whole file
+// once there are too many.
+// Escalation does not wait: while the file lock is not available, the
+// transaction keeps locking blocks and tries again later.
+func (cm *ConcurrencyManager) addBlockLock(filename string) {
+	cm.blockLocks[filename]++
+	threshold := cm.lockTable.EscalationThreshold()
+	if threshold <= 0 || cm.blockLocks[filename]%threshold != 0 {
+		return
+	}
+
+	mode := S
+	for block, held := range cm.locks {
+		if block.Filename() == filename && held == X {
+			mode = X
+			break
+		}
+	}
+	for block, intent := range cm.blockIntents {
+		if block.Filename() == filename && intent == IX {
+			mode = X
+			break
+		}
+	}
+	if !cm.lockTable.TryLockFile(filename, cm.txnum, mode) {
+		return
+	}
+	cm.fileLocks[filename] = mode.join(cm.fileLocks[filename])
+	for block, slots := range cm.recordLocks {
+		if block.Filename() == filename {
+			for slot := range slots {
+				cm.lockTable.UnlockRecord(block, slot, cm.txnum)
+			}
+			delete(cm.recordLocks, block)
+		}
+	}
+	for block := range cm.blockIntents {
+		if block.Filename() == filename {
+			cm.lockTable.Unlock(block, cm.txnum)
+			delete(cm.blockIntents, block)
+		}
+	}
+	for block := range cm.locks {
+		if block.Filename() == filename {
+			cm.lockTable.Unlock(block, cm.txnum)
+			delete(cm.locks, block)
+		}
+	}
+	delete(cm.blockLocks, filename)
 }
@@ -11,13 +11,16 @@ import (
 	"github.com/adieumonks/simple-db/log"
 	"github.com/adieumonks/simple-db/server"
 	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
 )
 
 var (
-	fm *file.FileManager
-	lm *log.LogManager
-	bm *buffer.BufferManager
-	wg sync.WaitGroup
+	fm       *file.FileManager
+	lm       *log.LogManager
+	bm       *buffer.BufferManager
+	lt       *concurrency.LockTable
+	registry *tx.TxRegistry
+	wg       sync.WaitGroup
 )
 
 func TestConcurrency(t *testing.T) {
@@ -25,6 +28,8 @@ func TestConcurrency(t *testing.T) {
 	fm = db.FileManager()
 	lm = db.LogManager()
 	bm = db.BufferManager()
+	lt = db.LockTable()
+	registry = db.TxRegistry()
 
 	tx, err := db.NewTransaction()
 	if err != nil {
@@ -53,7 +58,7 @@ func TestConcurrency(t *testing.T) {
 func runTransactionA(t *testing.T) {
 	defer wg.Done()
 
-	tx, err := tx.NewTransaction(fm, lm, bm)
+	tx, err := tx.NewTransaction(fm, lm, bm, lt, registry)
 	if err != nil {
 		t.Errorf("failed to create new transaction: %v", err)
 	}
@@ -85,7 +90,7 @@ func runTransactionA(t *testing.T) {
 func runTransactionB(t *testing.T) {
 	defer wg.Done()
 
-	tx, err := tx.NewTransaction(fm, lm, bm)
+	tx, err := tx.NewTransaction(fm, lm, bm, lt, registry)
 	if err != nil {
 		t.Errorf("failed to create new transaction: %v", err)
 		return
@@ -120,7 +125,7 @@ func runTransactionB(t *testing.T) {
 func runTransactionC(t *testing.T) {
 	defer wg.Done()
 
-	tx, err := tx.NewTransaction(fm, lm, bm)
+	tx, err := tx.NewTransaction(fm, lm, bm, lt, registry)
 	if err != nil {
 		t.Errorf("failed to create new transaction: %v", err)
 	}
@@ -0,0 +1,240 @@
+package concurrency_test
+
+import (
+	"errors"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/concurrency"
+)
+
+func TestDeadlockYoungestVictim(t *testing.T) {
+	lt := concurrency.NewLockTable()
+	cm1 := concurrency.NewConcurrencyManager(lt, 1)
+	cm2 := concurrency.NewConcurrencyManager(lt, 2)
+	b1 := file.NewBlockID("testfile", 1)
+	b2 := file.NewBlockID("testfile", 2)
+
+	if err := cm1.XLock(b1); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	if err := cm2.XLock(b2); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- cm1.XLock(b2)
+	}()
+	// let tx 1 start waiting for tx 2
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	err := cm2.XLock(b1)
+	if !errors.Is(err, concurrency.ErrDeadlock) {
+		t.Fatalf("expected %v, got %v", concurrency.ErrDeadlock, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the deadlock to be detected immediately, took %v", elapsed)
+	}
+
+	cm2.Release()
+	if err := <-done; err != nil {
+		t.Errorf("expected tx 1 to get the lock, got %v", err)
+	}
+	cm1.Release()
+}
+
+func TestDeadlockLeastWorkVictim(t *testing.T) {
+	lt := concurrency.NewLockTable()
+	lt.SetVictimPolicy(concurrency.LEAST_WORK)
+	cm1 := concurrency.NewConcurrencyManager(lt, 1)
+	cm2 := concurrency.NewConcurrencyManager(lt, 2)
+	b1 := file.NewBlockID("testfile", 1)
+	b2 := file.NewBlockID("testfile", 2)
+
+	if err := cm1.XLock(b1); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	for i := range int32(3) {
+		if err := cm2.SLock(file.NewBlockID("testfile", 2+i)); err != nil {
+			t.Fatalf("failed to lock block: %v", err)
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		err := cm1.XLock(b2)
+		// the victim gives up its locks, as a rollback would
+		cm1.Release()
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// tx 1 holds fewer locks than tx 2, so it is aborted although it is older
+	if err := cm2.XLock(b1); err != nil {
+		t.Fatalf("expected tx 2 to get the lock, got %v", err)
+	}
+	if err := <-done; !errors.Is(err, concurrency.ErrDeadlock) {
+		t.Errorf("expected %v, got %v", concurrency.ErrDeadlock, err)
+	}
+	cm2.Release()
+}
+
+func TestWaitDie(t *testing.T) {
+	db, err := server.NewSimpleDB(path.Join(t.TempDir(), "waitdietest"), 400, 8, server.WithDeadlockMode(concurrency.WAIT_DIE))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	lt := db.LockTable()
+	older := concurrency.NewConcurrencyManager(lt, 1)
+	younger := concurrency.NewConcurrencyManager(lt, 2)
+	b1 := file.NewBlockID("testfile", 1)
+	b2 := file.NewBlockID("testfile", 2)
+
+	// a younger transaction dies instead of waiting for an older one
+	if err := older.XLock(b1); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	start := time.Now()
+	if err := younger.SLock(b1); !errors.Is(err, concurrency.ErrWaitDie) {
+		t.Fatalf("expected %v, got %v", concurrency.ErrWaitDie, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the younger transaction to die immediately, took %v", elapsed)
+	}
+
+	// an older transaction waits for a younger one
+	if err := younger.XLock(b2); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- older.XLock(b2)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	younger.Release()
+	if err := <-done; err != nil {
+		t.Errorf("expected the older transaction to get the lock, got %v", err)
+	}
+	older.Release()
+}
+
+func TestWoundWait(t *testing.T) {
+	db, err := server.NewSimpleDB(path.Join(t.TempDir(), "woundwaittest"), 400, 8, server.WithDeadlockMode(concurrency.WOUND_WAIT))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	lt := db.LockTable()
+	older := concurrency.NewConcurrencyManager(lt, 1)
+	younger := concurrency.NewConcurrencyManager(lt, 2)
+	youngest := concurrency.NewConcurrencyManager(lt, 3)
+	b1 := file.NewBlockID("testfile", 1)
+	b2 := file.NewBlockID("testfile", 2)
+
+	// an older transaction wounds a younger holder and waits for it to abort
+	if err := younger.XLock(b1); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- older.XLock(b1)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := younger.SLock(b2); !errors.Is(err, concurrency.ErrWounded) {
+		t.Fatalf("expected %v, got %v", concurrency.ErrWounded, err)
+	}
+	younger.Release()
+	if err := <-done; err != nil {
+		t.Fatalf("expected the older transaction to get the lock, got %v", err)
+	}
+
+	// a younger transaction waits for an older one
+	go func() {
+		done <- youngest.SLock(b1)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	older.Release()
+	if err := <-done; err != nil {
+		t.Errorf("expected the youngest transaction to get the lock, got %v", err)
+	}
+	youngest.Release()
+}
+
+func TestWoundWaitIdleHolder(t *testing.T) {
+	db, err := server.NewSimpleDB(path.Join(t.TempDir(), "woundwaitidletest"), 400, 8, server.WithDeadlockMode(concurrency.WOUND_WAIT))
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	for range 2 {
+		if _, err := db.FileManager().Append("testfile"); err != nil {
+			t.Fatalf("failed to append block: %v", err)
+		}
+	}
+
+	// the younger holder asks for no more locks: it reads a block it has
+	// locked already, or commits
+	for _, finish := range []func(younger *tx.Transaction, block file.BlockID) error{
+		func(younger *tx.Transaction, block file.BlockID) error {
+			_, err := younger.GetInt(block, 0)
+			return err
+		},
+		func(younger *tx.Transaction, block file.BlockID) error {
+			return younger.Commit()
+		},
+	} {
+		older, err := db.NewTransaction()
+		if err != nil {
+			t.Fatalf("failed to create new transaction: %v", err)
+		}
+		younger, err := db.NewTransaction()
+		if err != nil {
+			t.Fatalf("failed to create new transaction: %v", err)
+		}
+		block := file.NewBlockID("testfile", 0)
+		if err := younger.Pin(block); err != nil {
+			t.Fatalf("failed to pin block: %v", err)
+		}
+		if err := younger.SetInt(block, 0, 7, true); err != nil {
+			t.Fatalf("failed to set int: %v", err)
+		}
+
+		done := make(chan error)
+		go func() {
+			if err := older.Pin(block); err != nil {
+				done <- err
+				return
+			}
+			done <- older.SetInt(block, 4, 1, true)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		start := time.Now()
+		if err := finish(younger, block); !errors.Is(err, concurrency.ErrWounded) {
+			t.Fatalf("expected %v, got %v", concurrency.ErrWounded, err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("expected the older transaction to get the lock, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the older transaction to get the lock at once, waited %v", elapsed)
+		}
+
+		// the younger transaction rolled back by itself
+		if val, err := older.GetInt(block, 0); err != nil || val != 0 {
+			t.Errorf("expected 0, got %d, %v", val, err)
+		}
+		if err := younger.Commit(); !errors.Is(err, concurrency.ErrWounded) {
+			t.Errorf("expected %v, got %v", concurrency.ErrWounded, err)
+		}
+		if err := younger.Rollback(); err != nil {
+			t.Errorf("expected rolling back again to do nothing, got %v", err)
+		}
+		if err := older.Commit(); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package concurrency_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/tx/concurrency"
+)
+
+func TestTableLocks(t *testing.T) {
+	lt := concurrency.NewLockTable()
+	reader := concurrency.NewConcurrencyManager(lt, 1)
+	writer := concurrency.NewConcurrencyManager(lt, 2)
+	ddl := concurrency.NewConcurrencyManager(lt, 3)
+
+	// intention locks let transactions lock different blocks of one table
+	if err := reader.SLock(file.NewBlockID("t.tbl", 0)); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	if err := writer.XLock(file.NewBlockID("t.tbl", 1)); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+
+	// a table lock waits for the transactions working on blocks of the table
+	done := make(chan error)
+	go func() {
+		done <- ddl.LockFile("t.tbl", concurrency.X)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("expected the table lock to wait, got %v", err)
+	default:
+	}
+	reader.Release()
+	writer.Release()
+	if err := <-done; err != nil {
+		t.Fatalf("failed to lock table: %v", err)
+	}
+
+	// and a locked table keeps them out
+	if lt.TryLockFile("t.tbl", 4, concurrency.IS) {
+		t.Errorf("expected IS to conflict with a table X lock")
+	}
+	ddl.Release()
+	if !lt.TryLockFile("t.tbl", 4, concurrency.IS) {
+		t.Errorf("expected IS to be granted once the table is unlocked")
+	}
+	lt.UnlockFile("t.tbl", 4)
+}
+
+func TestLockEscalation(t *testing.T) {
+	lt := concurrency.NewLockTable()
+	lt.SetEscalationThreshold(3)
+	reader := concurrency.NewConcurrencyManager(lt, 1)
+	writer := concurrency.NewConcurrencyManager(lt, 2)
+
+	for i := range int32(2) {
+		if err := reader.SLock(file.NewBlockID("t.tbl", i)); err != nil {
+			t.Fatalf("failed to lock block: %v", err)
+		}
+	}
+	if !lt.TryLockFile("t.tbl", 3, concurrency.IX) {
+		t.Fatalf("expected IX to be compatible with the reader's block locks")
+	}
+	lt.UnlockFile("t.tbl", 3)
+
+	// the third block lock is escalated to an S lock on the table
+	if err := reader.SLock(file.NewBlockID("t.tbl", 2)); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	if lt.TryLockFile("t.tbl", 3, concurrency.IX) {
+		t.Errorf("expected IX to conflict with the escalated table S lock")
+	}
+	reader.Release()
+
+	// escalation does not wait for a conflicting transaction,
+	// so the reader carries on with block locks
+	if err := writer.XLock(file.NewBlockID("t.tbl", 9)); err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	for i := range int32(3) {
+		if err := reader.SLock(file.NewBlockID("t.tbl", i)); err != nil {
+			t.Fatalf("failed to lock block: %v", err)
+		}
+	}
+	writer.Release()
+
+	// block X locks are escalated to an X lock on the table
+	for i := range int32(3) {
+		if err := writer.XLock(file.NewBlockID("u.tbl", i)); err != nil {
+			t.Fatalf("failed to lock block: %v", err)
+		}
+	}
+	if lt.TryLockFile("u.tbl", 3, concurrency.IS) {
+		t.Errorf("expected IS to conflict with the escalated table X lock")
+	}
+	reader.Release()
+	writer.Release()
+}
+
+func TestLockEscalationDisabled(t *testing.T) {
+	lt := concurrency.NewLockTable()
+	lt.SetEscalationThreshold(0)
+	reader := concurrency.NewConcurrencyManager(lt, 1)
+
+	for i := range int32(concurrency.DEFAULT_ESCALATION_THRESHOLD + 1) {
+		if err := reader.SLock(file.NewBlockID("t.tbl", i)); err != nil {
+			t.Fatalf("failed to lock block: %v", err)
+		}
+	}
+	// the reader keeps its block locks, which let a writer in
+	if !lt.TryLockFile("t.tbl", 2, concurrency.IX) {
+		t.Errorf("expected IX to be compatible with the reader's block locks")
+	}
+	lt.UnlockFile("t.tbl", 2)
+	reader.Release()
+}
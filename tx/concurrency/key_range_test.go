@@ -0,0 +1,49 @@
+package concurrency_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adieumonks/simple-db/tx/concurrency"
+)
+
+func TestKeyRangeLocks(t *testing.T) {
+	lt := concurrency.NewLockTable()
+	reader := concurrency.NewConcurrencyManager(lt, 1)
+	writer := concurrency.NewConcurrencyManager(lt, 2)
+
+	if err := reader.LockKey("idx", "=30", concurrency.S); err != nil {
+		t.Fatalf("failed to lock key: %v", err)
+	}
+
+	// ranges ending at other keys are not affected
+	if err := writer.AwaitKey("idx", "=40", concurrency.X); err != nil {
+		t.Fatalf("failed to wait for key: %v", err)
+	}
+	if err := writer.LockKey("idx", "=20", concurrency.X); err != nil {
+		t.Fatalf("failed to lock key: %v", err)
+	}
+
+	// an insert into the locked range waits for the reader
+	done := make(chan error)
+	go func() {
+		done <- writer.AwaitKey("idx", "=30", concurrency.X)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("expected the insert to wait, got %v", err)
+	default:
+	}
+	reader.Release()
+	if err := <-done; err != nil {
+		t.Fatalf("failed to wait for key: %v", err)
+	}
+
+	// waiting does not leave a lock behind
+	if err := reader.LockKey("idx", "=30", concurrency.S); err != nil {
+		t.Fatalf("failed to lock key: %v", err)
+	}
+	reader.Release()
+	writer.Release()
+}
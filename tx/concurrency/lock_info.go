@@ -0,0 +1,146 @@
+package concurrency
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/adieumonks/simple-db/file"
+)
+
+// ResourceKind tells what a lock is on.
+type ResourceKind int
+
+const (
+	FILE ResourceKind = iota
+	BLOCK
+	RECORD
+	KEY_RANGE
+)
+
+func (k ResourceKind) String() string {
+	switch k {
+	case FILE:
+		return "file"
+	case BLOCK:
+		return "block"
+	case RECORD:
+		return "record"
+	case KEY_RANGE:
+		return "key range"
+	default:
+		return "unknown"
+	}
+}
+
+// Resource is what a lock is on: a whole file, one of its blocks, the
+// record in a slot of a block, or the range of keys of an index that ends
+// at Key. It is the key of the lock table.
+type Resource struct {
+	Kind ResourceKind
+	// the file, or the index for a key range
+	Filename string
+	Block    int32
+	Slot     int32
+	Key      string
+}
+
+func (r Resource) String() string {
+	switch r.Kind {
+	case FILE:
+		return fmt.Sprintf("[file %s]", r.Filename)
+	case RECORD:
+		return fmt.Sprintf("[file %s, block %d, slot %d]", r.Filename, r.Block, r.Slot)
+	case KEY_RANGE:
+		return fmt.Sprintf("[index %s, key %s]", r.Filename, r.Key)
+	default:
+		return fmt.Sprintf("[file %s, block %d]", r.Filename, r.Block)
+	}
+}
+
+func fileResource(filename string) Resource {
+	return Resource{Kind: FILE, Filename: filename}
+}
+
+func blockResource(block file.BlockID) Resource {
+	return Resource{Kind: BLOCK, Filename: block.Filename(), Block: block.Number()}
+}
+
+func recordResource(block file.BlockID, slot int32) Resource {
+	return Resource{Kind: RECORD, Filename: block.Filename(), Block: block.Number(), Slot: slot}
+}
+
+func keyResource(index string, key string) Resource {
+	return Resource{Kind: KEY_RANGE, Filename: index, Key: key}
+}
+
+func compareResources(a, b Resource) int {
+	return cmp.Or(
+		cmp.Compare(a.Filename, b.Filename),
+		cmp.Compare(a.Kind, b.Kind),
+		cmp.Compare(a.Block, b.Block),
+		cmp.Compare(a.Slot, b.Slot),
+		cmp.Compare(a.Key, b.Key),
+	)
+}
+
+// LockInfo describes a lock held by a transaction.
+type LockInfo struct {
+	TxNum    int64
+	Resource Resource
+	Mode     LockMode
+}
+
+// WaitInfo describes a transaction waiting for a lock.
+type WaitInfo struct {
+	TxNum    int64
+	Resource Resource
+	Mode     LockMode
+	Since    time.Time
+	Waited   time.Duration
+	// the transactions holding the locks it waits for
+	BlockedBy []int64
+}
+
+// Locks returns the locks currently held, ordered by transaction
+// and then by resource.
+func (lt *LockTable) Locks() []LockInfo {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	var locks []LockInfo
+	for r, l := range lt.locks {
+		for txnum, mode := range l.holders {
+			locks = append(locks, LockInfo{TxNum: txnum, Resource: r, Mode: mode})
+		}
+	}
+	slices.SortFunc(locks, func(a, b LockInfo) int {
+		return cmp.Or(cmp.Compare(a.TxNum, b.TxNum), compareResources(a.Resource, b.Resource))
+	})
+	return locks
+}
+
+// Waits returns the transactions currently waiting for locks,
+// those waiting longest first.
+func (lt *LockTable) Waits() []WaitInfo {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	now := time.Now()
+	var waits []WaitInfo
+	for txnum, r := range lt.waiting {
+		waits = append(waits, WaitInfo{
+			TxNum:     txnum,
+			Resource:  r.resource,
+			Mode:      r.mode,
+			Since:     r.since,
+			Waited:    now.Sub(r.since),
+			BlockedBy: lt.conflicts(r.resource, txnum, r.mode),
+		})
+	}
+	slices.SortFunc(waits, func(a, b WaitInfo) int {
+		return cmp.Or(a.Since.Compare(b.Since), cmp.Compare(a.TxNum, b.TxNum))
+	})
+	return waits
+}
@@ -0,0 +1,74 @@
+package concurrency
+
+import "slices"
+
+// LockMode is the mode of a lock on a file or a block.
+// Blocks are only locked in S or X mode, after their file is locked in IS or IX mode.
+type LockMode int
+
+const (
+	// IS declares the intention to read blocks of a file.
+	IS LockMode = iota + 1
+	// IX declares the intention to modify blocks of a file.
+	IX
+	// S allows reading.
+	S
+	// SIX allows reading a whole file and declares the intention to modify some of its blocks.
+	SIX
+	// X allows reading and modifying.
+	X
+)
+
+var compatibility = map[LockMode][]LockMode{
+	IS:  {IS, IX, S, SIX},
+	IX:  {IS, IX},
+	S:   {IS, S},
+	SIX: {IS},
+	X:   {},
+}
+
+func (m LockMode) String() string {
+	switch m {
+	case IS:
+		return "IS"
+	case IX:
+		return "IX"
+	case S:
+		return "S"
+	case SIX:
+		return "SIX"
+	case X:
+		return "X"
+	default:
+		return "none"
+	}
+}
+
+func (m LockMode) compatible(other LockMode) bool {
+	return slices.Contains(compatibility[m], other)
+}
+
+// join returns the weakest mode allowing everything both modes allow.
+// The zero mode stands for no lock.
+func (m LockMode) join(other LockMode) LockMode {
+	switch {
+	case m == other || other == 0:
+		return m
+	case m == 0:
+		return other
+	case m == X || other == X:
+		return X
+	case (m == S && other == IX) || (m == IX && other == S) || m == SIX || other == SIX:
+		return SIX
+	case m == IS:
+		return other
+	case other == IS:
+		return m
+	}
+	return X
+}
+
+// covers reports whether holding m allows everything other allows.
+func (m LockMode) covers(other LockMode) bool {
+	return m.join(other) == m
+}
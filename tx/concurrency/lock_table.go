@@ -2,6 +2,7 @@ package concurrency
 
 import (
 	"errors"
+	"slices"
 	"sync"
 	"time"
 
@@ -10,83 +11,411 @@ import (
 )
 
 const (
-	MAX_TIME = 10 * time.Second
+	MAX_TIME                     = 10 * time.Second
+	DEFAULT_ESCALATION_THRESHOLD = 100
 )
 
-var ErrLockAbort = errors.New("lock abort")
+var (
+	ErrLockAbort = errors.New("lock abort")
+	ErrDeadlock  = errors.New("deadlock")
+	ErrWaitDie   = errors.New("younger transaction died instead of waiting")
+	ErrWounded   = errors.New("wounded by an older transaction")
+	// ErrLockNotAvailable is returned when a lock is requested without waiting
+	// and another transaction holds a conflicting one.
+	ErrLockNotAvailable = errors.New("lock not available")
+)
+
+// DeadlockMode decides how the lock table deals with deadlocks.
+// The prevention schemes use transaction numbers as timestamps.
+type DeadlockMode int
+
+const (
+	// DETECT lets transactions wait and aborts a victim once they form a cycle.
+	DETECT DeadlockMode = iota
+	// WAIT_DIE lets only older transactions wait for younger ones.
+	// A younger transaction requesting a conflicting lock is aborted.
+	WAIT_DIE
+	// WOUND_WAIT lets only younger transactions wait for older ones.
+	// An older transaction requesting a conflicting lock aborts the younger holders.
+	WOUND_WAIT
+)
+
+// VictimPolicy decides which transaction of a deadlock cycle is aborted.
+type VictimPolicy int
 
+const (
+	// YOUNGEST aborts the transaction with the highest number.
+	YOUNGEST VictimPolicy = iota
+	// LEAST_WORK aborts the transaction holding the fewest locks.
+	LEAST_WORK
+)
+
+type lock struct {
+	holders map[int64]LockMode
+}
+
+type request struct {
+	resource Resource
+	mode     LockMode
+	since    time.Time
+}
+
+// LockTable grants locks on files, their blocks and records, and ranges
+// of index keys to transactions.
+// In DETECT mode, waiting transactions form a wait-for graph, which is
+// checked for a cycle every time one starts to wait.
 type LockTable struct {
-	locks map[file.BlockID]int32
-	cond  *sync.Cond
+	locks   map[Resource]*lock
+	held    map[int64]int
+	waiting map[int64]request
+	// transactions to abort, with the reason, until they release their
+	// locks; their lock requests fail, and they roll back when they next
+	// use their transaction
+	aborted map[int64]error
+	// transactions rolling back, which those waiting for their locks
+	// wait for however long it takes
+	rollingBack         map[int64]bool
+	mode                DeadlockMode
+	policy              VictimPolicy
+	escalationThreshold int
+	cond                *sync.Cond
 }
 
 func NewLockTable() *LockTable {
 	return &LockTable{
-		locks: make(map[file.BlockID]int32),
-		cond:  sync.NewCond(&sync.Mutex{}),
+		locks:               make(map[Resource]*lock),
+		held:                make(map[int64]int),
+		waiting:             make(map[int64]request),
+		aborted:             make(map[int64]error),
+		rollingBack:         make(map[int64]bool),
+		mode:                DETECT,
+		policy:              YOUNGEST,
+		escalationThreshold: DEFAULT_ESCALATION_THRESHOLD,
+		cond:                sync.NewCond(&sync.Mutex{}),
 	}
 }
 
-func (lt *LockTable) SLock(block file.BlockID) error {
+// SetEscalationThreshold sets the number of block locks a transaction may
+// hold on one file before they are traded for a lock on the whole file.
+// A threshold of 0 or less turns escalation off.
+func (lt *LockTable) SetEscalationThreshold(threshold int) {
 	lt.cond.L.Lock()
 	defer lt.cond.L.Unlock()
 
-	timestamp := time.Now()
-	for lt.hasXLock(block) && !lt.waitingTooLong(timestamp) {
-		util.Wait(lt.cond, MAX_TIME)
+	lt.escalationThreshold = threshold
+}
+
+func (lt *LockTable) EscalationThreshold() int {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	return lt.escalationThreshold
+}
+
+func (lt *LockTable) SetDeadlockMode(mode DeadlockMode) {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	lt.mode = mode
+}
+
+func (lt *LockTable) SetVictimPolicy(policy VictimPolicy) {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	lt.policy = policy
+}
+
+// AbortReason returns the reason the transaction has to abort, if it has
+// to, such as when an older transaction has wounded it.
+func (lt *LockTable) AbortReason(txnum int64) error {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	return lt.aborted[txnum]
+}
+
+// StartRollback tells the lock table that the transaction is rolling back,
+// so that the transactions waiting only for its locks do not time out.
+func (lt *LockTable) StartRollback(txnum int64) {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	if lt.held[txnum] > 0 {
+		lt.rollingBack[txnum] = true
 	}
-	if lt.hasXLock(block) {
-		return ErrLockAbort
+}
+
+func (lt *LockTable) SLock(block file.BlockID, txnum int64) error {
+	return lt.acquire(blockResource(block), txnum, S)
+}
+
+func (lt *LockTable) XLock(block file.BlockID, txnum int64) error {
+	return lt.acquire(blockResource(block), txnum, X)
+}
+
+// LockBlock locks a block, or declares the intention to lock its records.
+func (lt *LockTable) LockBlock(block file.BlockID, txnum int64, mode LockMode) error {
+	return lt.acquire(blockResource(block), txnum, mode)
+}
+
+// TryLockBlock locks a block if that is possible without waiting.
+func (lt *LockTable) TryLockBlock(block file.BlockID, txnum int64, mode LockMode) bool {
+	return lt.tryAcquire(blockResource(block), txnum, mode)
+}
+
+// TryXLock locks the block in X mode if that is possible without waiting.
+func (lt *LockTable) TryXLock(block file.BlockID, txnum int64) bool {
+	return lt.tryAcquire(blockResource(block), txnum, X)
+}
+
+func (lt *LockTable) Unlock(block file.BlockID, txnum int64) {
+	lt.release(blockResource(block), txnum)
+}
+
+// LockRecord locks the record in the slot of a block.
+func (lt *LockTable) LockRecord(block file.BlockID, slot int32, txnum int64, mode LockMode) error {
+	return lt.acquire(recordResource(block, slot), txnum, mode)
+}
+
+// TryLockRecord locks the record in the slot of a block if that is possible
+// without waiting.
+func (lt *LockTable) TryLockRecord(block file.BlockID, slot int32, txnum int64, mode LockMode) bool {
+	return lt.tryAcquire(recordResource(block, slot), txnum, mode)
+}
+
+func (lt *LockTable) UnlockRecord(block file.BlockID, slot int32, txnum int64) {
+	lt.release(recordResource(block, slot), txnum)
+}
+
+// LockFile locks a whole file, or declares the intention to lock its blocks.
+func (lt *LockTable) LockFile(filename string, txnum int64, mode LockMode) error {
+	return lt.acquire(fileResource(filename), txnum, mode)
+}
+
+// TryLockFile locks a whole file if that is possible without waiting.
+func (lt *LockTable) TryLockFile(filename string, txnum int64, mode LockMode) bool {
+	return lt.tryAcquire(fileResource(filename), txnum, mode)
+}
+
+func (lt *LockTable) UnlockFile(filename string, txnum int64) {
+	lt.release(fileResource(filename), txnum)
+}
+
+// LockKey locks the range of index keys that ends at key,
+// that is, key and the gap before it.
+func (lt *LockTable) LockKey(index string, key string, txnum int64, mode LockMode) error {
+	return lt.acquire(keyResource(index, key), txnum, mode)
+}
+
+// AwaitKey waits until txnum could lock the range of index keys that ends
+// at key, without locking it. It serves as a lock of instant duration.
+func (lt *LockTable) AwaitKey(index string, key string, txnum int64, mode LockMode) error {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	return lt.await(keyResource(index, key), txnum, mode)
+}
+
+func (lt *LockTable) UnlockKey(index string, key string, txnum int64) {
+	lt.release(keyResource(index, key), txnum)
+}
+
+func (lt *LockTable) acquire(r Resource, txnum int64, mode LockMode) error {
+	lt.cond.L.Lock()
+	defer lt.cond.L.Unlock()
+
+	if err := lt.await(r, txnum, mode); err != nil {
+		return err
 	}
-	val := lt.getLockVal(block)
-	lt.locks[block] = val + 1
+	lt.grant(r, txnum, mode)
 	return nil
 }
 
-func (lt *LockTable) XLock(block file.BlockID) error {
+func (lt *LockTable) tryAcquire(r Resource, txnum int64, mode LockMode) bool {
 	lt.cond.L.Lock()
 	defer lt.cond.L.Unlock()
 
-	timestamp := time.Now()
-	for lt.hasOtherSLock(block) && !lt.waitingTooLong(timestamp) {
-		util.Wait(lt.cond, MAX_TIME)
+	if lt.aborted[txnum] != nil || len(lt.conflicts(r, txnum, mode)) > 0 {
+		return false
 	}
-	if lt.hasOtherSLock(block) {
-		return ErrLockAbort
+	lt.grant(r, txnum, mode)
+	return true
+}
+
+// await waits until no other transaction holds a lock on r
+// incompatible with mode. The caller holds the lock table mutex.
+func (lt *LockTable) await(r Resource, txnum int64, mode LockMode) error {
+	if err := lt.aborted[txnum]; err != nil {
+		return err
+	}
+	timestamp := time.Now()
+	for len(lt.conflicts(r, txnum, mode)) > 0 {
+		if err := lt.wait(r, txnum, mode, timestamp); err != nil {
+			return err
+		}
 	}
-	lt.locks[block] = -1
 	return nil
 }
 
-func (lt *LockTable) Unlock(block file.BlockID) {
+func (lt *LockTable) release(r Resource, txnum int64) {
 	lt.cond.L.Lock()
 	defer lt.cond.L.Unlock()
 
-	val := lt.getLockVal(block)
-	if val > 1 {
-		lt.locks[block] = val - 1
-	} else {
-		delete(lt.locks, block)
-		lt.cond.Broadcast()
+	l, ok := lt.locks[r]
+	if !ok {
+		return
+	}
+	if _, ok := l.holders[txnum]; !ok {
+		return
+	}
+	delete(l.holders, txnum)
+	if len(l.holders) == 0 {
+		delete(lt.locks, r)
+	}
+	lt.held[txnum]--
+	if lt.held[txnum] == 0 {
+		delete(lt.held, txnum)
+		delete(lt.aborted, txnum)
+		delete(lt.rollingBack, txnum)
+	}
+	lt.cond.Broadcast()
+}
+
+// wait blocks txnum until the lock table changes. It fails when txnum
+// has waited too long, or has to abort to resolve or prevent a deadlock.
+func (lt *LockTable) wait(r Resource, txnum int64, mode LockMode, startTime time.Time) error {
+	if lt.waitingTooLong(startTime) && !lt.waitingForRollbacks(r, txnum, mode) {
+		return ErrLockAbort
+	}
+	lt.waiting[txnum] = request{r, mode, startTime}
+	defer delete(lt.waiting, txnum)
+
+	switch lt.mode {
+	case WAIT_DIE:
+		for _, holder := range lt.conflicts(r, txnum, mode) {
+			if holder < txnum {
+				return ErrWaitDie
+			}
+		}
+	case WOUND_WAIT:
+		for _, holder := range lt.conflicts(r, txnum, mode) {
+			if holder > txnum {
+				lt.aborted[holder] = ErrWounded
+				lt.cond.Broadcast()
+			}
+		}
+	default:
+		if cycle := lt.findCycle(txnum); cycle != nil {
+			victim := lt.chooseVictim(cycle)
+			if victim == txnum {
+				return ErrDeadlock
+			}
+			lt.aborted[victim] = ErrDeadlock
+			lt.cond.Broadcast()
+		}
 	}
+	util.Wait(lt.cond, MAX_TIME)
+	return lt.aborted[txnum]
 }
 
-func (lt *LockTable) hasXLock(block file.BlockID) bool {
-	return lt.getLockVal(block) < 0
+// waitingForRollbacks reports whether all the transactions txnum waits for
+// are rolling back.
+func (lt *LockTable) waitingForRollbacks(r Resource, txnum int64, mode LockMode) bool {
+	for _, holder := range lt.conflicts(r, txnum, mode) {
+		if !lt.rollingBack[holder] {
+			return false
+		}
+	}
+	return true
 }
 
-func (lt *LockTable) hasOtherSLock(block file.BlockID) bool {
-	return lt.getLockVal(block) > 1
+// findCycle returns the transactions on a cycle of the wait-for graph
+// through txnum, or nil if there is none.
+func (lt *LockTable) findCycle(txnum int64) []int64 {
+	visited := make(map[int64]bool)
+	var path []int64
+	var visit func(t int64) bool
+	visit = func(t int64) bool {
+		path = append(path, t)
+		for _, next := range lt.waitsFor(t) {
+			if next == txnum {
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(txnum) {
+		return path
+	}
+	return nil
 }
 
-func (lt *LockTable) waitingTooLong(startTime time.Time) bool {
-	return time.Since(startTime) > MAX_TIME
+// waitsFor returns the transactions holding locks that keep txnum waiting.
+func (lt *LockTable) waitsFor(txnum int64) []int64 {
+	r, ok := lt.waiting[txnum]
+	if !ok {
+		return nil
+	}
+	return lt.conflicts(r.resource, txnum, r.mode)
 }
 
-func (lt *LockTable) getLockVal(block file.BlockID) int32 {
-	ival, ok := lt.locks[block]
+// conflicts returns the other transactions holding locks on r
+// that are incompatible with txnum locking it in the given mode.
+func (lt *LockTable) conflicts(r Resource, txnum int64, mode LockMode) []int64 {
+	l, ok := lt.locks[r]
 	if !ok {
-		return 0
+		return nil
 	}
-	return ival
+	mode = mode.join(l.holders[txnum])
+	var holders []int64
+	for t, held := range l.holders {
+		if t != txnum && !mode.compatible(held) {
+			holders = append(holders, t)
+		}
+	}
+	// keep the search order, and so the victim, independent of map order
+	slices.Sort(holders)
+	return holders
+}
+
+func (lt *LockTable) chooseVictim(cycle []int64) int64 {
+	victim := cycle[0]
+	for _, t := range cycle[1:] {
+		switch lt.policy {
+		case LEAST_WORK:
+			if lt.held[t] < lt.held[victim] || (lt.held[t] == lt.held[victim] && t > victim) {
+				victim = t
+			}
+		default:
+			if t > victim {
+				victim = t
+			}
+		}
+	}
+	return victim
+}
+
+func (lt *LockTable) grant(r Resource, txnum int64, mode LockMode) {
+	l, ok := lt.locks[r]
+	if !ok {
+		l = &lock{holders: make(map[int64]LockMode)}
+		lt.locks[r] = l
+	}
+	held, ok := l.holders[txnum]
+	if !ok {
+		lt.held[txnum]++
+	}
+	l.holders[txnum] = mode.join(held)
+}
+
+func (lt *LockTable) waitingTooLong(startTime time.Time) bool {
+	return time.Since(startTime) > MAX_TIME
 }
@@ -0,0 +1,65 @@
+package concurrency_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/tx/concurrency"
+)
+
+func TestRecordLocks(t *testing.T) {
+	lt := concurrency.NewLockTable()
+	lt.SetEscalationThreshold(4)
+	tx1 := concurrency.NewConcurrencyManager(lt, 1)
+	tx2 := concurrency.NewConcurrencyManager(lt, 2)
+	block := file.NewBlockID("t.tbl", 0)
+
+	// records of one block are locked apart
+	if err := tx1.LockRecord(block, 0, concurrency.X); err != nil {
+		t.Fatalf("failed to lock record: %v", err)
+	}
+	if err := tx2.LockRecord(block, 1, concurrency.X); err != nil {
+		t.Fatalf("failed to lock record: %v", err)
+	}
+	if tx2.TryLockRecord(block, 0, concurrency.S) {
+		t.Errorf("expected S to conflict with the X lock on the record")
+	}
+
+	// a block lock waits for the transactions working on its records
+	done := make(chan error)
+	go func() {
+		done <- tx2.SLock(block)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("expected the block lock to wait, got %v", err)
+	default:
+	}
+	tx1.Release()
+	if err := <-done; err != nil {
+		t.Fatalf("failed to lock block: %v", err)
+	}
+	if !tx2.TryLockRecord(block, 0, concurrency.S) {
+		t.Errorf("expected the block lock to cover the record")
+	}
+	tx2.Release()
+
+	// record locks are escalated to a lock on the file like block locks
+	for slot := range int32(4) {
+		if err := tx1.LockRecord(block, slot, concurrency.S); err != nil {
+			t.Fatalf("failed to lock record: %v", err)
+		}
+	}
+	if tx2.TryLockRecord(file.NewBlockID("t.tbl", 1), 0, concurrency.X) {
+		t.Errorf("expected X to conflict with the escalated file S lock")
+	}
+	for _, l := range lt.Locks() {
+		if l.TxNum == 1 && l.Resource.Kind != concurrency.FILE {
+			t.Errorf("expected only the file to be locked, got %v", l)
+		}
+	}
+	tx1.Release()
+	tx2.Release()
+}
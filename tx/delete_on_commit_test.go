@@ -0,0 +1,86 @@
+package tx_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+func TestDeleteOnCommit(t *testing.T) {
+	dir := path.Join(t.TempDir(), "deletetest")
+	db, _ := server.NewSimpleDB(dir, 400, 8)
+	fm := db.FileManager()
+	lm := db.LogManager()
+	bm := db.BufferManager()
+	lt := db.LockTable()
+	registry := db.TxRegistry()
+
+	exists := func(filename string) bool {
+		_, err := os.Stat(path.Join(dir, filename))
+		return err == nil
+	}
+
+	tx1, err := tx.NewTransaction(fm, lm, bm, lt, registry)
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	for _, filename := range []string{"kept", "undone", "deleted"} {
+		if _, err := tx1.Append(filename); err != nil {
+			t.Fatalf("failed to append block: %v", err)
+		}
+	}
+	block := file.NewBlockID("deleted", 0)
+	if err := tx1.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	if err := tx1.SetInt(block, 0, 7, false); err != nil {
+		t.Fatalf("failed to set int: %v", err)
+	}
+	tx1.Unpin(block)
+
+	// the deletions after a savepoint are undone along with the changes
+	savepoint, err := tx1.Savepoint()
+	if err != nil {
+		t.Fatalf("failed to create savepoint: %v", err)
+	}
+	tx1.DeleteOnCommit("undone")
+	if err := tx1.RollbackToSavepoint(savepoint); err != nil {
+		t.Fatalf("failed to rollback to savepoint: %v", err)
+	}
+	tx1.DeleteOnCommit("deleted")
+	if !exists("deleted") {
+		t.Errorf("expected the file to be kept until commit")
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if exists("deleted") || !exists("kept") || !exists("undone") {
+		t.Errorf("expected only the file deleted on commit to be gone")
+	}
+
+	// a file created again is read afresh, not from the buffers
+	tx2, err := tx.NewTransaction(fm, lm, bm, lt, registry)
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := tx2.Append("deleted"); err != nil {
+		t.Fatalf("failed to append block: %v", err)
+	}
+	if err := tx2.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	if val, _ := tx2.GetInt(block, 0); val != 0 {
+		t.Errorf("expected 0, got %d", val)
+	}
+	tx2.DeleteOnCommit("kept")
+	if err := tx2.Rollback(); err != nil {
+		t.Fatalf("failed to rollback: %v", err)
+	}
+	if !exists("kept") {
+		t.Errorf("expected the file to be kept on rollback")
+	}
+}
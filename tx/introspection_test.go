@@ -0,0 +1,95 @@
+package tx_test
+
+import (
+	"path"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx/concurrency"
+)
+
+func TestLockIntrospection(t *testing.T) {
+	db, err := server.NewSimpleDB(path.Join(t.TempDir(), "introspectiontest"), 400, 8)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+
+	writer, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	block, err := writer.Append("testfile")
+	if err != nil {
+		t.Fatalf("failed to append block: %v", err)
+	}
+	if err := writer.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	if err := writer.SetInt(block, 80, 1, true); err != nil {
+		t.Fatalf("failed to set int: %v", err)
+	}
+
+	reader, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if err := reader.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := reader.GetInt(block, 80)
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	active := db.ActiveTransactions()
+	if len(active) != 2 || active[0].TxNum != writer.TxNumber() || active[1].TxNum != reader.TxNumber() {
+		t.Fatalf("expected transactions %d and %d to be active, got %v", writer.TxNumber(), reader.TxNumber(), active)
+	}
+	// the start record and the update
+	if active[0].LogRecords != 2 {
+		t.Errorf("expected 2 log records, got %d", active[0].LogRecords)
+	}
+	if active[0].Started.After(active[1].Started) {
+		t.Errorf("expected %v to be before %v", active[0].Started, active[1].Started)
+	}
+
+	expected := concurrency.LockInfo{
+		TxNum:    writer.TxNumber(),
+		Resource: concurrency.Resource{Kind: concurrency.BLOCK, Filename: "testfile", Block: block.Number()},
+		Mode:     concurrency.X,
+	}
+	if !slices.Contains(db.Locks(), expected) {
+		t.Errorf("expected %v among %v", expected, db.Locks())
+	}
+
+	waits := db.LockWaits()
+	if len(waits) != 1 {
+		t.Fatalf("expected 1 waiting transaction, got %v", waits)
+	}
+	if waits[0].TxNum != reader.TxNumber() || waits[0].Resource != expected.Resource || waits[0].Mode != concurrency.S {
+		t.Errorf("expected transaction %d to wait for %v, got %v", reader.TxNumber(), expected.Resource, waits[0])
+	}
+	if !slices.Equal(waits[0].BlockedBy, []int64{writer.TxNumber()}) {
+		t.Errorf("expected it to wait for transaction %d, got %v", writer.TxNumber(), waits[0].BlockedBy)
+	}
+	if waits[0].Waited < 100*time.Millisecond {
+		t.Errorf("expected it to have waited at least 100ms, got %v", waits[0].Waited)
+	}
+
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("failed to get int: %v", err)
+	}
+	if err := reader.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	if len(db.ActiveTransactions()) != 0 || len(db.Locks()) != 0 || len(db.LockWaits()) != 0 {
+		t.Errorf("expected nothing to be left, got %v, %v and %v", db.ActiveTransactions(), db.Locks(), db.LockWaits())
+	}
+}
@@ -0,0 +1,32 @@
+package tx
+
+// IsolationLevel decides how much a transaction is kept apart from
+// concurrent ones. Read-only transactions always read their snapshot.
+type IsolationLevel int
+
+const (
+	// READ_COMMITTED only reads committed changes. A read lock is released
+	// once the transaction unpins the block, and versioned tables are read
+	// as of the start of each statement.
+	READ_COMMITTED IsolationLevel = iota
+	// REPEATABLE_READ keeps read locks until the transaction finishes, and
+	// reads versioned tables as of the start of the transaction. A repeated
+	// query may still find records inserted in the meantime.
+	REPEATABLE_READ
+	// SERIALIZABLE also locks the end of files and ranges of index keys
+	// against inserts, and locks versioned tables like the others.
+	SERIALIZABLE
+)
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case READ_COMMITTED:
+		return "READ COMMITTED"
+	case REPEATABLE_READ:
+		return "REPEATABLE READ"
+	case SERIALIZABLE:
+		return "SERIALIZABLE"
+	default:
+		return "UNKNOWN"
+	}
+}
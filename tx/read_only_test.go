@@ -0,0 +1,263 @@
+package tx_test
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/log"
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+	"github.com/adieumonks/simple-db/tx/recovery"
+)
+
+func TestReadOnlyTx(t *testing.T) {
+	db, _ := server.NewSimpleDB(path.Join(t.TempDir(), "readonlytest"), 400, 8)
+	fm := db.FileManager()
+	lm := db.LogManager()
+
+	if _, err := fm.Append("testfile"); err != nil {
+		t.Fatalf("failed to append block: %v", err)
+	}
+	block := file.NewBlockID("testfile", 0)
+
+	tx1, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if err := tx1.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	if err := tx1.SetInt(block, 80, 1, true); err != nil {
+		t.Fatalf("failed to set int: %v", err)
+	}
+	if err := tx1.SetString(block, 40, "one", true); err != nil {
+		t.Fatalf("failed to set string: %v", err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	// tx2 is active when the read-only transaction starts
+	tx2, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if err := tx2.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	if err := tx2.SetInt(block, 80, 2, true); err != nil {
+		t.Fatalf("failed to set int: %v", err)
+	}
+
+	records := countLogRecords(t, lm)
+	ro := db.NewReadOnlyTransaction()
+	if err := ro.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	assertReadOnlyValues(t, ro, block, 1, "one")
+
+	// writers are not blocked by the reader, and their changes stay invisible
+	if err := tx2.SetString(block, 40, "two", true); err != nil {
+		t.Fatalf("failed to set string: %v", err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	ro.Unpin(block)
+	if err := ro.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	assertReadOnlyValues(t, ro, block, 1, "one")
+
+	if err := ro.SetInt(block, 80, 3, true); !errors.Is(err, tx.ErrReadOnly) {
+		t.Errorf("expected %v, got %v", tx.ErrReadOnly, err)
+	}
+	if err := ro.SetString(block, 40, "three", true); !errors.Is(err, tx.ErrReadOnly) {
+		t.Errorf("expected %v, got %v", tx.ErrReadOnly, err)
+	}
+	if _, err := ro.Append("testfile"); !errors.Is(err, tx.ErrReadOnly) {
+		t.Errorf("expected %v, got %v", tx.ErrReadOnly, err)
+	}
+	if err := ro.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	// only tx2's string update and commit were logged since the reader started
+	if got := countLogRecords(t, lm); got != records+2 {
+		t.Errorf("expected %d log records, got %d", records+2, got)
+	}
+
+	ro2 := db.NewReadOnlyTransaction()
+	if err := ro2.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	assertReadOnlyValues(t, ro2, block, 2, "two")
+	if err := ro2.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+}
+
+func assertReadOnlyValues(t *testing.T, ro *tx.Transaction, block file.BlockID, expectedInt int32, expectedString string) {
+	t.Helper()
+	ival, err := ro.GetInt(block, 80)
+	if err != nil {
+		t.Fatalf("failed to get int: %v", err)
+	}
+	if ival != expectedInt {
+		t.Errorf("expected %d, got %d", expectedInt, ival)
+	}
+	sval, err := ro.GetString(block, 40)
+	if err != nil {
+		t.Fatalf("failed to get string: %v", err)
+	}
+	if sval != expectedString {
+		t.Errorf("expected %s, got %s", expectedString, sval)
+	}
+}
+
+func countLogRecords(t *testing.T, lm *log.LogManager) int {
+	t.Helper()
+	iter, err := lm.Iterator()
+	if err != nil {
+		t.Fatalf("failed to get log iterator: %v", err)
+	}
+	count := 0
+	for iter.HasNext() {
+		if _, err := iter.Next(); err != nil {
+			t.Fatalf("failed to read log record: %v", err)
+		}
+		count++
+	}
+	return count
+}
+
+func TestReadOnlyTxLongLog(t *testing.T) {
+	db, _ := server.NewSimpleDB(path.Join(t.TempDir(), "readonlylonglogtest"), 400, 8)
+	fm := db.FileManager()
+	lm := db.LogManager()
+
+	blocks := make([]file.BlockID, 3)
+	for i := range blocks {
+		block, err := fm.Append("testfile")
+		if err != nil {
+			t.Fatalf("failed to append block: %v", err)
+		}
+		blocks[i] = block
+	}
+
+	// a change of a transaction the snapshot never sees, logged long before
+	// it is taken; reading back this far would undo it
+	if _, err := recovery.NewSetIntRecord(1<<40, blocks[0], 80, 99).WriteToLog(lm); err != nil {
+		t.Fatalf("failed to write log record: %v", err)
+	}
+	for i := int32(1); i <= 200; i++ {
+		setInts(t, db, i, 80, blocks[0], blocks[1])
+	}
+
+	// tx2 is active when the read-only transaction starts, with its changes
+	// spanning several log blocks
+	tx2, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	startBlock, err := fm.Length(server.LOG_FILE)
+	if err != nil {
+		t.Fatalf("failed to get log size: %v", err)
+	}
+	for _, block := range blocks[:2] {
+		if err := tx2.Pin(block); err != nil {
+			t.Fatalf("failed to pin block: %v", err)
+		}
+		if err := tx2.SetInt(block, 80, -1, true); err != nil {
+			t.Fatalf("failed to set int: %v", err)
+		}
+	}
+	for i := int32(0); i < 30; i++ {
+		if err := tx2.SetInt(blocks[0], 120, i, true); err != nil {
+			t.Fatalf("failed to set int: %v", err)
+		}
+	}
+
+	ro := db.NewReadOnlyTransaction()
+	assertReadOnlyInt(t, ro, blocks[0], 200)
+	endBlock, err := fm.Length(server.LOG_FILE)
+	if err != nil {
+		t.Fatalf("failed to get log size: %v", err)
+	}
+
+	// later changes are read from the log records appended since
+	for i := int32(201); i <= 230; i++ {
+		setInts(t, db, i, 80, blocks[2])
+	}
+	assertReadOnlyInt(t, ro, blocks[2], 0)
+
+	// the undo images of tx2 were kept from the first read, so the log
+	// records it wrote before that are not read again: replacing them with
+	// a checkpoint does not change what the reader sees
+	scratch, err := log.NewLogManager(fm, "scratch.log")
+	if err != nil {
+		t.Fatalf("failed to create log manager: %v", err)
+	}
+	lsn, err := recovery.NewCheckpointRecord(0, nil).WriteToLog(scratch)
+	if err != nil {
+		t.Fatalf("failed to write log record: %v", err)
+	}
+	if err := scratch.Flush(lsn); err != nil {
+		t.Fatalf("failed to flush log: %v", err)
+	}
+	checkpoint := file.NewPage(fm.BlockSize())
+	if err := fm.Read(file.NewBlockID("scratch.log", 0), checkpoint); err != nil {
+		t.Fatalf("failed to read log block: %v", err)
+	}
+	for n := startBlock - 1; n < endBlock-1; n++ {
+		if err := fm.Write(file.NewBlockID(server.LOG_FILE, n), checkpoint); err != nil {
+			t.Fatalf("failed to write log block: %v", err)
+		}
+	}
+	assertReadOnlyInt(t, ro, blocks[1], 200)
+	assertReadOnlyInt(t, ro, blocks[0], 200)
+
+	if err := tx2.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := ro.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+}
+
+// setInts sets the int at the offset of the blocks in a transaction of its
+// own.
+func setInts(t *testing.T, db *server.SimpleDB, val int32, offset int32, blocks ...file.BlockID) {
+	t.Helper()
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	for _, block := range blocks {
+		if err := tx.Pin(block); err != nil {
+			t.Fatalf("failed to pin block: %v", err)
+		}
+		if err := tx.SetInt(block, offset, val, true); err != nil {
+			t.Fatalf("failed to set int: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+}
+
+func assertReadOnlyInt(t *testing.T, ro *tx.Transaction, block file.BlockID, expected int32) {
+	t.Helper()
+	if err := ro.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	defer ro.Unpin(block)
+	val, err := ro.GetInt(block, 80)
+	if err != nil {
+		t.Fatalf("failed to get int: %v", err)
+	}
+	if val != expected {
+		t.Errorf("expected %d in %v, got %d", expected, block, val)
+	}
+}
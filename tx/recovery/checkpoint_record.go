@@ -1,35 +1,76 @@
 package recovery
 
 import (
+	"fmt"
+
 	"github.com/adieumonks/simple-db/file"
 	"github.com/adieumonks/simple-db/log"
 )
 
-type CheckPointRecord struct{}
+type CheckPointRecord struct {
+	lastTxNum int64
+	inDoubt   []int64
+}
+
+func NewCheckpointRecord(lastTxNum int64, inDoubt []int64) *CheckPointRecord {
+	return &CheckPointRecord{
+		lastTxNum: lastTxNum,
+		inDoubt:   inDoubt,
+	}
+}
 
-func NewCheckpointRecord() *CheckPointRecord {
-	return &CheckPointRecord{}
+func NewCheckpointRecordFrom(p *file.Page) *CheckPointRecord {
+	tpos := file.Int32Bytes
+	npos := tpos + file.Int64Bytes
+	n := p.GetInt(npos)
+	inDoubt := make([]int64, n)
+	for i := range n {
+		inDoubt[i] = p.GetLong(npos + file.Int32Bytes + i*file.Int64Bytes)
+	}
+	return &CheckPointRecord{
+		lastTxNum: p.GetLong(tpos),
+		inDoubt:   inDoubt,
+	}
 }
 
 func (r *CheckPointRecord) Op() LogRecordType {
 	return CHECKPOINT
 }
 
-func (r *CheckPointRecord) TxNumber() int32 {
+func (r *CheckPointRecord) TxNumber() int64 {
 	return -1
 }
 
+// LastTxNumber is the highest transaction number handed out when the checkpoint was taken.
+func (r *CheckPointRecord) LastTxNumber() int64 {
+	return r.lastTxNum
+}
+
+// InDoubt lists the prepared transactions that were still unresolved at the checkpoint.
+// Their log records precede it.
+func (r *CheckPointRecord) InDoubt() []int64 {
+	return r.inDoubt
+}
+
 func (r *CheckPointRecord) Undo(tx Transaction) error {
 	return nil
 }
 
 func (r *CheckPointRecord) String() string {
-	return "<CHECKPOINT>"
+	return fmt.Sprintf("<CHECKPOINT %d %v>", r.lastTxNum, r.inDoubt)
 }
 
 func (r *CheckPointRecord) WriteToLog(lm *log.LogManager) (int32, error) {
-	rec := make([]byte, file.Int32Bytes)
+	tpos := file.Int32Bytes
+	npos := tpos + file.Int64Bytes
+	n := int32(len(r.inDoubt))
+	rec := make([]byte, npos+file.Int32Bytes+n*file.Int64Bytes)
 	p := file.NewPageFromBytes(rec)
 	p.SetInt(0, int32(CHECKPOINT))
+	p.SetLong(tpos, r.lastTxNum)
+	p.SetInt(npos, n)
+	for i, txnum := range r.inDoubt {
+		p.SetLong(npos+file.Int32Bytes+int32(i)*file.Int64Bytes, txnum)
+	}
 	return lm.Append(rec)
 }
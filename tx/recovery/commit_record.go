@@ -8,10 +8,10 @@ import (
 )
 
 type CommitRecord struct {
-	txnum int32
+	txnum int64
 }
 
-func NewCommitRecord(txnum int32) *CommitRecord {
+func NewCommitRecord(txnum int64) *CommitRecord {
 	return &CommitRecord{
 		txnum: txnum,
 	}
@@ -20,7 +20,7 @@ func NewCommitRecord(txnum int32) *CommitRecord {
 func NewCommitRecordFrom(p *file.Page) *CommitRecord {
 	tpos := file.Int32Bytes
 	return &CommitRecord{
-		txnum: p.GetInt(tpos),
+		txnum: p.GetLong(tpos),
 	}
 }
 
@@ -28,7 +28,7 @@ func (r *CommitRecord) Op() LogRecordType {
 	return COMMIT
 }
 
-func (r *CommitRecord) TxNumber() int32 {
+func (r *CommitRecord) TxNumber() int64 {
 	return r.txnum
 }
 
@@ -41,9 +41,9 @@ func (r *CommitRecord) String() string {
 }
 
 func (r *CommitRecord) WriteToLog(lm *log.LogManager) (int32, error) {
-	rec := make([]byte, 2*file.Int32Bytes)
+	rec := make([]byte, file.Int32Bytes+file.Int64Bytes)
 	p := file.NewPageFromBytes(rec)
 	p.SetInt(0, int32(COMMIT))
-	p.SetInt(file.Int32Bytes, r.txnum)
+	p.SetLong(file.Int32Bytes, r.txnum)
 	return lm.Append(rec)
 }
@@ -0,0 +1,200 @@
+package recovery_test
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"path"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/server"
+)
+
+var errCrash = errors.New("simulated crash")
+
+// crashInjector lets a number of block writes through and then fails
+// every write after that, as if the process had been killed.
+type crashInjector struct {
+	mu        sync.Mutex
+	remaining int
+	logOnly   bool
+	crashed   bool
+}
+
+func (ci *crashInjector) BeforeWrite(block file.BlockID) error {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if ci.crashed {
+		return errCrash
+	}
+	if ci.logOnly && block.Filename() != server.LOG_FILE {
+		return nil
+	}
+	if ci.remaining == 0 {
+		ci.crashed = true
+		return errCrash
+	}
+	ci.remaining--
+	return nil
+}
+
+func TestCrashRecovery(t *testing.T) {
+	const (
+		rounds      = 25
+		maxTxs      = 20
+		maxWrites   = 150
+		maxOpsPerTx = 5
+	)
+
+	dir := path.Join(t.TempDir(), "crashtest")
+	db, err := server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := db.Planner().ExecuteUpdate("create table T(k int, v varchar(20))", tx); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	committed := make(map[int32]string)
+	nextKey := int32(0)
+
+	for round := 0; round < rounds; round++ {
+		r := rand.New(rand.NewPCG(uint64(round), 0))
+		injector := &crashInjector{
+			remaining: r.IntN(maxWrites),
+			logOnly:   r.IntN(2) == 0,
+		}
+		db.FileManager().SetFaultInjector(injector)
+		planner := db.Planner()
+
+		// the state a transaction that crashed inside Commit may have left behind
+		var inDoubt map[int32]string
+
+	workload:
+		for i := 0; i < maxTxs; i++ {
+			tx, err := db.NewTransaction()
+			if err != nil {
+				break
+			}
+			pending := maps.Clone(committed)
+			numOps := 1 + r.IntN(maxOpsPerTx)
+			for j := 0; j < numOps; j++ {
+				var cmd string
+				keys := keysOf(pending)
+				switch op := r.IntN(4); {
+				case op == 0 && len(keys) > 0:
+					k := keys[r.IntN(len(keys))]
+					cmd = fmt.Sprintf("delete from T where k = %d", k)
+					delete(pending, k)
+				case op == 1 && len(keys) > 0:
+					k := keys[r.IntN(len(keys))]
+					v := fmt.Sprintf("upd%d", r.IntN(1000))
+					cmd = fmt.Sprintf("update T set v = '%s' where k = %d", v, k)
+					pending[k] = v
+				default:
+					k := nextKey
+					nextKey++
+					v := fmt.Sprintf("ins%d", k)
+					cmd = fmt.Sprintf("insert into T(k, v) values(%d, '%s')", k, v)
+					pending[k] = v
+				}
+				if _, err := planner.ExecuteUpdate(cmd, tx); err != nil {
+					break workload
+				}
+			}
+
+			// the last transaction of a round that has not crashed yet
+			// is left open, so it is killed without committing
+			if i == maxTxs-1 {
+				break
+			}
+			if r.IntN(4) == 0 {
+				if err := tx.Rollback(); err != nil {
+					break
+				}
+				continue
+			}
+			if err := tx.Commit(); err != nil {
+				inDoubt = pending
+				break
+			}
+			committed = pending
+		}
+
+		t.Logf("round %d: crashed=%v, committed records=%d", round, injector.crashed, len(committed))
+
+		db, err = server.NewSimpleDBWithMetadata(dir)
+		if err != nil {
+			t.Fatalf("round %d: failed to reopen database: %v", round, err)
+		}
+		actual := readAll(t, db)
+		switch {
+		case maps.Equal(actual, committed):
+		case inDoubt != nil && maps.Equal(actual, inDoubt):
+			committed = inDoubt
+		default:
+			t.Fatalf("round %d: recovered state %v does not match committed state %v", round, actual, committed)
+		}
+	}
+}
+
+func keysOf(m map[int32]string) []int32 {
+	keys := make([]int32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	// map iteration order is random, so sort to keep the workload reproducible
+	slices.Sort(keys)
+	return keys
+}
+
+func readAll(t *testing.T, db *server.SimpleDB) map[int32]string {
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	p, err := db.Planner().CreateQueryPlan("select k, v from T", tx)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	s, err := p.Open()
+	if err != nil {
+		t.Fatalf("failed to open scan: %v", err)
+	}
+	result := make(map[int32]string)
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next record: %v", err)
+		}
+		if !next {
+			break
+		}
+		k, err := s.GetInt("k")
+		if err != nil {
+			t.Fatalf("failed to get int: %v", err)
+		}
+		v, err := s.GetString("v")
+		if err != nil {
+			t.Fatalf("failed to get string: %v", err)
+		}
+		result[k] = v
+	}
+	s.Close()
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	return result
+}
@@ -15,11 +15,16 @@ const (
 	ROLLBACK
 	SETINT
 	SETSTRING
+	SAVEPOINT
+	PREPARE
+	SETLONG
+	SETBYTES
+	SETDOUBLE
 )
 
 type LogRecord interface {
 	Op() LogRecordType
-	TxNumber() int32
+	TxNumber() int64
 	Undo(tx Transaction) error
 }
 
@@ -27,7 +32,7 @@ func NewLogRecord(bytes []byte) (LogRecord, error) {
 	p := file.NewPageFromBytes(bytes)
 	switch LogRecordType(p.GetInt(0)) {
 	case CHECKPOINT:
-		return NewCheckpointRecord(), nil
+		return NewCheckpointRecordFrom(p), nil
 	case START:
 		return NewStartRecordFrom(p), nil
 	case COMMIT:
@@ -38,6 +43,16 @@ func NewLogRecord(bytes []byte) (LogRecord, error) {
 		return NewSetIntRecordFrom(p), nil
 	case SETSTRING:
 		return NewSetStringRecordFrom(p), nil
+	case SAVEPOINT:
+		return NewSavepointRecordFrom(p), nil
+	case PREPARE:
+		return NewPrepareRecordFrom(p), nil
+	case SETLONG:
+		return NewSetLongRecordFrom(p), nil
+	case SETBYTES:
+		return NewSetBytesRecordFrom(p), nil
+	case SETDOUBLE:
+		return NewSetDoubleRecordFrom(p), nil
 	default:
 		return nil, fmt.Errorf("invalid log record type %v", p.GetInt(0))
 	}
@@ -0,0 +1,60 @@
+package recovery
+
+import (
+	"fmt"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/log"
+)
+
+type PrepareRecord struct {
+	txnum    int64
+	globalID string
+}
+
+func NewPrepareRecord(txnum int64, globalID string) *PrepareRecord {
+	return &PrepareRecord{
+		txnum:    txnum,
+		globalID: globalID,
+	}
+}
+
+func NewPrepareRecordFrom(p *file.Page) *PrepareRecord {
+	tpos := file.Int32Bytes
+	gpos := tpos + file.Int64Bytes
+	return &PrepareRecord{
+		txnum:    p.GetLong(tpos),
+		globalID: p.GetString(gpos),
+	}
+}
+
+func (r *PrepareRecord) Op() LogRecordType {
+	return PREPARE
+}
+
+func (r *PrepareRecord) TxNumber() int64 {
+	return r.txnum
+}
+
+func (r *PrepareRecord) GlobalID() string {
+	return r.globalID
+}
+
+func (r *PrepareRecord) Undo(tx Transaction) error {
+	return nil
+}
+
+func (r *PrepareRecord) String() string {
+	return fmt.Sprintf("<PREPARE %d %s>", r.txnum, r.globalID)
+}
+
+func (r *PrepareRecord) WriteToLog(lm *log.LogManager) (int32, error) {
+	tpos := file.Int32Bytes
+	gpos := tpos + file.Int64Bytes
+	rec := make([]byte, gpos+file.MaxLength(int32(len(r.globalID))))
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, int32(PREPARE))
+	p.SetLong(tpos, r.txnum)
+	p.SetString(gpos, r.globalID)
+	return lm.Append(rec)
+}
@@ -0,0 +1,150 @@
+package recovery_test
+
+import (
+	"errors"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/adieumonks/simple-db/server"
+	"github.com/adieumonks/simple-db/tx"
+)
+
+func TestPreparedTransactions(t *testing.T) {
+	dir := path.Join(t.TempDir(), "preparetest")
+	db, err := server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+
+	setup, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	for _, cmd := range []string{
+		"create table T1(k int)",
+		"create table T2(k int)",
+		"create table T3(k int)",
+		"insert into T1(k) values(10)",
+		"insert into T2(k) values(20)",
+		"insert into T3(k) values(30)",
+	} {
+		if _, err := db.Planner().ExecuteUpdate(cmd, setup); err != nil {
+			t.Fatalf("failed to execute update: %v", err)
+		}
+	}
+	if err := setup.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	// each transaction writes its own table, so none of them waits for another
+	tx1 := executeInNewTransaction(t, db, "insert into T1(k) values(11)")
+	if err := tx1.Prepare("g1"); err != nil {
+		t.Fatalf("failed to prepare transaction: %v", err)
+	}
+	tx2 := executeInNewTransaction(t, db, "insert into T2(k) values(21)")
+	if err := tx2.Prepare("g2"); err != nil {
+		t.Fatalf("failed to prepare transaction: %v", err)
+	}
+	executeInNewTransaction(t, db, "insert into T3(k) values(31)")
+
+	if err := tx2.Prepare("g3"); !errors.Is(err, tx.ErrPrepared) {
+		t.Errorf("expected %v, got %v", tx.ErrPrepared, err)
+	}
+	if _, err := db.Planner().ExecuteUpdate("insert into T2(k) values(22)", tx2); !errors.Is(err, tx.ErrPrepared) {
+		t.Errorf("expected %v, got %v", tx.ErrPrepared, err)
+	}
+
+	// reopening twice checks that the in-doubt transactions outlive a checkpoint
+	for restart := range 2 {
+		db, err = server.NewSimpleDBWithMetadata(dir)
+		if err != nil {
+			t.Fatalf("restart %d: failed to reopen database: %v", restart, err)
+		}
+		if inDoubt := db.InDoubtTransactions(); !slices.Equal(inDoubt, []string{"g1", "g2"}) {
+			t.Fatalf("restart %d: expected in-doubt transactions [g1 g2], got %v", restart, inDoubt)
+		}
+		ro := db.NewReadOnlyTransaction()
+		assertKeys(t, db, ro, "T1", []int32{10})
+		assertKeys(t, db, ro, "T2", []int32{20})
+		assertKeys(t, db, ro, "T3", []int32{30})
+		if err := ro.Commit(); err != nil {
+			t.Fatalf("failed to commit transaction: %v", err)
+		}
+	}
+
+	if err := db.CommitPrepared("g1"); err != nil {
+		t.Fatalf("failed to commit prepared transaction: %v", err)
+	}
+	if err := db.RollbackPrepared("g2"); err != nil {
+		t.Fatalf("failed to rollback prepared transaction: %v", err)
+	}
+	if err := db.CommitPrepared("g2"); err == nil {
+		t.Errorf("expected resolved transaction g2 to be gone")
+	}
+	if inDoubt := db.InDoubtTransactions(); len(inDoubt) != 0 {
+		t.Errorf("expected no in-doubt transactions, got %v", inDoubt)
+	}
+
+	db, err = server.NewSimpleDBWithMetadata(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	if inDoubt := db.InDoubtTransactions(); len(inDoubt) != 0 {
+		t.Errorf("expected no in-doubt transactions, got %v", inDoubt)
+	}
+	check, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	assertKeys(t, db, check, "T1", []int32{10, 11})
+	assertKeys(t, db, check, "T2", []int32{20})
+	assertKeys(t, db, check, "T3", []int32{30})
+	if err := check.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+func executeInNewTransaction(t *testing.T, db *server.SimpleDB, cmd string) *tx.Transaction {
+	t.Helper()
+	tx, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := db.Planner().ExecuteUpdate(cmd, tx); err != nil {
+		t.Fatalf("failed to execute update: %v", err)
+	}
+	return tx
+}
+
+func assertKeys(t *testing.T, db *server.SimpleDB, tx *tx.Transaction, table string, expected []int32) {
+	t.Helper()
+	p, err := db.Planner().CreateQueryPlan("select k from "+table, tx)
+	if err != nil {
+		t.Fatalf("failed to create query plan: %v", err)
+	}
+	s, err := p.Open()
+	if err != nil {
+		t.Fatalf("failed to open scan: %v", err)
+	}
+	defer s.Close()
+	keys := []int32{}
+	for {
+		next, err := s.Next()
+		if err != nil {
+			t.Fatalf("failed to get next record: %v", err)
+		}
+		if !next {
+			break
+		}
+		k, err := s.GetInt("k")
+		if err != nil {
+			t.Fatalf("failed to get int: %v", err)
+		}
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, expected) {
+		t.Errorf("%s: expected keys %v, got %v", table, expected, keys)
+	}
+}
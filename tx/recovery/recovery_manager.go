@@ -2,6 +2,8 @@ package recovery
 
 import (
 	"fmt"
+	"slices"
+	"sync/atomic"
 
 	"github.com/adieumonks/simple-db/buffer"
 	"github.com/adieumonks/simple-db/file"
@@ -13,37 +15,99 @@ type Transaction interface {
 	Unpin(block file.BlockID)
 	SetInt(block file.BlockID, offset int32, val int32, okToLog bool) error
 	SetString(block file.BlockID, offset int32, val string, okToLog bool) error
+	SetLong(block file.BlockID, offset int32, val int64, okToLog bool) error
+	SetBytes(block file.BlockID, offset int32, val []byte, okToLog bool) error
+	SetDouble(block file.BlockID, offset int32, val float64, okToLog bool) error
 }
 
 type RecoveryManager struct {
-	lm    *log.LogManager
-	bm    *buffer.BufferManager
-	tx    Transaction
-	txnum int32
+	lm            *log.LogManager
+	bm            *buffer.BufferManager
+	tx            Transaction
+	txnum         int64
+	nextSavepoint int32
+	// the number of log records written by the transaction
+	logRecords atomic.Int64
 }
 
-func NewRecoveryManager(tx Transaction, txnum int32, lm *log.LogManager, bm *buffer.BufferManager) (*RecoveryManager, error) {
-	rm := RecoveryManager{
+func NewRecoveryManager(tx Transaction, txnum int64, lm *log.LogManager, bm *buffer.BufferManager) (*RecoveryManager, error) {
+	rm := &RecoveryManager{
 		lm:    lm,
 		bm:    bm,
 		tx:    tx,
 		txnum: txnum,
 	}
 
-	if _, err := NewStartRecord(txnum).WriteToLog(lm); err != nil {
+	if _, err := rm.logged(NewStartRecord(txnum).WriteToLog(lm)); err != nil {
 		return nil, err
 	}
 
-	return &rm, nil
+	return rm, nil
+}
+
+// NewPreparedRecoveryManager takes over the in-doubt transaction d found by recovery.
+func NewPreparedRecoveryManager(tx Transaction, d *InDoubtTx, lm *log.LogManager, bm *buffer.BufferManager) *RecoveryManager {
+	return &RecoveryManager{
+		lm:    lm,
+		bm:    bm,
+		tx:    tx,
+		txnum: d.txnum,
+	}
+}
+
+// InDoubtTx is a prepared transaction left unresolved by a crash,
+// along with the blocks it modified.
+type InDoubtTx struct {
+	txnum    int64
+	globalID string
+	blocks   []file.BlockID
+}
+
+func (d *InDoubtTx) TxNumber() int64 {
+	return d.txnum
+}
+
+func (d *InDoubtTx) GlobalID() string {
+	return d.globalID
+}
+
+func (d *InDoubtTx) Blocks() []file.BlockID {
+	return d.blocks
+}
+
+func (d *InDoubtTx) addBlock(block file.BlockID) {
+	if !slices.Contains(d.blocks, block) {
+		d.blocks = append(d.blocks, block)
+	}
 }
 
 func (rm *RecoveryManager) Commit() error {
-	rm.bm.FlushAll(rm.txnum)
-	lsn, err := NewCommitRecord(rm.txnum).WriteToLog(rm.lm)
+	if err := rm.bm.FlushAll(rm.txnum); err != nil {
+		return fmt.Errorf("failed to flush buffers: %w", err)
+	}
+	lsn, err := rm.logged(NewCommitRecord(rm.txnum).WriteToLog(rm.lm))
 	if err != nil {
 		return fmt.Errorf("failed to write commit record to log: %w", err)
 	}
-	rm.lm.Flush(lsn)
+	if err := rm.lm.Flush(lsn); err != nil {
+		return fmt.Errorf("failed to flush commit record: %w", err)
+	}
+	return nil
+}
+
+// Prepare makes the changes of the transaction durable and records that it
+// can no longer abort on its own, so that recovery keeps it in doubt.
+func (rm *RecoveryManager) Prepare(globalID string) error {
+	if err := rm.bm.FlushAll(rm.txnum); err != nil {
+		return fmt.Errorf("failed to flush buffers: %w", err)
+	}
+	lsn, err := rm.logged(NewPrepareRecord(rm.txnum, globalID).WriteToLog(rm.lm))
+	if err != nil {
+		return fmt.Errorf("failed to write prepare record to log: %w", err)
+	}
+	if err := rm.lm.Flush(lsn); err != nil {
+		return fmt.Errorf("failed to flush prepare record: %w", err)
+	}
 	return nil
 }
 
@@ -53,39 +117,101 @@ func (rm *RecoveryManager) RollBack() error {
 		return fmt.Errorf("failed to rollback: %w", err)
 	}
 
-	rm.bm.FlushAll(rm.txnum)
-	lsn, err := NewRollbackRecord(rm.txnum).WriteToLog(rm.lm)
+	if err := rm.bm.FlushAll(rm.txnum); err != nil {
+		return fmt.Errorf("failed to flush buffers: %w", err)
+	}
+	lsn, err := rm.logged(NewRollbackRecord(rm.txnum).WriteToLog(rm.lm))
 	if err != nil {
 		return fmt.Errorf("failed to write rollback record to log: %w", err)
 	}
-	rm.lm.Flush(lsn)
+	if err := rm.lm.Flush(lsn); err != nil {
+		return fmt.Errorf("failed to flush rollback record: %w", err)
+	}
 	return nil
 }
 
-func (rm *RecoveryManager) Recover() error {
-	err := rm.doRecover()
+// Recover undoes unfinished transactions other than prepared ones, which it
+// returns as in doubt, and writes a checkpoint recording lastTxNum, the
+// highest transaction number handed out so far.
+func (rm *RecoveryManager) Recover(lastTxNum int64) ([]*InDoubtTx, error) {
+	inDoubt, err := rm.doRecover()
 	if err != nil {
-		return fmt.Errorf("failed to recover: %w", err)
+		return nil, fmt.Errorf("failed to recover: %w", err)
+	}
+	if err := rm.bm.FlushAll(rm.txnum); err != nil {
+		return nil, fmt.Errorf("failed to flush buffers: %w", err)
+	}
+	txnums := make([]int64, len(inDoubt))
+	for i, d := range inDoubt {
+		txnums[i] = d.txnum
 	}
-	rm.bm.FlushAll(rm.txnum)
-	lsn, error := NewCheckpointRecord().WriteToLog(rm.lm)
+	lsn, error := rm.logged(NewCheckpointRecord(lastTxNum, txnums).WriteToLog(rm.lm))
 	if error != nil {
-		return fmt.Errorf("failed to write checkpoint record to log: %w", error)
+		return nil, fmt.Errorf("failed to write checkpoint record to log: %w", error)
+	}
+	if err := rm.lm.Flush(lsn); err != nil {
+		return nil, fmt.Errorf("failed to flush checkpoint record: %w", err)
+	}
+	return inDoubt, nil
+}
+
+func (rm *RecoveryManager) Savepoint() (int32, error) {
+	rm.nextSavepoint++
+	id := rm.nextSavepoint
+	if _, err := rm.logged(NewSavepointRecord(rm.txnum, id).WriteToLog(rm.lm)); err != nil {
+		return 0, fmt.Errorf("failed to write savepoint record to log: %w", err)
+	}
+	return id, nil
+}
+
+func (rm *RecoveryManager) RollbackToSavepoint(id int32) error {
+	if err := rm.doRollBackToSavepoint(id); err != nil {
+		return fmt.Errorf("failed to rollback to savepoint %d: %w", id, err)
 	}
-	rm.lm.Flush(lsn)
 	return nil
 }
 
 func (rm *RecoveryManager) SetInt(buffer *buffer.Buffer, offset int32, newVal int32) (int32, error) {
 	oldVal := buffer.Contents().GetInt(offset)
 	block := buffer.Block()
-	return NewSetIntRecord(rm.txnum, block, offset, oldVal).WriteToLog(rm.lm)
+	return rm.logged(NewSetIntRecord(rm.txnum, block, offset, oldVal).WriteToLog(rm.lm))
 }
 
 func (rm *RecoveryManager) SetString(buffer *buffer.Buffer, offset int32, newVal string) (int32, error) {
 	oldVal := buffer.Contents().GetString(offset)
 	block := buffer.Block()
-	return NewSetStringRecord(rm.txnum, block, offset, oldVal).WriteToLog(rm.lm)
+	return rm.logged(NewSetStringRecord(rm.txnum, block, offset, oldVal).WriteToLog(rm.lm))
+}
+
+func (rm *RecoveryManager) SetLong(buffer *buffer.Buffer, offset int32, newVal int64) (int32, error) {
+	oldVal := buffer.Contents().GetLong(offset)
+	block := buffer.Block()
+	return rm.logged(NewSetLongRecord(rm.txnum, block, offset, oldVal).WriteToLog(rm.lm))
+}
+
+func (rm *RecoveryManager) SetBytes(buffer *buffer.Buffer, offset int32, newVal []byte) (int32, error) {
+	oldVal := buffer.Contents().GetRawBytes(offset, int32(len(newVal)))
+	block := buffer.Block()
+	return rm.logged(NewSetBytesRecord(rm.txnum, block, offset, oldVal).WriteToLog(rm.lm))
+}
+
+func (rm *RecoveryManager) SetDouble(buffer *buffer.Buffer, offset int32, newVal float64) (int32, error) {
+	oldVal := buffer.Contents().GetDouble(offset)
+	block := buffer.Block()
+	return rm.logged(NewSetDoubleRecord(rm.txnum, block, offset, oldVal).WriteToLog(rm.lm))
+}
+
+// LogRecords returns the number of log records the transaction has written.
+func (rm *RecoveryManager) LogRecords() int64 {
+	return rm.logRecords.Load()
+}
+
+// logged counts a log record once it has been written.
+func (rm *RecoveryManager) logged(lsn int32, err error) (int32, error) {
+	if err == nil {
+		rm.logRecords.Add(1)
+	}
+	return lsn, err
 }
 
 func (rm *RecoveryManager) doRollBack() error {
@@ -116,8 +242,7 @@ func (rm *RecoveryManager) doRollBack() error {
 	return nil
 }
 
-func (rm *RecoveryManager) doRecover() error {
-	finishedTxs := make(map[int32]bool)
+func (rm *RecoveryManager) doRollBackToSavepoint(id int32) error {
 	iter, err := rm.lm.Iterator()
 	if err != nil {
 		return fmt.Errorf("failed to get log iterator: %w", err)
@@ -132,16 +257,123 @@ func (rm *RecoveryManager) doRecover() error {
 		if err != nil {
 			return fmt.Errorf("failed to create log record: %w", err)
 		}
-		if rec.Op() == CHECKPOINT {
+		if rec.TxNumber() != rm.txnum {
+			continue
+		}
+		if rec.Op() == START {
+			return fmt.Errorf("savepoint %d not found", id)
+		}
+		if sp, ok := rec.(*SavepointRecord); ok && sp.ID() == id {
 			return nil
 		}
-		if rec.Op() == COMMIT || rec.Op() == ROLLBACK {
-			finishedTxs[rec.TxNumber()] = true
-		} else if !finishedTxs[rec.TxNumber()] {
+		if err := rec.Undo(rm.tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rm *RecoveryManager) doRecover() ([]*InDoubtTx, error) {
+	finishedTxs := make(map[int64]bool)
+	preparedTxs := make(map[int64]*InDoubtTx)
+	var inDoubt []*InDoubtTx
+	// past the checkpoint, only the transactions it lists as in doubt
+	// are of interest, until their start records are reached
+	var pastCheckpoint map[int64]bool
+	iter, err := rm.lm.Iterator()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log iterator: %w", err)
+	}
+
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		rec, err := NewLogRecord(bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create log record: %w", err)
+		}
+		txnum := rec.TxNumber()
+		if pastCheckpoint != nil && !pastCheckpoint[txnum] {
+			continue
+		}
+		switch r := rec.(type) {
+		case *CheckPointRecord:
+			pastCheckpoint = make(map[int64]bool)
+			for _, txnum := range r.InDoubt() {
+				if !finishedTxs[txnum] {
+					pastCheckpoint[txnum] = true
+				}
+			}
+			if len(pastCheckpoint) == 0 {
+				return inDoubt, nil
+			}
+		case *CommitRecord, *RollbackRecord:
+			finishedTxs[txnum] = true
+		case *PrepareRecord:
+			if !finishedTxs[txnum] {
+				d := &InDoubtTx{txnum: txnum, globalID: r.GlobalID()}
+				preparedTxs[txnum] = d
+				inDoubt = append(inDoubt, d)
+			}
+		case *StartRecord:
+			if pastCheckpoint != nil {
+				delete(pastCheckpoint, txnum)
+				if len(pastCheckpoint) == 0 {
+					return inDoubt, nil
+				}
+			}
+		default:
+			if finishedTxs[txnum] {
+				continue
+			}
+			if d, ok := preparedTxs[txnum]; ok {
+				switch r := rec.(type) {
+				case *SetIntRecord:
+					d.addBlock(r.block)
+				case *SetStringRecord:
+					d.addBlock(r.block)
+				case *SetLongRecord:
+					d.addBlock(r.block)
+				case *SetBytesRecord:
+					d.addBlock(r.block)
+				case *SetDoubleRecord:
+					d.addBlock(r.block)
+				}
+				continue
+			}
 			if err := rec.Undo(rm.tx); err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
-	return nil
+	return inDoubt, nil
+}
+
+// LastTxNumber returns the highest transaction number recorded in the log.
+// It reads back only as far as the last checkpoint, which records the
+// highest number handed out before it was written.
+func LastTxNumber(lm *log.LogManager) (int64, error) {
+	var last int64
+	iter, err := lm.Iterator()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get log iterator: %w", err)
+	}
+
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		rec, err := NewLogRecord(bytes)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create log record: %w", err)
+		}
+		if cp, ok := rec.(*CheckPointRecord); ok {
+			return max(last, cp.LastTxNumber()), nil
+		}
+		last = max(last, rec.TxNumber())
+	}
+	return last, nil
 }
@@ -8,10 +8,10 @@ import (
 )
 
 type RollbackRecord struct {
-	txnum int32
+	txnum int64
 }
 
-func NewRollbackRecord(txnum int32) *RollbackRecord {
+func NewRollbackRecord(txnum int64) *RollbackRecord {
 	return &RollbackRecord{
 		txnum: txnum,
 	}
@@ -20,7 +20,7 @@ func NewRollbackRecord(txnum int32) *RollbackRecord {
 func NewRollbackRecordFrom(p *file.Page) *RollbackRecord {
 	tpos := file.Int32Bytes
 	return &RollbackRecord{
-		txnum: p.GetInt(tpos),
+		txnum: p.GetLong(tpos),
 	}
 }
 
@@ -28,7 +28,7 @@ func (r *RollbackRecord) Op() LogRecordType {
 	return ROLLBACK
 }
 
-func (r *RollbackRecord) TxNumber() int32 {
+func (r *RollbackRecord) TxNumber() int64 {
 	return r.txnum
 }
 
@@ -41,9 +41,9 @@ func (r *RollbackRecord) String() string {
 }
 
 func (r *RollbackRecord) WriteToLog(lm *log.LogManager) (int32, error) {
-	rec := make([]byte, 2*file.Int32Bytes)
+	rec := make([]byte, file.Int32Bytes+file.Int64Bytes)
 	p := file.NewPageFromBytes(rec)
 	p.SetInt(0, int32(ROLLBACK))
-	p.SetInt(file.Int32Bytes, r.txnum)
+	p.SetLong(file.Int32Bytes, r.txnum)
 	return lm.Append(rec)
 }
@@ -0,0 +1,60 @@
+package recovery
+
+import (
+	"fmt"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/log"
+)
+
+type SavepointRecord struct {
+	txnum int64
+	id    int32
+}
+
+func NewSavepointRecord(txnum int64, id int32) *SavepointRecord {
+	return &SavepointRecord{
+		txnum: txnum,
+		id:    id,
+	}
+}
+
+func NewSavepointRecordFrom(p *file.Page) *SavepointRecord {
+	tpos := file.Int32Bytes
+	ipos := tpos + file.Int64Bytes
+	return &SavepointRecord{
+		txnum: p.GetLong(tpos),
+		id:    p.GetInt(ipos),
+	}
+}
+
+func (r *SavepointRecord) Op() LogRecordType {
+	return SAVEPOINT
+}
+
+func (r *SavepointRecord) TxNumber() int64 {
+	return r.txnum
+}
+
+func (r *SavepointRecord) ID() int32 {
+	return r.id
+}
+
+func (r *SavepointRecord) Undo(tx Transaction) error {
+	return nil
+}
+
+func (r *SavepointRecord) String() string {
+	return fmt.Sprintf("<SAVEPOINT %d %d>", r.txnum, r.id)
+}
+
+func (r *SavepointRecord) WriteToLog(lm *log.LogManager) (int32, error) {
+	tpos := file.Int32Bytes
+	ipos := tpos + file.Int64Bytes
+	rec := make([]byte, ipos+file.Int32Bytes)
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, int32(SAVEPOINT))
+	p.SetLong(tpos, r.txnum)
+	p.SetInt(ipos, r.id)
+	return lm.Append(rec)
+}
@@ -0,0 +1,89 @@
+package recovery
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/log"
+)
+
+// SetBytesRecord holds the bytes a change overwrote, however many of them
+// it wrote, where the old value at offset may not have had the same size.
+type SetBytesRecord struct {
+	txnum  int64
+	offset int32
+	val    []byte
+	block  file.BlockID
+}
+
+func NewSetBytesRecord(txnum int64, block file.BlockID, offset int32, val []byte) *SetBytesRecord {
+	return &SetBytesRecord{
+		txnum:  txnum,
+		offset: offset,
+		val:    val,
+		block:  block,
+	}
+}
+
+func NewSetBytesRecordFrom(p *file.Page) *SetBytesRecord {
+	tpos := file.Int32Bytes
+	txnum := p.GetLong(tpos)
+	fpos := tpos + file.Int64Bytes
+	filename := p.GetString(fpos)
+	bpos := fpos + file.MaxLength(int32(len(filename)))
+	blockNum := p.GetInt(bpos)
+	block := file.NewBlockID(filename, blockNum)
+	opos := bpos + file.Int32Bytes
+	offset := p.GetInt(opos)
+	vpos := opos + file.Int32Bytes
+	val := slices.Clone(p.GetBytes(vpos))
+
+	return &SetBytesRecord{
+		txnum:  txnum,
+		offset: offset,
+		val:    val,
+		block:  block,
+	}
+}
+
+func (r *SetBytesRecord) Op() LogRecordType {
+	return SETBYTES
+}
+
+func (r *SetBytesRecord) TxNumber() int64 {
+	return r.txnum
+}
+
+func (r *SetBytesRecord) Undo(tx Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	if err := tx.SetBytes(r.block, r.offset, r.val, false); err != nil {
+		return err
+	}
+	tx.Unpin(r.block)
+	return nil
+}
+
+func (r *SetBytesRecord) String() string {
+	return fmt.Sprintf("<SETBYTES %d %v %d %d>", r.txnum, r.block, r.offset, len(r.val))
+}
+
+func (r *SetBytesRecord) WriteToLog(lm *log.LogManager) (int32, error) {
+	tpos := file.Int32Bytes
+	fpos := tpos + file.Int64Bytes
+	bpos := fpos + file.MaxLength(int32(len(r.block.Filename())))
+	opos := bpos + file.Int32Bytes
+	vpos := opos + file.Int32Bytes
+
+	rec := make([]byte, vpos+file.Int32Bytes+int32(len(r.val)))
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, int32(SETBYTES))
+	p.SetLong(tpos, r.txnum)
+	p.SetString(fpos, r.block.Filename())
+	p.SetInt(bpos, r.block.Number())
+	p.SetInt(opos, r.offset)
+	p.SetBytes(vpos, r.val)
+	return lm.Append(rec)
+}
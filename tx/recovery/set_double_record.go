@@ -0,0 +1,86 @@
+package recovery
+
+import (
+	"fmt"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/log"
+)
+
+type SetDoubleRecord struct {
+	txnum  int64
+	offset int32
+	val    float64
+	block  file.BlockID
+}
+
+func NewSetDoubleRecord(txnum int64, block file.BlockID, offset int32, val float64) *SetDoubleRecord {
+	return &SetDoubleRecord{
+		txnum:  txnum,
+		offset: offset,
+		val:    val,
+		block:  block,
+	}
+}
+
+func NewSetDoubleRecordFrom(p *file.Page) *SetDoubleRecord {
+	tpos := file.Int32Bytes
+	txnum := p.GetLong(tpos)
+	fpos := tpos + file.Int64Bytes
+	filename := p.GetString(fpos)
+	bpos := fpos + file.MaxLength(int32(len(filename)))
+	blockNum := p.GetInt(bpos)
+	block := file.NewBlockID(filename, blockNum)
+	opos := bpos + file.Int32Bytes
+	offset := p.GetInt(opos)
+	vpos := opos + file.Int32Bytes
+	val := p.GetDouble(vpos)
+
+	return &SetDoubleRecord{
+		txnum:  txnum,
+		offset: offset,
+		val:    val,
+		block:  block,
+	}
+}
+
+func (r *SetDoubleRecord) Op() LogRecordType {
+	return SETDOUBLE
+}
+
+func (r *SetDoubleRecord) TxNumber() int64 {
+	return r.txnum
+}
+
+func (r *SetDoubleRecord) Undo(tx Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	if err := tx.SetDouble(r.block, r.offset, r.val, false); err != nil {
+		return err
+	}
+	tx.Unpin(r.block)
+	return nil
+}
+
+func (r *SetDoubleRecord) String() string {
+	return fmt.Sprintf("<SETDOUBLE %d %v %d %g>", r.txnum, r.block, r.offset, r.val)
+}
+
+func (r *SetDoubleRecord) WriteToLog(lm *log.LogManager) (int32, error) {
+	tpos := file.Int32Bytes
+	fpos := tpos + file.Int64Bytes
+	bpos := fpos + file.MaxLength(int32(len(r.block.Filename())))
+	opos := bpos + file.Int32Bytes
+	vpos := opos + file.Int32Bytes
+
+	rec := make([]byte, vpos+file.Int64Bytes)
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, int32(SETDOUBLE))
+	p.SetLong(tpos, r.txnum)
+	p.SetString(fpos, r.block.Filename())
+	p.SetInt(bpos, r.block.Number())
+	p.SetInt(opos, r.offset)
+	p.SetDouble(vpos, r.val)
+	return lm.Append(rec)
+}
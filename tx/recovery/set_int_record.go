@@ -8,13 +8,13 @@ import (
 )
 
 type SetIntRecord struct {
-	txnum  int32
+	txnum  int64
 	offset int32
 	val    int32
 	block  file.BlockID
 }
 
-func NewSetIntRecord(txnum int32, block file.BlockID, offset int32, val int32) *SetIntRecord {
+func NewSetIntRecord(txnum int64, block file.BlockID, offset int32, val int32) *SetIntRecord {
 	return &SetIntRecord{
 		txnum:  txnum,
 		offset: offset,
@@ -25,8 +25,8 @@ func NewSetIntRecord(txnum int32, block file.BlockID, offset int32, val int32) *
 
 func NewSetIntRecordFrom(p *file.Page) *SetIntRecord {
 	tpos := file.Int32Bytes
-	txnum := p.GetInt(tpos)
-	fpos := tpos + file.Int32Bytes
+	txnum := p.GetLong(tpos)
+	fpos := tpos + file.Int64Bytes
 	filename := p.GetString(fpos)
 	bpos := fpos + file.MaxLength(int32(len(filename)))
 	blockNum := p.GetInt(bpos)
@@ -48,7 +48,7 @@ func (r *SetIntRecord) Op() LogRecordType {
 	return SETINT
 }
 
-func (r *SetIntRecord) TxNumber() int32 {
+func (r *SetIntRecord) TxNumber() int64 {
 	return r.txnum
 }
 
@@ -69,7 +69,7 @@ func (r *SetIntRecord) String() string {
 
 func (r *SetIntRecord) WriteToLog(lm *log.LogManager) (int32, error) {
 	tpos := file.Int32Bytes
-	fpos := tpos + file.Int32Bytes
+	fpos := tpos + file.Int64Bytes
 	bpos := fpos + file.MaxLength(int32(len(r.block.Filename())))
 	opos := bpos + file.Int32Bytes
 	vpos := opos + file.Int32Bytes
@@ -77,7 +77,7 @@ func (r *SetIntRecord) WriteToLog(lm *log.LogManager) (int32, error) {
 	rec := make([]byte, vpos+file.Int32Bytes)
 	p := file.NewPageFromBytes(rec)
 	p.SetInt(0, int32(SETINT))
-	p.SetInt(tpos, r.txnum)
+	p.SetLong(tpos, r.txnum)
 	p.SetString(fpos, r.block.Filename())
 	p.SetInt(bpos, r.block.Number())
 	p.SetInt(opos, r.offset)
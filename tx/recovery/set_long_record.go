@@ -0,0 +1,86 @@
+package recovery
+
+import (
+	"fmt"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/log"
+)
+
+type SetLongRecord struct {
+	txnum  int64
+	offset int32
+	val    int64
+	block  file.BlockID
+}
+
+func NewSetLongRecord(txnum int64, block file.BlockID, offset int32, val int64) *SetLongRecord {
+	return &SetLongRecord{
+		txnum:  txnum,
+		offset: offset,
+		val:    val,
+		block:  block,
+	}
+}
+
+func NewSetLongRecordFrom(p *file.Page) *SetLongRecord {
+	tpos := file.Int32Bytes
+	txnum := p.GetLong(tpos)
+	fpos := tpos + file.Int64Bytes
+	filename := p.GetString(fpos)
+	bpos := fpos + file.MaxLength(int32(len(filename)))
+	blockNum := p.GetInt(bpos)
+	block := file.NewBlockID(filename, blockNum)
+	opos := bpos + file.Int32Bytes
+	offset := p.GetInt(opos)
+	vpos := opos + file.Int32Bytes
+	val := p.GetLong(vpos)
+
+	return &SetLongRecord{
+		txnum:  txnum,
+		offset: offset,
+		val:    val,
+		block:  block,
+	}
+}
+
+func (r *SetLongRecord) Op() LogRecordType {
+	return SETLONG
+}
+
+func (r *SetLongRecord) TxNumber() int64 {
+	return r.txnum
+}
+
+func (r *SetLongRecord) Undo(tx Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	if err := tx.SetLong(r.block, r.offset, r.val, false); err != nil {
+		return err
+	}
+	tx.Unpin(r.block)
+	return nil
+}
+
+func (r *SetLongRecord) String() string {
+	return fmt.Sprintf("<SETLONG %d %v %d %d>", r.txnum, r.block, r.offset, r.val)
+}
+
+func (r *SetLongRecord) WriteToLog(lm *log.LogManager) (int32, error) {
+	tpos := file.Int32Bytes
+	fpos := tpos + file.Int64Bytes
+	bpos := fpos + file.MaxLength(int32(len(r.block.Filename())))
+	opos := bpos + file.Int32Bytes
+	vpos := opos + file.Int32Bytes
+
+	rec := make([]byte, vpos+file.Int64Bytes)
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, int32(SETLONG))
+	p.SetLong(tpos, r.txnum)
+	p.SetString(fpos, r.block.Filename())
+	p.SetInt(bpos, r.block.Number())
+	p.SetInt(opos, r.offset)
+	p.SetLong(vpos, r.val)
+	return lm.Append(rec)
+}
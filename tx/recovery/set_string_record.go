@@ -8,13 +8,13 @@ import (
 )
 
 type SetStringRecord struct {
-	txnum  int32
+	txnum  int64
 	offset int32
 	val    string
 	block  file.BlockID
 }
 
-func NewSetStringRecord(txnum int32, block file.BlockID, offset int32, val string) *SetStringRecord {
+func NewSetStringRecord(txnum int64, block file.BlockID, offset int32, val string) *SetStringRecord {
 	return &SetStringRecord{
 		txnum:  txnum,
 		offset: offset,
@@ -25,8 +25,8 @@ func NewSetStringRecord(txnum int32, block file.BlockID, offset int32, val strin
 
 func NewSetStringRecordFrom(p *file.Page) *SetStringRecord {
 	tpos := file.Int32Bytes
-	txnum := p.GetInt(tpos)
-	fpos := tpos + file.Int32Bytes
+	txnum := p.GetLong(tpos)
+	fpos := tpos + file.Int64Bytes
 	filename := p.GetString(fpos)
 	bpos := fpos + file.MaxLength(int32(len(filename)))
 	blockNum := p.GetInt(bpos)
@@ -48,7 +48,7 @@ func (r *SetStringRecord) Op() LogRecordType {
 	return SETSTRING
 }
 
-func (r *SetStringRecord) TxNumber() int32 {
+func (r *SetStringRecord) TxNumber() int64 {
 	return r.txnum
 }
 
@@ -69,7 +69,7 @@ func (r *SetStringRecord) String() string {
 
 func (r *SetStringRecord) WriteToLog(lm *log.LogManager) (int32, error) {
 	tpos := file.Int32Bytes
-	fpos := tpos + file.Int32Bytes
+	fpos := tpos + file.Int64Bytes
 	bpos := fpos + file.MaxLength(int32(len(r.block.Filename())))
 	opos := bpos + file.Int32Bytes
 	vpos := opos + file.Int32Bytes
@@ -78,7 +78,7 @@ func (r *SetStringRecord) WriteToLog(lm *log.LogManager) (int32, error) {
 	rec := make([]byte, recLength)
 	p := file.NewPageFromBytes(rec)
 	p.SetInt(0, int32(SETSTRING))
-	p.SetInt(tpos, r.txnum)
+	p.SetLong(tpos, r.txnum)
 	p.SetString(fpos, r.block.Filename())
 	p.SetInt(bpos, r.block.Number())
 	p.SetInt(opos, r.offset)
@@ -0,0 +1,103 @@
+package recovery
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/adieumonks/simple-db/file"
+	"github.com/adieumonks/simple-db/log"
+)
+
+// Snapshot identifies the transactions whose changes a read-only transaction
+// sees: those numbered up to lastTxNum that had finished when it was taken.
+type Snapshot struct {
+	lastTxNum int64
+	active    map[int64]bool
+	// the undo images of the changes made by transactions outside the
+	// snapshot, newest first for each block, as found in the log up to
+	// scannedLSN
+	undo       map[file.BlockID][]func(p *file.Page)
+	scanned    bool
+	scannedLSN int32
+}
+
+func NewSnapshot(lastTxNum int64, active map[int64]bool) *Snapshot {
+	return &Snapshot{
+		lastTxNum: lastTxNum,
+		active:    maps.Clone(active),
+		undo:      make(map[file.BlockID][]func(p *file.Page)),
+	}
+}
+
+func (s *Snapshot) IsVisible(txnum int64) bool {
+	return txnum <= s.lastTxNum && !s.active[txnum]
+}
+
+// Rewind undoes the changes to the contents of block made by transactions
+// outside the snapshot, turning p into the block as the snapshot sees it.
+func (s *Snapshot) Rewind(lm *log.LogManager, block file.BlockID, p *file.Page) error {
+	if err := s.scan(lm); err != nil {
+		return err
+	}
+	for _, undo := range s.undo[block] {
+		undo(p)
+	}
+	return nil
+}
+
+// scan collects the undo images of all blocks from the log. The first scan
+// reads back to the oldest start among the active transactions; later ones
+// only read the records logged since the one before.
+func (s *Snapshot) scan(lm *log.LogManager) error {
+	iter, lsn, err := lm.IteratorWithLSN()
+	if err != nil {
+		return fmt.Errorf("failed to get log iterator: %w", err)
+	}
+	latestLSN := lsn
+
+	// records of transactions outside the snapshot all follow either the
+	// start of an active transaction or the last record of a visible one
+	unstarted := len(s.active)
+	seenVisible := false
+	newer := make(map[file.BlockID][]func(p *file.Page))
+	for iter.HasNext() && (!s.scanned || lsn > s.scannedLSN) {
+		bytes, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		lsn--
+		rec, err := NewLogRecord(bytes)
+		if err != nil {
+			return fmt.Errorf("failed to create log record: %w", err)
+		}
+		if s.IsVisible(rec.TxNumber()) {
+			seenVisible = true
+		} else {
+			switch r := rec.(type) {
+			case *StartRecord:
+				if s.active[r.txnum] {
+					unstarted--
+				}
+			case *SetIntRecord:
+				newer[r.block] = append(newer[r.block], func(p *file.Page) { p.SetInt(r.offset, r.val) })
+			case *SetStringRecord:
+				newer[r.block] = append(newer[r.block], func(p *file.Page) { p.SetString(r.offset, r.val) })
+			case *SetLongRecord:
+				newer[r.block] = append(newer[r.block], func(p *file.Page) { p.SetLong(r.offset, r.val) })
+			case *SetBytesRecord:
+				newer[r.block] = append(newer[r.block], func(p *file.Page) { p.SetRawBytes(r.offset, r.val) })
+			case *SetDoubleRecord:
+				newer[r.block] = append(newer[r.block], func(p *file.Page) { p.SetDouble(r.offset, r.val) })
+			}
+		}
+		if !s.scanned && unstarted == 0 && seenVisible {
+			break
+		}
+	}
+	for block, undos := range newer {
+		s.undo[block] = append(undos, s.undo[block]...)
+	}
+	s.scanned = true
+	s.scannedLSN = latestLSN
+	return nil
+}
@@ -8,10 +8,10 @@ import (
 )
 
 type StartRecord struct {
-	txnum int32
+	txnum int64
 }
 
-func NewStartRecord(txnum int32) *StartRecord {
+func NewStartRecord(txnum int64) *StartRecord {
 	return &StartRecord{
 		txnum: txnum,
 	}
@@ -20,7 +20,7 @@ func NewStartRecord(txnum int32) *StartRecord {
 func NewStartRecordFrom(p *file.Page) *StartRecord {
 	tpos := file.Int32Bytes
 	return &StartRecord{
-		txnum: p.GetInt(tpos),
+		txnum: p.GetLong(tpos),
 	}
 }
 
@@ -28,7 +28,7 @@ func (r *StartRecord) Op() LogRecordType {
 	return START
 }
 
-func (r *StartRecord) TxNumber() int32 {
+func (r *StartRecord) TxNumber() int64 {
 	return r.txnum
 }
 
@@ -41,9 +41,9 @@ func (r *StartRecord) String() string {
 }
 
 func (r *StartRecord) WriteToLog(lm *log.LogManager) (int32, error) {
-	rec := make([]byte, 2*file.Int32Bytes)
+	rec := make([]byte, file.Int32Bytes+file.Int64Bytes)
 	p := file.NewPageFromBytes(rec)
 	p.SetInt(0, int32(START))
-	p.SetInt(file.Int32Bytes, r.txnum)
+	p.SetLong(file.Int32Bytes, r.txnum)
 	return lm.Append(rec)
 }
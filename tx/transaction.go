@@ -1,8 +1,10 @@
 package tx
 
 import (
+	"errors"
 	"fmt"
-	"sync"
+	"slices"
+	"time"
 
 	"github.com/adieumonks/simple-db/buffer"
 	"github.com/adieumonks/simple-db/file"
@@ -14,8 +16,11 @@ import (
 const END_OF_FILE = -1
 
 var (
-	mu        sync.Mutex
-	nextTxNum int32 = 0
+	ErrReadOnly = errors.New("transaction is read-only")
+	ErrPrepared = errors.New("transaction is prepared")
+	// ErrStatementStarted is returned when setting the isolation level of a
+	// transaction that has already run a statement
+	ErrStatementStarted = errors.New("transaction has started a statement")
 )
 
 type Transaction struct {
@@ -23,87 +28,345 @@ type Transaction struct {
 	cm        *concurrency.ConcurrencyManager
 	bm        *buffer.BufferManager
 	fm        *file.FileManager
-	txnum     int32
+	lm        *log.LogManager
+	lt        *concurrency.LockTable
+	txnum     int64
+	registry  *TxRegistry
 	myBuffers *BufferList
+	// set once the transaction is prepared for two-phase commit
+	globalID string
+	// the transactions whose changes this one sees; a read-only transaction
+	// reads pages rewound to its snapshot, and the others only read versioned
+	// files through it
+	snapshot      *recovery.Snapshot
+	snapshotPages map[file.BlockID]*file.Page
+	readOnly      bool
+	// the files holding versioned records
+	versioned map[string]bool
+	// the files whose records are locked one by one
+	recordLocked map[string]bool
+	// the files only read through indexes whose key locks cover the reads
+	keyLocked map[string]bool
+	isolation IsolationLevel
+	started   time.Time
+	// set once the transaction starts its first statement, or first reads
+	// or locks anything
+	statementStarted bool
+	// why the transaction rolled back by itself, once the lock table chose
+	// it to abort
+	abortErr error
+	// set while the transaction undoes its changes
+	rollingBack bool
+	// the files to delete once the transaction commits, and how many of
+	// them there were at each savepoint
+	deleteOnCommit   []string
+	savepointDeletes map[int32]int
 }
 
-func NewTransaction(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager) (*Transaction, error) {
-	txnum := nextTxNumber()
+func NewTransaction(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager, lt *concurrency.LockTable, registry *TxRegistry) (*Transaction, error) {
+	txnum, snapshot := registry.Next()
 	tx := &Transaction{
-		bm:        bm,
-		fm:        fm,
-		txnum:     txnum,
-		cm:        concurrency.NewConcurrencyManager(),
-		myBuffers: NewBufferList(bm),
+		bm:               bm,
+		fm:               fm,
+		lm:               lm,
+		lt:               lt,
+		txnum:            txnum,
+		registry:         registry,
+		cm:               concurrency.NewConcurrencyManager(lt, txnum),
+		myBuffers:        NewBufferList(bm),
+		snapshot:         snapshot,
+		snapshotPages:    make(map[file.BlockID]*file.Page),
+		versioned:        make(map[string]bool),
+		recordLocked:     make(map[string]bool),
+		keyLocked:        make(map[string]bool),
+		isolation:        SERIALIZABLE,
+		started:          time.Now(),
+		savepointDeletes: make(map[int32]int),
 	}
 
 	var err error
 	tx.rm, err = recovery.NewRecoveryManager(tx, txnum, lm, bm)
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(err, registry.Finish(txnum))
 	}
+	registry.register(tx)
 
 	return tx, nil
 }
 
+// resumePreparedTransaction takes over an in-doubt transaction found by recovery,
+// locking the blocks it modified until it is committed or rolled back.
+func resumePreparedTransaction(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager, lt *concurrency.LockTable, registry *TxRegistry, d *recovery.InDoubtTx) (*Transaction, error) {
+	tx := &Transaction{
+		bm:        bm,
+		fm:        fm,
+		lm:        lm,
+		lt:        lt,
+		txnum:     d.TxNumber(),
+		registry:  registry,
+		cm:        concurrency.NewConcurrencyManager(lt, d.TxNumber()),
+		myBuffers: NewBufferList(bm),
+		globalID:  d.GlobalID(),
+		// it is only ever committed or rolled back, so sees nothing of others
+		snapshot:         recovery.NewSnapshot(0, nil),
+		snapshotPages:    make(map[file.BlockID]*file.Page),
+		versioned:        make(map[string]bool),
+		recordLocked:     make(map[string]bool),
+		keyLocked:        make(map[string]bool),
+		isolation:        SERIALIZABLE,
+		started:          time.Now(),
+		savepointDeletes: make(map[int32]int),
+	}
+	tx.rm = recovery.NewPreparedRecoveryManager(tx, d, lm, bm)
+	for _, block := range d.Blocks() {
+		if err := tx.cm.XLock(block); err != nil {
+			return nil, fmt.Errorf("failed to resume prepared transaction %s: %w", d.GlobalID(), err)
+		}
+	}
+	registry.resume(tx)
+	return tx, nil
+}
+
+// NewReadOnlyTransaction creates a transaction that sees the state committed
+// when it started. It writes nothing to the log and takes no locks.
+func NewReadOnlyTransaction(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager, registry *TxRegistry) *Transaction {
+	txnum, snapshot := registry.NextReadOnly()
+	return &Transaction{
+		bm:            bm,
+		fm:            fm,
+		lm:            lm,
+		txnum:         txnum,
+		registry:      registry,
+		myBuffers:     NewBufferList(bm),
+		snapshot:      snapshot,
+		snapshotPages: make(map[file.BlockID]*file.Page),
+		readOnly:      true,
+		versioned:     make(map[string]bool),
+		recordLocked:  make(map[string]bool),
+		keyLocked:     make(map[string]bool),
+		isolation:     REPEATABLE_READ,
+		started:       time.Now(),
+	}
+}
+
 func (tx *Transaction) Commit() error {
+	if tx.IsReadOnly() {
+		return tx.finishReadOnly()
+	}
+	if err := tx.checkAbort(); err != nil {
+		return err
+	}
 	if err := tx.rm.Commit(); err != nil {
 		return err
 	}
-	tx.cm.Release()
 	tx.myBuffers.UnpinAll()
+	// snapshots taken before the commit may still read the files, so they
+	// go once none is left
+	tx.registry.deleteLater(tx.txnum, tx.deleteOnCommit, tx.deleteFile)
+	tx.deleteOnCommit = nil
+	tx.cm.Release()
+	clear(tx.snapshotPages)
+	return tx.registry.Finish(tx.txnum)
+}
+
+// Prepare is the first phase of a two-phase commit under the given global id.
+// Once it returns, the transaction survives a crash, and can only be
+// committed or rolled back.
+func (tx *Transaction) Prepare(globalID string) error {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to prepare: %w", ErrReadOnly)
+	}
+	if tx.IsPrepared() {
+		return fmt.Errorf("failed to prepare: %w", ErrPrepared)
+	}
+	if err := tx.registry.addPrepared(globalID, tx); err != nil {
+		return fmt.Errorf("failed to prepare: %w", err)
+	}
+	if err := tx.rm.Prepare(globalID); err != nil {
+		tx.registry.removePrepared(globalID)
+		return fmt.Errorf("failed to prepare: %w", err)
+	}
+	tx.globalID = globalID
 	return nil
 }
 
 func (tx *Transaction) Rollback() error {
-	if err := tx.rm.RollBack(); err != nil {
+	if tx.IsReadOnly() {
+		return tx.finishReadOnly()
+	}
+	// rolled back already, and other transactions may have changed what it
+	// undid since
+	if tx.abortErr != nil {
+		return nil
+	}
+	tx.lt.StartRollback(tx.txnum)
+	tx.rollingBack = true
+	err := tx.rm.RollBack()
+	tx.rollingBack = false
+	if err != nil {
 		return err
 	}
+	tx.deleteOnCommit = nil
 	tx.cm.Release()
 	tx.myBuffers.UnpinAll()
+	clear(tx.snapshotPages)
+	return tx.registry.Finish(tx.txnum)
+}
+
+func (tx *Transaction) Savepoint() (int32, error) {
+	if tx.IsReadOnly() {
+		return 0, fmt.Errorf("failed to create savepoint: %w", ErrReadOnly)
+	}
+	if tx.IsPrepared() {
+		return 0, fmt.Errorf("failed to create savepoint: %w", ErrPrepared)
+	}
+	if err := tx.checkAbort(); err != nil {
+		return 0, err
+	}
+	id, err := tx.rm.Savepoint()
+	if err != nil {
+		return 0, err
+	}
+	tx.savepointDeletes[id] = len(tx.deleteOnCommit)
+	return id, nil
+}
+
+func (tx *Transaction) RollbackToSavepoint(savepoint int32) error {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to rollback to savepoint: %w", ErrReadOnly)
+	}
+	if tx.IsPrepared() {
+		return fmt.Errorf("failed to rollback to savepoint: %w", ErrPrepared)
+	}
+	if err := tx.checkAbort(); err != nil {
+		return err
+	}
+	tx.rollingBack = true
+	err := tx.rm.RollbackToSavepoint(savepoint)
+	tx.rollingBack = false
+	if err != nil {
+		return err
+	}
+	if n, ok := tx.savepointDeletes[savepoint]; ok {
+		tx.deleteOnCommit = tx.deleteOnCommit[:n]
+	}
 	return nil
 }
 
 func (tx *Transaction) Recover() error {
-	tx.bm.FlushAll(tx.txnum)
-	if err := tx.rm.Recover(); err != nil {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to recover: %w", ErrReadOnly)
+	}
+	if err := tx.bm.FlushAll(tx.txnum); err != nil {
+		return err
+	}
+	inDoubt, err := tx.rm.Recover(tx.registry.Last())
+	if err != nil {
 		return err
 	}
+	// the undone changes are on disk, so the locks taken to undo them
+	// can go before the in-doubt transactions take theirs back
+	tx.cm.Release()
+	for _, d := range inDoubt {
+		if _, err := resumePreparedTransaction(tx.fm, tx.lm, tx.bm, tx.lt, tx.registry, d); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (tx *Transaction) Pin(block file.BlockID) error {
+	if err := tx.checkAbort(); err != nil {
+		return err
+	}
+	tx.statementStarted = true
 	return tx.myBuffers.Pin(block)
 }
 
 func (tx *Transaction) Unpin(block file.BlockID) {
+	// rolling back unpinned everything
+	if tx.abortErr != nil {
+		return
+	}
 	tx.myBuffers.Unpin(block)
+	if tx.myBuffers.GetBuffer(block) == nil {
+		delete(tx.snapshotPages, block)
+		if !tx.IsReadOnly() && tx.isolation == READ_COMMITTED {
+			tx.cm.ReleaseSLock(block)
+		}
+	}
 }
 
 func (tx *Transaction) GetInt(block file.BlockID, offset int32) (int32, error) {
-	err := tx.cm.SLock(block)
+	var val int32
+	err := tx.read(block, func(p *file.Page) {
+		val = p.GetInt(offset)
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to get int: %w", err)
 	}
-	buffer := tx.myBuffers.GetBuffer(block)
-	return buffer.Contents().GetInt(offset), nil
+	return val, nil
 }
 
 func (tx *Transaction) GetString(block file.BlockID, offset int32) (string, error) {
-	err := tx.cm.SLock(block)
+	var val string
+	err := tx.read(block, func(p *file.Page) {
+		val = p.GetString(offset)
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to get string: %w", err)
 	}
-	buffer := tx.myBuffers.GetBuffer(block)
-	return buffer.Contents().GetString(offset), nil
+	return val, nil
 }
 
-func (tx *Transaction) SetInt(block file.BlockID, offset int32, val int32, okToLog bool) error {
-	err := tx.cm.XLock(block)
+func (tx *Transaction) GetLong(block file.BlockID, offset int32) (int64, error) {
+	var val int64
+	err := tx.read(block, func(p *file.Page) {
+		val = p.GetLong(offset)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get long: %w", err)
+	}
+	return val, nil
+}
+
+func (tx *Transaction) GetDouble(block file.BlockID, offset int32) (float64, error) {
+	var val float64
+	err := tx.read(block, func(p *file.Page) {
+		val = p.GetDouble(offset)
+	})
 	if err != nil {
+		return 0, fmt.Errorf("failed to get double: %w", err)
+	}
+	return val, nil
+}
+
+// GetBytes returns the length bytes at offset, read as one.
+func (tx *Transaction) GetBytes(block file.BlockID, offset int32, length int32) ([]byte, error) {
+	var val []byte
+	err := tx.read(block, func(p *file.Page) {
+		val = p.GetRawBytes(offset, length)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bytes: %w", err)
+	}
+	return val, nil
+}
+
+func (tx *Transaction) SetInt(block file.BlockID, offset int32, val int32, okToLog bool) error {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to set int: %w", ErrReadOnly)
+	}
+	// rolling back a prepared transaction undoes its changes without logging
+	if okToLog && tx.IsPrepared() {
+		return fmt.Errorf("failed to set int: %w", ErrPrepared)
+	}
+	if err := tx.lockForWrite(block); err != nil {
 		return fmt.Errorf("failed to set int: %w", err)
 	}
 	buffer := tx.myBuffers.GetBuffer(block)
+	buffer.Lock()
+	defer buffer.Unlock()
 	var lsn int32 = -1
 	if okToLog {
 		var err error
@@ -119,11 +382,18 @@ func (tx *Transaction) SetInt(block file.BlockID, offset int32, val int32, okToL
 }
 
 func (tx *Transaction) SetString(block file.BlockID, offset int32, val string, okToLog bool) error {
-	err := tx.cm.XLock(block)
-	if err != nil {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to set string: %w", ErrReadOnly)
+	}
+	if okToLog && tx.IsPrepared() {
+		return fmt.Errorf("failed to set string: %w", ErrPrepared)
+	}
+	if err := tx.lockForWrite(block); err != nil {
 		return fmt.Errorf("failed to set string: %w", err)
 	}
 	buffer := tx.myBuffers.GetBuffer(block)
+	buffer.Lock()
+	defer buffer.Unlock()
 	var lsn int32 = -1
 	if okToLog {
 		var err error
@@ -138,7 +408,100 @@ func (tx *Transaction) SetString(block file.BlockID, offset int32, val string, o
 	return nil
 }
 
+func (tx *Transaction) SetLong(block file.BlockID, offset int32, val int64, okToLog bool) error {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to set long: %w", ErrReadOnly)
+	}
+	if okToLog && tx.IsPrepared() {
+		return fmt.Errorf("failed to set long: %w", ErrPrepared)
+	}
+	if err := tx.lockForWrite(block); err != nil {
+		return fmt.Errorf("failed to set long: %w", err)
+	}
+	buffer := tx.myBuffers.GetBuffer(block)
+	buffer.Lock()
+	defer buffer.Unlock()
+	var lsn int32 = -1
+	if okToLog {
+		var err error
+		lsn, err = tx.rm.SetLong(buffer, offset, val)
+		if err != nil {
+			return fmt.Errorf("failed to set long: %w", err)
+		}
+	}
+	p := buffer.Contents()
+	p.SetLong(offset, val)
+	buffer.SetModified(tx.txnum, lsn)
+	return nil
+}
+
+func (tx *Transaction) SetDouble(block file.BlockID, offset int32, val float64, okToLog bool) error {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to set double: %w", ErrReadOnly)
+	}
+	if okToLog && tx.IsPrepared() {
+		return fmt.Errorf("failed to set double: %w", ErrPrepared)
+	}
+	if err := tx.lockForWrite(block); err != nil {
+		return fmt.Errorf("failed to set double: %w", err)
+	}
+	buffer := tx.myBuffers.GetBuffer(block)
+	buffer.Lock()
+	defer buffer.Unlock()
+	var lsn int32 = -1
+	if okToLog {
+		var err error
+		lsn, err = tx.rm.SetDouble(buffer, offset, val)
+		if err != nil {
+			return fmt.Errorf("failed to set double: %w", err)
+		}
+	}
+	p := buffer.Contents()
+	p.SetDouble(offset, val)
+	buffer.SetModified(tx.txnum, lsn)
+	return nil
+}
+
+// SetBytes writes the bytes at offset, and logs the bytes they replace so
+// that the change can be undone whatever was there before.
+func (tx *Transaction) SetBytes(block file.BlockID, offset int32, val []byte, okToLog bool) error {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to set bytes: %w", ErrReadOnly)
+	}
+	if okToLog && tx.IsPrepared() {
+		return fmt.Errorf("failed to set bytes: %w", ErrPrepared)
+	}
+	if err := tx.lockForWrite(block); err != nil {
+		return fmt.Errorf("failed to set bytes: %w", err)
+	}
+	buffer := tx.myBuffers.GetBuffer(block)
+	buffer.Lock()
+	defer buffer.Unlock()
+	var lsn int32 = -1
+	if okToLog {
+		var err error
+		lsn, err = tx.rm.SetBytes(buffer, offset, val)
+		if err != nil {
+			return fmt.Errorf("failed to set bytes: %w", err)
+		}
+	}
+	p := buffer.Contents()
+	p.SetRawBytes(offset, val)
+	buffer.SetModified(tx.txnum, lsn)
+	return nil
+}
+
+// Size returns the number of blocks in the file. A serializable transaction
+// locks the size, so that no other transaction can append to the file
+// until this one finishes.
 func (tx *Transaction) Size(filename string) (int32, error) {
+	if err := tx.checkAbort(); err != nil {
+		return 0, err
+	}
+	tx.statementStarted = true
+	if tx.IsReadOnly() || tx.isolation != SERIALIZABLE || tx.keyLocked[filename] {
+		return tx.fm.Length(filename)
+	}
 	dummyBlock := file.NewBlockID(filename, END_OF_FILE)
 	err := tx.cm.SLock(dummyBlock)
 	if err != nil {
@@ -147,7 +510,26 @@ func (tx *Transaction) Size(filename string) (int32, error) {
 	return tx.fm.Length(filename)
 }
 
+// UnlockedSize returns the number of blocks in the file without locking
+// its end, so others may still append to it. A scan locks the end once it
+// reaches it.
+func (tx *Transaction) UnlockedSize(filename string) (int32, error) {
+	if err := tx.checkAbort(); err != nil {
+		return 0, err
+	}
+	return tx.fm.Length(filename)
+}
+
 func (tx *Transaction) Append(filename string) (file.BlockID, error) {
+	if tx.IsReadOnly() {
+		return file.NewBlockID("", 0), fmt.Errorf("failed to append: %w", ErrReadOnly)
+	}
+	if tx.IsPrepared() {
+		return file.NewBlockID("", 0), fmt.Errorf("failed to append: %w", ErrPrepared)
+	}
+	if err := tx.checkAbort(); err != nil {
+		return file.NewBlockID("", 0), err
+	}
 	dummyBlock := file.NewBlockID(filename, END_OF_FILE)
 	err := tx.cm.XLock(dummyBlock)
 	if err != nil {
@@ -156,6 +538,204 @@ func (tx *Transaction) Append(filename string) (file.BlockID, error) {
 	return tx.fm.Append(filename)
 }
 
+// DeleteOnCommit deletes the file once the transaction commits, keeping it
+// if the transaction rolls back. The caller holds an exclusive lock on it.
+// Snapshots taken before the commit still read the file, so it goes when
+// the last of them finishes.
+func (tx *Transaction) DeleteOnCommit(filename string) {
+	if !slices.Contains(tx.deleteOnCommit, filename) {
+		tx.deleteOnCommit = append(tx.deleteOnCommit, filename)
+	}
+}
+
+// IsDeletedOnCommit reports whether the file goes when the transaction
+// commits, or is still waiting to go after another one has committed.
+func (tx *Transaction) IsDeletedOnCommit(filename string) bool {
+	return slices.Contains(tx.deleteOnCommit, filename) || tx.registry.isDeletePending(filename)
+}
+
+func (tx *Transaction) deleteFile(filename string) error {
+	tx.bm.Discard(filename)
+	return tx.fm.Delete(filename)
+}
+
+// LockFile locks a whole file, such as the file of a table, until the
+// transaction ends. A read-only transaction needs no locks to read.
+func (tx *Transaction) LockFile(filename string, mode concurrency.LockMode) error {
+	if tx.IsReadOnly() {
+		if mode == concurrency.IS || mode == concurrency.S {
+			return nil
+		}
+		return fmt.Errorf("failed to lock file: %w", ErrReadOnly)
+	}
+	return tx.cm.LockFile(filename, mode)
+}
+
+// LockKey locks the range of keys of an index that ends at key, that is,
+// key and the gap before it, so that no other transaction can insert into
+// or delete from the range until this one finishes.
+func (tx *Transaction) LockKey(index string, key string, mode concurrency.LockMode) error {
+	tx.statementStarted = true
+	if tx.IsReadOnly() {
+		if mode == concurrency.S {
+			return nil
+		}
+		return fmt.Errorf("failed to lock key: %w", ErrReadOnly)
+	}
+	if mode == concurrency.S && tx.isolation == READ_COMMITTED {
+		return nil
+	}
+	return tx.cm.LockKey(index, key, mode)
+}
+
+// AwaitKey waits until no other transaction holds a lock on the range of
+// keys of an index that ends at key incompatible with mode.
+func (tx *Transaction) AwaitKey(index string, key string, mode concurrency.LockMode) error {
+	if tx.IsReadOnly() {
+		if mode == concurrency.S {
+			return nil
+		}
+		return fmt.Errorf("failed to wait for key: %w", ErrReadOnly)
+	}
+	return tx.cm.AwaitKey(index, key, mode)
+}
+
+// UseVersions tells the transaction that the file holds versioned records.
+// Their versions keep transactions apart, so the transaction reads the file
+// as of its snapshot, without locks, and only locks the blocks it updates.
+func (tx *Transaction) UseVersions(filename string) {
+	tx.versioned[filename] = true
+}
+
+// UseRecordLocks tells the transaction that the records of the file are
+// locked one by one, before they are read or written, rather than the
+// blocks holding them. The blocks are only latched while they are accessed.
+func (tx *Transaction) UseRecordLocks(filename string) {
+	tx.recordLocked[filename] = true
+}
+
+// UseKeyLocks tells the transaction that the file is only read through an
+// index whose key locks keep others from inserting what it reads, so its
+// end is not locked when its size is read.
+func (tx *Transaction) UseKeyLocks(filename string) {
+	tx.keyLocked[filename] = true
+}
+
+// LockRecord locks the record in the slot of a block until the transaction
+// finishes, or under READ COMMITTED, an S lock until the block is unpinned.
+func (tx *Transaction) LockRecord(block file.BlockID, slot int32, mode concurrency.LockMode) error {
+	if tx.IsReadOnly() {
+		if mode == concurrency.S {
+			return nil
+		}
+		return fmt.Errorf("failed to lock record: %w", ErrReadOnly)
+	}
+	return tx.cm.LockRecord(block, slot, mode)
+}
+
+// TryLockRecord locks the record in the slot of a block if it can do so
+// without waiting for another transaction, and reports whether it did.
+func (tx *Transaction) TryLockRecord(block file.BlockID, slot int32, mode concurrency.LockMode) (bool, error) {
+	if tx.IsReadOnly() {
+		if mode == concurrency.S {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to lock record: %w", ErrReadOnly)
+	}
+	return tx.cm.TryLockRecord(block, slot, mode), nil
+}
+
+// HoldsRecordLock reports whether the transaction may read the record in
+// the slot of a block through a lock it holds.
+func (tx *Transaction) HoldsRecordLock(block file.BlockID, slot int32) bool {
+	return !tx.IsReadOnly() && tx.cm.HasRecordLock(block, slot, concurrency.S)
+}
+
+// UnlockRecord gives up the lock on the record in the slot of a block
+// before the transaction finishes. The caller must not have changed the
+// record since it locked it.
+func (tx *Transaction) UnlockRecord(block file.BlockID, slot int32) {
+	if !tx.IsReadOnly() {
+		tx.cm.UnlockRecord(block, slot)
+	}
+}
+
+// HoldsBlockLock reports whether the transaction holds a lock on the block.
+func (tx *Transaction) HoldsBlockLock(block file.BlockID) bool {
+	return !tx.IsReadOnly() && tx.cm.HasLock(block)
+}
+
+// UnlockBlock gives up the lock on the block before the transaction
+// finishes. The caller must not have changed the block since it locked it.
+func (tx *Transaction) UnlockBlock(block file.BlockID) {
+	if !tx.IsReadOnly() {
+		tx.cm.UnlockBlock(block)
+	}
+}
+
+// LockForUpdate locks the block for writing, and so for reading its
+// current contents, before the transaction decides how to update it.
+func (tx *Transaction) LockForUpdate(block file.BlockID) error {
+	if tx.IsReadOnly() {
+		return fmt.Errorf("failed to lock block: %w", ErrReadOnly)
+	}
+	return tx.cm.XLock(block)
+}
+
+// TryLockForUpdate locks the block for writing if it can do so without
+// waiting for another transaction, and reports whether it did.
+func (tx *Transaction) TryLockForUpdate(block file.BlockID) (bool, error) {
+	if tx.IsReadOnly() {
+		return false, fmt.Errorf("failed to lock block: %w", ErrReadOnly)
+	}
+	return tx.cm.TryXLock(block), nil
+}
+
+// IsVisible reports whether the transaction sees the changes made by txnum:
+// its own, and those of the transactions that finished before its snapshot.
+// A serializable transaction locks what it reads, and so sees all the
+// changes of finished transactions.
+func (tx *Transaction) IsVisible(txnum int64) bool {
+	if txnum == tx.txnum {
+		return true
+	}
+	if !tx.IsReadOnly() && tx.isolation == SERIALIZABLE {
+		return tx.registry.IsFinished(txnum)
+	}
+	return tx.snapshot.IsVisible(txnum)
+}
+
+// SetIsolationLevel sets the isolation level of the transaction, which is
+// only possible before its first statement, read or lock.
+func (tx *Transaction) SetIsolationLevel(level IsolationLevel) error {
+	if tx.statementStarted {
+		return fmt.Errorf("failed to set isolation level: %w", ErrStatementStarted)
+	}
+	tx.isolation = level
+	return nil
+}
+
+func (tx *Transaction) IsolationLevel() IsolationLevel {
+	return tx.isolation
+}
+
+// StartStatement tells the transaction that a new statement starts.
+// A read committed transaction takes a new snapshot for it.
+func (tx *Transaction) StartStatement() {
+	tx.statementStarted = true
+	if tx.IsReadOnly() || tx.isolation != READ_COMMITTED {
+		return
+	}
+	tx.snapshot = tx.registry.Snapshot(tx.txnum)
+	clear(tx.snapshotPages)
+}
+
+// VersionHorizon returns the number below which the changes of every
+// transaction are visible to all current and future transactions.
+func (tx *Transaction) VersionHorizon() int64 {
+	return tx.registry.Horizon()
+}
+
 func (tx *Transaction) BlockSize() int32 {
 	return tx.fm.BlockSize()
 }
@@ -164,10 +744,100 @@ func (tx *Transaction) AvailableBuffers() int32 {
 	return tx.bm.Available()
 }
 
-func nextTxNumber() int32 {
-	mu.Lock()
-	defer mu.Unlock()
+func (tx *Transaction) TxNumber() int64 {
+	return tx.txnum
+}
+
+func (tx *Transaction) IsReadOnly() bool {
+	return tx.readOnly
+}
+
+func (tx *Transaction) IsPrepared() bool {
+	return tx.globalID != ""
+}
+
+// read calls f with the page to read the pinned block from. The block is
+// latched meanwhile if other transactions may be changing other records
+// of it.
+func (tx *Transaction) read(block file.BlockID, f func(p *file.Page)) error {
+	if err := tx.checkAbort(); err != nil {
+		return err
+	}
+	if tx.IsReadOnly() || (tx.versioned[block.Filename()] && tx.isolation != SERIALIZABLE && !tx.cm.HasXLock(block)) {
+		p, err := tx.snapshotPage(block)
+		if err != nil {
+			return err
+		}
+		f(p)
+		return nil
+	}
+	buffer := tx.myBuffers.GetBuffer(block)
+	if tx.recordLocked[block.Filename()] {
+		buffer.Lock()
+		defer buffer.Unlock()
+	} else if err := tx.cm.SLock(block); err != nil {
+		return err
+	}
+	f(buffer.Contents())
+	return nil
+}
 
-	nextTxNum++
-	return nextTxNum
+// lockForWrite locks the block for writing, unless its records are locked
+// one by one, in which case the caller has locked the record it changes.
+func (tx *Transaction) lockForWrite(block file.BlockID) error {
+	if err := tx.checkAbort(); err != nil {
+		return err
+	}
+	if tx.recordLocked[block.Filename()] {
+		return nil
+	}
+	return tx.cm.XLock(block)
+}
+
+// checkAbort rolls the transaction back if the lock table has chosen it to
+// abort, as when an older transaction wounds it, so that it gives up its
+// locks even if it asks for no more of them. From then on, it returns the
+// reason.
+func (tx *Transaction) checkAbort() error {
+	if tx.abortErr != nil {
+		return tx.abortErr
+	}
+	if tx.IsReadOnly() || tx.IsPrepared() || tx.rollingBack {
+		return nil
+	}
+	err := tx.lt.AbortReason(tx.txnum)
+	if err == nil {
+		return nil
+	}
+	if rbErr := tx.Rollback(); rbErr != nil {
+		return errors.Join(err, rbErr)
+	}
+	tx.abortErr = err
+	return err
+}
+
+// snapshotPage returns a copy of the pinned block, taken without locking it.
+// For a read-only transaction, the copy is rewound to its snapshot.
+// The copy is kept while the block is pinned.
+func (tx *Transaction) snapshotPage(block file.BlockID) (*file.Page, error) {
+	if p, ok := tx.snapshotPages[block]; ok {
+		return p, nil
+	}
+	buffer := tx.myBuffers.GetBuffer(block)
+	buffer.Lock()
+	p := buffer.Contents().Clone()
+	buffer.Unlock()
+	if tx.IsReadOnly() {
+		if err := tx.snapshot.Rewind(tx.lm, block, p); err != nil {
+			return nil, err
+		}
+	}
+	tx.snapshotPages[block] = p
+	return p, nil
+}
+
+func (tx *Transaction) finishReadOnly() error {
+	tx.myBuffers.UnpinAll()
+	clear(tx.snapshotPages)
+	return tx.registry.Finish(tx.txnum)
 }
@@ -1,6 +1,7 @@
 package tx_test
 
 import (
+	"errors"
 	"path"
 	"testing"
 
@@ -14,8 +15,10 @@ func TestTx(t *testing.T) {
 	fm := db.FileManager()
 	lm := db.LogManager()
 	bm := db.BufferManager()
+	lt := db.LockTable()
+	registry := db.TxRegistry()
 
-	tx1, err := tx.NewTransaction(fm, lm, bm)
+	tx1, err := tx.NewTransaction(fm, lm, bm, lt, registry)
 	if err != nil {
 		t.Fatalf("failed to create new transaction: %v", err)
 	}
@@ -39,7 +42,7 @@ func TestTx(t *testing.T) {
 		t.Fatalf("failed to commit: %v", err)
 	}
 
-	tx2, err := tx.NewTransaction(fm, lm, bm)
+	tx2, err := tx.NewTransaction(fm, lm, bm, lt, registry)
 	if err != nil {
 		t.Fatalf("failed to create new transaction: %v", err)
 	}
@@ -63,7 +66,7 @@ func TestTx(t *testing.T) {
 		t.Fatalf("failed to commit: %v", err)
 	}
 
-	tx3, err := tx.NewTransaction(fm, lm, bm)
+	tx3, err := tx.NewTransaction(fm, lm, bm, lt, registry)
 	if err != nil {
 		t.Fatalf("failed to create new transaction: %v", err)
 	}
@@ -83,7 +86,7 @@ func TestTx(t *testing.T) {
 		t.Fatalf("failed to rollback: %v", err)
 	}
 
-	tx4, err := tx.NewTransaction(fm, lm, bm)
+	tx4, err := tx.NewTransaction(fm, lm, bm, lt, registry)
 	if err != nil {
 		t.Fatalf("failed to create new transaction: %v", err)
 	}
@@ -96,3 +99,54 @@ func TestTx(t *testing.T) {
 		t.Fatalf("failed to commit: %v", err)
 	}
 }
+
+func TestIsolationLevelFixedOnceRead(t *testing.T) {
+	db, err := server.NewSimpleDB(path.Join(t.TempDir(), "isolationleveltest"), 400, 8)
+	if err != nil {
+		t.Fatalf("failed to create new database: %v", err)
+	}
+	if _, err := db.FileManager().Append("testfile"); err != nil {
+		t.Fatalf("failed to append block: %v", err)
+	}
+
+	tx1, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if err := tx1.SetIsolationLevel(tx.READ_COMMITTED); err != nil {
+		t.Fatalf("failed to set isolation level: %v", err)
+	}
+	// reading through the transaction itself, without a statement
+	block := file.NewBlockID("testfile", 0)
+	if err := tx1.Pin(block); err != nil {
+		t.Fatalf("failed to pin block: %v", err)
+	}
+	if _, err := tx1.GetInt(block, 0); err != nil {
+		t.Fatalf("failed to get int: %v", err)
+	}
+	tx1.Unpin(block)
+	if err := tx1.SetIsolationLevel(tx.SERIALIZABLE); !errors.Is(err, tx.ErrStatementStarted) {
+		t.Fatalf("expected ErrStatementStarted, got %v", err)
+	}
+	if tx1.IsolationLevel() != tx.READ_COMMITTED {
+		t.Fatalf("expected %v, got %v", tx.READ_COMMITTED, tx1.IsolationLevel())
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	// a lock taken before any read fixes it too
+	tx2, err := db.NewTransaction()
+	if err != nil {
+		t.Fatalf("failed to create new transaction: %v", err)
+	}
+	if _, err := tx2.Size("testfile"); err != nil {
+		t.Fatalf("failed to get size: %v", err)
+	}
+	if err := tx2.SetIsolationLevel(tx.READ_COMMITTED); !errors.Is(err, tx.ErrStatementStarted) {
+		t.Fatalf("expected ErrStatementStarted, got %v", err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package tx
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/adieumonks/simple-db/log"
+	"github.com/adieumonks/simple-db/tx/recovery"
+)
+
+// TxRegistry hands out transaction numbers that keep increasing
+// across restarts, continuing from the highest number found in the log.
+// It also tracks the read-write transactions that have not finished yet,
+// and the prepared ones by their global id.
+type TxRegistry struct {
+	mu     sync.Mutex
+	last   int64
+	active map[int64]bool
+	// for each active transaction, the number below which all
+	// transactions were finished when its snapshot was taken
+	horizons map[int64]int64
+	// the active transactions, once they have started
+	transactions map[int64]*Transaction
+	prepared     map[string]*Transaction
+	// the files deleted by committed transactions, kept while snapshots
+	// taken before those finished may still read them
+	pendingDeletes []pendingDelete
+}
+
+// pendingDelete is a file deleted by the committed transaction txnum.
+type pendingDelete struct {
+	txnum    int64
+	filename string
+	delete   func(filename string) error
+}
+
+// TxInfo describes an active read-write transaction.
+type TxInfo struct {
+	TxNum   int64
+	Started time.Time
+	// the global id it was prepared under, if it is prepared
+	GlobalID string
+	// the number of log records it has written
+	LogRecords int64
+}
+
+func NewTxRegistry(lm *log.LogManager) (*TxRegistry, error) {
+	last, err := recovery.LastTxNumber(lm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last transaction number: %w", err)
+	}
+	return &TxRegistry{
+		last:         last,
+		active:       make(map[int64]bool),
+		horizons:     make(map[int64]int64),
+		transactions: make(map[int64]*Transaction),
+		prepared:     make(map[string]*Transaction),
+	}, nil
+}
+
+// Next returns the number of a new read-write transaction, which stays
+// active until it is passed to Finish, along with its snapshot.
+func (r *TxRegistry) Next() (int64, *recovery.Snapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := recovery.NewSnapshot(r.last, r.active)
+	horizon := r.oldestActive()
+	r.last++
+	r.horizons[r.last] = horizon
+	r.active[r.last] = true
+	return r.last, snapshot
+}
+
+// NextReadOnly returns the number of a new read-only transaction
+// along with the snapshot of committed state it reads. It is passed to
+// Finish when it ends, so that the files it may read are kept until then.
+func (r *TxRegistry) NextReadOnly() (int64, *recovery.Snapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := recovery.NewSnapshot(r.last, r.active)
+	horizon := r.oldestActive()
+	r.last++
+	r.horizons[r.last] = horizon
+	return r.last, snapshot
+}
+
+// Snapshot takes a new snapshot for an active transaction.
+func (r *TxRegistry) Snapshot(txnum int64) *recovery.Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.horizons[txnum] = r.oldestActive()
+	return recovery.NewSnapshot(r.last, r.active)
+}
+
+// IsFinished reports whether the transaction has committed or rolled back.
+func (r *TxRegistry) IsFinished(txnum int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return txnum <= r.last && !r.active[txnum]
+}
+
+// Finish ends the transaction, and deletes the files of committed
+// transactions that no snapshot sees as active anymore.
+func (r *TxRegistry) Finish(txnum int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.active, txnum)
+	delete(r.horizons, txnum)
+	delete(r.transactions, txnum)
+	for globalID, tx := range r.prepared {
+		if tx.txnum == txnum {
+			delete(r.prepared, globalID)
+		}
+	}
+
+	horizon := r.horizon()
+	var errs []error
+	pending := r.pendingDeletes[:0]
+	for _, d := range r.pendingDeletes {
+		if d.txnum >= horizon {
+			pending = append(pending, d)
+			continue
+		}
+		if err := d.delete(d.filename); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete file %s: %w", d.filename, err))
+		}
+	}
+	r.pendingDeletes = pending
+	return errors.Join(errs...)
+}
+
+// deleteLater deletes the files of the committing transaction once it is
+// below the horizon, as readers of snapshots taken before it finishes hold
+// no locks on them.
+func (r *TxRegistry) deleteLater(txnum int64, filenames []string, delete func(filename string) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, filename := range filenames {
+		r.pendingDeletes = append(r.pendingDeletes, pendingDelete{txnum: txnum, filename: filename, delete: delete})
+	}
+}
+
+// isDeletePending reports whether the file is waiting to be deleted.
+func (r *TxRegistry) isDeletePending(filename string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.ContainsFunc(r.pendingDeletes, func(d pendingDelete) bool {
+		return d.filename == filename
+	})
+}
+
+// InDoubt returns the global ids of the prepared transactions
+// that are waiting to be committed or rolled back.
+func (r *TxRegistry) InDoubt() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	globalIDs := make([]string, 0, len(r.prepared))
+	for globalID := range r.prepared {
+		globalIDs = append(globalIDs, globalID)
+	}
+	slices.Sort(globalIDs)
+	return globalIDs
+}
+
+func (r *TxRegistry) CommitPrepared(globalID string) error {
+	tx, err := r.lookupPrepared(globalID)
+	if err != nil {
+		return fmt.Errorf("failed to commit prepared transaction: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (r *TxRegistry) RollbackPrepared(globalID string) error {
+	tx, err := r.lookupPrepared(globalID)
+	if err != nil {
+		return fmt.Errorf("failed to rollback prepared transaction: %w", err)
+	}
+	return tx.Rollback()
+}
+
+func (r *TxRegistry) lookupPrepared(globalID string) (*Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, ok := r.prepared[globalID]
+	if !ok {
+		return nil, fmt.Errorf("no prepared transaction with global id %s", globalID)
+	}
+	return tx, nil
+}
+
+func (r *TxRegistry) addPrepared(globalID string, tx *Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.prepared[globalID]; ok {
+		return fmt.Errorf("global id %s is already in use", globalID)
+	}
+	r.prepared[globalID] = tx
+	return nil
+}
+
+func (r *TxRegistry) removePrepared(globalID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.prepared, globalID)
+}
+
+// resume registers an in-doubt transaction taken over after recovery.
+func (r *TxRegistry) resume(tx *Transaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.active[tx.txnum] = true
+	r.horizons[tx.txnum] = tx.txnum
+	r.transactions[tx.txnum] = tx
+	r.prepared[tx.globalID] = tx
+}
+
+// register records a new read-write transaction once it has started.
+func (r *TxRegistry) register(tx *Transaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.transactions[tx.txnum] = tx
+}
+
+// Active describes the active read-write transactions, oldest first.
+func (r *TxRegistry) Active() []TxInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	globalIDs := make(map[int64]string)
+	for globalID, tx := range r.prepared {
+		globalIDs[tx.txnum] = globalID
+	}
+	infos := make([]TxInfo, 0, len(r.transactions))
+	for txnum, tx := range r.transactions {
+		infos = append(infos, TxInfo{
+			TxNum:      txnum,
+			Started:    tx.started,
+			GlobalID:   globalIDs[txnum],
+			LogRecords: tx.rm.LogRecords(),
+		})
+	}
+	slices.SortFunc(infos, func(a, b TxInfo) int {
+		return cmp.Compare(a.TxNum, b.TxNum)
+	})
+	return infos
+}
+
+// Horizon returns the number below which every transaction has finished,
+// and is seen as finished by the snapshots of all active transactions.
+// Changes made by those transactions are visible to every transaction.
+func (r *TxRegistry) Horizon() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.horizon()
+}
+
+func (r *TxRegistry) horizon() int64 {
+	horizon := r.oldestActive()
+	for _, h := range r.horizons {
+		horizon = min(horizon, h)
+	}
+	return horizon
+}
+
+// oldestActive returns the lowest number of an active transaction,
+// or the next number to hand out if there is none.
+func (r *TxRegistry) oldestActive() int64 {
+	oldest := r.last + 1
+	for txnum := range r.active {
+		oldest = min(oldest, txnum)
+	}
+	return oldest
+}
+
+func (r *TxRegistry) Last() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.last
+}
@@ -0,0 +1,42 @@
+package tx_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/adieumonks/simple-db/server"
+)
+
+func TestTxNumberPersistsAcrossRestarts(t *testing.T) {
+	dir := path.Join(t.TempDir(), "txnumtest")
+
+	var last int64
+	// the second open recovers and writes a checkpoint, the later ones
+	// skip recovery and have to read the number back from the log alone
+	for restart := range 4 {
+		var db *server.SimpleDB
+		var err error
+		if restart < 2 {
+			db, err = server.NewSimpleDBWithMetadata(dir)
+		} else {
+			db, err = server.NewSimpleDB(dir, 400, 8)
+		}
+		if err != nil {
+			t.Fatalf("restart %d: failed to open database: %v", restart, err)
+		}
+
+		for range 3 {
+			tx, err := db.NewTransaction()
+			if err != nil {
+				t.Fatalf("restart %d: failed to create new transaction: %v", restart, err)
+			}
+			if tx.TxNumber() <= last {
+				t.Errorf("restart %d: expected transaction number greater than %d, got %d", restart, last, tx.TxNumber())
+			}
+			last = tx.TxNumber()
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("restart %d: failed to commit transaction: %v", restart, err)
+			}
+		}
+	}
+}